@@ -12,16 +12,23 @@ import (
 	"github.com/codewithwan/gostreamix/internal/infrastructure/database"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/logger"
 	"github.com/codewithwan/gostreamix/internal/shared/jwt"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	"golang.org/x/term"
 )
 
 func main() {
 	reset := flag.Bool("reset-password", false, "Reset the primary user password")
 	setPwd := flag.String("set-password", "", "Directly set password to this value (non-interactive)")
+	disable2FA := flag.Bool("disable-2fa", false, "Turn off two-factor authentication for a locked out user")
+	username := flag.String("username", "", "User to reset the password or 2FA of (defaults to the primary admin)")
+	rekey := flag.Bool("rekey", false, "Re-encrypt stored secrets with the current encryption key")
+	oldKey := flag.String("old-key", "", "Key the secrets are encrypted with now (defaults to the app secret)")
 	flag.Parse()
 
-	if !*reset {
-		fmt.Println("Usage: gostreamix-cli --reset-password [--set-password=<newpassword>]")
+	if !*reset && !*rekey && !*disable2FA {
+		fmt.Println("Usage: gostreamix-cli --reset-password [--username=<user>] [--set-password=<newpassword>]")
+		fmt.Println("       gostreamix-cli --disable-2fa [--username=<user>]")
+		fmt.Println("       gostreamix-cli --rekey [--old-key=<previous ENCRYPTION_KEY>]")
 		os.Exit(0)
 	}
 
@@ -32,20 +39,65 @@ func main() {
 		os.Exit(1)
 	}
 
-	db, err := database.NewSQLiteDB(cfg, log)
+	cipher, err := secret.New(cfg.EncryptionKey)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	repo := auth.NewRepository(db)
+	db, err := database.NewSQLiteDB(cfg, cipher, log)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *rekey {
+		// Without ENCRYPTION_KEY the secrets were sealed with the app secret.
+		from := *oldKey
+		if from == "" {
+			from = cfg.Secret
+		}
+		oldCipher, err := secret.New(from)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		count, err := database.RekeySecrets(context.Background(), db, oldCipher, cipher)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Re-encrypted %d stored secret(s).\n", count)
+		return
+	}
+
+	repo := auth.NewRepository(db, cipher)
 	jwtSvc := jwt.NewJWTService(struct{ Secret string }{Secret: cfg.Secret})
 	svc := auth.NewService(repo, jwtSvc)
 
-	user, err := svc.GetPrimaryUser(context.Background())
-	if err != nil {
-		fmt.Println("Error: No user found. Please setup the system first.")
-		os.Exit(1)
+	var user *auth.User
+	if *username != "" {
+		user, err = repo.GetUserByUsername(context.Background(), *username)
+		if err != nil {
+			fmt.Printf("Error: User %q not found.\n", *username)
+			os.Exit(1)
+		}
+	} else {
+		user, err = svc.GetPrimaryUser(context.Background())
+		if err != nil {
+			fmt.Println("Error: No user found. Please setup the system first.")
+			os.Exit(1)
+		}
+	}
+
+	if *disable2FA {
+		if err := svc.ResetTwoFactor(context.Background(), user.ID); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Two-factor authentication disabled for user: %s\n", user.Username)
+		return
 	}
 
 	fmt.Printf("Resetting password for user: %s\n", user.Username)
@@ -1,11 +1,18 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/codewithwan/gostreamix/internal/domain/notification"
+	"github.com/codewithwan/gostreamix/internal/domain/schedule"
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/monitor"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/rtmp"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/server"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/ws"
 	"go.uber.org/dig"
@@ -13,7 +20,7 @@ import (
 )
 
 func Bootstrap(c *dig.Container) error {
-	return c.Invoke(func(s *server.Server, l *zap.Logger, hub *ws.Hub) {
+	return c.Invoke(func(s *server.Server, l *zap.Logger, hub *ws.Hub, streamSvc stream.Service, scheduler *schedule.Scheduler, caps *ffmpeg.Capabilities, ingest *rtmp.Server, bus *events.Bus, notifier notification.Service) {
 		appURL := s.Config.AppURL
 		if appURL == "http://localhost:8080" && s.Config.Host == "0.0.0.0" {
 			appURL = fmt.Sprintf("http://localhost:%s", s.Config.Port)
@@ -21,6 +28,20 @@ func Bootstrap(c *dig.Container) error {
 
 		printBanner(s.Config.Port, s.Config.DBPath, appURL)
 
+		stream.SetCertsDir(s.Config.CertsDir)
+
+		if err := caps.CheckPipeline(); err != nil {
+			l.Warn("ffmpeg cannot run stream pipelines", zap.Error(err), zap.String("detail", caps.Error))
+		} else {
+			l.Info("ffmpeg capabilities detected",
+				zap.String("version", caps.Version),
+				zap.Bool("rtmps", caps.Features["rtmps"]),
+				zap.Bool("srt", caps.Features["srt"]),
+			)
+		}
+
+		bus.Subscribe(notifier.HandleEvent)
+
 		go func() {
 			ticker := time.NewTicker(5 * time.Second)
 			for range ticker.C {
@@ -38,6 +59,19 @@ func Bootstrap(c *dig.Container) error {
 			}
 		}()
 
+		go func() {
+			if err := ingest.ListenAndServe(context.Background()); err != nil {
+				l.Error("rtmp ingest stopped", zap.Error(err))
+			}
+		}()
+
+		go func() {
+			if err := streamSvc.ResumeStreams(context.Background()); err != nil {
+				l.Warn("some streams could not be resumed", zap.Error(err))
+			}
+			scheduler.Start(context.Background())
+		}()
+
 		if err := s.Start(); err != nil {
 			l.Fatal("server failed to start", zap.Error(err))
 		}
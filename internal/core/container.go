@@ -1,20 +1,27 @@
 package core
 
 import (
+	"github.com/codewithwan/gostreamix/internal/domain/asset"
 	"github.com/codewithwan/gostreamix/internal/domain/auth"
 	"github.com/codewithwan/gostreamix/internal/domain/dashboard"
+	"github.com/codewithwan/gostreamix/internal/domain/encoder"
 	"github.com/codewithwan/gostreamix/internal/domain/notification"
 	"github.com/codewithwan/gostreamix/internal/domain/platform"
+	"github.com/codewithwan/gostreamix/internal/domain/schedule"
 	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
 	"github.com/codewithwan/gostreamix/internal/domain/video"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/config"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/database"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/logger"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/monitor"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/rtmp"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/server"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/ws"
 	"github.com/codewithwan/gostreamix/internal/shared/jwt"
 	"github.com/codewithwan/gostreamix/internal/shared/middleware"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	"github.com/uptrace/bun"
 	"go.uber.org/dig"
 	"go.uber.org/zap"
@@ -28,10 +35,14 @@ func BuildContainer() *dig.Container {
 	c.Provide(func(cfg *config.Config) struct{ Secret string } {
 		return struct{ Secret string }{Secret: cfg.Secret}
 	})
-	c.Provide(func(cfg *config.Config, log *zap.Logger) (*bun.DB, error) {
-		return database.NewSQLiteDB(cfg, log)
+	c.Provide(func(cfg *config.Config) (*secret.Cipher, error) {
+		return secret.New(cfg.EncryptionKey)
+	})
+	c.Provide(func(cfg *config.Config, cipher *secret.Cipher, log *zap.Logger) (*bun.DB, error) {
+		return database.NewSQLiteDB(cfg, cipher, log)
 	})
 	c.Provide(ws.NewHub)
+	c.Provide(events.NewBus)
 	c.Provide(monitor.NewCollector)
 
 	c.Provide(auth.NewRepository)
@@ -40,12 +51,28 @@ func BuildContainer() *dig.Container {
 	c.Provide(middleware.NewAuthGuard)
 	c.Provide(auth.NewHandler)
 
+	c.Provide(ffmpeg.DetectCapabilities)
 	c.Provide(stream.NewRepository)
+	c.Provide(stream.NewSessionRepository)
 	c.Provide(stream.NewService)
 	c.Provide(stream.NewProcessManager)
 	c.Provide(stream.NewPipeline)
+	c.Provide(func(repo stream.Repository, hub *ws.Hub, cfg *config.Config, log *zap.Logger) *stream.IngestHub {
+		return stream.NewIngestHub(repo, hub, cfg.IngestURL, log)
+	})
+	c.Provide(func(cfg *config.Config, ingest *stream.IngestHub, log *zap.Logger) *rtmp.Server {
+		return rtmp.NewServer(cfg.IngestAddr, ingest, log)
+	})
 	c.Provide(stream.NewHandler)
 
+	c.Provide(encoder.NewRepository)
+	c.Provide(encoder.NewService)
+	c.Provide(encoder.NewHandler)
+
+	c.Provide(asset.NewRepository)
+	c.Provide(asset.NewService)
+	c.Provide(asset.NewHandler)
+
 	c.Provide(video.NewRepository)
 	c.Provide(video.NewService)
 	c.Provide(video.NewHandler)
@@ -54,6 +81,11 @@ func BuildContainer() *dig.Container {
 	c.Provide(platform.NewService)
 	c.Provide(platform.NewHandler)
 
+	c.Provide(schedule.NewRepository)
+	c.Provide(schedule.NewService)
+	c.Provide(schedule.NewScheduler)
+	c.Provide(schedule.NewHandler)
+
 	c.Provide(dashboard.NewService)
 	c.Provide(dashboard.NewHandler)
 
@@ -0,0 +1,7 @@
+package asset
+
+type UploadAssetDTO struct {
+	Filename     string
+	OriginalName string
+	Path         string
+}
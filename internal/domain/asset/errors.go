@@ -0,0 +1,9 @@
+package asset
+
+import "errors"
+
+var (
+	ErrAssetNotFound    = errors.New("asset not found")
+	ErrAssetInUse       = errors.New("asset is used by one or more stream overlays")
+	ErrUnsupportedImage = errors.New("only PNG and JPEG images are supported")
+)
@@ -0,0 +1,107 @@
+package asset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type Handler struct {
+	svc Service
+	log *zap.Logger
+}
+
+func NewHandler(svc Service, log *zap.Logger) *Handler {
+	return &Handler{svc: svc, log: log}
+}
+
+func (h *Handler) Routes(app *fiber.App) {
+	api := app.Group("/api/assets")
+	api.Get("/", h.ApiGetAssets)
+	api.Post("/upload", h.ApiUploadAsset)
+	api.Get("/:id/file", h.ApiGetAssetFile)
+	api.Delete("/:id", h.ApiDeleteAsset)
+}
+
+func (h *Handler) ApiGetAssets(c *fiber.Ctx) error {
+	assets, err := h.svc.GetAssets(c.Context())
+	if err != nil {
+		h.log.Error("Failed to list assets", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list assets"})
+	}
+	return c.JSON(assets)
+}
+
+func (h *Handler) ApiUploadAsset(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "no file found"})
+	}
+
+	filename := uuid.New().String() + strings.ToLower(filepath.Ext(file.Filename))
+	path := filepath.Join("data", "assets", filename)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create asset directory"})
+	}
+	if err := c.SaveFile(file, path); err != nil {
+		h.log.Error("Failed to save uploaded asset", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save file"})
+	}
+
+	a, err := h.svc.SaveUpload(c.Context(), UploadAssetDTO{
+		Filename:     filename,
+		OriginalName: file.Filename,
+		Path:         path,
+	})
+	if err != nil {
+		_ = os.Remove(path)
+		if errors.Is(err, ErrUnsupportedImage) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to record asset", zap.Error(err), zap.String("filename", filename))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save asset"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(a)
+}
+
+func (h *Handler) ApiGetAssetFile(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid id"})
+	}
+
+	a, err := h.svc.GetAsset(c.Context(), id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": ErrAssetNotFound.Error()})
+	}
+
+	c.Set(fiber.HeaderContentType, a.ContentType)
+	return c.SendFile(a.Path())
+}
+
+func (h *Handler) ApiDeleteAsset(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid id"})
+	}
+
+	if err := h.svc.DeleteAsset(c.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, ErrAssetNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": ErrAssetNotFound.Error()})
+		case errors.Is(err, ErrAssetInUse):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to delete asset", zap.Error(err), zap.String("assetID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete asset"})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
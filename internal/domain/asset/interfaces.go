@@ -0,0 +1,22 @@
+package asset
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, a *Asset) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindByID(ctx context.Context, id uuid.UUID) (*Asset, error)
+	List(ctx context.Context) ([]*Asset, error)
+	CountReferences(ctx context.Context, id uuid.UUID) (int, error)
+}
+
+type Service interface {
+	GetAssets(ctx context.Context) ([]*Asset, error)
+	GetAsset(ctx context.Context, id uuid.UUID) (*Asset, error)
+	SaveUpload(ctx context.Context, dto UploadAssetDTO) (*Asset, error)
+	DeleteAsset(ctx context.Context, id uuid.UUID) error
+}
@@ -0,0 +1,27 @@
+package asset
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Asset is an uploaded image that streams can compose into their output,
+// such as a watermark logo.
+type Asset struct {
+	bun.BaseModel `bun:"table:assets,alias:a"`
+
+	ID           uuid.UUID `bun:",pk,type:text" json:"id"`
+	Filename     string    `bun:",notnull" json:"filename"`
+	OriginalName string    `json:"original_name"`
+	ContentType  string    `bun:",notnull" json:"content_type"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Path is where the asset file is stored.
+func (a *Asset) Path() string {
+	return filepath.Join("data", "assets", a.Filename)
+}
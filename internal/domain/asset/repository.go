@@ -0,0 +1,56 @@
+package asset
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type repository struct {
+	db *bun.DB
+}
+
+func NewRepository(db *bun.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(ctx context.Context, a *Asset) error {
+	_, err := r.db.NewInsert().Model(a).Exec(ctx)
+	return err
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.NewDelete().Model((*Asset)(nil)).Where("id = ?", id).Exec(ctx)
+	return err
+}
+
+func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (*Asset, error) {
+	a := new(Asset)
+	if err := r.db.NewSelect().Model(a).Where("id = ?", id).Scan(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *repository) List(ctx context.Context) ([]*Asset, error) {
+	var assets []*Asset
+	err := r.db.NewSelect().Model(&assets).Order("created_at DESC").Scan(ctx)
+	return assets, err
+}
+
+// CountReferences reports how many streams and programs use the asset in an
+// overlay. Overlays are stored as JSON, so this matches on the serialised id
+// rather than depending on the stream domain.
+func (r *repository) CountReferences(ctx context.Context, id uuid.UUID) (int, error) {
+	pattern := "%" + id.String() + "%"
+	streams, err := r.db.NewSelect().Table("streams").Where("overlays LIKE ?", pattern).Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+	programs, err := r.db.NewSelect().Table("stream_programs").Where("overlays LIKE ?", pattern).Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return streams + programs, nil
+}
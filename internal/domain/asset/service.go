@@ -0,0 +1,96 @@
+package asset
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) GetAssets(ctx context.Context) ([]*Asset, error) {
+	assets, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list assets: %w", err)
+	}
+	return assets, nil
+}
+
+func (s *service) GetAsset(ctx context.Context, id uuid.UUID) (*Asset, error) {
+	a, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAssetNotFound, err)
+	}
+	return a, nil
+}
+
+// SaveUpload records an uploaded file after checking from its content, not
+// its name, that ffmpeg will be able to read it as an image.
+func (s *service) SaveUpload(ctx context.Context, dto UploadAssetDTO) (*Asset, error) {
+	contentType, err := sniffContentType(dto.Path)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "image/png" && contentType != "image/jpeg" {
+		return nil, ErrUnsupportedImage
+	}
+
+	info, err := os.Stat(dto.Path)
+	if err != nil {
+		return nil, fmt.Errorf("stat asset file: %w", err)
+	}
+
+	a := &Asset{
+		ID:           uuid.New(),
+		Filename:     dto.Filename,
+		OriginalName: dto.OriginalName,
+		ContentType:  contentType,
+		Size:         info.Size(),
+	}
+	if err := s.repo.Create(ctx, a); err != nil {
+		return nil, fmt.Errorf("create asset record: %w", err)
+	}
+	return a, nil
+}
+
+func (s *service) DeleteAsset(ctx context.Context, id uuid.UUID) error {
+	a, err := s.GetAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	inUse, err := s.repo.CountReferences(ctx, id)
+	if err != nil {
+		return fmt.Errorf("count overlays using asset: %w", err)
+	}
+	if inUse > 0 {
+		return ErrAssetInUse
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete asset record: %w", err)
+	}
+	_ = os.Remove(a.Path())
+	return nil
+}
+
+func sniffContentType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open asset file: %w", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	return http.DetectContentType(buf[:n]), nil
+}
@@ -1,11 +1,22 @@
 package auth
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type UserDTO struct {
 	ID       uuid.UUID `json:"id"`
 	Username string    `json:"username"`
 	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+}
+
+func ToUserDTO(u *User) UserDTO {
+	return UserDTO{ID: u.ID, Username: u.Username, Email: u.Email, Role: u.Role}
 }
 
 type LoginDTO struct {
@@ -19,3 +30,47 @@ type SetupDTO struct {
 	Password        string `json:"password" validate:"required,min=8"`
 	ConfirmPassword string `json:"confirm_password" validate:"required"`
 }
+
+type InviteUserDTO struct {
+	Username string `json:"username" validate:"required,min=3"`
+	Email    string `json:"email" validate:"required,email"`
+	Role     string `json:"role" validate:"required"`
+}
+
+type UpdateUserDTO struct {
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type AcceptInviteDTO struct {
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required,min=8"`
+	ConfirmPassword string `json:"confirm_password" validate:"required"`
+}
+
+type CreateAPITokenDTO struct {
+	Name   string   `json:"name" validate:"required"`
+	Scopes []string `json:"scopes" validate:"required"`
+}
+
+type TwoFactorLoginDTO struct {
+	Challenge string `json:"challenge" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+}
+
+// TwoFactorCodeDTO confirms a 2FA change with a code from the authenticator
+// app or, where allowed, a recovery code.
+type TwoFactorCodeDTO struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorSetupDTO struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type TwoFactorStatusDTO struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
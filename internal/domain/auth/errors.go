@@ -7,4 +7,20 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrAlreadySetup       = errors.New("system already setup")
 	ErrPasswordMismatch   = errors.New("passwords do not match")
+	ErrUserDisabled       = errors.New("account is disabled")
+	ErrUsernameTaken      = errors.New("username is already taken")
+	ErrInvalidRole        = errors.New("role must be admin, operator or viewer")
+	ErrInvalidInvite      = errors.New("invite is invalid or has expired")
+	ErrOwnAccount         = errors.New("you cannot disable, demote or delete your own account")
+	ErrLastAdmin          = errors.New("at least one active admin is required")
+	ErrTokenNameRequired  = errors.New("token name is required")
+	ErrInvalidScope       = errors.New("scopes must be read-only, stream-control or admin")
+	ErrScopeNotAllowed    = errors.New("your role cannot grant this scope")
+	ErrTokenNotFound      = errors.New("API token not found")
+	ErrInvalidToken       = errors.New("invalid API token")
+	ErrTwoFactorNotSetup  = errors.New("two-factor authentication has not been set up")
+	ErrTwoFactorEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorDisabled  = errors.New("two-factor authentication is not enabled")
+	ErrInvalidCode        = errors.New("invalid authentication code")
+	ErrInvalidChallenge   = errors.New("sign in expired, please enter your password again")
 )
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"time"
 
 	"github.com/codewithwan/gostreamix/internal/shared/jwt"
@@ -24,13 +25,46 @@ func NewHandler(svc Service, jwt *jwt.JWTService, guard Guard, log *zap.Logger)
 func (h *Handler) Routes(app *fiber.App) {
 	app.Use(h.guard.RequireSetup)
 	app.Use(h.guard.RequireAuth)
+	app.Use(h.guard.RequireRole)
 
 	api := app.Group("/api/auth")
 	api.Get("/session", h.ApiSession)
 	api.Post("/setup", h.ApiSetup)
 	api.Post("/login", h.ApiLogin)
+	api.Post("/login/2fa", h.ApiLoginTwoFactor)
 	api.Post("/logout", h.ApiLogout)
 	api.Post("/refresh", h.PostRefresh)
+	api.Post("/invite/accept", h.ApiAcceptInvite)
+	api.Get("/tokens", h.ApiGetAPITokens)
+	api.Post("/tokens", h.ApiCreateAPIToken)
+	api.Delete("/tokens/:id", h.ApiRevokeAPIToken)
+
+	twoFactor := app.Group("/api/auth/2fa", h.requireSession)
+	twoFactor.Get("/", h.ApiGetTwoFactor)
+	twoFactor.Post("/setup", h.ApiSetupTwoFactor)
+	twoFactor.Post("/enable", h.ApiEnableTwoFactor)
+	twoFactor.Post("/disable", h.ApiDisableTwoFactor)
+	twoFactor.Post("/recovery-codes", h.ApiRegenerateRecoveryCodes)
+
+	users := app.Group("/api/users")
+	users.Get("/", h.ApiGetUsers)
+	users.Post("/invite", h.ApiInviteUser)
+	users.Put("/:id", h.ApiUpdateUser)
+	users.Delete("/:id", h.ApiDeleteUser)
+}
+
+// CurrentUser returns the signed in user the auth guard stored on the
+// request, or nil on public routes.
+func CurrentUser(c *fiber.Ctx) *User {
+	u, _ := c.Locals("user").(*User)
+	return u
+}
+
+// CurrentToken returns the API token the request was authenticated with,
+// or nil for browser sessions.
+func CurrentToken(c *fiber.Ctx) *APIToken {
+	t, _ := c.Locals("api_token").(*APIToken)
+	return t
 }
 
 func (h *Handler) ApiSession(c *fiber.Ctx) error {
@@ -71,11 +105,7 @@ func (h *Handler) ApiSession(c *fiber.Ctx) error {
 	}
 
 	res["authenticated"] = true
-	res["user"] = fiber.Map{
-		"id":       user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-	}
+	res["user"] = h.userDTO(c, user)
 
 	return c.JSON(res)
 }
@@ -132,12 +162,54 @@ func (h *Handler) ApiLogin(c *fiber.Ctx) error {
 	if err != nil {
 		h.log.Warn("API login failed", zap.String("username", req.Username), zap.String("ip", c.IP()), zap.Error(err))
 		errMsg := "invalid credentials"
-		if err.Error() == "account locked due to too many failed attempts" {
+		if err.Error() == "account locked due to too many failed attempts" || errors.Is(err, ErrUserDisabled) {
 			errMsg = err.Error()
 		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": errMsg})
 	}
 
+	status, err := h.svc.GetTwoFactorStatus(c.Context(), usr.ID)
+	if err != nil {
+		h.log.Error("Failed to check two-factor status", zap.Error(err), zap.String("userID", usr.ID.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create session"})
+	}
+	if status.Enabled {
+		// The password was right; the session waits for the second step.
+		challenge, err := h.svc.CreateLoginChallenge(c.Context(), usr.ID)
+		if err != nil {
+			h.log.Error("Failed to create login challenge", zap.Error(err), zap.String("userID", usr.ID.String()))
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create session"})
+		}
+		return c.JSON(fiber.Map{
+			"two_factor_required": true,
+			"challenge":           challenge,
+			"expires_in":          int(challengeTTL.Seconds()),
+		})
+	}
+
+	return h.signIn(c, usr)
+}
+
+func (h *Handler) ApiLoginTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorLoginDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	usr, err := h.svc.VerifyLoginChallenge(c.Context(), req.Challenge, req.Code)
+	if err != nil {
+		h.log.Warn("API two-factor login failed", zap.String("ip", c.IP()), zap.Error(err))
+		if errors.Is(err, ErrInvalidCode) || errors.Is(err, ErrInvalidChallenge) || errors.Is(err, ErrUserDisabled) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create session"})
+	}
+
+	return h.signIn(c, usr)
+}
+
+// signIn creates the session for a user who has fully authenticated.
+func (h *Handler) signIn(c *fiber.Ctx, usr *User) error {
 	at, rt, err := h.svc.CreateSession(c.Context(), usr.ID, c.IP(), c.Get("User-Agent"))
 	if err != nil {
 		h.log.Error("Failed to create API session", zap.Error(err), zap.String("userID", usr.ID.String()))
@@ -150,14 +222,19 @@ func (h *Handler) ApiLogin(c *fiber.Ctx) error {
 		"token":         at,
 		"refresh_token": rt,
 		"expires_in":    900,
-		"user": fiber.Map{
-			"id":       usr.ID,
-			"username": usr.Username,
-			"email":    usr.Email,
-		},
+		"user":          h.userDTO(c, usr),
 	})
 }
 
+// userDTO adds the user's 2FA state, which is kept outside the users table.
+func (h *Handler) userDTO(c *fiber.Ctx, u *User) UserDTO {
+	dto := ToUserDTO(u)
+	if status, err := h.svc.GetTwoFactorStatus(c.Context(), u.ID); err == nil {
+		dto.TwoFactorEnabled = status.Enabled
+	}
+	return dto
+}
+
 func (h *Handler) ApiLogout(c *fiber.Ctx) error {
 	rt := c.Cookies("refresh_token")
 	if rt != "" {
@@ -211,13 +288,264 @@ func (h *Handler) userFromAccessToken(accessToken string, c *fiber.Ctx) *User {
 	}
 
 	user, err := h.svc.GetUserByID(c.Context(), userID)
-	if err != nil {
+	if err != nil || user.Disabled {
 		return nil
 	}
 
 	return user
 }
 
+func (h *Handler) ApiAcceptInvite(c *fiber.Ctx) error {
+	var req AcceptInviteDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if req.Password != req.ConfirmPassword {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": ErrPasswordMismatch.Error()})
+	}
+	if err := validator.Password(req.Password); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	usr, err := h.svc.AcceptInvite(c.Context(), req.Token, req.Password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidInvite) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to accept invite", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to accept invite"})
+	}
+
+	return h.signIn(c, usr)
+}
+
+func (h *Handler) ApiGetUsers(c *fiber.Ctx) error {
+	users, err := h.svc.ListUsers(c.Context())
+	if err != nil {
+		h.log.Error("Failed to list users", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list users"})
+	}
+	return c.JSON(users)
+}
+
+func (h *Handler) ApiInviteUser(c *fiber.Ctx) error {
+	var req InviteUserDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	req.Username = validator.SanitizeInput(req.Username)
+	req.Email = validator.SanitizeInput(req.Email)
+
+	if err := validator.Username(req.Username); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validator.Email(req.Email); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	usr, token, err := h.svc.InviteUser(c.Context(), req)
+	if err != nil {
+		return h.userError(c, err, "failed to invite user")
+	}
+
+	// The token is only shown here; the invitee signs up with it.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"user": usr, "invite_token": token})
+}
+
+func (h *Handler) ApiUpdateUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+	}
+
+	var req UpdateUserDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	usr, err := h.svc.UpdateUser(c.Context(), h.actorID(c), id, req)
+	if err != nil {
+		return h.userError(c, err, "failed to update user")
+	}
+	return c.JSON(usr)
+}
+
+func (h *Handler) ApiDeleteUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+	}
+
+	if err := h.svc.DeleteUser(c.Context(), h.actorID(c), id); err != nil {
+		return h.userError(c, err, "failed to delete user")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) ApiGetAPITokens(c *fiber.Ctx) error {
+	tokens, err := h.svc.ListAPITokens(c.Context(), h.actorID(c))
+	if err != nil {
+		h.log.Error("Failed to list API tokens", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list API tokens"})
+	}
+	return c.JSON(tokens)
+}
+
+func (h *Handler) ApiCreateAPIToken(c *fiber.Ctx) error {
+	u := CurrentUser(c)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req CreateAPITokenDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	req.Name = validator.SanitizeInput(req.Name)
+
+	t, token, err := h.svc.CreateAPIToken(c.Context(), u, req)
+	if err != nil {
+		if errors.Is(err, ErrTokenNameRequired) || errors.Is(err, ErrInvalidScope) || errors.Is(err, ErrScopeNotAllowed) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to create API token", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create API token"})
+	}
+
+	// The token is only shown here; afterwards only its prefix is known.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"api_token": t, "token": token})
+}
+
+func (h *Handler) ApiRevokeAPIToken(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid token id"})
+	}
+
+	if err := h.svc.RevokeAPIToken(c.Context(), h.actorID(c), id); err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to revoke API token", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to revoke API token"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// requireSession keeps API tokens away from the 2FA settings, so a leaked
+// token cannot be used to turn off or take over the second factor.
+func (h *Handler) requireSession(c *fiber.Ctx) error {
+	if CurrentUser(c) == nil || CurrentToken(c) != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "two-factor settings can only be changed from a signed in session"})
+	}
+	return c.Next()
+}
+
+func (h *Handler) ApiGetTwoFactor(c *fiber.Ctx) error {
+	status, err := h.svc.GetTwoFactorStatus(c.Context(), h.actorID(c))
+	if err != nil {
+		return h.twoFactorError(c, err, "failed to get two-factor status")
+	}
+	return c.JSON(status)
+}
+
+// ApiSetupTwoFactor returns a new secret for the authenticator app, as text
+// and as the otpauth URI the UI renders into a QR code. 2FA only takes
+// effect once a code is confirmed through ApiEnableTwoFactor.
+func (h *Handler) ApiSetupTwoFactor(c *fiber.Ctx) error {
+	setup, err := h.svc.BeginTwoFactorSetup(c.Context(), CurrentUser(c))
+	if err != nil {
+		return h.twoFactorError(c, err, "failed to set up two-factor authentication")
+	}
+	return c.JSON(setup)
+}
+
+func (h *Handler) ApiEnableTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorCodeDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	codes, err := h.svc.EnableTwoFactor(c.Context(), h.actorID(c), req.Code)
+	if err != nil {
+		return h.twoFactorError(c, err, "failed to enable two-factor authentication")
+	}
+	h.renewSession(c)
+
+	// The recovery codes are only shown here.
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+func (h *Handler) ApiDisableTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorCodeDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	if err := h.svc.DisableTwoFactor(c.Context(), h.actorID(c), req.Code); err != nil {
+		return h.twoFactorError(c, err, "failed to disable two-factor authentication")
+	}
+	h.renewSession(c)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// renewSession signs the current browser in again after changing 2FA
+// revoked all of the user's sessions; the others stay signed out. If it
+// fails, the user signs in again once the access token expires.
+func (h *Handler) renewSession(c *fiber.Ctx) {
+	at, rt, err := h.svc.CreateSession(c.Context(), h.actorID(c), c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		h.log.Error("Failed to renew session", zap.Error(err), zap.String("userID", h.actorID(c).String()))
+		return
+	}
+	setSessionCookies(c, at, rt)
+}
+
+func (h *Handler) ApiRegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req TwoFactorCodeDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	codes, err := h.svc.RegenerateRecoveryCodes(c.Context(), h.actorID(c), req.Code)
+	if err != nil {
+		return h.twoFactorError(c, err, "failed to regenerate recovery codes")
+	}
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+func (h *Handler) actorID(c *fiber.Ctx) uuid.UUID {
+	if u := CurrentUser(c); u != nil {
+		return u.ID
+	}
+	return uuid.Nil
+}
+
+func (h *Handler) userError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrInvalidRole):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrUsernameTaken), errors.Is(err, ErrOwnAccount), errors.Is(err, ErrLastAdmin):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.log.Error(message, zap.Error(err))
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+}
+
+func (h *Handler) twoFactorError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, ErrInvalidCode), errors.Is(err, ErrTwoFactorNotSetup), errors.Is(err, ErrTwoFactorDisabled):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrTwoFactorEnabled):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.log.Error(message, zap.Error(err))
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+}
+
 func setSessionCookies(c *fiber.Ctx, accessToken, refreshToken string) {
 	secure := c.Protocol() == "https"
 
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -13,7 +14,21 @@ type Repository interface {
 	GetUserByUsername(ctx context.Context, u string) (*User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*User, error)
 	UpdatePassword(ctx context.Context, username, hash string) error
-	GetAnyUser(ctx context.Context) (*User, error)
+	GetPrimaryUser(ctx context.Context) (*User, error)
+	ListUsers(ctx context.Context) ([]*User, error)
+	UpdateUser(ctx context.Context, u *User) error
+	GetUserByInviteHash(ctx context.Context, hash string) (*User, error)
+	CountActiveAdmins(ctx context.Context) (int, error)
+	DeleteUser(ctx context.Context, id, heirID uuid.UUID) error
+	CreateAPIToken(ctx context.Context, t *APIToken) error
+	ListAPITokens(ctx context.Context, userID uuid.UUID) ([]*APIToken, error)
+	GetAPITokenByHash(ctx context.Context, hash string) (*APIToken, error)
+	DeleteAPIToken(ctx context.Context, userID, id uuid.UUID) error
+	TouchAPIToken(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+	GetTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactor, error)
+	CreateTwoFactor(ctx context.Context, tf *TwoFactor) error
+	UpdateTwoFactor(ctx context.Context, tf *TwoFactor) error
+	DeleteTwoFactor(ctx context.Context, userID uuid.UUID) error
 	SaveRefreshToken(ctx context.Context, rt *RefreshToken) error
 	GetRefreshToken(ctx context.Context, hash string) (*RefreshToken, error)
 	RevokeRefreshToken(ctx context.Context, hash string) error
@@ -31,10 +46,28 @@ type Service interface {
 	RefreshSession(ctx context.Context, refreshToken, ip, userAgent string) (string, string, error)
 	RevokeSession(ctx context.Context, refreshToken string) error
 	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+	ListUsers(ctx context.Context) ([]*User, error)
+	InviteUser(ctx context.Context, dto InviteUserDTO) (*User, string, error)
+	AcceptInvite(ctx context.Context, token, password string) (*User, error)
+	UpdateUser(ctx context.Context, actorID, id uuid.UUID, dto UpdateUserDTO) (*User, error)
+	DeleteUser(ctx context.Context, actorID, id uuid.UUID) error
+	CreateAPIToken(ctx context.Context, u *User, dto CreateAPITokenDTO) (*APIToken, string, error)
+	ListAPITokens(ctx context.Context, userID uuid.UUID) ([]*APIToken, error)
+	RevokeAPIToken(ctx context.Context, userID, id uuid.UUID) error
+	AuthenticateToken(ctx context.Context, token string) (*User, *APIToken, error)
+	GetTwoFactorStatus(ctx context.Context, userID uuid.UUID) (*TwoFactorStatusDTO, error)
+	BeginTwoFactorSetup(ctx context.Context, u *User) (*TwoFactorSetupDTO, error)
+	EnableTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	ResetTwoFactor(ctx context.Context, userID uuid.UUID) error
+	CreateLoginChallenge(ctx context.Context, userID uuid.UUID) (string, error)
+	VerifyLoginChallenge(ctx context.Context, challenge, code string) (*User, error)
 }
 
 type Guard interface {
 	RequireSetup(c *fiber.Ctx) error
 	RequireAuth(c *fiber.Ctx) error
+	RequireRole(c *fiber.Ctx) error
 	GuestOnly(c *fiber.Ctx) error
 }
@@ -10,12 +10,47 @@ import (
 type User struct {
 	bun.BaseModel `bun:"table:users,alias:u"`
 
-	ID           uuid.UUID `bun:",pk,type:text" json:"id"`
-	Username     string    `bun:",unique,notnull" json:"username"`
-	Email        string    `bun:",notnull" json:"email"`
-	PasswordHash string    `bun:",notnull" json:"-"`
-	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
-	UpdatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	ID              uuid.UUID  `bun:",pk,type:text" json:"id"`
+	Username        string     `bun:",unique,notnull" json:"username"`
+	Email           string     `bun:",notnull" json:"email"`
+	PasswordHash    string     `bun:",notnull" json:"-"`
+	Role            string     `bun:",notnull,default:'viewer'" json:"role"`
+	Disabled        bool       `bun:",notnull,default:false" json:"disabled"`
+	InviteTokenHash string     `bun:",nullzero" json:"-"`
+	InviteExpiresAt *time.Time `json:"invite_expires_at,omitempty"`
+	CreatedAt       time.Time  `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt       time.Time  `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Roles, from least to most privileged. Viewers can only read, operators
+// run streams and manage media, admins also manage users and settings.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// HasRole reports whether the user's role grants at least role.
+func (u *User) HasRole(role string) bool {
+	return ValidRole(role) && roleRank[u.Role] >= roleRank[role]
+}
+
+// CanManage reports whether the user may act on something owned by ownerID:
+// their own, or anyone's as an admin.
+func (u *User) CanManage(ownerID uuid.UUID) bool {
+	return u.ID == ownerID || u.HasRole(RoleAdmin)
+}
+
+// Pending reports whether the user was invited and has not accepted yet;
+// until then the account has no password.
+func (u *User) Pending() bool {
+	return u.PasswordHash == ""
 }
 
 type RefreshToken struct {
@@ -31,3 +66,69 @@ type RefreshToken struct {
 	UserAgent string    `bun:",type:text" json:"user_agent"`
 	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
 }
+
+// APIToken is a personal access token for scripting the API. Only its hash
+// is stored; Prefix is kept so users can tell their tokens apart.
+type APIToken struct {
+	bun.BaseModel `bun:"table:api_tokens,alias:at"`
+
+	ID         uuid.UUID  `bun:",pk,type:text" json:"id"`
+	UserID     uuid.UUID  `bun:",notnull,type:text" json:"user_id"`
+	Name       string     `bun:",notnull" json:"name"`
+	Prefix     string     `bun:",notnull" json:"prefix"`
+	TokenHash  string     `bun:",notnull,unique" json:"-"`
+	Scopes     []string   `bun:",type:json" json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Token scopes. Read-only allows reading anything, stream-control starting,
+// stopping and reloading streams, admin everything. Each scope includes the
+// ones below it, and a token never allows more than its owner's role.
+const (
+	ScopeReadOnly      = "read-only"
+	ScopeStreamControl = "stream-control"
+	ScopeAdmin         = "admin"
+)
+
+// scopeRoles is the least privileged role that may grant each scope.
+var scopeRoles = map[string]string{
+	ScopeReadOnly:      RoleViewer,
+	ScopeStreamControl: RoleOperator,
+	ScopeAdmin:         RoleAdmin,
+}
+
+// HasScope reports whether the token was granted scope or one above it:
+// admin includes stream-control, which includes read-only.
+func (t *APIToken) HasScope(scope string) bool {
+	required, ok := scopeRoles[scope]
+	if !ok {
+		return false
+	}
+	for _, s := range t.Scopes {
+		if granted, ok := scopeRoles[s]; ok && roleRank[granted] >= roleRank[required] {
+			return true
+		}
+	}
+	return false
+}
+
+// TwoFactor holds a user's TOTP enrollment. It is created pending when
+// setup starts and only protects sign in once EnabledAt is set, after the
+// user proved their authenticator works. Secret is sealed at rest and
+// RecoveryCodes holds the hashes of the unused recovery codes.
+type TwoFactor struct {
+	bun.BaseModel `bun:"table:user_two_factor,alias:tf"`
+
+	ID            uuid.UUID  `bun:",pk,type:text" json:"id"`
+	UserID        uuid.UUID  `bun:",notnull,unique,type:text" json:"user_id"`
+	Secret        string     `bun:",notnull" json:"-"`
+	RecoveryCodes []string   `bun:",type:json" json:"-"`
+	LastStep      int64      `bun:",notnull,default:0" json:"-"`
+	EnabledAt     *time.Time `json:"enabled_at"`
+	CreatedAt     time.Time  `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+func (t *TwoFactor) Enabled() bool {
+	return t != nil && t.EnabledAt != nil
+}
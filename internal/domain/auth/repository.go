@@ -2,17 +2,23 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 )
 
 type repository struct {
-	db *bun.DB
+	db     *bun.DB
+	cipher *secret.Cipher
 }
 
-func NewRepository(db *bun.DB) Repository {
-	return &repository{db: db}
+func NewRepository(db *bun.DB, cipher *secret.Cipher) Repository {
+	return &repository{db: db, cipher: cipher}
 }
 
 func (r *repository) CountUsers(ctx context.Context) (int, error) {
@@ -33,6 +39,9 @@ func (r *repository) GetUserByUsername(ctx context.Context, u string) (*User, er
 func (r *repository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	usr := new(User)
 	err := r.db.NewSelect().Model(usr).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
 	return usr, err
 }
 
@@ -41,12 +50,148 @@ func (r *repository) UpdatePassword(ctx context.Context, username, hash string)
 	return err
 }
 
-func (r *repository) GetAnyUser(ctx context.Context) (*User, error) {
+// GetPrimaryUser returns the oldest admin, the account created at setup.
+func (r *repository) GetPrimaryUser(ctx context.Context) (*User, error) {
 	usr := new(User)
-	err := r.db.NewSelect().Model(usr).Limit(1).Scan(ctx)
+	err := r.db.NewSelect().Model(usr).Where("role = ?", RoleAdmin).Order("created_at ASC").Limit(1).Scan(ctx)
 	return usr, err
 }
 
+func (r *repository) ListUsers(ctx context.Context) ([]*User, error) {
+	var users []*User
+	err := r.db.NewSelect().Model(&users).Order("created_at ASC").Scan(ctx)
+	return users, err
+}
+
+func (r *repository) UpdateUser(ctx context.Context, u *User) error {
+	u.UpdatedAt = time.Now().UTC()
+	_, err := r.db.NewUpdate().Model(u).WherePK().Exec(ctx)
+	return err
+}
+
+func (r *repository) GetUserByInviteHash(ctx context.Context, hash string) (*User, error) {
+	usr := new(User)
+	err := r.db.NewSelect().Model(usr).Where("invite_token_hash = ?", hash).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	return usr, err
+}
+
+// CountActiveAdmins counts the admins that can sign in.
+func (r *repository) CountActiveAdmins(ctx context.Context) (int, error) {
+	return r.db.NewSelect().
+		Model((*User)(nil)).
+		Where("role = ?", RoleAdmin).
+		Where("disabled = ?", false).
+		Where("password_hash != ''").
+		Count(ctx)
+}
+
+// ownedTables hold resources with a user_id owner column.
+var ownedTables = []string{"streams", "videos", "platforms"}
+
+// DeleteUser removes the user and their sessions, handing what they own over
+// to heirID so no stream or video is left without an owner.
+func (r *repository) DeleteUser(ctx context.Context, id, heirID uuid.UUID) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, table := range ownedTables {
+			if _, err := tx.NewUpdate().Table(table).Set("user_id = ?", heirID).Where("user_id = ?", id).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.NewDelete().Model((*RefreshToken)(nil)).Where("user_id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*APIToken)(nil)).Where("user_id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*TwoFactor)(nil)).Where("user_id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewDelete().Model((*User)(nil)).Where("id = ?", id).Exec(ctx)
+		return err
+	})
+}
+
+func (r *repository) CreateAPIToken(ctx context.Context, t *APIToken) error {
+	_, err := r.db.NewInsert().Model(t).Exec(ctx)
+	return err
+}
+
+func (r *repository) ListAPITokens(ctx context.Context, userID uuid.UUID) ([]*APIToken, error) {
+	var tokens []*APIToken
+	err := r.db.NewSelect().Model(&tokens).Where("user_id = ?", userID).Order("created_at DESC").Scan(ctx)
+	return tokens, err
+}
+
+func (r *repository) GetAPITokenByHash(ctx context.Context, hash string) (*APIToken, error) {
+	t := new(APIToken)
+	err := r.db.NewSelect().Model(t).Where("token_hash = ?", hash).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	return t, err
+}
+
+func (r *repository) DeleteAPIToken(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.db.NewDelete().Model((*APIToken)(nil)).Where("id = ?", id).Where("user_id = ?", userID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+func (r *repository) TouchAPIToken(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	_, err := r.db.NewUpdate().Model((*APIToken)(nil)).Set("last_used_at = ?", usedAt).Where("id = ?", id).Exec(ctx)
+	return err
+}
+
+func (r *repository) GetTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactor, error) {
+	tf := new(TwoFactor)
+	err := r.db.NewSelect().Model(tf).Where("user_id = ?", userID).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTwoFactorNotSetup
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cipher.OpenFields(&tf.Secret); err != nil {
+		return nil, fmt.Errorf("decrypt TOTP secret: %w", err)
+	}
+	return tf, nil
+}
+
+func (r *repository) CreateTwoFactor(ctx context.Context, tf *TwoFactor) error {
+	restore, err := r.cipher.SealFields(&tf.Secret)
+	if err != nil {
+		return fmt.Errorf("encrypt TOTP secret: %w", err)
+	}
+	defer restore()
+
+	_, err = r.db.NewInsert().Model(tf).Exec(ctx)
+	return err
+}
+
+func (r *repository) UpdateTwoFactor(ctx context.Context, tf *TwoFactor) error {
+	restore, err := r.cipher.SealFields(&tf.Secret)
+	if err != nil {
+		return fmt.Errorf("encrypt TOTP secret: %w", err)
+	}
+	defer restore()
+
+	_, err = r.db.NewUpdate().Model(tf).WherePK().Exec(ctx)
+	return err
+}
+
+func (r *repository) DeleteTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.db.NewDelete().Model((*TwoFactor)(nil)).Where("user_id = ?", userID).Exec(ctx)
+	return err
+}
+
 func (r *repository) SaveRefreshToken(ctx context.Context, rt *RefreshToken) error {
 	_, err := r.db.NewInsert().Model(rt).Exec(ctx)
 	return err
@@ -23,10 +23,13 @@ var loginAttempts = sync.Map{}
 type service struct {
 	repo Repository
 	jwt  *jwt.JWTService
+
+	challengeMu sync.Mutex
+	challenges  map[string]*loginChallenge
 }
 
 func NewService(repo Repository, jwt *jwt.JWTService) Service {
-	return &service{repo: repo, jwt: jwt}
+	return &service{repo: repo, jwt: jwt, challenges: make(map[string]*loginChallenge)}
 }
 
 func (s *service) IsSetup(ctx context.Context) (bool, error) {
@@ -46,7 +49,7 @@ func (s *service) Setup(ctx context.Context, u, e, p string) error {
 	if err != nil {
 		return fmt.Errorf("generate password hash: %w", err)
 	}
-	if err := s.repo.CreateUser(ctx, &User{ID: uuid.New(), Username: u, Email: e, PasswordHash: string(h)}); err != nil {
+	if err := s.repo.CreateUser(ctx, &User{ID: uuid.New(), Username: u, Email: e, PasswordHash: string(h), Role: RoleAdmin}); err != nil {
 		return fmt.Errorf("create user: %w", err)
 	}
 	return nil
@@ -76,6 +79,9 @@ func (s *service) Authenticate(ctx context.Context, u, p string) (*User, error)
 		s.recordFailedAttempt(u)
 		return nil, ErrInvalidCredentials
 	}
+	if usr.Disabled {
+		return nil, ErrUserDisabled
+	}
 
 	s.clearFailedAttempts(u)
 	return usr, nil
@@ -128,7 +134,7 @@ func (s *service) ResetPassword(ctx context.Context, username, password string)
 }
 
 func (s *service) GetPrimaryUser(ctx context.Context) (*User, error) {
-	usr, err := s.repo.GetAnyUser(ctx)
+	usr, err := s.repo.GetPrimaryUser(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("get primary user: %w", err)
 	}
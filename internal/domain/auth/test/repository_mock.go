@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"time"
 
 	"github.com/codewithwan/gostreamix/internal/domain/auth"
 	"github.com/google/uuid"
@@ -43,7 +44,7 @@ func (m *MockAuthRepository) UpdatePassword(ctx context.Context, username, hash
 	return args.Error(0)
 }
 
-func (m *MockAuthRepository) GetAnyUser(ctx context.Context) (*auth.User, error) {
+func (m *MockAuthRepository) GetPrimaryUser(ctx context.Context) (*auth.User, error) {
 	args := m.Called(ctx)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -51,6 +52,37 @@ func (m *MockAuthRepository) GetAnyUser(ctx context.Context) (*auth.User, error)
 	return args.Get(0).(*auth.User), args.Error(1)
 }
 
+func (m *MockAuthRepository) ListUsers(ctx context.Context) ([]*auth.User, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*auth.User), args.Error(1)
+}
+
+func (m *MockAuthRepository) UpdateUser(ctx context.Context, u *auth.User) error {
+	args := m.Called(ctx, u)
+	return args.Error(0)
+}
+
+func (m *MockAuthRepository) GetUserByInviteHash(ctx context.Context, hash string) (*auth.User, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.User), args.Error(1)
+}
+
+func (m *MockAuthRepository) CountActiveAdmins(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAuthRepository) DeleteUser(ctx context.Context, id, heirID uuid.UUID) error {
+	args := m.Called(ctx, id, heirID)
+	return args.Error(0)
+}
+
 func (m *MockAuthRepository) SaveRefreshToken(ctx context.Context, rt *auth.RefreshToken) error {
 	args := m.Called(ctx, rt)
 	return args.Error(0)
@@ -73,3 +105,57 @@ func (m *MockAuthRepository) RevokeAllRefreshTokens(ctx context.Context, userID
 	args := m.Called(ctx, userID)
 	return args.Error(0)
 }
+
+func (m *MockAuthRepository) CreateAPIToken(ctx context.Context, t *auth.APIToken) error {
+	args := m.Called(ctx, t)
+	return args.Error(0)
+}
+
+func (m *MockAuthRepository) ListAPITokens(ctx context.Context, userID uuid.UUID) ([]*auth.APIToken, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*auth.APIToken), args.Error(1)
+}
+
+func (m *MockAuthRepository) GetAPITokenByHash(ctx context.Context, hash string) (*auth.APIToken, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.APIToken), args.Error(1)
+}
+
+func (m *MockAuthRepository) DeleteAPIToken(ctx context.Context, userID, id uuid.UUID) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *MockAuthRepository) TouchAPIToken(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	args := m.Called(ctx, id, usedAt)
+	return args.Error(0)
+}
+
+func (m *MockAuthRepository) GetTwoFactor(ctx context.Context, userID uuid.UUID) (*auth.TwoFactor, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.TwoFactor), args.Error(1)
+}
+
+func (m *MockAuthRepository) CreateTwoFactor(ctx context.Context, tf *auth.TwoFactor) error {
+	args := m.Called(ctx, tf)
+	return args.Error(0)
+}
+
+func (m *MockAuthRepository) UpdateTwoFactor(ctx context.Context, tf *auth.TwoFactor) error {
+	args := m.Called(ctx, tf)
+	return args.Error(0)
+}
+
+func (m *MockAuthRepository) DeleteTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
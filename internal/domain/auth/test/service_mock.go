@@ -70,3 +70,124 @@ func (m *MockAuthService) RevokeAllSessions(ctx context.Context, userID uuid.UUI
 	args := m.Called(ctx, userID)
 	return args.Error(0)
 }
+
+func (m *MockAuthService) ListUsers(ctx context.Context) ([]*auth.User, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*auth.User), args.Error(1)
+}
+
+func (m *MockAuthService) InviteUser(ctx context.Context, dto auth.InviteUserDTO) (*auth.User, string, error) {
+	args := m.Called(ctx, dto)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*auth.User), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) AcceptInvite(ctx context.Context, token, password string) (*auth.User, error) {
+	args := m.Called(ctx, token, password)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.User), args.Error(1)
+}
+
+func (m *MockAuthService) UpdateUser(ctx context.Context, actorID, id uuid.UUID, dto auth.UpdateUserDTO) (*auth.User, error) {
+	args := m.Called(ctx, actorID, id, dto)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.User), args.Error(1)
+}
+
+func (m *MockAuthService) DeleteUser(ctx context.Context, actorID, id uuid.UUID) error {
+	args := m.Called(ctx, actorID, id)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) CreateAPIToken(ctx context.Context, u *auth.User, dto auth.CreateAPITokenDTO) (*auth.APIToken, string, error) {
+	args := m.Called(ctx, u, dto)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*auth.APIToken), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) ListAPITokens(ctx context.Context, userID uuid.UUID) ([]*auth.APIToken, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*auth.APIToken), args.Error(1)
+}
+
+func (m *MockAuthService) RevokeAPIToken(ctx context.Context, userID, id uuid.UUID) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) AuthenticateToken(ctx context.Context, token string) (*auth.User, *auth.APIToken, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*auth.User), args.Get(1).(*auth.APIToken), args.Error(2)
+}
+
+func (m *MockAuthService) GetTwoFactorStatus(ctx context.Context, userID uuid.UUID) (*auth.TwoFactorStatusDTO, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.TwoFactorStatusDTO), args.Error(1)
+}
+
+func (m *MockAuthService) BeginTwoFactorSetup(ctx context.Context, u *auth.User) (*auth.TwoFactorSetupDTO, error) {
+	args := m.Called(ctx, u)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.TwoFactorSetupDTO), args.Error(1)
+}
+
+func (m *MockAuthService) EnableTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockAuthService) DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	args := m.Called(ctx, userID, code)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockAuthService) ResetTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) CreateLoginChallenge(ctx context.Context, userID uuid.UUID) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAuthService) VerifyLoginChallenge(ctx context.Context, challenge, code string) (*auth.User, error) {
+	args := m.Called(ctx, challenge, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.User), args.Error(1)
+}
@@ -131,7 +131,7 @@ func TestAuthService_GetPrimaryUser(t *testing.T) {
 		mockRepo := new(MockAuthRepository)
 		service := auth.NewService(mockRepo, testJWT)
 
-		mockRepo.On("GetAnyUser", ctx).Return(user, nil)
+		mockRepo.On("GetPrimaryUser", ctx).Return(user, nil)
 
 		res, err := service.GetPrimaryUser(ctx)
 		assert.NoError(t, err)
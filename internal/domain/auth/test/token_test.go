@@ -0,0 +1,123 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/shared/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAPIToken_HasScope(t *testing.T) {
+	control := &auth.APIToken{Scopes: []string{auth.ScopeReadOnly, auth.ScopeStreamControl}}
+	assert.True(t, control.HasScope(auth.ScopeStreamControl))
+	assert.False(t, control.HasScope(auth.ScopeAdmin))
+
+	admin := &auth.APIToken{Scopes: []string{auth.ScopeAdmin}}
+	assert.True(t, admin.HasScope(auth.ScopeReadOnly))
+	assert.True(t, admin.HasScope(auth.ScopeStreamControl))
+
+	t.Run("Stream control includes read-only", func(t *testing.T) {
+		token := &auth.APIToken{Scopes: []string{auth.ScopeStreamControl}}
+		assert.True(t, token.HasScope(auth.ScopeReadOnly))
+		assert.True(t, token.HasScope(auth.ScopeStreamControl))
+		assert.False(t, token.HasScope(auth.ScopeAdmin))
+	})
+
+	t.Run("Read-only grants nothing more", func(t *testing.T) {
+		token := &auth.APIToken{Scopes: []string{auth.ScopeReadOnly}}
+		assert.True(t, token.HasScope(auth.ScopeReadOnly))
+		assert.False(t, token.HasScope(auth.ScopeStreamControl))
+		assert.False(t, token.HasScope(auth.ScopeAdmin))
+	})
+
+	t.Run("Unknown scopes grant nothing", func(t *testing.T) {
+		token := &auth.APIToken{Scopes: []string{"superuser"}}
+		assert.False(t, token.HasScope(auth.ScopeReadOnly))
+		assert.False(t, admin.HasScope("superuser"))
+	})
+}
+
+func TestAuthService_CreateAPIToken(t *testing.T) {
+	ctx := context.Background()
+	operator := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+
+	t.Run("Only the hash is stored", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+		mockRepo.On("CreateAPIToken", ctx, mock.AnythingOfType("*auth.APIToken")).Return(nil)
+
+		tok, token, err := service.CreateAPIToken(ctx, operator, auth.CreateAPITokenDTO{
+			Name:   "CI",
+			Scopes: []string{auth.ScopeReadOnly, auth.ScopeStreamControl, auth.ScopeReadOnly},
+		})
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(token, auth.TokenPrefix))
+		assert.Equal(t, utils.HashToken(token), tok.TokenHash)
+		assert.Equal(t, token[:12], tok.Prefix)
+		assert.Equal(t, []string{auth.ScopeReadOnly, auth.ScopeStreamControl}, tok.Scopes)
+		assert.Equal(t, operator.ID, tok.UserID)
+	})
+
+	t.Run("Scopes are checked against the role", func(t *testing.T) {
+		service := auth.NewService(new(MockAuthRepository), testJWT)
+
+		_, _, err := service.CreateAPIToken(ctx, operator, auth.CreateAPITokenDTO{Name: "CI", Scopes: []string{auth.ScopeAdmin}})
+		assert.ErrorIs(t, err, auth.ErrScopeNotAllowed)
+		_, _, err = service.CreateAPIToken(ctx, operator, auth.CreateAPITokenDTO{Name: "CI", Scopes: []string{"write"}})
+		assert.ErrorIs(t, err, auth.ErrInvalidScope)
+		_, _, err = service.CreateAPIToken(ctx, operator, auth.CreateAPITokenDTO{Name: "CI"})
+		assert.ErrorIs(t, err, auth.ErrInvalidScope)
+		_, _, err = service.CreateAPIToken(ctx, operator, auth.CreateAPITokenDTO{Name: " ", Scopes: []string{auth.ScopeReadOnly}})
+		assert.ErrorIs(t, err, auth.ErrTokenNameRequired)
+	})
+}
+
+func TestAuthService_AuthenticateToken(t *testing.T) {
+	ctx := context.Background()
+	user := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	token := auth.TokenPrefix + "abc"
+
+	t.Run("Use is recorded", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		stored := &auth.APIToken{ID: uuid.New(), UserID: user.ID, Scopes: []string{auth.ScopeReadOnly}}
+		mockRepo.On("GetAPITokenByHash", ctx, utils.HashToken(token)).Return(stored, nil)
+		mockRepo.On("GetUserByID", ctx, user.ID).Return(user, nil)
+		mockRepo.On("TouchAPIToken", ctx, stored.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		usr, tok, err := service.AuthenticateToken(ctx, token)
+		assert.NoError(t, err)
+		assert.Equal(t, user, usr)
+		assert.NotNil(t, tok.LastUsedAt)
+
+		// A second use within the minute is not written again.
+		_, _, err = service.AuthenticateToken(ctx, token)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Unknown tokens and disabled users are rejected", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		used := time.Now()
+		disabled := &auth.User{ID: uuid.New(), Disabled: true}
+		mockRepo.On("GetAPITokenByHash", ctx, utils.HashToken(token)).Return(&auth.APIToken{UserID: disabled.ID, LastUsedAt: &used}, nil)
+		mockRepo.On("GetUserByID", ctx, disabled.ID).Return(disabled, nil)
+		mockRepo.On("GetAPITokenByHash", ctx, utils.HashToken(auth.TokenPrefix+"gone")).Return(nil, auth.ErrTokenNotFound)
+
+		_, _, err := service.AuthenticateToken(ctx, token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		_, _, err = service.AuthenticateToken(ctx, auth.TokenPrefix+"gone")
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		_, _, err = service.AuthenticateToken(ctx, "eyJhbGciOi")
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/shared/totp"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// enrollTwoFactor runs setup and confirmation through the service and
+// returns the stored enrollment and the recovery codes.
+func enrollTwoFactor(t *testing.T, service auth.Service, mockRepo *MockAuthRepository, user *auth.User) (*auth.TwoFactor, []string) {
+	ctx := context.Background()
+	var stored *auth.TwoFactor
+	mockRepo.On("GetTwoFactor", ctx, user.ID).Return(nil, auth.ErrTwoFactorNotSetup).Once()
+	mockRepo.On("DeleteTwoFactor", ctx, user.ID).Return(nil).Once()
+	mockRepo.On("CreateTwoFactor", ctx, mock.AnythingOfType("*auth.TwoFactor")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*auth.TwoFactor)
+	}).Return(nil).Once()
+
+	setup, err := service.BeginTwoFactorSetup(ctx, user)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(setup.URI, "otpauth://totp/GoStreamix:"+user.Username+"?"))
+	assert.Contains(t, setup.URI, "secret="+setup.Secret)
+	assert.False(t, stored.Enabled())
+
+	mockRepo.On("GetTwoFactor", ctx, user.ID).Return(stored, nil)
+	mockRepo.On("UpdateTwoFactor", ctx, stored).Return(nil)
+
+	_, err = service.EnableTwoFactor(ctx, user.ID, "000000")
+	assert.ErrorIs(t, err, auth.ErrInvalidCode)
+
+	mockRepo.AssertNotCalled(t, "RevokeAllRefreshTokens", ctx, user.ID)
+
+	mockRepo.On("RevokeAllRefreshTokens", ctx, user.ID).Return(nil).Once()
+	code, err := totp.Code(setup.Secret, time.Now())
+	assert.NoError(t, err)
+	codes, err := service.EnableTwoFactor(ctx, user.ID, code)
+	assert.NoError(t, err)
+	assert.True(t, stored.Enabled())
+	mockRepo.AssertNumberOfCalls(t, "RevokeAllRefreshTokens", 1)
+	return stored, codes
+}
+
+func TestAuthService_TwoFactorEnrollment(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockAuthRepository)
+	service := auth.NewService(mockRepo, testJWT)
+	user := &auth.User{ID: uuid.New(), Username: "admin"}
+
+	tf, codes := enrollTwoFactor(t, service, mockRepo, user)
+
+	t.Run("Recovery codes are stored hashed", func(t *testing.T) {
+		assert.Len(t, codes, 10)
+		assert.Len(t, tf.RecoveryCodes, 10)
+		assert.NotContains(t, tf.RecoveryCodes, codes[0])
+	})
+
+	t.Run("Setup is refused once enabled", func(t *testing.T) {
+		_, err := service.BeginTwoFactorSetup(ctx, user)
+		assert.ErrorIs(t, err, auth.ErrTwoFactorEnabled)
+
+		status, err := service.GetTwoFactorStatus(ctx, user.ID)
+		assert.NoError(t, err)
+		assert.True(t, status.Enabled)
+		assert.Equal(t, 10, status.RecoveryCodesLeft)
+	})
+
+	t.Run("Disabling needs a valid code and signs out every session", func(t *testing.T) {
+		assert.ErrorIs(t, service.DisableTwoFactor(ctx, user.ID, "000000"), auth.ErrInvalidCode)
+		mockRepo.AssertNumberOfCalls(t, "RevokeAllRefreshTokens", 1)
+
+		mockRepo.On("DeleteTwoFactor", ctx, user.ID).Return(nil).Once()
+		mockRepo.On("RevokeAllRefreshTokens", ctx, user.ID).Return(nil).Once()
+		assert.NoError(t, service.DisableTwoFactor(ctx, user.ID, codes[0]))
+		mockRepo.AssertNumberOfCalls(t, "RevokeAllRefreshTokens", 2)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestAuthService_TwoFactorLogin(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockAuthRepository)
+	service := auth.NewService(mockRepo, testJWT)
+	user := &auth.User{ID: uuid.New(), Username: "admin"}
+	mockRepo.On("GetUserByID", ctx, user.ID).Return(user, nil)
+
+	tf, codes := enrollTwoFactor(t, service, mockRepo, user)
+
+	t.Run("A code completes the challenge once", func(t *testing.T) {
+		// The code used to enable 2FA cannot be used again, so sign in with
+		// the next period's.
+		code, err := totp.Code(tf.Secret, time.Now().Add(totp.Period*time.Second))
+		assert.NoError(t, err)
+
+		challenge, err := service.CreateLoginChallenge(ctx, user.ID)
+		assert.NoError(t, err)
+		usr, err := service.VerifyLoginChallenge(ctx, challenge, code)
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, usr.ID)
+
+		_, err = service.VerifyLoginChallenge(ctx, challenge, code)
+		assert.ErrorIs(t, err, auth.ErrInvalidChallenge)
+
+		challenge, err = service.CreateLoginChallenge(ctx, user.ID)
+		assert.NoError(t, err)
+		_, err = service.VerifyLoginChallenge(ctx, challenge, code)
+		assert.ErrorIs(t, err, auth.ErrInvalidCode)
+	})
+
+	t.Run("Recovery codes work once", func(t *testing.T) {
+		challenge, err := service.CreateLoginChallenge(ctx, user.ID)
+		assert.NoError(t, err)
+		_, err = service.VerifyLoginChallenge(ctx, challenge, strings.ToUpper(codes[1]))
+		assert.NoError(t, err)
+		assert.Len(t, tf.RecoveryCodes, 9)
+
+		challenge, err = service.CreateLoginChallenge(ctx, user.ID)
+		assert.NoError(t, err)
+		_, err = service.VerifyLoginChallenge(ctx, challenge, codes[1])
+		assert.ErrorIs(t, err, auth.ErrInvalidCode)
+	})
+
+	t.Run("Too many wrong codes drop the challenge", func(t *testing.T) {
+		challenge, err := service.CreateLoginChallenge(ctx, user.ID)
+		assert.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			_, err = service.VerifyLoginChallenge(ctx, challenge, "000000")
+			assert.ErrorIs(t, err, auth.ErrInvalidCode)
+		}
+		_, err = service.VerifyLoginChallenge(ctx, challenge, codes[2])
+		assert.ErrorIs(t, err, auth.ErrInvalidChallenge)
+	})
+}
@@ -0,0 +1,179 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/shared/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUser_HasRole(t *testing.T) {
+	operator := &auth.User{Role: auth.RoleOperator}
+	assert.True(t, operator.HasRole(auth.RoleViewer))
+	assert.True(t, operator.HasRole(auth.RoleOperator))
+	assert.False(t, operator.HasRole(auth.RoleAdmin))
+	assert.False(t, (&auth.User{Role: "owner"}).HasRole(auth.RoleViewer))
+	assert.False(t, (&auth.User{Role: auth.RoleAdmin}).HasRole("owner"))
+}
+
+func TestUser_CanManage(t *testing.T) {
+	owner := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	assert.True(t, owner.CanManage(owner.ID))
+	assert.False(t, owner.CanManage(uuid.New()))
+	assert.True(t, (&auth.User{ID: uuid.New(), Role: auth.RoleAdmin}).CanManage(owner.ID))
+}
+
+func TestAuthService_SetupCreatesAdmin(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockAuthRepository)
+	service := auth.NewService(mockRepo, testJWT)
+
+	mockRepo.On("CountUsers", ctx).Return(0, nil)
+	mockRepo.On("CreateUser", ctx, mock.MatchedBy(func(u *auth.User) bool {
+		return u.Role == auth.RoleAdmin
+	})).Return(nil)
+
+	assert.NoError(t, service.Setup(ctx, "admin", "admin@example.com", "password"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Invites(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Invite stores only the token hash", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		mockRepo.On("GetUserByUsername", ctx, "dina").Return(nil, auth.ErrUserNotFound)
+		mockRepo.On("CreateUser", ctx, mock.AnythingOfType("*auth.User")).Return(nil)
+
+		usr, token, err := service.InviteUser(ctx, auth.InviteUserDTO{Username: "dina", Email: "dina@example.com", Role: auth.RoleOperator})
+		assert.NoError(t, err)
+		assert.Len(t, token, 64)
+		assert.Equal(t, utils.HashToken(token), usr.InviteTokenHash)
+		assert.True(t, usr.Pending())
+		assert.WithinDuration(t, time.Now().Add(72*time.Hour), *usr.InviteExpiresAt, time.Minute)
+	})
+
+	t.Run("Invite rejects unknown roles and taken usernames", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		_, _, err := service.InviteUser(ctx, auth.InviteUserDTO{Username: "dina", Role: "owner"})
+		assert.ErrorIs(t, err, auth.ErrInvalidRole)
+
+		mockRepo.On("GetUserByUsername", ctx, "admin").Return(&auth.User{ID: uuid.New()}, nil)
+		_, _, err = service.InviteUser(ctx, auth.InviteUserDTO{Username: "admin", Role: auth.RoleViewer})
+		assert.ErrorIs(t, err, auth.ErrUsernameTaken)
+	})
+
+	t.Run("Accepting sets the password and clears the invite", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		expires := time.Now().Add(time.Hour)
+		invited := &auth.User{ID: uuid.New(), Username: "dina", Role: auth.RoleOperator, InviteTokenHash: utils.HashToken("tok"), InviteExpiresAt: &expires}
+		mockRepo.On("GetUserByInviteHash", ctx, utils.HashToken("tok")).Return(invited, nil)
+		mockRepo.On("UpdateUser", ctx, invited).Return(nil)
+
+		usr, err := service.AcceptInvite(ctx, "tok", "Secret123!")
+		assert.NoError(t, err)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(usr.PasswordHash), []byte("Secret123!")))
+		assert.Empty(t, usr.InviteTokenHash)
+		assert.Nil(t, usr.InviteExpiresAt)
+	})
+
+	t.Run("Expired and unknown invites are rejected", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		expired := time.Now().Add(-time.Minute)
+		mockRepo.On("GetUserByInviteHash", ctx, utils.HashToken("old")).Return(&auth.User{InviteExpiresAt: &expired}, nil)
+		mockRepo.On("GetUserByInviteHash", ctx, utils.HashToken("nope")).Return(nil, auth.ErrUserNotFound)
+
+		_, err := service.AcceptInvite(ctx, "old", "Secret123!")
+		assert.ErrorIs(t, err, auth.ErrInvalidInvite)
+		_, err = service.AcceptInvite(ctx, "nope", "Secret123!")
+		assert.ErrorIs(t, err, auth.ErrInvalidInvite)
+	})
+}
+
+func TestAuthService_DisabledUserCannotSignIn(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockAuthRepository)
+	service := auth.NewService(mockRepo, testJWT)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+	mockRepo.On("GetUserByUsername", ctx, "disabled").Return(&auth.User{Username: "disabled", PasswordHash: string(hash), Disabled: true}, nil)
+
+	_, err := service.Authenticate(ctx, "disabled", "password")
+	assert.ErrorIs(t, err, auth.ErrUserDisabled)
+}
+
+func TestAuthService_ManageUsers(t *testing.T) {
+	ctx := context.Background()
+	actorID := uuid.New()
+	admin := func() *auth.User {
+		return &auth.User{ID: actorID, Role: auth.RoleAdmin, PasswordHash: "hash"}
+	}
+
+	t.Run("Disabling signs the user out", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		operator := &auth.User{ID: uuid.New(), Role: auth.RoleOperator, PasswordHash: "hash"}
+		disabled := true
+		mockRepo.On("GetUserByID", ctx, operator.ID).Return(operator, nil)
+		mockRepo.On("UpdateUser", ctx, operator).Return(nil)
+		mockRepo.On("RevokeAllRefreshTokens", ctx, operator.ID).Return(nil)
+
+		usr, err := service.UpdateUser(ctx, actorID, operator.ID, auth.UpdateUserDTO{Disabled: &disabled})
+		assert.NoError(t, err)
+		assert.True(t, usr.Disabled)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Admins cannot lock themselves out", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		viewer := auth.RoleViewer
+		mockRepo.On("GetUserByID", ctx, actorID).Return(admin(), nil)
+
+		_, err := service.UpdateUser(ctx, actorID, actorID, auth.UpdateUserDTO{Role: &viewer})
+		assert.ErrorIs(t, err, auth.ErrOwnAccount)
+		assert.ErrorIs(t, service.DeleteUser(ctx, actorID, actorID), auth.ErrOwnAccount)
+	})
+
+	t.Run("The last admin is kept", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		other := &auth.User{ID: uuid.New(), Role: auth.RoleAdmin, PasswordHash: "hash"}
+		operator := auth.RoleOperator
+		mockRepo.On("GetUserByID", ctx, other.ID).Return(other, nil)
+		mockRepo.On("CountActiveAdmins", ctx).Return(1, nil)
+
+		_, err := service.UpdateUser(ctx, actorID, other.ID, auth.UpdateUserDTO{Role: &operator})
+		assert.ErrorIs(t, err, auth.ErrLastAdmin)
+		assert.ErrorIs(t, service.DeleteUser(ctx, actorID, other.ID), auth.ErrLastAdmin)
+	})
+
+	t.Run("Deleting hands resources to the admin", func(t *testing.T) {
+		mockRepo := new(MockAuthRepository)
+		service := auth.NewService(mockRepo, testJWT)
+
+		viewer := &auth.User{ID: uuid.New(), Role: auth.RoleViewer, PasswordHash: "hash"}
+		mockRepo.On("GetUserByID", ctx, viewer.ID).Return(viewer, nil)
+		mockRepo.On("DeleteUser", ctx, viewer.ID, actorID).Return(nil)
+
+		assert.NoError(t, service.DeleteUser(ctx, actorID, viewer.ID))
+		mockRepo.AssertExpectations(t)
+	})
+}
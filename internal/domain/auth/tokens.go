@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/shared/utils"
+	"github.com/google/uuid"
+)
+
+// TokenPrefix starts every API token so they are easy to recognise, for
+// instance by secret scanners.
+const TokenPrefix = "gsx_"
+
+// lastUsedResolution limits how often a busy token's last use is written.
+const lastUsedResolution = time.Minute
+
+func (s *service) CreateAPIToken(ctx context.Context, u *User, dto CreateAPITokenDTO) (*APIToken, string, error) {
+	name := strings.TrimSpace(dto.Name)
+	if name == "" {
+		return nil, "", ErrTokenNameRequired
+	}
+	scopes, err := normalizeScopes(u, dto.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", fmt.Errorf("generate API token: %w", err)
+	}
+	token := TokenPrefix + hex.EncodeToString(b)
+
+	t := &APIToken{
+		ID:        uuid.New(),
+		UserID:    u.ID,
+		Name:      name,
+		Prefix:    token[:len(TokenPrefix)+8],
+		TokenHash: utils.HashToken(token),
+		Scopes:    scopes,
+	}
+	if err := s.repo.CreateAPIToken(ctx, t); err != nil {
+		return nil, "", fmt.Errorf("create API token: %w", err)
+	}
+	return t, token, nil
+}
+
+// normalizeScopes checks the requested scopes are known and within the
+// user's role, and drops duplicates.
+func normalizeScopes(u *User, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, ErrInvalidScope
+	}
+	scopes := make([]string, 0, len(requested))
+	seen := make(map[string]bool, len(requested))
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		role, ok := scopeRoles[scope]
+		if !ok {
+			return nil, ErrInvalidScope
+		}
+		if !u.HasRole(role) {
+			return nil, fmt.Errorf("%w: %s", ErrScopeNotAllowed, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func (s *service) ListAPITokens(ctx context.Context, userID uuid.UUID) ([]*APIToken, error) {
+	tokens, err := s.repo.ListAPITokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list API tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *service) RevokeAPIToken(ctx context.Context, userID, id uuid.UUID) error {
+	return s.repo.DeleteAPIToken(ctx, userID, id)
+}
+
+// AuthenticateToken resolves a bearer token to its user and records its
+// use. Tokens of disabled users are rejected.
+func (s *service) AuthenticateToken(ctx context.Context, token string) (*User, *APIToken, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return nil, nil, ErrInvalidToken
+	}
+	t, err := s.repo.GetAPITokenByHash(ctx, utils.HashToken(token))
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("get API token: %w", err)
+	}
+	usr, err := s.repo.GetUserByID(ctx, t.UserID)
+	if err != nil || usr.Disabled {
+		return nil, nil, ErrInvalidToken
+	}
+
+	now := time.Now().UTC()
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= lastUsedResolution {
+		if err := s.repo.TouchAPIToken(ctx, t.ID, now); err != nil {
+			return nil, nil, fmt.Errorf("record API token use: %w", err)
+		}
+		t.LastUsedAt = &now
+	}
+	return usr, t, nil
+}
@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/shared/totp"
+	"github.com/codewithwan/gostreamix/internal/shared/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer        = "GoStreamix"
+	recoveryCodeCount = 10
+
+	// A login challenge bridges the password and code steps of signing in.
+	challengeTTL      = 5 * time.Minute
+	challengeAttempts = 5
+)
+
+type loginChallenge struct {
+	userID   uuid.UUID
+	expires  time.Time
+	attempts int
+}
+
+func (s *service) GetTwoFactorStatus(ctx context.Context, userID uuid.UUID) (*TwoFactorStatusDTO, error) {
+	tf, err := s.repo.GetTwoFactor(ctx, userID)
+	if errors.Is(err, ErrTwoFactorNotSetup) {
+		return &TwoFactorStatusDTO{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get two-factor: %w", err)
+	}
+	if !tf.Enabled() {
+		return &TwoFactorStatusDTO{}, nil
+	}
+	return &TwoFactorStatusDTO{Enabled: true, EnabledAt: tf.EnabledAt, RecoveryCodesLeft: len(tf.RecoveryCodes)}, nil
+}
+
+// BeginTwoFactorSetup starts enrollment with a fresh secret, replacing an
+// earlier one that was never confirmed.
+func (s *service) BeginTwoFactorSetup(ctx context.Context, u *User) (*TwoFactorSetupDTO, error) {
+	existing, err := s.repo.GetTwoFactor(ctx, u.ID)
+	if err == nil && existing.Enabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+	if err != nil && !errors.Is(err, ErrTwoFactorNotSetup) {
+		return nil, fmt.Errorf("get two-factor: %w", err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeleteTwoFactor(ctx, u.ID); err != nil {
+		return nil, fmt.Errorf("delete pending two-factor: %w", err)
+	}
+	if err := s.repo.CreateTwoFactor(ctx, &TwoFactor{ID: uuid.New(), UserID: u.ID, Secret: secret}); err != nil {
+		return nil, fmt.Errorf("create two-factor: %w", err)
+	}
+	return &TwoFactorSetupDTO{Secret: secret, URI: totp.URI(totpIssuer, u.Username, secret)}, nil
+}
+
+// EnableTwoFactor confirms enrollment with a code from the authenticator and
+// returns the recovery codes, which are only ever shown here. Every session
+// of the user is revoked, as they were signed in with the password alone.
+func (s *service) EnableTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	tf, err := s.repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+	if !checkTOTP(tf, code) {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	tf.EnabledAt = &now
+	tf.RecoveryCodes = hashes
+	if err := s.repo.UpdateTwoFactor(ctx, tf); err != nil {
+		return nil, fmt.Errorf("update two-factor: %w", err)
+	}
+	if err := s.repo.RevokeAllRefreshTokens(ctx, userID); err != nil {
+		return nil, fmt.Errorf("revoke sessions: %w", err)
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns 2FA off and revokes every session of the user, so
+// one left signed in elsewhere does not outlive the change.
+func (s *service) DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	tf, err := s.enabledTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyCode(ctx, tf, code); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteTwoFactor(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.RevokeAllRefreshTokens(ctx, userID); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, used or not.
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	tf, err := s.enabledTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyCode(ctx, tf, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	tf.RecoveryCodes = hashes
+	if err := s.repo.UpdateTwoFactor(ctx, tf); err != nil {
+		return nil, fmt.Errorf("update two-factor: %w", err)
+	}
+	return codes, nil
+}
+
+// ResetTwoFactor turns 2FA off without a code, for a user who lost both
+// their authenticator and their recovery codes.
+func (s *service) ResetTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	if err := s.repo.DeleteTwoFactor(ctx, userID); err != nil {
+		return fmt.Errorf("delete two-factor: %w", err)
+	}
+	return nil
+}
+
+// CreateLoginChallenge starts the second step of signing in for a user whose
+// password was accepted. Challenges only live in memory; after a restart
+// the user simply enters their password again.
+func (s *service) CreateLoginChallenge(ctx context.Context, userID uuid.UUID) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate login challenge: %w", err)
+	}
+	challenge := hex.EncodeToString(b)
+
+	now := time.Now()
+	s.challengeMu.Lock()
+	defer s.challengeMu.Unlock()
+	for key, c := range s.challenges {
+		if now.After(c.expires) {
+			delete(s.challenges, key)
+		}
+	}
+	s.challenges[utils.HashToken(challenge)] = &loginChallenge{userID: userID, expires: now.Add(challengeTTL)}
+	return challenge, nil
+}
+
+// VerifyLoginChallenge completes signing in with an authenticator or
+// recovery code. A challenge is used once; after too many wrong codes it is
+// dropped and the password has to be entered again.
+func (s *service) VerifyLoginChallenge(ctx context.Context, challenge, code string) (*User, error) {
+	key := utils.HashToken(challenge)
+	c := s.takeChallenge(key)
+	if c == nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	tf, err := s.enabledTwoFactor(ctx, c.userID)
+	if errors.Is(err, ErrTwoFactorDisabled) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyCode(ctx, tf, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			s.returnChallenge(key, c)
+		}
+		return nil, err
+	}
+
+	usr, err := s.repo.GetUserByID(ctx, c.userID)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	if usr.Disabled {
+		return nil, ErrUserDisabled
+	}
+	return usr, nil
+}
+
+// takeChallenge removes the challenge while its code is checked, so two
+// requests cannot complete the same sign in.
+func (s *service) takeChallenge(key string) *loginChallenge {
+	s.challengeMu.Lock()
+	defer s.challengeMu.Unlock()
+	c, ok := s.challenges[key]
+	if !ok {
+		return nil
+	}
+	delete(s.challenges, key)
+	if time.Now().After(c.expires) {
+		return nil
+	}
+	return c
+}
+
+func (s *service) returnChallenge(key string, c *loginChallenge) {
+	c.attempts++
+	if c.attempts >= challengeAttempts {
+		return
+	}
+	s.challengeMu.Lock()
+	s.challenges[key] = c
+	s.challengeMu.Unlock()
+}
+
+func (s *service) enabledTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactor, error) {
+	tf, err := s.repo.GetTwoFactor(ctx, userID)
+	if errors.Is(err, ErrTwoFactorNotSetup) || (err == nil && !tf.Enabled()) {
+		return nil, ErrTwoFactorDisabled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get two-factor: %w", err)
+	}
+	return tf, nil
+}
+
+// verifyCode accepts a current authenticator code or an unused recovery
+// code, and records its use so it cannot be replayed.
+func (s *service) verifyCode(ctx context.Context, tf *TwoFactor, code string) error {
+	if checkTOTP(tf, code) {
+		if err := s.repo.UpdateTwoFactor(ctx, tf); err != nil {
+			return fmt.Errorf("update two-factor: %w", err)
+		}
+		return nil
+	}
+
+	hash := hashRecoveryCode(code)
+	for i, h := range tf.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			tf.RecoveryCodes = append(tf.RecoveryCodes[:i:i], tf.RecoveryCodes[i+1:]...)
+			if err := s.repo.UpdateTwoFactor(ctx, tf); err != nil {
+				return fmt.Errorf("update two-factor: %w", err)
+			}
+			return nil
+		}
+	}
+	return ErrInvalidCode
+}
+
+// checkTOTP validates an authenticator code. Each code works once: its
+// period has to be later than the last one accepted.
+func checkTOTP(tf *TwoFactor, code string) bool {
+	step, ok := totp.Validate(tf.Secret, strings.ReplaceAll(strings.TrimSpace(code), " ", ""), time.Now())
+	if !ok || step <= tf.LastStep {
+		return false
+	}
+	tf.LastStep = step
+	return true
+}
+
+// newRecoveryCodes returns codes formatted for the user, like
+// "3f9a1-c07e2", and the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		raw := hex.EncodeToString(b)
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and the dash, which users may type
+// differently from how the code was shown.
+func hashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+	return utils.HashToken(normalized)
+}
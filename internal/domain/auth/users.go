@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/shared/utils"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const inviteTTL = 72 * time.Hour
+
+func (s *service) ListUsers(ctx context.Context) ([]*User, error) {
+	users, err := s.repo.ListUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	return users, nil
+}
+
+// InviteUser creates an account without a password and returns the token
+// the invitee signs up with. Only its hash is stored.
+func (s *service) InviteUser(ctx context.Context, dto InviteUserDTO) (*User, string, error) {
+	if !ValidRole(dto.Role) {
+		return nil, "", ErrInvalidRole
+	}
+	if _, err := s.repo.GetUserByUsername(ctx, dto.Username); err == nil {
+		return nil, "", ErrUsernameTaken
+	}
+
+	token, err := newInviteToken()
+	if err != nil {
+		return nil, "", err
+	}
+	expires := time.Now().UTC().Add(inviteTTL)
+	usr := &User{
+		ID:              uuid.New(),
+		Username:        dto.Username,
+		Email:           dto.Email,
+		Role:            dto.Role,
+		InviteTokenHash: utils.HashToken(token),
+		InviteExpiresAt: &expires,
+	}
+	if err := s.repo.CreateUser(ctx, usr); err != nil {
+		return nil, "", fmt.Errorf("create user: %w", err)
+	}
+	return usr, token, nil
+}
+
+func (s *service) AcceptInvite(ctx context.Context, token, password string) (*User, error) {
+	usr, err := s.repo.GetUserByInviteHash(ctx, utils.HashToken(strings.TrimSpace(token)))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidInvite
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get invite: %w", err)
+	}
+	if usr.InviteExpiresAt == nil || time.Now().After(*usr.InviteExpiresAt) {
+		return nil, ErrInvalidInvite
+	}
+
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("generate password hash: %w", err)
+	}
+	usr.PasswordHash = string(h)
+	usr.InviteTokenHash = ""
+	usr.InviteExpiresAt = nil
+	if err := s.repo.UpdateUser(ctx, usr); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+	return usr, nil
+}
+
+// UpdateUser changes the role of a user or disables them. Disabling signs
+// the user out everywhere. Admins cannot lock themselves out, and the last
+// active admin cannot be demoted or disabled.
+func (s *service) UpdateUser(ctx context.Context, actorID, id uuid.UUID, dto UpdateUserDTO) (*User, error) {
+	usr, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	role := usr.Role
+	if dto.Role != nil {
+		if !ValidRole(*dto.Role) {
+			return nil, ErrInvalidRole
+		}
+		role = *dto.Role
+	}
+	disabled := usr.Disabled
+	if dto.Disabled != nil {
+		disabled = *dto.Disabled
+	}
+
+	losesAdmin := usr.Role == RoleAdmin && !usr.Disabled && !usr.Pending() && (role != RoleAdmin || disabled)
+	if losesAdmin {
+		if actorID == id {
+			return nil, ErrOwnAccount
+		}
+		if err := s.requireAnotherAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if actorID == id && disabled {
+		return nil, ErrOwnAccount
+	}
+
+	usr.Role = role
+	usr.Disabled = disabled
+	if err := s.repo.UpdateUser(ctx, usr); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+	if disabled {
+		if err := s.repo.RevokeAllRefreshTokens(ctx, id); err != nil {
+			return nil, fmt.Errorf("revoke sessions: %w", err)
+		}
+	}
+	return usr, nil
+}
+
+// DeleteUser removes a user, handing their streams, videos and platforms
+// over to the admin deleting them.
+func (s *service) DeleteUser(ctx context.Context, actorID, id uuid.UUID) error {
+	if actorID == id {
+		return ErrOwnAccount
+	}
+	usr, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if usr.Role == RoleAdmin && !usr.Disabled && !usr.Pending() {
+		if err := s.requireAnotherAdmin(ctx); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.DeleteUser(ctx, id, actorID); err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	return nil
+}
+
+func (s *service) requireAnotherAdmin(ctx context.Context) error {
+	admins, err := s.repo.CountActiveAdmins(ctx)
+	if err != nil {
+		return fmt.Errorf("count admins: %w", err)
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func newInviteToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate invite token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/activity"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/monitor"
 	"github.com/codewithwan/gostreamix/internal/shared/middleware"
@@ -16,11 +17,12 @@ import (
 type Handler struct {
 	authSvc auth.Service
 	db      *bun.DB
+	caps    *ffmpeg.Capabilities
 	log     *zap.Logger
 }
 
-func NewHandler(authSvc auth.Service, db *bun.DB, log *zap.Logger) *Handler {
-	return &Handler{authSvc: authSvc, db: db, log: log}
+func NewHandler(authSvc auth.Service, db *bun.DB, caps *ffmpeg.Capabilities, log *zap.Logger) *Handler {
+	return &Handler{authSvc: authSvc, db: db, caps: caps, log: log}
 }
 
 func (h *Handler) Routes(app *fiber.App) {
@@ -29,6 +31,7 @@ func (h *Handler) Routes(app *fiber.App) {
 	api.Get("/stats", h.ApiStats)
 	api.Get("/metrics", h.ApiMetrics)
 	api.Get("/logs", h.ApiLogs)
+	api.Get("/ffmpeg", h.ApiFFmpegCapabilities)
 }
 
 func (h *Handler) ApiProfile(c *fiber.Ctx) error {
@@ -41,6 +44,7 @@ func (h *Handler) ApiProfile(c *fiber.Ctx) error {
 		"id":       u.ID,
 		"username": u.Username,
 		"email":    u.Email,
+		"role":     u.Role,
 	})
 }
 
@@ -53,6 +57,15 @@ func (h *Handler) ApiStats(c *fiber.Ctx) error {
 	return c.JSON(monitor.GetStats())
 }
 
+func (h *Handler) ApiFFmpegCapabilities(c *fiber.Ctx) error {
+	u := middleware.GetUser(c, h.authSvc)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	return c.JSON(h.caps)
+}
+
 func (h *Handler) ApiMetrics(c *fiber.Ctx) error {
 	u := middleware.GetUser(c, h.authSvc)
 	if u == nil {
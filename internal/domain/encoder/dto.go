@@ -0,0 +1,34 @@
+package encoder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+)
+
+// SaveProfileDTO carries the profile name alongside its encoder settings,
+// which are inlined in the JSON body.
+type SaveProfileDTO struct {
+	Name string `json:"name"`
+	ffmpeg.EncoderSettings
+}
+
+// NewSaveProfileDTO starts from the default settings so a request only needs
+// to send the fields it changes.
+func NewSaveProfileDTO() SaveProfileDTO {
+	return SaveProfileDTO{EncoderSettings: ffmpeg.DefaultEncoderSettings()}
+}
+
+func (d *SaveProfileDTO) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return ErrValidationNameRequired
+	}
+	if len(d.Name) > 50 {
+		return ErrValidationNameTooLong
+	}
+	if err := d.EncoderSettings.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+	}
+	return nil
+}
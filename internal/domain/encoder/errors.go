@@ -0,0 +1,11 @@
+package encoder
+
+import "errors"
+
+var (
+	ErrProfileNotFound        = errors.New("encoder profile not found")
+	ErrProfileInUse           = errors.New("encoder profile is used by one or more streams")
+	ErrInvalidProfile         = errors.New("invalid encoder profile")
+	ErrValidationNameRequired = errors.New("profile name is required")
+	ErrValidationNameTooLong  = errors.New("profile name must be less than 50 characters")
+)
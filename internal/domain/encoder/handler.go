@@ -0,0 +1,129 @@
+package encoder
+
+import (
+	"errors"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type Handler struct {
+	svc  Service
+	caps *ffmpeg.Capabilities
+	log  *zap.Logger
+}
+
+func NewHandler(svc Service, caps *ffmpeg.Capabilities, log *zap.Logger) *Handler {
+	return &Handler{svc: svc, caps: caps, log: log}
+}
+
+func (h *Handler) Routes(app *fiber.App) {
+	api := app.Group("/api/encoder-profiles")
+	api.Get("/", h.ApiGetProfiles)
+	api.Post("/", h.ApiCreateProfile)
+	api.Get("/:id", h.ApiGetProfile)
+	api.Put("/:id", h.ApiUpdateProfile)
+	api.Delete("/:id", h.ApiDeleteProfile)
+}
+
+func (h *Handler) ApiGetProfiles(c *fiber.Ctx) error {
+	profiles, err := h.svc.GetProfiles(c.Context())
+	if err != nil {
+		h.log.Error("Failed to list encoder profiles", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list encoder profiles"})
+	}
+
+	return c.JSON(profiles)
+}
+
+func (h *Handler) ApiGetProfile(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid id"})
+	}
+
+	p, err := h.svc.GetProfile(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrProfileNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": ErrProfileNotFound.Error()})
+		}
+		h.log.Error("Failed to get encoder profile", zap.Error(err), zap.String("profileID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get encoder profile"})
+	}
+
+	return c.JSON(p)
+}
+
+func (h *Handler) ApiCreateProfile(c *fiber.Ctx) error {
+	req := NewSaveProfileDTO()
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := h.caps.CheckEncoder(req.EncoderSettings); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	p, err := h.svc.CreateProfile(c.Context(), req)
+	if err != nil {
+		h.log.Error("Failed to create encoder profile", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create encoder profile"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(p)
+}
+
+func (h *Handler) ApiUpdateProfile(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid id"})
+	}
+
+	req := NewSaveProfileDTO()
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := h.caps.CheckEncoder(req.EncoderSettings); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	p, err := h.svc.UpdateProfile(c.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, ErrProfileNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": ErrProfileNotFound.Error()})
+		}
+		h.log.Error("Failed to update encoder profile", zap.Error(err), zap.String("profileID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update encoder profile"})
+	}
+
+	return c.JSON(p)
+}
+
+func (h *Handler) ApiDeleteProfile(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid id"})
+	}
+
+	if err := h.svc.DeleteProfile(c.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, ErrProfileNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": ErrProfileNotFound.Error()})
+		case errors.Is(err, ErrProfileInUse):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to delete encoder profile", zap.Error(err), zap.String("profileID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete encoder profile"})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,24 @@
+package encoder
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, p *EncoderProfile) error
+	Update(ctx context.Context, p *EncoderProfile) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindByID(ctx context.Context, id uuid.UUID) (*EncoderProfile, error)
+	List(ctx context.Context) ([]*EncoderProfile, error)
+	CountStreams(ctx context.Context, id uuid.UUID) (int, error)
+}
+
+type Service interface {
+	GetProfiles(ctx context.Context) ([]*EncoderProfile, error)
+	GetProfile(ctx context.Context, id uuid.UUID) (*EncoderProfile, error)
+	CreateProfile(ctx context.Context, dto SaveProfileDTO) (*EncoderProfile, error)
+	UpdateProfile(ctx context.Context, id uuid.UUID, dto SaveProfileDTO) (*EncoderProfile, error)
+	DeleteProfile(ctx context.Context, id uuid.UUID) error
+}
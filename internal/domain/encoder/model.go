@@ -0,0 +1,19 @@
+package encoder
+
+import (
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type EncoderProfile struct {
+	bun.BaseModel `bun:"table:encoder_profiles,alias:ep"`
+
+	ID   uuid.UUID `bun:",pk,type:text" json:"id"`
+	Name string    `bun:",notnull" json:"name"`
+	ffmpeg.EncoderSettings
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
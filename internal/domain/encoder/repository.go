@@ -0,0 +1,51 @@
+package encoder
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type repository struct {
+	db *bun.DB
+}
+
+func NewRepository(db *bun.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(ctx context.Context, p *EncoderProfile) error {
+	_, err := r.db.NewInsert().Model(p).Exec(ctx)
+	return err
+}
+
+func (r *repository) Update(ctx context.Context, p *EncoderProfile) error {
+	_, err := r.db.NewUpdate().Model(p).WherePK().Exec(ctx)
+	return err
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.NewDelete().Model((*EncoderProfile)(nil)).Where("id = ?", id).Exec(ctx)
+	return err
+}
+
+func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (*EncoderProfile, error) {
+	p := new(EncoderProfile)
+	if err := r.db.NewSelect().Model(p).Where("id = ?", id).Scan(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *repository) List(ctx context.Context) ([]*EncoderProfile, error) {
+	var profiles []*EncoderProfile
+	err := r.db.NewSelect().Model(&profiles).Order("name ASC").Scan(ctx)
+	return profiles, err
+}
+
+// CountStreams reports how many streams reference the profile. It queries the
+// streams table directly so this package does not depend on the stream domain.
+func (r *repository) CountStreams(ctx context.Context, id uuid.UUID) (int, error) {
+	return r.db.NewSelect().Table("streams").Where("encoder_profile_id = ?", id).Count(ctx)
+}
@@ -0,0 +1,86 @@
+package encoder
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sharedutils "github.com/codewithwan/gostreamix/internal/shared/utils"
+	"github.com/google/uuid"
+)
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) GetProfiles(ctx context.Context) ([]*EncoderProfile, error) {
+	profiles, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list encoder profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+func (s *service) GetProfile(ctx context.Context, id uuid.UUID) (*EncoderProfile, error) {
+	p, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find encoder profile: %w", err)
+	}
+	return p, nil
+}
+
+func (s *service) CreateProfile(ctx context.Context, dto SaveProfileDTO) (*EncoderProfile, error) {
+	p := &EncoderProfile{
+		ID:              uuid.New(),
+		Name:            sharedutils.SanitizeStrict(dto.Name),
+		EncoderSettings: dto.EncoderSettings,
+	}
+	if err := s.repo.Create(ctx, p); err != nil {
+		return nil, fmt.Errorf("create encoder profile: %w", err)
+	}
+	return p, nil
+}
+
+func (s *service) UpdateProfile(ctx context.Context, id uuid.UUID, dto SaveProfileDTO) (*EncoderProfile, error) {
+	p, err := s.GetProfile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Name = sharedutils.SanitizeStrict(dto.Name)
+	p.EncoderSettings = dto.EncoderSettings
+	p.UpdatedAt = time.Now().UTC()
+
+	if err := s.repo.Update(ctx, p); err != nil {
+		return nil, fmt.Errorf("update encoder profile: %w", err)
+	}
+	return p, nil
+}
+
+func (s *service) DeleteProfile(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.GetProfile(ctx, id); err != nil {
+		return err
+	}
+
+	inUse, err := s.repo.CountStreams(ctx, id)
+	if err != nil {
+		return fmt.Errorf("count streams using encoder profile: %w", err)
+	}
+	if inUse > 0 {
+		return ErrProfileInUse
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete encoder profile: %w", err)
+	}
+	return nil
+}
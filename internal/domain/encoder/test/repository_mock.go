@@ -0,0 +1,49 @@
+package test
+
+import (
+	"context"
+
+	"github.com/codewithwan/gostreamix/internal/domain/encoder"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockEncoderRepository struct {
+	mock.Mock
+}
+
+func (m *MockEncoderRepository) Create(ctx context.Context, p *encoder.EncoderProfile) error {
+	args := m.Called(ctx, p)
+	return args.Error(0)
+}
+
+func (m *MockEncoderRepository) Update(ctx context.Context, p *encoder.EncoderProfile) error {
+	args := m.Called(ctx, p)
+	return args.Error(0)
+}
+
+func (m *MockEncoderRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockEncoderRepository) FindByID(ctx context.Context, id uuid.UUID) (*encoder.EncoderProfile, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*encoder.EncoderProfile), args.Error(1)
+}
+
+func (m *MockEncoderRepository) List(ctx context.Context) ([]*encoder.EncoderProfile, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*encoder.EncoderProfile), args.Error(1)
+}
+
+func (m *MockEncoderRepository) CountStreams(ctx context.Context, id uuid.UUID) (int, error) {
+	args := m.Called(ctx, id)
+	return args.Int(0), args.Error(1)
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/encoder"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoderService_DeleteProfile(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+
+	t.Run("Delete success", func(t *testing.T) {
+		mockRepo := new(MockEncoderRepository)
+		service := encoder.NewService(mockRepo)
+
+		mockRepo.On("FindByID", ctx, id).Return(&encoder.EncoderProfile{ID: id}, nil)
+		mockRepo.On("CountStreams", ctx, id).Return(0, nil)
+		mockRepo.On("Delete", ctx, id).Return(nil)
+
+		assert.NoError(t, service.DeleteProfile(ctx, id))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Delete blocked while in use", func(t *testing.T) {
+		mockRepo := new(MockEncoderRepository)
+		service := encoder.NewService(mockRepo)
+
+		mockRepo.On("FindByID", ctx, id).Return(&encoder.EncoderProfile{ID: id}, nil)
+		mockRepo.On("CountStreams", ctx, id).Return(2, nil)
+
+		err := service.DeleteProfile(ctx, id)
+		assert.ErrorIs(t, err, encoder.ErrProfileInUse)
+		mockRepo.AssertNotCalled(t, "Delete", ctx, id)
+	})
+
+	t.Run("Delete missing profile", func(t *testing.T) {
+		mockRepo := new(MockEncoderRepository)
+		service := encoder.NewService(mockRepo)
+
+		mockRepo.On("FindByID", ctx, id).Return(nil, sql.ErrNoRows)
+
+		err := service.DeleteProfile(ctx, id)
+		assert.ErrorIs(t, err, encoder.ErrProfileNotFound)
+	})
+
+	t.Run("Database failure is not a missing profile", func(t *testing.T) {
+		mockRepo := new(MockEncoderRepository)
+		service := encoder.NewService(mockRepo)
+
+		mockRepo.On("FindByID", ctx, id).Return(nil, sql.ErrConnDone)
+
+		err := service.DeleteProfile(ctx, id)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.NotErrorIs(t, err, encoder.ErrProfileNotFound)
+	})
+}
+
+func TestSaveProfileDTO_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*encoder.SaveProfileDTO)
+		valid  bool
+	}{
+		{"defaults", func(d *encoder.SaveProfileDTO) {}, true},
+		{"missing name", func(d *encoder.SaveProfileDTO) { d.Name = " " }, false},
+		{"crf with x264", func(d *encoder.SaveProfileDTO) { d.RateControl = ffmpeg.RateControlCRF; d.CRF = 23 }, true},
+		{"crf out of range", func(d *encoder.SaveProfileDTO) { d.RateControl = ffmpeg.RateControlCRF; d.CRF = 0 }, false},
+		{"crf with nvenc", func(d *encoder.SaveProfileDTO) {
+			d.VideoCodec, d.Preset, d.Tune = "h264_nvenc", "p4", ""
+			d.RateControl, d.CRF = ffmpeg.RateControlCRF, 23
+		}, false},
+		{"x264 preset on nvenc", func(d *encoder.SaveProfileDTO) { d.VideoCodec, d.Tune = "h264_nvenc", "" }, false},
+		{"baseline with b-frames", func(d *encoder.SaveProfileDTO) { d.Profile, d.BFrames = "baseline", 2 }, false},
+		{"non h264 codec", func(d *encoder.SaveProfileDTO) { d.VideoCodec = "libvpx-vp9" }, false},
+		{"mp3 at 48k", func(d *encoder.SaveProfileDTO) { d.AudioCodec, d.AudioSampleRate = "libmp3lame", 48000 }, false},
+		{"filter graph", func(d *encoder.SaveProfileDTO) { d.Filters = "[0:v]split[a][b]" }, false},
+		{"filter chain", func(d *encoder.SaveProfileDTO) { d.Filters = "eq=contrast=1.1,unsharp" }, true},
+		{"filter reading a file", func(d *encoder.SaveProfileDTO) { d.Filters = "drawtext=textfile=data/app.key" }, false},
+		{"file option on an allowed filter", func(d *encoder.SaveProfileDTO) { d.Filters = "eq=contrast=1.1,unsharp=textfile=x" }, false},
+		{"quoted separator", func(d *encoder.SaveProfileDTO) { d.Filters = "eq=contrast='1\\,subtitles=f.srt'" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dto := encoder.NewSaveProfileDTO()
+			dto.Name = "Profile"
+			tt.mutate(&dto)
+
+			err := dto.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
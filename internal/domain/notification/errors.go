@@ -0,0 +1,13 @@
+package notification
+
+import "errors"
+
+var (
+	ErrRuleNotFound = errors.New("alert rule not found")
+	ErrInvalidRule  = errors.New("invalid alert rule")
+
+	ErrWebhookNotFound   = errors.New("webhook not found")
+	ErrInvalidWebhook    = errors.New("invalid webhook")
+	ErrDeliveryNotFound  = errors.New("webhook delivery not found")
+	ErrDeliveryDelivered = errors.New("webhook delivery already succeeded")
+)
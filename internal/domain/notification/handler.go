@@ -1,7 +1,12 @@
 package notification
 
 import (
+	"errors"
+	"strconv"
+
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
@@ -19,6 +24,18 @@ func (h *Handler) Routes(app *fiber.App) {
 	api.Get("/", h.ApiGetSettings)
 	api.Put("/", h.ApiSaveSettings)
 	api.Post("/test", h.ApiSendTest)
+	api.Get("/events", h.ApiGetEvents)
+	api.Get("/rules", h.ApiGetRules)
+	api.Post("/rules", h.ApiCreateRule)
+	api.Put("/rules/:id", h.ApiUpdateRule)
+	api.Delete("/rules/:id", h.ApiDeleteRule)
+	api.Get("/deliveries", h.ApiGetDeliveries)
+	api.Get("/webhooks", h.ApiGetWebhooks)
+	api.Post("/webhooks", h.ApiCreateWebhook)
+	api.Get("/webhooks/deliveries", h.ApiGetWebhookDeliveries)
+	api.Post("/webhooks/deliveries/:id/replay", h.ApiReplayWebhookDelivery)
+	api.Put("/webhooks/:id", h.ApiUpdateWebhook)
+	api.Delete("/webhooks/:id", h.ApiDeleteWebhook)
 }
 
 func (h *Handler) ApiGetSettings(c *fiber.Ctx) error {
@@ -27,7 +44,7 @@ func (h *Handler) ApiGetSettings(c *fiber.Ctx) error {
 		h.log.Error("failed to get notification settings", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load notification settings"})
 	}
-	return c.JSON(settings)
+	return c.JSON(settings.Masked())
 }
 
 func (h *Handler) ApiSaveSettings(c *fiber.Ctx) error {
@@ -42,7 +59,7 @@ func (h *Handler) ApiSaveSettings(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save notification settings"})
 	}
 
-	return c.JSON(settings)
+	return c.JSON(settings.Masked())
 }
 
 func (h *Handler) ApiSendTest(c *fiber.Ctx) error {
@@ -58,3 +75,171 @@ func (h *Handler) ApiSendTest(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{"message": "test notification sent"})
 }
+
+func (h *Handler) ApiGetEvents(c *fiber.Ctx) error {
+	return c.JSON(events.Types())
+}
+
+func (h *Handler) ApiGetRules(c *fiber.Ctx) error {
+	rules, err := h.svc.ListRules(c.Context())
+	if err != nil {
+		h.log.Error("failed to list alert rules", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load alert rules"})
+	}
+	return c.JSON(rules)
+}
+
+func (h *Handler) ApiCreateRule(c *fiber.Ctx) error {
+	var dto SaveRuleDTO
+	if err := c.BodyParser(&dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	rule, err := h.svc.CreateRule(c.Context(), dto)
+	if err != nil {
+		return h.ruleError(c, err, "failed to create alert rule")
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+func (h *Handler) ApiUpdateRule(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid rule id"})
+	}
+
+	var dto SaveRuleDTO
+	if err := c.BodyParser(&dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	rule, err := h.svc.UpdateRule(c.Context(), id, dto)
+	if err != nil {
+		return h.ruleError(c, err, "failed to update alert rule")
+	}
+	return c.JSON(rule)
+}
+
+func (h *Handler) ApiDeleteRule(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid rule id"})
+	}
+
+	if err := h.svc.DeleteRule(c.Context(), id); err != nil {
+		return h.ruleError(c, err, "failed to delete alert rule")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) ApiGetDeliveries(c *fiber.Ctx) error {
+	deliveries, err := h.svc.ListDeliveries(c.Context())
+	if err != nil {
+		h.log.Error("failed to list alert deliveries", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load alert deliveries"})
+	}
+	return c.JSON(deliveries)
+}
+
+func (h *Handler) ApiGetWebhooks(c *fiber.Ctx) error {
+	hooks, err := h.svc.ListWebhooks(c.Context())
+	if err != nil {
+		h.log.Error("failed to list webhooks", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load webhooks"})
+	}
+	masked := make([]*WebhookEndpoint, len(hooks))
+	for i, hook := range hooks {
+		masked[i] = hook.Masked()
+	}
+	return c.JSON(masked)
+}
+
+func (h *Handler) ApiCreateWebhook(c *fiber.Ctx) error {
+	var dto SaveWebhookDTO
+	if err := c.BodyParser(&dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	hook, err := h.svc.CreateWebhook(c.Context(), dto)
+	if err != nil {
+		return h.webhookError(c, err, "failed to create webhook")
+	}
+	// The generated secret is shown once so the receiver can be configured.
+	return c.Status(fiber.StatusCreated).JSON(hook)
+}
+
+func (h *Handler) ApiUpdateWebhook(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid webhook id"})
+	}
+
+	var dto SaveWebhookDTO
+	if err := c.BodyParser(&dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	hook, err := h.svc.UpdateWebhook(c.Context(), id, dto)
+	if err != nil {
+		return h.webhookError(c, err, "failed to update webhook")
+	}
+	return c.JSON(hook.Masked())
+}
+
+func (h *Handler) ApiDeleteWebhook(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid webhook id"})
+	}
+
+	if err := h.svc.DeleteWebhook(c.Context(), id); err != nil {
+		return h.webhookError(c, err, "failed to delete webhook")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) ApiGetWebhookDeliveries(c *fiber.Ctx) error {
+	deliveries, err := h.svc.ListWebhookDeliveries(c.Context())
+	if err != nil {
+		h.log.Error("failed to list webhook deliveries", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load webhook deliveries"})
+	}
+	return c.JSON(deliveries)
+}
+
+func (h *Handler) ApiReplayWebhookDelivery(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid delivery id"})
+	}
+
+	delivery, err := h.svc.ReplayDelivery(c.Context(), id)
+	if err != nil {
+		return h.webhookError(c, err, "failed to replay webhook delivery")
+	}
+	return c.JSON(delivery)
+}
+
+func (h *Handler) webhookError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, ErrInvalidWebhook), errors.Is(err, ErrDeliveryDelivered):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrWebhookNotFound), errors.Is(err, ErrDeliveryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	default:
+		h.log.Error(message, zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
+
+func (h *Handler) ruleError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, ErrInvalidRule):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ErrRuleNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	default:
+		h.log.Error(message, zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+	}
+}
@@ -1,15 +1,50 @@
 package notification
 
-import "context"
+import (
+	"context"
+
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
+	"github.com/google/uuid"
+)
 
 type Repository interface {
 	Get(ctx context.Context) (*Settings, error)
 	Create(ctx context.Context, s *Settings) error
 	Update(ctx context.Context, s *Settings) error
+	ListRules(ctx context.Context) ([]*AlertRule, error)
+	GetRule(ctx context.Context, id uuid.UUID) (*AlertRule, error)
+	CreateRule(ctx context.Context, rule *AlertRule) error
+	UpdateRule(ctx context.Context, rule *AlertRule) error
+	DeleteRule(ctx context.Context, id uuid.UUID) error
+	AddDelivery(ctx context.Context, d *AlertDelivery) error
+	ListDeliveries(ctx context.Context, limit int) ([]*AlertDelivery, error)
+	ListWebhooks(ctx context.Context) ([]*WebhookEndpoint, error)
+	GetWebhook(ctx context.Context, id uuid.UUID) (*WebhookEndpoint, error)
+	CreateWebhook(ctx context.Context, hook *WebhookEndpoint) error
+	UpdateWebhook(ctx context.Context, hook *WebhookEndpoint) error
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	AddWebhookDelivery(ctx context.Context, d *WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, id int64) (*WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, limit int) ([]*WebhookDelivery, error)
 }
 
 type Service interface {
 	GetSettings(ctx context.Context) (*Settings, error)
 	SaveSettings(ctx context.Context, dto SaveSettingsDTO) (*Settings, error)
 	SendTest(ctx context.Context, message string) error
+	ListRules(ctx context.Context) ([]*AlertRule, error)
+	CreateRule(ctx context.Context, dto SaveRuleDTO) (*AlertRule, error)
+	UpdateRule(ctx context.Context, id uuid.UUID, dto SaveRuleDTO) (*AlertRule, error)
+	DeleteRule(ctx context.Context, id uuid.UUID) error
+	ListDeliveries(ctx context.Context) ([]*AlertDelivery, error)
+	ListWebhooks(ctx context.Context) ([]*WebhookEndpoint, error)
+	CreateWebhook(ctx context.Context, dto SaveWebhookDTO) (*WebhookEndpoint, error)
+	UpdateWebhook(ctx context.Context, id uuid.UUID, dto SaveWebhookDTO) (*WebhookEndpoint, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	ListWebhookDeliveries(ctx context.Context) ([]*WebhookDelivery, error)
+	// ReplayDelivery sends the payload of a failed delivery again.
+	ReplayDelivery(ctx context.Context, id int64) (*WebhookDelivery, error)
+	// HandleEvent delivers the event to the channels of every rule that
+	// covers it and is not cooling down, and to the subscribed webhooks.
+	HandleEvent(e events.Event)
 }
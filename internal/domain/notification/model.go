@@ -1,8 +1,14 @@
 package notification
 
 import (
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
+	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 )
 
@@ -14,6 +20,22 @@ type Settings struct {
 	TelegramBotToken string    `bun:",type:text" json:"telegram_bot_token"`
 	TelegramChatID   string    `bun:",type:text" json:"telegram_chat_id"`
 	UpdatedAt        time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	// Webhooks are stored on their own and managed through their own
+	// endpoints; they are listed here for a complete view.
+	Webhooks []*WebhookEndpoint `bun:"-" json:"webhooks"`
+}
+
+// Masked is a copy of the settings safe to send to the browser, with the
+// credentials hidden.
+func (s *Settings) Masked() *Settings {
+	masked := *s
+	masked.DiscordWebhook = secret.Mask(s.DiscordWebhook)
+	masked.TelegramBotToken = secret.Mask(s.TelegramBotToken)
+	masked.Webhooks = make([]*WebhookEndpoint, len(s.Webhooks))
+	for i, hook := range s.Webhooks {
+		masked.Webhooks[i] = hook.Masked()
+	}
+	return &masked
 }
 
 type SaveSettingsDTO struct {
@@ -21,3 +43,165 @@ type SaveSettingsDTO struct {
 	TelegramBotToken string `json:"telegram_bot_token"`
 	TelegramChatID   string `json:"telegram_chat_id"`
 }
+
+// Channels an alert can be delivered to.
+const (
+	ChannelDiscord  = "discord"
+	ChannelTelegram = "telegram"
+)
+
+// Delivery outcomes.
+const (
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+)
+
+// AlertRule sends the chosen stream events to a channel. A rule without a
+// stream covers every stream. After an alert, the same rule stays quiet for
+// the cooldown on that stream.
+type AlertRule struct {
+	bun.BaseModel `bun:"table:alert_rules,alias:ar"`
+
+	ID          uuid.UUID  `bun:",pk,type:text" json:"id"`
+	Name        string     `bun:",notnull" json:"name"`
+	Events      []string   `bun:",type:json" json:"events"`
+	Channel     string     `bun:",notnull" json:"channel"`
+	StreamID    *uuid.UUID `bun:",type:text" json:"stream_id"`
+	CooldownSec int        `bun:",notnull,default:300" json:"cooldown_sec"`
+	Enabled     bool       `bun:",notnull,default:true" json:"enabled"`
+	CreatedAt   time.Time  `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt   time.Time  `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Matches reports whether the rule covers the event.
+func (r *AlertRule) Matches(e events.Event) bool {
+	if !r.Enabled {
+		return false
+	}
+	if r.StreamID != nil && r.StreamID.String() != e.StreamID {
+		return false
+	}
+	for _, t := range r.Events {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// AlertDelivery records one attempt to deliver an alert.
+type AlertDelivery struct {
+	bun.BaseModel `bun:"table:alert_deliveries,alias:ad"`
+
+	ID        int64     `bun:",pk,autoincrement" json:"id"`
+	RuleID    uuid.UUID `bun:",notnull,type:text" json:"rule_id"`
+	Event     string    `bun:",notnull" json:"event"`
+	StreamID  string    `json:"stream_id"`
+	Channel   string    `bun:",notnull" json:"channel"`
+	Message   string    `json:"message"`
+	Status    string    `bun:",notnull" json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+type SaveRuleDTO struct {
+	Name        string     `json:"name"`
+	Events      []string   `json:"events"`
+	Channel     string     `json:"channel"`
+	StreamID    *uuid.UUID `json:"stream_id"`
+	CooldownSec *int       `json:"cooldown_sec"`
+	Enabled     *bool      `json:"enabled"`
+}
+
+func (d SaveRuleDTO) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	if len(d.Events) == 0 {
+		return fmt.Errorf("%w: choose at least one event", ErrInvalidRule)
+	}
+	for _, e := range d.Events {
+		if !events.Known(e) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidRule, e)
+		}
+	}
+	if d.Channel != ChannelDiscord && d.Channel != ChannelTelegram {
+		return fmt.Errorf("%w: channel must be %s or %s", ErrInvalidRule, ChannelDiscord, ChannelTelegram)
+	}
+	if d.CooldownSec != nil && (*d.CooldownSec < 0 || *d.CooldownSec > 86400) {
+		return fmt.Errorf("%w: cooldown_sec must be between 0 and 86400", ErrInvalidRule)
+	}
+	return nil
+}
+
+// WebhookEndpoint receives the chosen events as signed JSON.
+type WebhookEndpoint struct {
+	bun.BaseModel `bun:"table:webhook_endpoints,alias:we"`
+
+	ID        uuid.UUID `bun:",pk,type:text" json:"id"`
+	URL       string    `bun:",notnull" json:"url"`
+	Secret    string    `bun:",notnull" json:"secret"`
+	Events    []string  `bun:",type:json" json:"events"`
+	Enabled   bool      `bun:",notnull,default:true" json:"enabled"`
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Masked is a copy of the endpoint with its signing secret hidden.
+func (w *WebhookEndpoint) Masked() *WebhookEndpoint {
+	masked := *w
+	masked.Secret = secret.Mask(w.Secret)
+	return &masked
+}
+
+func (w *WebhookEndpoint) Subscribes(eventType string) bool {
+	if !w.Enabled {
+		return false
+	}
+	for _, t := range w.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records the delivery of one payload to an endpoint, over
+// all its attempts. A replay is recorded as a new delivery.
+type WebhookDelivery struct {
+	bun.BaseModel `bun:"table:webhook_deliveries,alias:wd"`
+
+	ID         int64     `bun:",pk,autoincrement" json:"id"`
+	WebhookID  uuid.UUID `bun:",notnull,type:text" json:"webhook_id"`
+	Event      string    `bun:",notnull" json:"event"`
+	Payload    string    `bun:",notnull" json:"payload"`
+	Status     string    `bun:",notnull" json:"status"`
+	StatusCode int       `json:"status_code"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error,omitempty"`
+	ReplayOf   *int64    `json:"replay_of,omitempty"`
+	CreatedAt  time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+type SaveWebhookDTO struct {
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled"`
+}
+
+func (d SaveWebhookDTO) Validate() error {
+	u, err := url.Parse(strings.TrimSpace(d.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an http or https URL", ErrInvalidWebhook)
+	}
+	if len(d.Events) == 0 {
+		return fmt.Errorf("%w: choose at least one event", ErrInvalidWebhook)
+	}
+	for _, e := range d.Events {
+		if !events.Known(e) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+	}
+	return nil
+}
@@ -6,30 +6,47 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
+	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 )
 
+// deliveriesKept bounds the delivery log.
+const deliveriesKept = 1000
+
+// repository keeps the Discord webhook, the Telegram bot token and webhook
+// secrets encrypted at rest; callers only see plaintext.
 type repository struct {
-	db *bun.DB
+	db     *bun.DB
+	cipher *secret.Cipher
 }
 
-func NewRepository(db *bun.DB) Repository {
-	return &repository{db: db}
+func NewRepository(db *bun.DB, cipher *secret.Cipher) Repository {
+	return &repository{db: db, cipher: cipher}
 }
 
 func (r *repository) Get(ctx context.Context) (*Settings, error) {
 	var s Settings
 	err := r.db.NewSelect().Model(&s).Order("id ASC").Limit(1).Scan(ctx)
-	if err == nil {
-		return &s, nil
-	}
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
-	return nil, fmt.Errorf("query notification settings: %w", err)
+	if err != nil {
+		return nil, fmt.Errorf("query notification settings: %w", err)
+	}
+	if err := r.cipher.OpenFields(&s.DiscordWebhook, &s.TelegramBotToken); err != nil {
+		return nil, fmt.Errorf("decrypt notification settings: %w", err)
+	}
+	return &s, nil
 }
 
 func (r *repository) Create(ctx context.Context, s *Settings) error {
+	restore, err := r.cipher.SealFields(&s.DiscordWebhook, &s.TelegramBotToken)
+	if err != nil {
+		return fmt.Errorf("encrypt notification settings: %w", err)
+	}
+	defer restore()
+
 	if _, err := r.db.NewInsert().Model(s).Exec(ctx); err != nil {
 		return fmt.Errorf("insert notification settings: %w", err)
 	}
@@ -37,8 +54,174 @@ func (r *repository) Create(ctx context.Context, s *Settings) error {
 }
 
 func (r *repository) Update(ctx context.Context, s *Settings) error {
+	restore, err := r.cipher.SealFields(&s.DiscordWebhook, &s.TelegramBotToken)
+	if err != nil {
+		return fmt.Errorf("encrypt notification settings: %w", err)
+	}
+	defer restore()
+
 	if _, err := r.db.NewUpdate().Model(s).WherePK().Exec(ctx); err != nil {
 		return fmt.Errorf("update notification settings: %w", err)
 	}
 	return nil
 }
+
+func (r *repository) ListRules(ctx context.Context) ([]*AlertRule, error) {
+	rules := make([]*AlertRule, 0)
+	if err := r.db.NewSelect().Model(&rules).Order("created_at ASC").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("query alert rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *repository) GetRule(ctx context.Context, id uuid.UUID) (*AlertRule, error) {
+	rule := new(AlertRule)
+	err := r.db.NewSelect().Model(rule).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query alert rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (r *repository) CreateRule(ctx context.Context, rule *AlertRule) error {
+	if _, err := r.db.NewInsert().Model(rule).Exec(ctx); err != nil {
+		return fmt.Errorf("insert alert rule: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) UpdateRule(ctx context.Context, rule *AlertRule) error {
+	if _, err := r.db.NewUpdate().Model(rule).WherePK().Exec(ctx); err != nil {
+		return fmt.Errorf("update alert rule: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.NewDelete().Model((*AlertRule)(nil)).Where("id = ?", id).Exec(ctx); err != nil {
+		return fmt.Errorf("delete alert rule: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) AddDelivery(ctx context.Context, d *AlertDelivery) error {
+	if _, err := r.db.NewInsert().Model(d).Exec(ctx); err != nil {
+		return fmt.Errorf("insert alert delivery: %w", err)
+	}
+
+	_, err := r.db.NewRaw(
+		"DELETE FROM alert_deliveries WHERE id NOT IN (SELECT id FROM alert_deliveries ORDER BY id DESC LIMIT ?)",
+		deliveriesKept,
+	).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("prune alert deliveries: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) ListDeliveries(ctx context.Context, limit int) ([]*AlertDelivery, error) {
+	deliveries := make([]*AlertDelivery, 0, limit)
+	if err := r.db.NewSelect().Model(&deliveries).Order("id DESC").Limit(limit).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("query alert deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *repository) ListWebhooks(ctx context.Context) ([]*WebhookEndpoint, error) {
+	hooks := make([]*WebhookEndpoint, 0)
+	if err := r.db.NewSelect().Model(&hooks).Order("created_at ASC").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("query webhooks: %w", err)
+	}
+	for _, hook := range hooks {
+		if err := r.cipher.OpenFields(&hook.Secret); err != nil {
+			return nil, fmt.Errorf("decrypt webhook secret: %w", err)
+		}
+	}
+	return hooks, nil
+}
+
+func (r *repository) GetWebhook(ctx context.Context, id uuid.UUID) (*WebhookEndpoint, error) {
+	hook := new(WebhookEndpoint)
+	err := r.db.NewSelect().Model(hook).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query webhook: %w", err)
+	}
+	if err := r.cipher.OpenFields(&hook.Secret); err != nil {
+		return nil, fmt.Errorf("decrypt webhook secret: %w", err)
+	}
+	return hook, nil
+}
+
+func (r *repository) CreateWebhook(ctx context.Context, hook *WebhookEndpoint) error {
+	restore, err := r.cipher.SealFields(&hook.Secret)
+	if err != nil {
+		return fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+	defer restore()
+
+	if _, err := r.db.NewInsert().Model(hook).Exec(ctx); err != nil {
+		return fmt.Errorf("insert webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) UpdateWebhook(ctx context.Context, hook *WebhookEndpoint) error {
+	restore, err := r.cipher.SealFields(&hook.Secret)
+	if err != nil {
+		return fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+	defer restore()
+
+	if _, err := r.db.NewUpdate().Model(hook).WherePK().Exec(ctx); err != nil {
+		return fmt.Errorf("update webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.NewDelete().Model((*WebhookEndpoint)(nil)).Where("id = ?", id).Exec(ctx); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) AddWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	if _, err := r.db.NewInsert().Model(d).Exec(ctx); err != nil {
+		return fmt.Errorf("insert webhook delivery: %w", err)
+	}
+
+	_, err := r.db.NewRaw(
+		"DELETE FROM webhook_deliveries WHERE id NOT IN (SELECT id FROM webhook_deliveries ORDER BY id DESC LIMIT ?)",
+		deliveriesKept,
+	).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("prune webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) GetWebhookDelivery(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	d := new(WebhookDelivery)
+	err := r.db.NewSelect().Model(d).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+func (r *repository) ListWebhookDeliveries(ctx context.Context, limit int) ([]*WebhookDelivery, error) {
+	deliveries := make([]*WebhookDelivery, 0, limit)
+	if err := r.db.NewSelect().Model(&deliveries).Order("id DESC").Limit(limit).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("query webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
@@ -9,20 +9,33 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type service struct {
 	repo   Repository
 	client *http.Client
+	log    *zap.Logger
+	// quietUntil is when each rule's cooldown ends for each stream. Entries
+	// are dropped once it has passed.
+	quietUntil map[string]time.Time
+	mu         sync.Mutex
 }
 
-func NewService(repo Repository) Service {
+func NewService(repo Repository, log *zap.Logger) Service {
 	return &service{
 		repo: repo,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		log:        log,
+		quietUntil: make(map[string]time.Time),
 	}
 }
 
@@ -32,7 +45,10 @@ func (s *service) GetSettings(ctx context.Context) (*Settings, error) {
 		return nil, err
 	}
 	if settings == nil {
-		return &Settings{}, nil
+		settings = &Settings{}
+	}
+	if settings.Webhooks, err = s.repo.ListWebhooks(ctx); err != nil {
+		return nil, err
 	}
 	return settings, nil
 }
@@ -47,8 +63,8 @@ func (s *service) SaveSettings(ctx context.Context, dto SaveSettingsDTO) (*Setti
 		settings = &Settings{}
 	}
 
-	settings.DiscordWebhook = strings.TrimSpace(dto.DiscordWebhook)
-	settings.TelegramBotToken = strings.TrimSpace(dto.TelegramBotToken)
+	settings.DiscordWebhook = secret.Unmask(strings.TrimSpace(dto.DiscordWebhook), settings.DiscordWebhook)
+	settings.TelegramBotToken = secret.Unmask(strings.TrimSpace(dto.TelegramBotToken), settings.TelegramBotToken)
 	settings.TelegramChatID = strings.TrimSpace(dto.TelegramChatID)
 
 	if settings.ID == 0 {
@@ -144,3 +160,161 @@ func (s *service) sendTelegram(ctx context.Context, botToken, chatID, message st
 
 	return nil
 }
+
+func (s *service) ListRules(ctx context.Context) ([]*AlertRule, error) {
+	return s.repo.ListRules(ctx)
+}
+
+func (s *service) CreateRule(ctx context.Context, dto SaveRuleDTO) (*AlertRule, error) {
+	if err := dto.Validate(); err != nil {
+		return nil, err
+	}
+
+	rule := &AlertRule{ID: uuid.New(), CooldownSec: 300, Enabled: true}
+	dto.apply(rule)
+	if err := s.repo.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *service) UpdateRule(ctx context.Context, id uuid.UUID, dto SaveRuleDTO) (*AlertRule, error) {
+	if err := dto.Validate(); err != nil {
+		return nil, err
+	}
+
+	rule, err := s.repo.GetRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dto.apply(rule)
+	rule.UpdatedAt = time.Now().UTC()
+	if err := s.repo.UpdateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (d SaveRuleDTO) apply(rule *AlertRule) {
+	rule.Name = strings.TrimSpace(d.Name)
+	rule.Events = d.Events
+	rule.Channel = d.Channel
+	rule.StreamID = d.StreamID
+	if d.CooldownSec != nil {
+		rule.CooldownSec = *d.CooldownSec
+	}
+	if d.Enabled != nil {
+		rule.Enabled = *d.Enabled
+	}
+}
+
+func (s *service) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.repo.GetRule(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteRule(ctx, id)
+}
+
+func (s *service) ListDeliveries(ctx context.Context) ([]*AlertDelivery, error) {
+	return s.repo.ListDeliveries(ctx, 100)
+}
+
+func (s *service) HandleEvent(e events.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.deliverWebhooks(ctx, e)
+	}()
+	s.alert(ctx, e)
+	wg.Wait()
+}
+
+func (s *service) alert(ctx context.Context, e events.Event) {
+	rules, err := s.repo.ListRules(ctx)
+	if err != nil {
+		s.log.Warn("failed to load alert rules", zap.String("event", e.Type), zap.Error(err))
+		return
+	}
+
+	var settings *Settings
+	for _, rule := range rules {
+		if !rule.Matches(e) || !s.takeCooldown(rule, e.StreamID) {
+			continue
+		}
+		if settings == nil {
+			if settings, err = s.GetSettings(ctx); err != nil {
+				s.log.Warn("failed to load notification settings", zap.Error(err))
+				return
+			}
+		}
+
+		message := alertMessage(e)
+		delivery := &AlertDelivery{
+			RuleID:   rule.ID,
+			Event:    e.Type,
+			StreamID: e.StreamID,
+			Channel:  rule.Channel,
+			Message:  message,
+			Status:   DeliverySent,
+		}
+		if err := s.send(ctx, settings, rule.Channel, message); err != nil {
+			delivery.Status = DeliveryFailed
+			delivery.Error = err.Error()
+		}
+		if err := s.repo.AddDelivery(ctx, delivery); err != nil {
+			s.log.Warn("failed to record alert delivery", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+		}
+	}
+}
+
+// takeCooldown reports whether the rule may alert for the stream now, and if
+// so starts its cooldown.
+func (s *service) takeCooldown(rule *AlertRule, streamID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, until := range s.quietUntil {
+		if !now.Before(until) {
+			delete(s.quietUntil, key)
+		}
+	}
+
+	key := rule.ID.String() + "/" + streamID
+	if _, ok := s.quietUntil[key]; ok {
+		return false
+	}
+	if rule.CooldownSec > 0 {
+		s.quietUntil[key] = now.Add(time.Duration(rule.CooldownSec) * time.Second)
+	}
+	return true
+}
+
+func (s *service) send(ctx context.Context, settings *Settings, channel, message string) error {
+	switch channel {
+	case ChannelDiscord:
+		if settings.DiscordWebhook == "" {
+			return fmt.Errorf("discord webhook is not configured")
+		}
+		return s.sendDiscord(ctx, settings.DiscordWebhook, message)
+	case ChannelTelegram:
+		if settings.TelegramBotToken == "" || settings.TelegramChatID == "" {
+			return fmt.Errorf("telegram bot is not configured")
+		}
+		return s.sendTelegram(ctx, settings.TelegramBotToken, settings.TelegramChatID, message)
+	default:
+		return fmt.Errorf("unsupported channel %q", channel)
+	}
+}
+
+func alertMessage(e events.Event) string {
+	name := e.StreamName
+	if name == "" {
+		name = e.StreamID
+	}
+	return fmt.Sprintf("[GoStreamix] %s — %s: %s", name, e.Type, e.Message)
+}
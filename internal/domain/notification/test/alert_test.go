@@ -0,0 +1,182 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/notification"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
+	_ "github.com/glebarez/go-sqlite"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"go.uber.org/zap"
+)
+
+func setupTestDB(t *testing.T) *bun.DB {
+	sqldb, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqldb.SetMaxOpenConns(1)
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+
+	ctx := context.Background()
+	models := []interface{}{
+		(*notification.Settings)(nil), (*notification.AlertRule)(nil), (*notification.AlertDelivery)(nil),
+		(*notification.WebhookEndpoint)(nil), (*notification.WebhookDelivery)(nil),
+	}
+	for _, m := range models {
+		if _, err := db.NewCreateTable().Model(m).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func newTestRepository(t *testing.T, db *bun.DB) notification.Repository {
+	cipher, err := secret.New("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notification.NewRepository(db, cipher)
+}
+
+// discordServer records the messages posted to a fake Discord webhook.
+type discordServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []string
+}
+
+func newDiscordServer(t *testing.T) *discordServer {
+	d := &discordServer{}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Content string `json:"content"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		d.mu.Lock()
+		d.messages = append(d.messages, body.Content)
+		d.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(d.Close)
+	return d
+}
+
+func TestAlertRules(t *testing.T) {
+	db := setupTestDB(t)
+	svc := notification.NewService(newTestRepository(t, db), zap.NewNop())
+	ctx := context.Background()
+	discord := newDiscordServer(t)
+
+	_, err := svc.SaveSettings(ctx, notification.SaveSettingsDTO{DiscordWebhook: discord.URL})
+	assert.NoError(t, err)
+
+	streamID := uuid.New()
+	crash, err := svc.CreateRule(ctx, notification.SaveRuleDTO{
+		Name:    "Crashes",
+		Events:  []string{events.StreamError, events.DestinationFailed},
+		Channel: notification.ChannelDiscord,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 300, crash.CooldownSec)
+	assert.True(t, crash.Enabled)
+
+	_, err = svc.CreateRule(ctx, notification.SaveRuleDTO{
+		Name:     "Telegram for another stream",
+		Events:   []string{events.StreamError},
+		Channel:  notification.ChannelTelegram,
+		StreamID: &[]uuid.UUID{uuid.New()}[0],
+	})
+	assert.NoError(t, err)
+
+	t.Run("Invalid rules are rejected", func(t *testing.T) {
+		_, err := svc.CreateRule(ctx, notification.SaveRuleDTO{Name: "x", Events: []string{"stream.exploded"}, Channel: notification.ChannelDiscord})
+		assert.ErrorIs(t, err, notification.ErrInvalidRule)
+		_, err = svc.CreateRule(ctx, notification.SaveRuleDTO{Name: "x", Events: []string{events.StreamError}, Channel: "sms"})
+		assert.ErrorIs(t, err, notification.ErrInvalidRule)
+	})
+
+	t.Run("Matching events are delivered once per cooldown", func(t *testing.T) {
+		event := events.Event{Type: events.StreamError, StreamID: streamID.String(), StreamName: "Lofi", Message: "ffmpeg exited"}
+		svc.HandleEvent(event)
+		svc.HandleEvent(event)
+		svc.HandleEvent(events.Event{Type: events.StreamStarted, StreamID: streamID.String(), StreamName: "Lofi"})
+
+		assert.Equal(t, []string{"[GoStreamix] Lofi — stream.error: ffmpeg exited"}, discord.messages)
+
+		deliveries, err := svc.ListDeliveries(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+		assert.Equal(t, notification.DeliverySent, deliveries[0].Status)
+		assert.Equal(t, crash.ID, deliveries[0].RuleID)
+	})
+
+	t.Run("Failed deliveries are recorded", func(t *testing.T) {
+		zero := 0
+		_, err := svc.CreateRule(ctx, notification.SaveRuleDTO{
+			Name:        "Slow",
+			Events:      []string{events.StreamSlow},
+			Channel:     notification.ChannelTelegram,
+			CooldownSec: &zero,
+		})
+		assert.NoError(t, err)
+
+		svc.HandleEvent(events.Event{Type: events.StreamSlow, StreamID: streamID.String(), Message: "0.8x"})
+
+		deliveries, err := svc.ListDeliveries(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, notification.DeliveryFailed, deliveries[0].Status)
+		assert.Equal(t, "telegram bot is not configured", deliveries[0].Error)
+	})
+
+	t.Run("Rules alert again once the cooldown has passed", func(t *testing.T) {
+		one := 1
+		_, err := svc.CreateRule(ctx, notification.SaveRuleDTO{
+			Name:        "Stops",
+			Events:      []string{events.StreamStopped},
+			Channel:     notification.ChannelDiscord,
+			CooldownSec: &one,
+		})
+		assert.NoError(t, err)
+
+		event := events.Event{Type: events.StreamStopped, StreamID: streamID.String(), StreamName: "Lofi"}
+		svc.HandleEvent(event)
+		svc.HandleEvent(event)
+		time.Sleep(1100 * time.Millisecond)
+		svc.HandleEvent(event)
+
+		discord.mu.Lock()
+		defer discord.mu.Unlock()
+		assert.Len(t, discord.messages, 3)
+	})
+
+	t.Run("Disabled rules stay quiet", func(t *testing.T) {
+		disabled := false
+		_, err := svc.UpdateRule(ctx, crash.ID, notification.SaveRuleDTO{
+			Name:    crash.Name,
+			Events:  []string{events.DestinationFailed},
+			Channel: notification.ChannelDiscord,
+			Enabled: &disabled,
+		})
+		assert.NoError(t, err)
+
+		sent := len(discord.messages)
+		svc.HandleEvent(events.Event{Type: events.DestinationFailed, StreamID: uuid.NewString()})
+		assert.Len(t, discord.messages, sent)
+	})
+
+	t.Run("Unknown rules are not found", func(t *testing.T) {
+		assert.ErrorIs(t, svc.DeleteRule(ctx, uuid.New()), notification.ErrRuleNotFound)
+	})
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/notification"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestSettingsSecrets(t *testing.T) {
+	db := setupTestDB(t)
+	svc := notification.NewService(newTestRepository(t, db), zap.NewNop())
+	ctx := context.Background()
+
+	const token = "123456:telegram-bot-token"
+	saved, err := svc.SaveSettings(ctx, notification.SaveSettingsDTO{
+		DiscordWebhook:   "https://discord.com/api/webhooks/1/abcdef",
+		TelegramBotToken: token,
+		TelegramChatID:   "42",
+	})
+	assert.NoError(t, err)
+	_, err = svc.CreateWebhook(ctx, notification.SaveWebhookDTO{URL: "https://example.com/hook", Secret: "hook-secret", Events: []string{"stream.error"}})
+	assert.NoError(t, err)
+
+	t.Run("Credentials are sealed at rest", func(t *testing.T) {
+		var stored struct {
+			DiscordWebhook   string `bun:"discord_webhook"`
+			TelegramBotToken string `bun:"telegram_bot_token"`
+		}
+		assert.NoError(t, db.NewSelect().Table("notification_settings").Column("discord_webhook", "telegram_bot_token").Scan(ctx, &stored))
+		assert.True(t, secret.IsSealed(stored.DiscordWebhook))
+		assert.True(t, secret.IsSealed(stored.TelegramBotToken))
+
+		var hookSecret string
+		assert.NoError(t, db.NewSelect().Table("webhook_endpoints").Column("secret").Scan(ctx, &hookSecret))
+		assert.True(t, secret.IsSealed(hookSecret))
+
+		settings, err := svc.GetSettings(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, token, settings.TelegramBotToken)
+		assert.Equal(t, "hook-secret", settings.Webhooks[0].Secret)
+	})
+
+	t.Run("Masked values are hidden and saved back unchanged", func(t *testing.T) {
+		masked := saved.Masked()
+		assert.Equal(t, "********oken", masked.TelegramBotToken)
+		assert.Equal(t, "********cdef", masked.DiscordWebhook)
+		assert.Equal(t, "42", masked.TelegramChatID)
+		assert.Equal(t, token, saved.TelegramBotToken)
+
+		_, err := svc.SaveSettings(ctx, notification.SaveSettingsDTO{
+			DiscordWebhook:   masked.DiscordWebhook,
+			TelegramBotToken: masked.TelegramBotToken,
+			TelegramChatID:   "43",
+		})
+		assert.NoError(t, err)
+
+		settings, err := svc.GetSettings(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, token, settings.TelegramBotToken)
+		assert.Equal(t, "https://discord.com/api/webhooks/1/abcdef", settings.DiscordWebhook)
+		assert.Equal(t, "43", settings.TelegramChatID)
+		assert.Equal(t, "********cret", settings.Masked().Webhooks[0].Secret)
+	})
+
+	t.Run("A masked webhook secret keeps the current one", func(t *testing.T) {
+		hooks, err := svc.ListWebhooks(ctx)
+		assert.NoError(t, err)
+		hook := hooks[0]
+
+		updated, err := svc.UpdateWebhook(ctx, hook.ID, notification.SaveWebhookDTO{URL: hook.URL, Secret: hook.Masked().Secret, Events: hook.Events})
+		assert.NoError(t, err)
+		assert.Equal(t, "hook-secret", updated.Secret)
+	})
+}
@@ -0,0 +1,199 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/notification"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// receiver is a webhook endpoint that answers with the queued status codes,
+// then 200, and records the requests whose signature checks out.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	codes    []int
+	requests int
+	verified []events.Event
+}
+
+func newReceiver(t *testing.T, secret string, codes ...int) *receiver {
+	rc := &receiver{codes: codes}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		rc.requests++
+		if len(rc.codes) > 0 {
+			code := rc.codes[0]
+			rc.codes = rc.codes[1:]
+			w.WriteHeader(code)
+			return
+		}
+		if !notification.Verify(secret, r.Header.Get(notification.TimestampHeader), r.Header.Get(notification.SignatureHeader), body, time.Now()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var e events.Event
+		_ = json.Unmarshal(body, &e)
+		assert.Equal(t, e.Type, r.Header.Get(notification.EventHeader))
+		rc.verified = append(rc.verified, e)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) counts() (int, int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.requests, len(rc.verified)
+}
+
+func TestSaveWebhookDTO_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		dto   notification.SaveWebhookDTO
+		valid bool
+	}{
+		{"valid", notification.SaveWebhookDTO{URL: "https://example.com/hook", Events: []string{events.StreamStarted}}, true},
+		{"not http", notification.SaveWebhookDTO{URL: "ftp://example.com", Events: []string{events.StreamStarted}}, false},
+		{"no host", notification.SaveWebhookDTO{URL: "http://", Events: []string{events.StreamStarted}}, false},
+		{"no events", notification.SaveWebhookDTO{URL: "https://example.com/hook"}, false},
+		{"unknown event", notification.SaveWebhookDTO{URL: "https://example.com/hook", Events: []string{"stream.exploded"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.dto.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, notification.ErrInvalidWebhook)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"stream.error"}`)
+	now := time.Unix(1_700_000_000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := notification.Sign("s3cret", timestamp, body)
+
+	assert.True(t, notification.Verify("s3cret", timestamp, signature, body, now))
+	assert.True(t, notification.Verify("s3cret", timestamp, signature, body, now.Add(notification.SignatureTolerance)))
+
+	t.Run("The timestamp is part of the signature", func(t *testing.T) {
+		other := strconv.FormatInt(now.Unix()+1, 10)
+		assert.False(t, notification.Verify("s3cret", other, signature, body, now))
+		assert.NotEqual(t, signature, notification.Sign("s3cret", other, body))
+	})
+
+	t.Run("Stale and future timestamps are refused", func(t *testing.T) {
+		assert.False(t, notification.Verify("s3cret", timestamp, signature, body, now.Add(notification.SignatureTolerance+time.Second)))
+		assert.False(t, notification.Verify("s3cret", timestamp, signature, body, now.Add(-notification.SignatureTolerance-time.Second)))
+	})
+
+	t.Run("Tampering is refused", func(t *testing.T) {
+		assert.False(t, notification.Verify("s3cret", timestamp, signature, []byte(`{"type":"stream.started"}`), now))
+		assert.False(t, notification.Verify("other", timestamp, signature, body, now))
+		assert.False(t, notification.Verify("s3cret", "", signature, body, now))
+		assert.False(t, notification.Verify("s3cret", "soon", signature, body, now))
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	db := setupTestDB(t)
+	svc := notification.NewService(newTestRepository(t, db), zap.NewNop())
+	ctx := context.Background()
+	event := events.Event{Type: events.StreamError, StreamID: "abc", StreamName: "Lofi", Message: "ffmpeg exited"}
+
+	t.Run("Payloads are signed and listed in settings", func(t *testing.T) {
+		rc := newReceiver(t, "s3cret")
+		hook, err := svc.CreateWebhook(ctx, notification.SaveWebhookDTO{URL: rc.URL, Secret: "s3cret", Events: []string{events.StreamError}})
+		assert.NoError(t, err)
+
+		svc.HandleEvent(event)
+		svc.HandleEvent(events.Event{Type: events.StreamStarted, StreamID: "abc"})
+
+		requests, verified := rc.counts()
+		assert.Equal(t, 1, requests)
+		assert.Equal(t, 1, verified)
+		assert.Equal(t, "ffmpeg exited", rc.verified[0].Message)
+
+		settings, err := svc.GetSettings(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, settings.Webhooks, 1)
+
+		assert.NoError(t, svc.DeleteWebhook(ctx, hook.ID))
+	})
+
+	t.Run("Server errors are retried", func(t *testing.T) {
+		rc := newReceiver(t, "s3cret", http.StatusInternalServerError, http.StatusBadGateway)
+		hook, err := svc.CreateWebhook(ctx, notification.SaveWebhookDTO{URL: rc.URL, Secret: "s3cret", Events: []string{events.StreamError}})
+		assert.NoError(t, err)
+
+		svc.HandleEvent(event)
+
+		requests, verified := rc.counts()
+		assert.Equal(t, 3, requests)
+		assert.Equal(t, 1, verified)
+
+		deliveries, err := svc.ListWebhookDeliveries(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, notification.DeliverySent, deliveries[0].Status)
+		assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+		assert.Equal(t, 3, deliveries[0].Attempts)
+
+		assert.NoError(t, svc.DeleteWebhook(ctx, hook.ID))
+	})
+
+	t.Run("Rejected deliveries fail at once and can be replayed", func(t *testing.T) {
+		rc := newReceiver(t, "s3cret", http.StatusBadRequest)
+		_, err := svc.CreateWebhook(ctx, notification.SaveWebhookDTO{URL: rc.URL, Secret: "s3cret", Events: []string{events.StreamError}})
+		assert.NoError(t, err)
+
+		svc.HandleEvent(event)
+
+		deliveries, err := svc.ListWebhookDeliveries(ctx)
+		assert.NoError(t, err)
+		failed := deliveries[0]
+		assert.Equal(t, notification.DeliveryFailed, failed.Status)
+		assert.Equal(t, http.StatusBadRequest, failed.StatusCode)
+		assert.Equal(t, 1, failed.Attempts)
+
+		replay, err := svc.ReplayDelivery(ctx, failed.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, notification.DeliverySent, replay.Status)
+		assert.Equal(t, failed.ID, *replay.ReplayOf)
+		assert.Equal(t, failed.Payload, replay.Payload)
+
+		requests, verified := rc.counts()
+		assert.Equal(t, 2, requests)
+		assert.Equal(t, 1, verified)
+
+		_, err = svc.ReplayDelivery(ctx, replay.ID)
+		assert.ErrorIs(t, err, notification.ErrDeliveryDelivered)
+	})
+
+	t.Run("A missing secret is generated", func(t *testing.T) {
+		hook, err := svc.CreateWebhook(ctx, notification.SaveWebhookDTO{URL: "https://example.com/hook", Events: []string{events.StreamStopped}})
+		assert.NoError(t, err)
+		assert.Len(t, hook.Secret, 64)
+
+		updated, err := svc.UpdateWebhook(ctx, hook.ID, notification.SaveWebhookDTO{URL: "https://example.com/other", Events: []string{events.StreamStopped}})
+		assert.NoError(t, err)
+		assert.Equal(t, hook.Secret, updated.Secret)
+	})
+}
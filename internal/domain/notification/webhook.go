@@ -0,0 +1,261 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Headers sent with every webhook request. The timestamp is the Unix time
+// the request was signed at. The signature is "sha256=" and the hex encoded
+// HMAC-SHA256, keyed with the endpoint's secret, of the timestamp, a dot and
+// the request body.
+//
+// Receivers recompute the signature over the timestamp header and the raw
+// body, compare it in constant time, and refuse timestamps further than
+// SignatureTolerance from their clock so a captured request cannot be
+// replayed later. Verify does all three.
+const (
+	SignatureHeader = "X-GoStreamix-Signature"
+	TimestampHeader = "X-GoStreamix-Timestamp"
+	EventHeader     = "X-GoStreamix-Event"
+)
+
+// SignatureTolerance is how far a request's timestamp may be from the
+// receiver's clock before Verify refuses it.
+const SignatureTolerance = 5 * time.Minute
+
+const (
+	webhookAttempts = 3
+	webhookBackoff  = 500 * time.Millisecond
+)
+
+// Sign returns the signature header value for body sent with the timestamp
+// header value timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature and timestamp, as received in the
+// headers, match body and the timestamp is within SignatureTolerance of now.
+func Verify(secret, timestamp, signature string, body []byte, now time.Time) bool {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+func (s *service) ListWebhooks(ctx context.Context) ([]*WebhookEndpoint, error) {
+	return s.repo.ListWebhooks(ctx)
+}
+
+func (s *service) CreateWebhook(ctx context.Context, dto SaveWebhookDTO) (*WebhookEndpoint, error) {
+	if err := dto.Validate(); err != nil {
+		return nil, err
+	}
+
+	hook := &WebhookEndpoint{ID: uuid.New(), Enabled: true}
+	dto.apply(hook)
+	if hook.Secret == "" {
+		generated, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		hook.Secret = generated
+	}
+	if err := s.repo.CreateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+func (s *service) UpdateWebhook(ctx context.Context, id uuid.UUID, dto SaveWebhookDTO) (*WebhookEndpoint, error) {
+	if err := dto.Validate(); err != nil {
+		return nil, err
+	}
+
+	hook, err := s.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dto.apply(hook)
+	hook.UpdatedAt = time.Now().UTC()
+	if err := s.repo.UpdateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// apply copies the DTO onto the endpoint. An empty or masked secret keeps
+// the current one.
+func (d SaveWebhookDTO) apply(hook *WebhookEndpoint) {
+	hook.URL = strings.TrimSpace(d.URL)
+	hook.Events = d.Events
+	if submitted := strings.TrimSpace(d.Secret); submitted != "" {
+		hook.Secret = secret.Unmask(submitted, hook.Secret)
+	}
+	if d.Enabled != nil {
+		hook.Enabled = *d.Enabled
+	}
+}
+
+func (s *service) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.repo.GetWebhook(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteWebhook(ctx, id)
+}
+
+func (s *service) ListWebhookDeliveries(ctx context.Context) ([]*WebhookDelivery, error) {
+	return s.repo.ListWebhookDeliveries(ctx, 100)
+}
+
+func (s *service) ReplayDelivery(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	original, err := s.repo.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if original.Status != DeliveryFailed {
+		return nil, ErrDeliveryDelivered
+	}
+	hook, err := s.repo.GetWebhook(ctx, original.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &WebhookDelivery{
+		WebhookID: hook.ID,
+		Event:     original.Event,
+		Payload:   original.Payload,
+		ReplayOf:  &original.ID,
+	}
+	s.deliverWebhook(ctx, hook, delivery)
+	if err := s.repo.AddWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// deliverWebhooks sends the event to every endpoint subscribed to it, in
+// parallel so one slow receiver does not hold up the others.
+func (s *service) deliverWebhooks(ctx context.Context, e events.Event) {
+	hooks, err := s.repo.ListWebhooks(ctx)
+	if err != nil {
+		s.log.Warn("failed to load webhooks", zap.String("event", e.Type), zap.Error(err))
+		return
+	}
+
+	var payload []byte
+	var wg sync.WaitGroup
+	for _, hook := range hooks {
+		if !hook.Subscribes(e.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(e); err != nil {
+				s.log.Warn("failed to encode webhook payload", zap.String("event", e.Type), zap.Error(err))
+				return
+			}
+		}
+
+		wg.Add(1)
+		go func(hook *WebhookEndpoint) {
+			defer wg.Done()
+			delivery := &WebhookDelivery{WebhookID: hook.ID, Event: e.Type, Payload: string(payload)}
+			s.deliverWebhook(ctx, hook, delivery)
+			if err := s.repo.AddWebhookDelivery(ctx, delivery); err != nil {
+				s.log.Warn("failed to record webhook delivery", zap.String("webhook_id", hook.ID.String()), zap.Error(err))
+			}
+		}(hook)
+	}
+	wg.Wait()
+}
+
+// deliverWebhook posts the delivery's payload, retrying with backoff while
+// the receiver is unreachable or failing, and records the outcome on it.
+// Other responses are final: the receiver saw the request and rejected it.
+func (s *service) deliverWebhook(ctx context.Context, hook *WebhookEndpoint, delivery *WebhookDelivery) {
+	backoff := webhookBackoff
+	for {
+		delivery.Attempts++
+		code, err := s.postWebhook(ctx, hook, delivery)
+		delivery.StatusCode = code
+		if err == nil {
+			delivery.Status = DeliverySent
+			delivery.Error = ""
+			return
+		}
+		delivery.Status = DeliveryFailed
+		delivery.Error = err.Error()
+
+		retryable := code == 0 || code == http.StatusTooManyRequests || code >= 500
+		if !retryable || delivery.Attempts >= webhookAttempts {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (s *service) postWebhook(ctx context.Context, hook *WebhookEndpoint, delivery *WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GoStreamix-Webhook")
+	req.Header.Set(EventHeader, delivery.Event)
+	// Each attempt is signed afresh so retries are not refused as stale.
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -40,6 +40,7 @@ type UpdatePlatformDTO struct {
 	PlatformType string `json:"platform_type" form:"platform_type"`
 	StreamKey    string `json:"stream_key" form:"stream_key"`
 	CustomURL    string `json:"custom_url" form:"custom_url"`
+	Enabled      *bool  `json:"enabled" form:"enabled"`
 }
 
 func (d *UpdatePlatformDTO) Validate() error {
@@ -6,4 +6,5 @@ var (
 	ErrPlatformNotFound = errors.New("platform not found")
 	ErrInvalidPlatform  = errors.New("invalid platform data")
 	ErrPlatformExists   = errors.New("platform already exists")
+	ErrPlatformInUse    = errors.New("platform is used by live streams")
 )
@@ -1,6 +1,8 @@
 package platform
 
 import (
+	"errors"
+
 	"github.com/codewithwan/gostreamix/internal/domain/auth"
 	"github.com/codewithwan/gostreamix/internal/shared/middleware"
 	"github.com/gofiber/fiber/v2"
@@ -22,8 +24,35 @@ func (h *Handler) Routes(app *fiber.App) {
 	api := app.Group("/api/platforms")
 	api.Get("/", h.ApiGetPlatforms)
 	api.Post("/", h.ApiCreatePlatform)
-	api.Put("/:id", h.ApiUpdatePlatform)
-	api.Delete("/:id", h.ApiDeletePlatform)
+	api.Put("/:id", h.ownPlatform, h.ApiUpdatePlatform)
+	api.Delete("/:id", h.ownPlatform, h.ApiDeletePlatform)
+}
+
+// ownPlatform lets a request on a platform through only for its owner or an
+// admin, as changing its key redirects every stream linked to it.
+func (h *Handler) ownPlatform(c *fiber.Ctx) error {
+	u := middleware.GetUser(c, h.authSvc)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid id"})
+	}
+
+	p, err := h.svc.GetPlatform(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrPlatformNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": ErrPlatformNotFound.Error()})
+		}
+		h.log.Error("Failed to get platform", zap.Error(err), zap.String("platformID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get platform"})
+	}
+	if !u.CanManage(p.UserID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "only the platform's owner or an admin can do this"})
+	}
+	return c.Next()
 }
 
 func (h *Handler) ApiGetPlatforms(c *fiber.Ctx) error {
@@ -38,7 +67,11 @@ func (h *Handler) ApiGetPlatforms(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to retrieve platforms"})
 	}
 
-	return c.JSON(platforms)
+	masked := make([]*Platform, len(platforms))
+	for i, p := range platforms {
+		masked[i] = p.Masked()
+	}
+	return c.JSON(masked)
 }
 
 func (h *Handler) ApiCreatePlatform(c *fiber.Ctx) error {
@@ -62,7 +95,7 @@ func (h *Handler) ApiCreatePlatform(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create platform"})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(p)
+	return c.Status(fiber.StatusCreated).JSON(p.Masked())
 }
 
 func (h *Handler) ApiDeletePlatform(c *fiber.Ctx) error {
@@ -76,7 +109,10 @@ func (h *Handler) ApiDeletePlatform(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid id"})
 	}
 
-	if err := h.svc.DeletePlatform(c.Context(), id); err != nil {
+	if err := h.svc.DeletePlatform(c.Context(), u, id); err != nil {
+		if errors.Is(err, ErrPlatformInUse) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
 		h.log.Error("Failed to delete platform", zap.Error(err), zap.String("platformID", id.String()))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete platform"})
 	}
@@ -106,9 +142,12 @@ func (h *Handler) ApiUpdatePlatform(c *fiber.Ctx) error {
 
 	p, err := h.svc.UpdatePlatform(c.Context(), id, req)
 	if err != nil {
+		if errors.Is(err, ErrPlatformNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": ErrPlatformNotFound.Error()})
+		}
 		h.log.Error("Failed to update platform", zap.Error(err), zap.String("platformID", id.String()))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update platform"})
 	}
 
-	return c.JSON(p)
+	return c.JSON(p.Masked())
 }
@@ -3,13 +3,14 @@ package platform
 import (
 	"context"
 
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
 	"github.com/google/uuid"
 )
 
 type Service interface {
 	CreatePlatform(ctx context.Context, userID uuid.UUID, dto CreatePlatformDTO) (*Platform, error)
 	GetPlatforms(ctx context.Context, userID uuid.UUID) ([]*Platform, error)
-	DeletePlatform(ctx context.Context, id uuid.UUID) error
+	DeletePlatform(ctx context.Context, caller *auth.User, id uuid.UUID) error
 	GetPlatform(ctx context.Context, id uuid.UUID) (*Platform, error)
 	UpdatePlatform(ctx context.Context, id uuid.UUID, dto UpdatePlatformDTO) (*Platform, error)
 }
@@ -20,4 +21,5 @@ type Repository interface {
 	Delete(ctx context.Context, id uuid.UUID) error
 	FindByID(ctx context.Context, id uuid.UUID) (*Platform, error)
 	FindByUserID(ctx context.Context, userID uuid.UUID) ([]*Platform, error)
+	LiveStreams(ctx context.Context, id uuid.UUID) ([]LiveStream, error)
 }
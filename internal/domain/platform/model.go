@@ -1,8 +1,10 @@
 package platform
 
 import (
+	"strings"
 	"time"
 
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 )
@@ -21,3 +23,48 @@ type Platform struct {
 	CreatedAt    time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt    time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 }
+
+// LiveStream is a stream meant to be running that pushes to a platform.
+type LiveStream struct {
+	Name   string    `bun:"name"`
+	UserID uuid.UUID `bun:"user_id,type:uuid"`
+}
+
+// Masked is a copy of the platform safe to send to the browser, with the
+// stream key hidden.
+func (p *Platform) Masked() *Platform {
+	masked := *p
+	masked.StreamKey = secret.Mask(p.StreamKey)
+	return &masked
+}
+
+// defaultIngestURLs are the RTMP endpoints of the known platform types, used
+// when the platform has no custom URL.
+var defaultIngestURLs = map[string]string{
+	"youtube":  "rtmp://a.rtmp.youtube.com/live2",
+	"twitch":   "rtmp://live.twitch.tv/app",
+	"facebook": "rtmps://live-api-s.facebook.com:443/rtmp",
+	"tiktok":   "rtmp://push-rtmp-global.tiktok.com/live",
+}
+
+// RTMPURL is the full publish URL, stream key included. It is empty when the
+// platform type has no default endpoint and no custom URL is set.
+func (p *Platform) RTMPURL() string {
+	baseURL := strings.TrimSpace(p.CustomURL)
+	streamKey := strings.TrimSpace(p.StreamKey)
+
+	if baseURL == "" {
+		baseURL = defaultIngestURLs[strings.ToLower(strings.TrimSpace(p.PlatformType))]
+	}
+	if baseURL == "" {
+		return ""
+	}
+
+	if streamKey == "" {
+		return baseURL
+	}
+	if strings.HasSuffix(baseURL, "/") {
+		return baseURL + streamKey
+	}
+	return baseURL + "/" + streamKey
+}
@@ -2,45 +2,100 @@ package platform
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 )
 
+// repository keeps stream keys encrypted at rest; callers only see
+// plaintext.
 type repository struct {
-	db *bun.DB
+	db     *bun.DB
+	cipher *secret.Cipher
 }
 
-func NewRepository(db *bun.DB) Repository {
-	return &repository{db: db}
+func NewRepository(db *bun.DB, cipher *secret.Cipher) Repository {
+	return &repository{db: db, cipher: cipher}
 }
 
 func (r *repository) Create(ctx context.Context, p *Platform) error {
-	_, err := r.db.NewInsert().Model(p).Exec(ctx)
+	restore, err := r.cipher.SealFields(&p.StreamKey)
+	if err != nil {
+		return fmt.Errorf("encrypt stream key: %w", err)
+	}
+	defer restore()
+
+	_, err = r.db.NewInsert().Model(p).Exec(ctx)
 	return err
 }
 
 func (r *repository) Update(ctx context.Context, p *Platform) error {
-	_, err := r.db.NewUpdate().Model(p).WherePK().Exec(ctx)
+	restore, err := r.cipher.SealFields(&p.StreamKey)
+	if err != nil {
+		return fmt.Errorf("encrypt stream key: %w", err)
+	}
+	defer restore()
+
+	_, err = r.db.NewUpdate().Model(p).WherePK().Exec(ctx)
 	return err
 }
 
+// Delete removes the platform and unlinks it from every stream. The links
+// are removed through the table directly so this package does not depend on
+// the stream domain.
 func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	_, err := r.db.NewDelete().Model((*Platform)(nil)).Where("id = ?", id).Exec(ctx)
-	return err
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Table("stream_platforms").Where("platform_id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewDelete().Model((*Platform)(nil)).Where("id = ?", id).Exec(ctx)
+		return err
+	})
 }
 
 func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (*Platform, error) {
 	p := new(Platform)
 	err := r.db.NewSelect().Model(p).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPlatformNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
+	if err := r.cipher.OpenFields(&p.StreamKey); err != nil {
+		return nil, fmt.Errorf("decrypt stream key: %w", err)
+	}
 	return p, nil
 }
 
 func (r *repository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]*Platform, error) {
 	var platforms []*Platform
-	err := r.db.NewSelect().Model(&platforms).Where("user_id = ?", userID).Scan(ctx)
-	return platforms, err
+	if err := r.db.NewSelect().Model(&platforms).Where("user_id = ?", userID).Scan(ctx); err != nil {
+		return platforms, err
+	}
+	for _, p := range platforms {
+		if err := r.cipher.OpenFields(&p.StreamKey); err != nil {
+			return nil, fmt.Errorf("decrypt stream key: %w", err)
+		}
+	}
+	return platforms, nil
+}
+
+// LiveStreams returns the streams meant to be running that push to the
+// platform.
+func (r *repository) LiveStreams(ctx context.Context, id uuid.UUID) ([]LiveStream, error) {
+	var live []LiveStream
+	err := r.db.NewSelect().
+		Table("stream_platforms").
+		Join("JOIN streams AS s ON s.id = stream_platforms.stream_id").
+		ColumnExpr("s.name, s.user_id").
+		Where("stream_platforms.platform_id = ?", id).
+		Where("s.desired_state = ?", "running").
+		OrderExpr("s.name ASC").
+		Scan(ctx, &live)
+	return live, err
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	sharedutils "github.com/codewithwan/gostreamix/internal/shared/utils"
 	"github.com/google/uuid"
 )
@@ -44,7 +47,28 @@ func (s *service) GetPlatforms(ctx context.Context, userID uuid.UUID) ([]*Platfo
 	return platforms, nil
 }
 
-func (s *service) DeletePlatform(ctx context.Context, id uuid.UUID) error {
+func (s *service) DeletePlatform(ctx context.Context, caller *auth.User, id uuid.UUID) error {
+	live, err := s.repo.LiveStreams(ctx, id)
+	if err != nil {
+		return fmt.Errorf("find live streams using platform: %w", err)
+	}
+	if len(live) > 0 {
+		// Only the caller's own streams are named; the others are counted.
+		var names []string
+		others := 0
+		for _, st := range live {
+			if caller.CanManage(st.UserID) {
+				names = append(names, st.Name)
+			} else {
+				others++
+			}
+		}
+		if others > 0 {
+			names = append(names, fmt.Sprintf("%d stream(s) of other users", others))
+		}
+		return fmt.Errorf("%w: stop %s first", ErrPlatformInUse, strings.Join(names, ", "))
+	}
+
 	if err := s.repo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("delete platform: %w", err)
 	}
@@ -67,9 +91,13 @@ func (s *service) UpdatePlatform(ctx context.Context, id uuid.UUID, dto UpdatePl
 
 	p.Name = sharedutils.SanitizeStrict(dto.Name)
 	p.PlatformType = dto.PlatformType
-	p.StreamKey = dto.StreamKey
+	p.StreamKey = secret.Unmask(dto.StreamKey, p.StreamKey)
 	p.CustomURL = dto.CustomURL
 	p.Color = normalizePlatformColor(dto.PlatformType)
+	if dto.Enabled != nil {
+		p.Enabled = *dto.Enabled
+	}
+	p.UpdatedAt = time.Now().UTC()
 
 	if err := s.repo.Update(ctx, p); err != nil {
 		return nil, fmt.Errorf("update platform: %w", err)
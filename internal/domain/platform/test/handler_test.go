@@ -54,3 +54,43 @@ func TestPlatformHandler(t *testing.T) {
 		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
 	})
 }
+
+func TestPlatformHandler_Ownership(t *testing.T) {
+	owner := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	other := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	admin := &auth.User{ID: uuid.New(), Role: auth.RoleAdmin}
+	p := &platform.Platform{ID: uuid.New(), UserID: owner.ID, Name: "Twitch", PlatformType: "twitch", StreamKey: "tw-key"}
+
+	svc := new(MockPlatformService)
+	svc.On("GetPlatform", mock.Anything, p.ID).Return(p, nil)
+	svc.On("GetPlatform", mock.Anything, mock.Anything).Return(nil, platform.ErrPlatformNotFound)
+	svc.On("UpdatePlatform", mock.Anything, p.ID, mock.Anything).Return(p, nil)
+	svc.On("DeletePlatform", mock.Anything, mock.Anything, p.ID).Return(nil)
+	handler := platform.NewHandler(svc, nil, zap.NewNop())
+
+	var current *auth.User
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", current)
+		return c.Next()
+	})
+	handler.Routes(app)
+
+	call := func(user *auth.User, method string, id uuid.UUID) int {
+		current = user
+		req := httptest.NewRequest(method, "/api/platforms/"+id.String(), strings.NewReader(`{"name":"Mine","platform_type":"twitch","stream_key":"my-key"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusForbidden, call(other, "PUT", p.ID))
+	assert.Equal(t, fiber.StatusForbidden, call(other, "DELETE", p.ID))
+	svc.AssertNotCalled(t, "UpdatePlatform", mock.Anything, mock.Anything, mock.Anything)
+	svc.AssertNotCalled(t, "DeletePlatform", mock.Anything, mock.Anything, mock.Anything)
+
+	assert.Equal(t, fiber.StatusNotFound, call(admin, "DELETE", uuid.New()))
+	assert.Equal(t, fiber.StatusOK, call(owner, "PUT", p.ID))
+	assert.Equal(t, fiber.StatusNoContent, call(admin, "DELETE", p.ID))
+}
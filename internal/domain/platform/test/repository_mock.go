@@ -42,3 +42,11 @@ func (m *MockPlatformRepository) FindByUserID(ctx context.Context, userID uuid.U
 	}
 	return args.Get(0).([]*platform.Platform), args.Error(1)
 }
+
+func (m *MockPlatformRepository) LiveStreams(ctx context.Context, id uuid.UUID) ([]platform.LiveStream, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]platform.LiveStream), args.Error(1)
+}
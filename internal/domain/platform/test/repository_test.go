@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/codewithwan/gostreamix/internal/domain/platform"
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	_ "github.com/glebarez/go-sqlite"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -18,12 +20,14 @@ func setupTestDB(t *testing.T) *bun.DB {
 	if err != nil {
 		t.Fatal(err)
 	}
+	sqldb.SetMaxOpenConns(1)
 	db := bun.NewDB(sqldb, sqlitedialect.New())
 
 	ctx := context.Background()
-	_, err = db.NewCreateTable().Model((*platform.Platform)(nil)).Exec(ctx)
-	if err != nil {
-		t.Fatal(err)
+	for _, model := range []interface{}{(*platform.Platform)(nil), (*stream.Stream)(nil), (*stream.StreamPlatform)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
 	}
 
 	return db
@@ -31,7 +35,9 @@ func setupTestDB(t *testing.T) *bun.DB {
 
 func TestPlatformRepository(t *testing.T) {
 	db := setupTestDB(t)
-	repo := platform.NewRepository(db)
+	cipher, err := secret.New("test-key")
+	assert.NoError(t, err)
+	repo := platform.NewRepository(db, cipher)
 	ctx := context.Background()
 	userID := uuid.New()
 
@@ -53,6 +59,26 @@ func TestPlatformRepository(t *testing.T) {
 		assert.Equal(t, p.UserID, found.UserID)
 	})
 
+	t.Run("Stream key is sealed at rest", func(t *testing.T) {
+		p := &platform.Platform{ID: uuid.New(), UserID: userID, Name: "Sealed", PlatformType: "youtube", StreamKey: "live_abcd1234"}
+		assert.NoError(t, repo.Create(ctx, p))
+		assert.Equal(t, "live_abcd1234", p.StreamKey)
+
+		var stored string
+		assert.NoError(t, db.NewSelect().Table("platforms").Column("stream_key").Where("id = ?", p.ID).Scan(ctx, &stored))
+		assert.True(t, secret.IsSealed(stored))
+		assert.NotContains(t, stored, "abcd1234")
+
+		found, err := repo.FindByID(ctx, p.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "live_abcd1234", found.StreamKey)
+
+		other, err := secret.New("other-key")
+		assert.NoError(t, err)
+		_, err = platform.NewRepository(db, other).FindByID(ctx, p.ID)
+		assert.ErrorIs(t, err, secret.ErrDecrypt)
+	})
+
 	t.Run("FindByUserID", func(t *testing.T) {
 		p1 := &platform.Platform{ID: uuid.New(), UserID: userID, Name: "P1", PlatformType: "t", StreamKey: "k"}
 		p2 := &platform.Platform{ID: uuid.New(), UserID: userID, Name: "P2", PlatformType: "t", StreamKey: "k"}
@@ -86,7 +112,29 @@ func TestPlatformRepository(t *testing.T) {
 		assert.NoError(t, err)
 
 		found, err := repo.FindByID(ctx, id)
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, platform.ErrPlatformNotFound)
 		assert.Nil(t, found)
 	})
+
+	t.Run("LiveStreams and unlinking on delete", func(t *testing.T) {
+		p := &platform.Platform{ID: uuid.New(), UserID: userID, Name: "Linked", PlatformType: "twitch", StreamKey: "k"}
+		assert.NoError(t, repo.Create(ctx, p))
+
+		streams := stream.NewRepository(db, cipher)
+		live := &stream.Stream{ID: uuid.New(), UserID: userID, Name: "Live", DesiredState: stream.DesiredRunning}
+		idle := &stream.Stream{ID: uuid.New(), Name: "Idle", DesiredState: stream.DesiredStopped}
+		for _, s := range []*stream.Stream{live, idle} {
+			assert.NoError(t, streams.Create(ctx, s))
+			assert.NoError(t, streams.SetPlatforms(ctx, s.ID, []uuid.UUID{p.ID}))
+		}
+
+		running, err := repo.LiveStreams(ctx, p.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []platform.LiveStream{{Name: "Live", UserID: userID}}, running)
+
+		assert.NoError(t, repo.Delete(ctx, p.ID))
+		found, err := streams.GetByID(ctx, idle.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, found.PlatformIDs)
+	})
 }
@@ -3,6 +3,7 @@ package test
 import (
 	"context"
 
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
 	"github.com/codewithwan/gostreamix/internal/domain/platform"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
@@ -28,8 +29,8 @@ func (m *MockPlatformService) GetPlatforms(ctx context.Context, userID uuid.UUID
 	return args.Get(0).([]*platform.Platform), args.Error(1)
 }
 
-func (m *MockPlatformService) DeletePlatform(ctx context.Context, id uuid.UUID) error {
-	args := m.Called(ctx, id)
+func (m *MockPlatformService) DeletePlatform(ctx context.Context, caller *auth.User, id uuid.UUID) error {
+	args := m.Called(ctx, caller, id)
 	return args.Error(0)
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
 	"github.com/codewithwan/gostreamix/internal/domain/platform"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -113,16 +114,69 @@ func TestPlatformService_UpdatePlatform(t *testing.T) {
 func TestPlatformService_DeletePlatform(t *testing.T) {
 	ctx := context.Background()
 	platformID := uuid.New()
+	owner := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	admin := &auth.User{ID: uuid.New(), Role: auth.RoleAdmin}
 
 	t.Run("Delete success", func(t *testing.T) {
 		mockRepo := new(MockPlatformRepository)
 		service := platform.NewService(mockRepo)
 
+		mockRepo.On("LiveStreams", ctx, platformID).Return(nil, nil)
 		mockRepo.On("Delete", ctx, platformID).Return(nil)
 
-		err := service.DeletePlatform(ctx, platformID)
+		err := service.DeletePlatform(ctx, owner, platformID)
 		assert.NoError(t, err)
 
 		mockRepo.AssertExpectations(t)
 	})
+
+	t.Run("Delete blocked while live streams use it", func(t *testing.T) {
+		mockRepo := new(MockPlatformRepository)
+		service := platform.NewService(mockRepo)
+
+		mockRepo.On("LiveStreams", ctx, platformID).Return([]platform.LiveStream{
+			{Name: "Lofi 24/7", UserID: owner.ID},
+			{Name: "Morning Show", UserID: owner.ID},
+		}, nil)
+
+		err := service.DeletePlatform(ctx, owner, platformID)
+		assert.ErrorIs(t, err, platform.ErrPlatformInUse)
+		assert.EqualError(t, err, "platform is used by live streams: stop Lofi 24/7, Morning Show first")
+
+		mockRepo.AssertNotCalled(t, "Delete", ctx, platformID)
+	})
+
+	t.Run("Other users' stream names are not revealed", func(t *testing.T) {
+		mockRepo := new(MockPlatformRepository)
+		service := platform.NewService(mockRepo)
+
+		mockRepo.On("LiveStreams", ctx, platformID).Return([]platform.LiveStream{
+			{Name: "Lofi 24/7", UserID: owner.ID},
+			{Name: "Admin Only", UserID: admin.ID},
+		}, nil)
+
+		err := service.DeletePlatform(ctx, owner, platformID)
+		assert.EqualError(t, err, "platform is used by live streams: stop Lofi 24/7, 1 stream(s) of other users first")
+
+		err = service.DeletePlatform(ctx, admin, platformID)
+		assert.EqualError(t, err, "platform is used by live streams: stop Lofi 24/7, Admin Only first")
+	})
+}
+
+func TestPlatform_RTMPURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform platform.Platform
+		want     string
+	}{
+		{"known type", platform.Platform{PlatformType: "YouTube", StreamKey: "abcd-1234"}, "rtmp://a.rtmp.youtube.com/live2/abcd-1234"},
+		{"custom url", platform.Platform{PlatformType: "custom", CustomURL: "rtmp://ingest.example.com/live/", StreamKey: "key"}, "rtmp://ingest.example.com/live/key"},
+		{"unknown type without url", platform.Platform{PlatformType: "custom", StreamKey: "key"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.platform.RTMPURL())
+		})
+	}
 }
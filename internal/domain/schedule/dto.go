@@ -0,0 +1,46 @@
+package schedule
+
+import (
+	"strings"
+	"time"
+)
+
+const maxDurationMin = 7 * 24 * 60
+
+type SaveScheduleDTO struct {
+	Name        string     `json:"name"`
+	Kind        string     `json:"kind"`
+	StartAt     *time.Time `json:"start_at"`
+	EndAt       *time.Time `json:"end_at"`
+	Cron        string     `json:"cron"`
+	DurationMin int        `json:"duration_min"`
+	Timezone    string     `json:"timezone"`
+	Enabled     *bool      `json:"enabled"`
+}
+
+func (d *SaveScheduleDTO) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return ErrValidationRequired
+	}
+	if _, err := loadLocation(d.Timezone); err != nil {
+		return ErrInvalidTimezone
+	}
+
+	switch d.Kind {
+	case KindOnce:
+		if d.StartAt == nil || d.EndAt == nil || !d.EndAt.After(*d.StartAt) {
+			return ErrInvalidWindow
+		}
+	case KindRecurring:
+		if _, err := ParseCron(d.Cron); err != nil {
+			return err
+		}
+		if d.DurationMin <= 0 || d.DurationMin > maxDurationMin {
+			return ErrInvalidDuration
+		}
+	default:
+		return ErrInvalidKind
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package schedule
+
+import "errors"
+
+var (
+	ErrScheduleNotFound   = errors.New("schedule not found")
+	ErrInvalidKind        = errors.New("schedule kind must be once or recurring")
+	ErrInvalidWindow      = errors.New("schedule end must be after start")
+	ErrInvalidCron        = errors.New("invalid cron expression")
+	ErrInvalidDuration    = errors.New("schedule duration must be between 1 and 10080 minutes")
+	ErrInvalidTimezone    = errors.New("invalid time zone")
+	ErrValidationRequired = errors.New("schedule name is required")
+)
@@ -0,0 +1,152 @@
+package schedule
+
+import (
+	"errors"
+
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/shared/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type Handler struct {
+	svc       Service
+	streamSvc stream.Service
+	authSvc   auth.Service
+	log       *zap.Logger
+}
+
+func NewHandler(svc Service, streamSvc stream.Service, authSvc auth.Service, log *zap.Logger) *Handler {
+	return &Handler{svc: svc, streamSvc: streamSvc, authSvc: authSvc, log: log}
+}
+
+func (h *Handler) Routes(app *fiber.App) {
+	api := app.Group("/api/streams/:id/schedules")
+	api.Get("/", h.ApiGetSchedules)
+	api.Post("/", h.ownStream, h.ApiCreateSchedule)
+	api.Put("/:scheduleId", h.ownStream, h.ApiUpdateSchedule)
+	api.Delete("/:scheduleId", h.ownStream, h.ApiDeleteSchedule)
+}
+
+// ownStream lets schedule changes through only for the stream's owner or an
+// admin, as schedules start and stop the stream.
+func (h *Handler) ownStream(c *fiber.Ctx) error {
+	u := middleware.GetUser(c, h.authSvc)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	streamID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	streamData, err := h.streamSvc.GetStream(c.Context(), streamID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": stream.ErrStreamNotFound.Error()})
+	}
+	if !u.CanManage(streamData.UserID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "only the stream's owner or an admin can do this"})
+	}
+	return c.Next()
+}
+
+func (h *Handler) ApiGetSchedules(c *fiber.Ctx) error {
+	streamID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	schedules, err := h.svc.ListSchedules(c.Context(), streamID)
+	if err != nil {
+		h.log.Error("Failed to list schedules", zap.Error(err), zap.String("streamID", streamID.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list schedules"})
+	}
+
+	return c.JSON(schedules)
+}
+
+func (h *Handler) ApiCreateSchedule(c *fiber.Ctx) error {
+	streamID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	var req SaveScheduleDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	sch, err := h.svc.CreateSchedule(c.Context(), streamID, req)
+	if err != nil {
+		if errors.Is(err, stream.ErrStreamNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "stream not found"})
+		}
+		h.log.Error("Failed to create schedule", zap.Error(err), zap.String("streamID", streamID.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create schedule"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(sch)
+}
+
+func (h *Handler) ApiUpdateSchedule(c *fiber.Ctx) error {
+	streamID, id, err := parseIDs(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var req SaveScheduleDTO
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	sch, err := h.svc.UpdateSchedule(c.Context(), streamID, id, req)
+	if err != nil {
+		if errors.Is(err, ErrScheduleNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to update schedule", zap.Error(err), zap.String("scheduleID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update schedule"})
+	}
+
+	return c.JSON(sch)
+}
+
+func (h *Handler) ApiDeleteSchedule(c *fiber.Ctx) error {
+	streamID, id, err := parseIDs(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := h.svc.DeleteSchedule(c.Context(), streamID, id); err != nil {
+		if errors.Is(err, ErrScheduleNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to delete schedule", zap.Error(err), zap.String("scheduleID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete schedule"})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func parseIDs(c *fiber.Ctx) (uuid.UUID, uuid.UUID, error) {
+	streamID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("invalid stream id")
+	}
+	id, err := uuid.Parse(c.Params("scheduleId"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("invalid schedule id")
+	}
+	return streamID, id, nil
+}
@@ -0,0 +1,23 @@
+package schedule
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, s *Schedule) error
+	Update(ctx context.Context, s *Schedule) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindByID(ctx context.Context, id uuid.UUID) (*Schedule, error)
+	FindByStreamID(ctx context.Context, streamID uuid.UUID) ([]*Schedule, error)
+	ListActive(ctx context.Context) ([]*Schedule, error)
+}
+
+type Service interface {
+	ListSchedules(ctx context.Context, streamID uuid.UUID) ([]*Schedule, error)
+	CreateSchedule(ctx context.Context, streamID uuid.UUID, dto SaveScheduleDTO) (*Schedule, error)
+	UpdateSchedule(ctx context.Context, streamID, id uuid.UUID, dto SaveScheduleDTO) (*Schedule, error)
+	DeleteSchedule(ctx context.Context, streamID, id uuid.UUID) error
+}
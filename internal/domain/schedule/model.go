@@ -0,0 +1,35 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+const (
+	KindOnce      = "once"
+	KindRecurring = "recurring"
+)
+
+type Schedule struct {
+	bun.BaseModel `bun:"table:stream_schedules,alias:ss"`
+
+	ID          uuid.UUID `bun:",pk,type:text" json:"id"`
+	StreamID    uuid.UUID `bun:",notnull,type:text" json:"stream_id"`
+	Name        string    `bun:",notnull" json:"name"`
+	Kind        string    `bun:",notnull" json:"kind"`
+	StartAt     time.Time `bun:",nullzero" json:"start_at,omitempty"`
+	EndAt       time.Time `bun:",nullzero" json:"end_at,omitempty"`
+	Cron        string    `json:"cron"`
+	DurationMin int       `json:"duration_min"`
+	Timezone    string    `bun:",notnull,default:'UTC'" json:"timezone"`
+	Enabled     bool      `bun:",notnull" json:"enabled"`
+	// LastWindowAt is the start of the most recent window the scheduler has
+	// handled, whether it was started or recorded as missed.
+	LastWindowAt time.Time `bun:",nullzero" json:"last_window_at,omitempty"`
+	// ActiveUntil is set while a window started by this schedule is live.
+	ActiveUntil time.Time `bun:",nullzero" json:"active_until,omitempty"`
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
@@ -0,0 +1,57 @@
+package schedule
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type repository struct {
+	db *bun.DB
+}
+
+func NewRepository(db *bun.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(ctx context.Context, s *Schedule) error {
+	_, err := r.db.NewInsert().Model(s).Exec(ctx)
+	return err
+}
+
+func (r *repository) Update(ctx context.Context, s *Schedule) error {
+	_, err := r.db.NewUpdate().Model(s).WherePK().Exec(ctx)
+	return err
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.NewDelete().Model((*Schedule)(nil)).Where("id = ?", id).Exec(ctx)
+	return err
+}
+
+func (r *repository) FindByID(ctx context.Context, id uuid.UUID) (*Schedule, error) {
+	s := new(Schedule)
+	err := r.db.NewSelect().Model(s).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *repository) FindByStreamID(ctx context.Context, streamID uuid.UUID) ([]*Schedule, error) {
+	var schedules []*Schedule
+	err := r.db.NewSelect().Model(&schedules).Where("stream_id = ?", streamID).Order("created_at ASC").Scan(ctx)
+	return schedules, err
+}
+
+// ListActive returns enabled schedules plus any schedule that still owns a
+// live window, so disabling a schedule mid-window still stops its stream.
+func (r *repository) ListActive(ctx context.Context) ([]*Schedule, error) {
+	var schedules []*Schedule
+	err := r.db.NewSelect().Model(&schedules).
+		Where("enabled = ?", true).
+		WhereOr("active_until IS NOT NULL").
+		Scan(ctx)
+	return schedules, err
+}
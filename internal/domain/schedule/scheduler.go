@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/activity"
+	"go.uber.org/zap"
+)
+
+// lateGrace is how long after a window opens the scheduler still treats the
+// start as on time.
+const lateGrace = 2 * time.Minute
+
+// Scheduler starts and stops streams as their schedule windows open and close.
+type Scheduler struct {
+	repo      Repository
+	streamSvc stream.Service
+	log       *zap.Logger
+	interval  time.Duration
+}
+
+func NewScheduler(repo Repository, streamSvc stream.Service, log *zap.Logger) *Scheduler {
+	return &Scheduler{repo: repo, streamSvc: streamSvc, log: log, interval: 30 * time.Second}
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	s.tick(ctx)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.tick(ctx)
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	schedules, err := s.repo.ListActive(ctx)
+	if err != nil {
+		s.log.Warn("failed to list schedules", zap.Error(err))
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, sch := range schedules {
+		if err := s.evaluate(ctx, sch, now); err != nil {
+			s.log.Warn("failed to evaluate schedule", zap.String("schedule_id", sch.ID.String()), zap.Error(err))
+		}
+	}
+}
+
+func (s *Scheduler) evaluate(ctx context.Context, sch *Schedule, now time.Time) error {
+	st, err := s.streamSvc.GetStream(ctx, sch.StreamID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.repo.Delete(ctx, sch.ID)
+		}
+		return err
+	}
+
+	changed := false
+
+	if !sch.ActiveUntil.IsZero() && (!now.Before(sch.ActiveUntil) || !sch.Enabled) {
+		if err := s.streamSvc.StopStream(ctx, sch.StreamID); err != nil {
+			s.recordError("schedule_stop_failed", sch, fmt.Sprintf("Schedule %q failed to stop %s", sch.Name, st.Name), err)
+		} else {
+			s.record("info", "schedule_stopped", sch, fmt.Sprintf("Schedule %q stopped %s", sch.Name, st.Name))
+		}
+		sch.ActiveUntil = time.Time{}
+		changed = true
+	}
+
+	if sch.Enabled {
+		if start, end, active := sch.WindowAt(now); active {
+			if start.After(sch.LastWindowAt) {
+				s.startWindow(ctx, sch, st, start, end, now)
+				changed = true
+			}
+		} else if start, _, found := sch.PreviousWindow(now); found && start.After(sch.LastWindowAt) && start.After(sch.definedAt()) {
+			s.record("warning", "schedule_window_missed", sch, fmt.Sprintf(
+				"Schedule %q missed the window starting %s for %s", sch.Name, start.Format(time.RFC3339), st.Name,
+			))
+			sch.LastWindowAt = start
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	sch.UpdatedAt = now
+	return s.repo.Update(ctx, sch)
+}
+
+func (s *Scheduler) startWindow(ctx context.Context, sch *Schedule, st *stream.Stream, start, end, now time.Time) {
+	sch.LastWindowAt = start
+
+	if now.Sub(start) > lateGrace {
+		s.record("warning", "schedule_window_late", sch, fmt.Sprintf(
+			"Schedule %q window opened at %s; starting %s late", sch.Name, start.Format(time.RFC3339), st.Name,
+		))
+	}
+
+	if err := s.streamSvc.StartStream(ctx, sch.StreamID); err != nil && !errors.Is(err, stream.ErrStreamAlreadyRunning) {
+		s.recordError("schedule_start_failed", sch, fmt.Sprintf("Schedule %q could not start %s", sch.Name, st.Name), err)
+		return
+	}
+
+	sch.ActiveUntil = end
+	s.record("info", "schedule_started", sch, fmt.Sprintf("Schedule %q started %s until %s", sch.Name, st.Name, end.Format(time.RFC3339)))
+}
+
+// definedAt is when the current schedule definition took effect; windows
+// before it are not reported as missed.
+func (sch *Schedule) definedAt() time.Time {
+	if sch.UpdatedAt.After(sch.CreatedAt) {
+		return sch.UpdatedAt
+	}
+	return sch.CreatedAt
+}
+
+func (s *Scheduler) record(level, event string, sch *Schedule, message string) {
+	s.log.Info("schedule event", zap.String("event", event), zap.String("schedule_id", sch.ID.String()), zap.String("message", message))
+	activity.Record(activity.Entry{
+		Timestamp: time.Now().UTC(),
+		Source:    "scheduler",
+		Level:     level,
+		Event:     event,
+		Message:   message,
+		StreamID:  sch.StreamID.String(),
+	})
+}
+
+// recordError records a failed action with its cause kept apart from the
+// message, so the activity log can show why the scheduler gave up.
+func (s *Scheduler) recordError(event string, sch *Schedule, message string, err error) {
+	s.log.Warn("schedule event", zap.String("event", event), zap.String("schedule_id", sch.ID.String()), zap.String("message", message), zap.Error(err))
+	activity.Record(activity.Entry{
+		Timestamp: time.Now().UTC(),
+		Source:    "scheduler",
+		Level:     "error",
+		Event:     event,
+		Message:   fmt.Sprintf("%s: %v", message, err),
+		StreamID:  sch.StreamID.String(),
+		Error:     err.Error(),
+	})
+}
@@ -0,0 +1,115 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	sharedutils "github.com/codewithwan/gostreamix/internal/shared/utils"
+	"github.com/google/uuid"
+)
+
+type service struct {
+	repo      Repository
+	streamSvc stream.Service
+}
+
+func NewService(repo Repository, streamSvc stream.Service) Service {
+	return &service{repo: repo, streamSvc: streamSvc}
+}
+
+func (s *service) ListSchedules(ctx context.Context, streamID uuid.UUID) ([]*Schedule, error) {
+	schedules, err := s.repo.FindByStreamID(ctx, streamID)
+	if err != nil {
+		return nil, fmt.Errorf("list schedules by stream id: %w", err)
+	}
+	return schedules, nil
+}
+
+func (s *service) CreateSchedule(ctx context.Context, streamID uuid.UUID, dto SaveScheduleDTO) (*Schedule, error) {
+	_, err := s.streamSvc.GetStream(ctx, streamID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, stream.ErrStreamNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get schedule stream: %w", err)
+	}
+
+	sch := &Schedule{
+		ID:       uuid.New(),
+		StreamID: streamID,
+		Enabled:  true,
+	}
+	applyDTO(sch, dto)
+
+	if err := s.repo.Create(ctx, sch); err != nil {
+		return nil, fmt.Errorf("create schedule: %w", err)
+	}
+	return sch, nil
+}
+
+func (s *service) UpdateSchedule(ctx context.Context, streamID, id uuid.UUID, dto SaveScheduleDTO) (*Schedule, error) {
+	sch, err := s.find(ctx, streamID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyDTO(sch, dto)
+	sch.UpdatedAt = time.Now().UTC()
+
+	if err := s.repo.Update(ctx, sch); err != nil {
+		return nil, fmt.Errorf("update schedule: %w", err)
+	}
+	return sch, nil
+}
+
+func (s *service) DeleteSchedule(ctx context.Context, streamID, id uuid.UUID) error {
+	if _, err := s.find(ctx, streamID, id); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete schedule: %w", err)
+	}
+	return nil
+}
+
+func (s *service) find(ctx context.Context, streamID, id uuid.UUID) (*Schedule, error) {
+	sch, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find schedule: %w", err)
+	}
+	if sch.StreamID != streamID {
+		return nil, ErrScheduleNotFound
+	}
+	return sch, nil
+}
+
+func applyDTO(sch *Schedule, dto SaveScheduleDTO) {
+	sch.Name = sharedutils.SanitizeStrict(strings.TrimSpace(dto.Name))
+	sch.Kind = dto.Kind
+	sch.Timezone = strings.TrimSpace(dto.Timezone)
+	if sch.Timezone == "" {
+		sch.Timezone = "UTC"
+	}
+	if dto.Enabled != nil {
+		sch.Enabled = *dto.Enabled
+	}
+
+	sch.StartAt, sch.EndAt = time.Time{}, time.Time{}
+	sch.Cron, sch.DurationMin = "", 0
+	switch dto.Kind {
+	case KindOnce:
+		sch.StartAt = dto.StartAt.UTC()
+		sch.EndAt = dto.EndAt.UTC()
+	case KindRecurring:
+		sch.Cron = strings.Join(strings.Fields(dto.Cron), " ")
+		sch.DurationMin = dto.DurationMin
+	}
+}
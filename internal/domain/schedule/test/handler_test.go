@@ -0,0 +1,73 @@
+package test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/schedule"
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// ownedStream is a stream service serving one stream from memory.
+type ownedStream struct {
+	stream.Service
+	stream *stream.Stream
+}
+
+func (s *ownedStream) GetStream(ctx context.Context, id uuid.UUID) (*stream.Stream, error) {
+	if id != s.stream.ID {
+		return nil, stream.ErrStreamNotFound
+	}
+	return s.stream, nil
+}
+
+// deletions is a schedule service counting deleted schedules.
+type deletions struct {
+	schedule.Service
+	deleted int
+}
+
+func (s *deletions) DeleteSchedule(ctx context.Context, streamID, id uuid.UUID) error {
+	s.deleted++
+	return nil
+}
+
+func TestHandler_ScheduleOwnership(t *testing.T) {
+	owner := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	other := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	admin := &auth.User{ID: uuid.New(), Role: auth.RoleAdmin}
+	st := &stream.Stream{ID: uuid.New(), UserID: owner.ID, Name: "Morning show"}
+
+	svc := &deletions{}
+	handler := schedule.NewHandler(svc, &ownedStream{stream: st}, nil, zap.NewNop())
+
+	var current *auth.User
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", current)
+		return c.Next()
+	})
+	handler.Routes(app)
+
+	remove := func(user *auth.User, streamID uuid.UUID) int {
+		current = user
+		path := "/api/streams/" + streamID.String() + "/schedules/" + uuid.New().String()
+		resp, err := app.Test(httptest.NewRequest("DELETE", path, nil), -1)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusForbidden, remove(other, st.ID))
+	assert.Equal(t, 0, svc.deleted)
+
+	assert.Equal(t, fiber.StatusNotFound, remove(admin, uuid.New()))
+	assert.Equal(t, fiber.StatusNoContent, remove(owner, st.ID))
+	assert.Equal(t, fiber.StatusNoContent, remove(admin, st.ID))
+	assert.Equal(t, 2, svc.deleted)
+}
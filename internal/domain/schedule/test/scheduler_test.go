@@ -0,0 +1,128 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/schedule"
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/activity"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type stubRepository struct {
+	schedule.Repository
+	mu        sync.Mutex
+	schedules []*schedule.Schedule
+	updated   []*schedule.Schedule
+	findErr   error
+}
+
+func (r *stubRepository) FindByID(ctx context.Context, id uuid.UUID) (*schedule.Schedule, error) {
+	if r.findErr != nil {
+		return nil, r.findErr
+	}
+	for _, s := range r.schedules {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *stubRepository) ListActive(ctx context.Context) ([]*schedule.Schedule, error) {
+	return r.schedules, nil
+}
+
+func (r *stubRepository) Update(ctx context.Context, s *schedule.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updated = append(r.updated, s)
+	return nil
+}
+
+func (r *stubRepository) updates() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.updated)
+}
+
+type stubStreamService struct {
+	stream.Service
+	startErr error
+}
+
+func (s *stubStreamService) GetStream(ctx context.Context, id uuid.UUID) (*stream.Stream, error) {
+	return &stream.Stream{ID: id, Name: "Morning show"}, nil
+}
+
+func (s *stubStreamService) StartStream(ctx context.Context, id uuid.UUID) error {
+	return s.startErr
+}
+
+func findEvent(streamID uuid.UUID, event string) (activity.Entry, bool) {
+	for _, entry := range activity.List(0) {
+		if entry.StreamID == streamID.String() && entry.Event == event {
+			return entry, true
+		}
+	}
+	return activity.Entry{}, false
+}
+
+func TestScheduler_StartFailureIsNotAMissedWindow(t *testing.T) {
+	now := time.Now().UTC()
+	sch := &schedule.Schedule{
+		ID:        uuid.New(),
+		StreamID:  uuid.New(),
+		Name:      "Weekday",
+		Kind:      schedule.KindOnce,
+		StartAt:   now.Add(-time.Minute),
+		EndAt:     now.Add(time.Hour),
+		Timezone:  "UTC",
+		Enabled:   true,
+		CreatedAt: now.Add(-time.Hour),
+		UpdatedAt: now.Add(-time.Hour),
+	}
+	repo := &stubRepository{schedules: []*schedule.Schedule{sch}}
+	streams := &stubStreamService{startErr: errors.New("no platforms configured")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	schedule.NewScheduler(repo, streams, zap.NewNop()).Start(ctx)
+
+	assert.Eventually(t, func() bool { return repo.updates() > 0 }, time.Second, 10*time.Millisecond)
+
+	entry, ok := findEvent(sch.StreamID, "schedule_start_failed")
+	assert.True(t, ok)
+	assert.Equal(t, "error", entry.Level)
+	assert.Equal(t, "no platforms configured", entry.Error)
+	assert.Contains(t, entry.Message, "no platforms configured")
+
+	_, missed := findEvent(sch.StreamID, "schedule_window_missed")
+	assert.False(t, missed)
+}
+
+func TestService_FindSchedule(t *testing.T) {
+	ctx := context.Background()
+	streamID := uuid.New()
+	sch := &schedule.Schedule{ID: uuid.New(), StreamID: streamID}
+
+	t.Run("Missing or foreign schedules are not found", func(t *testing.T) {
+		svc := schedule.NewService(&stubRepository{schedules: []*schedule.Schedule{sch}}, &stubStreamService{})
+		assert.ErrorIs(t, svc.DeleteSchedule(ctx, streamID, uuid.New()), schedule.ErrScheduleNotFound)
+		assert.ErrorIs(t, svc.DeleteSchedule(ctx, uuid.New(), sch.ID), schedule.ErrScheduleNotFound)
+	})
+
+	t.Run("Database failures are reported as such", func(t *testing.T) {
+		svc := schedule.NewService(&stubRepository{findErr: sql.ErrConnDone}, &stubStreamService{})
+		err := svc.DeleteSchedule(ctx, streamID, sch.ID)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.NotErrorIs(t, err, schedule.ErrScheduleNotFound)
+	})
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/schedule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	_, err := schedule.ParseCron("0 20 * * 1-5")
+	assert.NoError(t, err)
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "0 20 * * mon"} {
+		_, err := schedule.ParseCron(expr)
+		assert.ErrorIs(t, err, schedule.ErrInvalidCron, expr)
+	}
+}
+
+func TestCronMatchesDayOfMonthOrWeek(t *testing.T) {
+	spec, err := schedule.ParseCron("0 9 1 * 0")
+	assert.NoError(t, err)
+
+	// 2026-03-01 is a Sunday and the first; 2026-03-08 is only a Sunday.
+	assert.True(t, spec.Matches(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)))
+	assert.True(t, spec.Matches(time.Date(2026, 3, 8, 9, 0, 0, 0, time.UTC)))
+	assert.False(t, spec.Matches(time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)))
+}
+
+func TestRecurringWindowHonoursTimezone(t *testing.T) {
+	sch := &schedule.Schedule{
+		Kind:        schedule.KindRecurring,
+		Cron:        "0 20 * * *",
+		DurationMin: 90,
+		Timezone:    "Asia/Jakarta",
+	}
+
+	// 20:30 in Jakarta is 13:30 UTC.
+	now := time.Date(2026, 5, 4, 13, 30, 0, 0, time.UTC)
+	start, end, active := sch.WindowAt(now)
+	assert.True(t, active)
+	assert.True(t, start.Equal(time.Date(2026, 5, 4, 13, 0, 0, 0, time.UTC)))
+	assert.True(t, end.Equal(time.Date(2026, 5, 4, 14, 30, 0, 0, time.UTC)))
+
+	_, _, active = sch.WindowAt(now.Add(2 * time.Hour))
+	assert.False(t, active)
+
+	start, _, found := sch.PreviousWindow(now.Add(2 * time.Hour))
+	assert.True(t, found)
+	assert.True(t, start.Equal(time.Date(2026, 5, 4, 13, 0, 0, 0, time.UTC)))
+}
+
+func TestOnceWindow(t *testing.T) {
+	startAt := time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)
+	sch := &schedule.Schedule{
+		Kind:    schedule.KindOnce,
+		StartAt: startAt,
+		EndAt:   startAt.Add(time.Hour),
+	}
+
+	_, _, active := sch.WindowAt(startAt.Add(-time.Minute))
+	assert.False(t, active)
+	_, _, active = sch.WindowAt(startAt.Add(30 * time.Minute))
+	assert.True(t, active)
+	_, _, found := sch.PreviousWindow(startAt.Add(30 * time.Minute))
+	assert.False(t, found)
+	_, _, found = sch.PreviousWindow(startAt.Add(2 * time.Hour))
+	assert.True(t, found)
+}
+
+func TestSaveScheduleDTOValidate(t *testing.T) {
+	startAt := time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)
+	endAt := startAt.Add(-time.Hour)
+
+	dto := schedule.SaveScheduleDTO{Name: "Evening", Kind: schedule.KindOnce, StartAt: &startAt, EndAt: &endAt}
+	assert.ErrorIs(t, dto.Validate(), schedule.ErrInvalidWindow)
+
+	dto = schedule.SaveScheduleDTO{Name: "Evening", Kind: schedule.KindRecurring, Cron: "0 20 * * *", DurationMin: 60, Timezone: "Mars/Olympus"}
+	assert.ErrorIs(t, dto.Validate(), schedule.ErrInvalidTimezone)
+
+	dto.Timezone = "Europe/Berlin"
+	assert.NoError(t, dto.Validate())
+
+	dto.DurationMin = 0
+	assert.ErrorIs(t, dto.Validate(), schedule.ErrInvalidDuration)
+}
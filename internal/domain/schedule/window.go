@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"strings"
+	"time"
+
+	// Embed the zoneinfo database so schedules resolve time zones even on
+	// minimal container images.
+	_ "time/tzdata"
+)
+
+// lookback bounds how far the scheduler searches for a missed window.
+const lookback = 7 * 24 * time.Hour
+
+func loadLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// WindowAt returns the window that contains now, if any.
+func (s *Schedule) WindowAt(now time.Time) (time.Time, time.Time, bool) {
+	switch s.Kind {
+	case KindOnce:
+		if !now.Before(s.StartAt) && now.Before(s.EndAt) {
+			return s.StartAt, s.EndAt, true
+		}
+	case KindRecurring:
+		spec, loc, ok := s.recurrence()
+		if !ok {
+			return time.Time{}, time.Time{}, false
+		}
+		duration := time.Duration(s.DurationMin) * time.Minute
+		start, found := spec.Prev(now.In(loc), duration)
+		if found {
+			end := start.Add(duration)
+			if now.Before(end) {
+				return start, end, true
+			}
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// PreviousWindow returns the most recent window that ended at or before now.
+func (s *Schedule) PreviousWindow(now time.Time) (time.Time, time.Time, bool) {
+	switch s.Kind {
+	case KindOnce:
+		if !now.Before(s.EndAt) {
+			return s.StartAt, s.EndAt, true
+		}
+	case KindRecurring:
+		spec, loc, ok := s.recurrence()
+		if !ok {
+			return time.Time{}, time.Time{}, false
+		}
+		duration := time.Duration(s.DurationMin) * time.Minute
+		start, found := spec.Prev(now.In(loc).Add(-duration), lookback)
+		if found {
+			return start, start.Add(duration), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func (s *Schedule) recurrence() (*CronSpec, *time.Location, bool) {
+	spec, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil, nil, false
+	}
+	loc, err := loadLocation(s.Timezone)
+	if err != nil {
+		return nil, nil, false
+	}
+	return spec, loc, true
+}
@@ -0,0 +1,73 @@
+package stream
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// Audio modes: keep the source's audio (silence when it has none), play an
+// audio playlist over the video, or mute the stream.
+const (
+	AudioSource   = "source"
+	AudioPlaylist = "playlist"
+	AudioMute     = "mute"
+)
+
+type AudioSettings struct {
+	Mode      string      `bun:",notnull,default:'source'" json:"mode"`
+	Playlist  []uuid.UUID `bun:",type:json" json:"playlist"`
+	Normalize bool        `bun:",notnull,default:false" json:"normalize"`
+}
+
+func (a AudioSettings) Validate() error {
+	switch a.Mode {
+	case "", AudioSource, AudioMute:
+		if len(a.Playlist) > 0 {
+			return audioError("playlist needs the %s mode", AudioPlaylist)
+		}
+	case AudioPlaylist:
+		if len(a.Playlist) == 0 {
+			return audioError("playlist mode needs at least one audio file")
+		}
+	default:
+		return audioError("unsupported mode %q", a.Mode)
+	}
+	return nil
+}
+
+// Replaced reports whether the source's audio is dropped entirely.
+func (a AudioSettings) Replaced() bool {
+	return a.Mode == AudioPlaylist || a.Mode == AudioMute
+}
+
+// AudioPlan is how the pipeline sources the stream's audio.
+type AudioPlan struct {
+	Playlist  []QueueItem
+	Mute      bool
+	Normalize bool
+}
+
+// silenceFor reports whether an input of the given items needs generated
+// silence: the concat demuxer cannot mix files with and without audio, so a
+// single silent item silences the whole queue.
+func (a AudioPlan) silenceFor(items []QueueItem) bool {
+	if a.Mute {
+		return true
+	}
+	for _, item := range items {
+		if !item.HasAudio {
+			return true
+		}
+	}
+	return false
+}
+
+func audioError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidAudio, fmt.Sprintf(format, args...))
+}
+
+func audioPlaylistPath(streamID uuid.UUID) string {
+	return filepath.Join("data", "playlists", streamID.String()+"-audio.txt")
+}
@@ -0,0 +1,311 @@
+package stream
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
+)
+
+const (
+	ProtocolRTMP  = "rtmp"
+	ProtocolRTMPS = "rtmps"
+	ProtocolSRT   = "srt"
+)
+
+// Destination is a typed output target. Legacy rtmp_targets URLs are parsed
+// into destinations with default options.
+type Destination struct {
+	Protocol string             `json:"protocol"`
+	URL      string             `json:"url"`
+	Options  DestinationOptions `json:"options"`
+	Encoding *Encoding          `json:"encoding,omitempty"`
+}
+
+// DestinationOptions holds the per-protocol settings. SRT fields only apply to
+// srt destinations and TLS fields only to rtmps.
+type DestinationOptions struct {
+	LatencyMs  int    `json:"latency_ms,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+	PBKeyLen   int    `json:"pbkeylen,omitempty"`
+	StreamID   string `json:"stream_id,omitempty"`
+	TLSVerify  bool   `json:"tls_verify,omitempty"`
+	CAFile     string `json:"ca_file,omitempty"`
+}
+
+// ParseDestination turns a bare URL into a destination, taking the protocol
+// from its scheme.
+func ParseDestination(raw string) (Destination, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Destination{}, fmt.Errorf("%w: %v", ErrInvalidDestination, err)
+	}
+
+	d := Destination{Protocol: strings.ToLower(u.Scheme), URL: raw}
+	if err := d.Validate(); err != nil {
+		return Destination{}, err
+	}
+	return d, nil
+}
+
+func (d Destination) Validate() error {
+	u, err := url.Parse(strings.TrimSpace(d.URL))
+	if err != nil || u.Host == "" {
+		return destinationError("url must be an absolute URL with a host")
+	}
+	if !strings.EqualFold(u.Scheme, d.Protocol) {
+		return destinationError("url scheme %q does not match protocol %q", u.Scheme, d.Protocol)
+	}
+
+	if d.Encoding != nil {
+		if err := d.Encoding.Validate(); err != nil {
+			return err
+		}
+	}
+
+	opts := d.Options
+	switch d.Protocol {
+	case ProtocolRTMP, ProtocolRTMPS:
+		if strings.Trim(u.Path, "/") == "" {
+			return destinationError("%s url needs an application path and stream key", d.Protocol)
+		}
+		if opts.LatencyMs != 0 || opts.Passphrase != "" || opts.PBKeyLen != 0 || opts.StreamID != "" {
+			return destinationError("srt options do not apply to %s", d.Protocol)
+		}
+		if d.Protocol == ProtocolRTMP && (opts.TLSVerify || opts.CAFile != "") {
+			return destinationError("tls options need rtmps")
+		}
+		if opts.CAFile != "" {
+			if _, ok := resolveCAFile(opts.CAFile); !ok {
+				return destinationError("ca_file must name a certificate in the certificates directory")
+			}
+		}
+	case ProtocolSRT:
+		if u.Port() == "" {
+			return destinationError("srt url needs a port")
+		}
+		if opts.TLSVerify || opts.CAFile != "" {
+			return destinationError("tls options do not apply to srt")
+		}
+		if opts.LatencyMs != 0 && (opts.LatencyMs < 20 || opts.LatencyMs > 8000) {
+			return destinationError("latency_ms must be between 20 and 8000")
+		}
+		if opts.Passphrase != "" && (len(opts.Passphrase) < 10 || len(opts.Passphrase) > 79) {
+			return destinationError("passphrase must be 10 to 79 characters")
+		}
+		switch opts.PBKeyLen {
+		case 0:
+		case 16, 24, 32:
+			if opts.Passphrase == "" {
+				return destinationError("pbkeylen needs a passphrase")
+			}
+		default:
+			return destinationError("pbkeylen must be 16, 24 or 32")
+		}
+		if len(opts.StreamID) > 512 {
+			return destinationError("stream_id must be at most 512 characters")
+		}
+	default:
+		return destinationError("unsupported protocol %q", d.Protocol)
+	}
+
+	return nil
+}
+
+// Output renders the destination for ffmpeg: FLV for RTMP(S), MPEG-TS for SRT.
+func (d Destination) Output() ffmpeg.Output {
+	out := ffmpeg.Output{Format: "flv", URL: strings.TrimSpace(d.URL), Options: map[string]string{}}
+	opts := d.Options
+
+	switch d.Protocol {
+	case ProtocolSRT:
+		out.Format = "mpegts"
+		if opts.LatencyMs > 0 {
+			// ffmpeg takes SRT latency in microseconds.
+			out.Options["latency"] = strconv.Itoa(opts.LatencyMs * 1000)
+		}
+		if opts.Passphrase != "" {
+			out.Options["passphrase"] = opts.Passphrase
+		}
+		if opts.PBKeyLen > 0 {
+			out.Options["pbkeylen"] = strconv.Itoa(opts.PBKeyLen)
+		}
+		if opts.StreamID != "" {
+			out.Options["streamid"] = opts.StreamID
+		}
+	case ProtocolRTMPS:
+		if opts.TLSVerify {
+			out.Options["tls_verify"] = "1"
+		}
+		if path, ok := resolveCAFile(opts.CAFile); ok {
+			out.Options["ca_file"] = path
+		}
+	}
+
+	return out
+}
+
+var certsRoot = filepath.Join("data", "certs")
+
+// SetCertsDir sets the directory CA files are read from. Destinations can
+// only name certificates inside it.
+func SetCertsDir(dir string) {
+	certsRoot = dir
+}
+
+// resolveCAFile returns the real path of a CA file named relative to the
+// certificates directory, or by an absolute path inside it. Anything that
+// resolves outside the directory, or is not a regular file, is refused.
+func resolveCAFile(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	root, err := filepath.Abs(certsRoot)
+	if err != nil {
+		return "", false
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", false
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return path, true
+}
+
+// Display is the destination URL with its stream key hidden.
+func (d Destination) Display() string {
+	return maskTarget(d.URL)
+}
+
+// Redact hides the destination's URL and passphrase wherever they appear in
+// text, such as an ffmpeg error line naming the output it failed to open.
+func (d Destination) Redact(text string) string {
+	if target := strings.TrimSpace(d.URL); target != "" {
+		text = strings.ReplaceAll(text, target, d.Display())
+	}
+	if d.Options.Passphrase != "" {
+		text = strings.ReplaceAll(text, d.Options.Passphrase, secret.Mask(d.Options.Passphrase))
+	}
+	return text
+}
+
+// Masked is the destination with the stream key in its URL and its
+// passphrase hidden.
+func (d Destination) Masked() Destination {
+	d.URL = maskTarget(d.URL)
+	d.Options.Passphrase = secret.Mask(d.Options.Passphrase)
+	return d
+}
+
+func maskTargets(targets []string) []string {
+	if targets == nil {
+		return nil
+	}
+	masked := make([]string, len(targets))
+	for i, target := range targets {
+		masked[i] = maskTarget(target)
+	}
+	return masked
+}
+
+// maskedIndex returns the index of the current value whose mask was
+// submitted, preferring the one at the same position, or -1.
+func maskedIndex(submitted string, i int, current []string) int {
+	if i < len(current) && submitted == maskTarget(current[i]) {
+		return i
+	}
+	for j, value := range current {
+		if submitted == maskTarget(value) {
+			return j
+		}
+	}
+	return -1
+}
+
+// unmaskTargets puts back the targets a form was only shown masked and sent
+// back unchanged.
+func unmaskTargets(submitted, current []string) []string {
+	if submitted == nil {
+		return nil
+	}
+	targets := make([]string, len(submitted))
+	for i, target := range submitted {
+		if j := maskedIndex(target, i, current); j >= 0 {
+			target = current[j]
+		}
+		targets[i] = target
+	}
+	return targets
+}
+
+// unmaskDestinations puts back the URLs and passphrases of destinations a
+// form was only shown masked and sent back unchanged.
+func unmaskDestinations(submitted, current []Destination) []Destination {
+	if submitted == nil {
+		return nil
+	}
+	urls := make([]string, len(current))
+	for i, d := range current {
+		urls[i] = d.URL
+	}
+
+	destinations := make([]Destination, len(submitted))
+	for i, d := range submitted {
+		var prev Destination
+		if j := maskedIndex(d.URL, i, urls); j >= 0 {
+			prev = current[j]
+			d.URL = prev.URL
+		} else if i < len(current) {
+			prev = current[i]
+		}
+		d.Options.Passphrase = secret.Unmask(d.Options.Passphrase, prev.Options.Passphrase)
+		destinations[i] = d
+	}
+	return destinations
+}
+
+// ResolveDestinations returns every output of the stream: the legacy
+// rtmp_targets followed by the typed destinations.
+func (s *Stream) ResolveDestinations() ([]Destination, error) {
+	destinations := make([]Destination, 0, len(s.RTMPTargets)+len(s.Destinations))
+	for _, target := range s.RTMPTargets {
+		if strings.TrimSpace(target) == "" {
+			continue
+		}
+		d, err := ParseDestination(target)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", maskTarget(target), err)
+		}
+		destinations = append(destinations, d)
+	}
+	for _, d := range s.Destinations {
+		if err := d.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", d.Display(), err)
+		}
+		destinations = append(destinations, d)
+	}
+	return destinations, nil
+}
+
+func destinationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidDestination, fmt.Sprintf(format, args...))
+}
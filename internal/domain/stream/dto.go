@@ -3,30 +3,56 @@ package stream
 import "github.com/google/uuid"
 
 type CreateStreamDTO struct {
-	VideoID     uuid.UUID `json:"video_id"`
-	Name        string    `json:"name" validate:"required,min=3"`
-	RTMPTargets []string  `json:"rtmp_targets" validate:"required"`
-	Bitrate     int       `json:"bitrate" validate:"required,min=500"`
-	Resolution  string    `json:"resolution"`
-	FPS         int       `json:"fps"`
-	Loop        bool      `json:"loop"`
+	VideoID          uuid.UUID      `json:"video_id"`
+	Name             string         `json:"name" validate:"required,min=3"`
+	RTMPTargets      []string       `json:"rtmp_targets"`
+	Destinations     []Destination  `json:"destinations"`
+	PlatformIDs      []uuid.UUID    `json:"platform_ids"`
+	Bitrate          int            `json:"bitrate" validate:"required,min=500"`
+	Resolution       string         `json:"resolution"`
+	Fit              string         `json:"fit"`
+	CropOffset       *int           `json:"crop_offset"`
+	FPS              int            `json:"fps"`
+	Loop             bool           `json:"loop"`
+	RestartPolicy    *RestartPolicy `json:"restart_policy"`
+	SlowAfterSec     *int           `json:"slow_after_sec"`
+	EncoderProfileID *uuid.UUID     `json:"encoder_profile_id"`
+	Passthrough      bool           `json:"passthrough"`
+	SourceType       string         `json:"source_type"`
+	FallbackVideoID  *uuid.UUID     `json:"fallback_video_id"`
+	Overlays         []Overlay      `json:"overlays"`
+	Audio            *AudioSettings `json:"audio"`
 }
 
 type UpdateStreamDTO struct {
-	VideoID     uuid.UUID `json:"video_id"`
-	Name        string    `json:"name"`
-	RTMPTargets []string  `json:"rtmp_targets"`
-	Bitrate     int       `json:"bitrate"`
-	Resolution  string    `json:"resolution"`
-	FPS         int       `json:"fps"`
-	Loop        bool      `json:"loop"`
+	VideoID          uuid.UUID      `json:"video_id"`
+	Name             string         `json:"name"`
+	RTMPTargets      []string       `json:"rtmp_targets"`
+	Destinations     []Destination  `json:"destinations"`
+	PlatformIDs      []uuid.UUID    `json:"platform_ids"`
+	Bitrate          int            `json:"bitrate"`
+	Resolution       string         `json:"resolution"`
+	Fit              string         `json:"fit"`
+	CropOffset       *int           `json:"crop_offset"`
+	FPS              int            `json:"fps"`
+	Loop             bool           `json:"loop"`
+	RestartPolicy    *RestartPolicy `json:"restart_policy"`
+	SlowAfterSec     *int           `json:"slow_after_sec"`
+	EncoderProfileID *uuid.UUID     `json:"encoder_profile_id"`
+	Passthrough      *bool          `json:"passthrough"`
+	SourceType       string         `json:"source_type"`
+	FallbackVideoID  *uuid.UUID     `json:"fallback_video_id"`
+	Overlays         []Overlay      `json:"overlays"`
+	Audio            *AudioSettings `json:"audio"`
 }
 
 type SaveProgramDTO struct {
 	Name         string      `json:"name"`
 	VideoIDs     []uuid.UUID `json:"video_ids"`
 	RTMPTargets  []string    `json:"rtmp_targets"`
+	PlatformIDs  []uuid.UUID `json:"platform_ids"`
 	Bitrate      int         `json:"bitrate"`
 	Resolution   string      `json:"resolution"`
+	Overlays     []Overlay   `json:"overlays"`
 	ApplyLiveNow bool        `json:"apply_live_now"`
 }
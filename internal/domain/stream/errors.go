@@ -3,8 +3,21 @@ package stream
 import "errors"
 
 var (
-	ErrStreamNotFound       = errors.New("stream not found")
-	ErrStreamAlreadyRunning = errors.New("stream already running")
-	ErrInvalidRTMPTarget    = errors.New("invalid RTMP target")
-	ErrStreamProgramEmpty   = errors.New("stream program has no videos")
+	ErrStreamNotFound          = errors.New("stream not found")
+	ErrStreamAlreadyRunning    = errors.New("stream already running")
+	ErrInvalidRTMPTarget       = errors.New("invalid RTMP target")
+	ErrStreamProgramEmpty      = errors.New("stream program has no videos")
+	ErrInvalidRestartPolicy    = errors.New("invalid restart policy")
+	ErrInvalidSlowAfter        = errors.New("invalid slow encoding threshold")
+	ErrPassthroughIncompatible = errors.New("source is not compatible with passthrough")
+	ErrInvalidDestination      = errors.New("invalid destination")
+	ErrInvalidSourceType       = errors.New("invalid source type")
+	ErrIngestKeyUnknown        = errors.New("unknown ingest key")
+	ErrIngestBusy              = errors.New("stream already has a live publisher")
+	ErrFallbackVideoNotFound   = errors.New("fallback video not found")
+	ErrInvalidOverlay          = errors.New("invalid overlay")
+	ErrInvalidAudio            = errors.New("invalid audio settings")
+	ErrInvalidFit              = errors.New("invalid fit")
+	ErrReframeIncompatible     = errors.New("source cannot be reframed")
+	ErrSessionNotFound         = errors.New("stream session not found")
 )
@@ -0,0 +1,56 @@
+package ffmpeg
+
+import "fmt"
+
+// loudnormFilter normalises to EBU R128 at the -16 LUFS most streaming
+// platforms target.
+const loudnormFilter = "loudnorm=I=-16:TP=-1.5:LRA=11"
+
+// silenceSource generates the silent track used when a source has no audio.
+const silenceSource = "anullsrc=channel_layout=stereo:sample_rate=44100"
+
+// audioInputArgs returns the extra input that replaces the source's audio,
+// if any.
+func (b *CommandBuilder) audioInputArgs() []string {
+	switch {
+	case b.audioPlaylist != "":
+		var args []string
+		if !b.liveInput {
+			args = append(args, "-re")
+		}
+		return append(args, "-stream_loop", "-1", "-f", "concat", "-safe", "0", "-i", b.audioPlaylist)
+	case b.silence:
+		return []string{"-f", "lavfi", "-i", silenceSource}
+	default:
+		return nil
+	}
+}
+
+// audioMap is the audio stream every output takes.
+func (b *CommandBuilder) audioMap() string {
+	if b.audioInput > 0 {
+		return fmt.Sprintf("%d:a", b.audioInput)
+	}
+	return "0:a"
+}
+
+// endsWithVideo reports whether the outputs need -shortest: replacement
+// audio never ends on its own, so a video input that does must end them.
+func (b *CommandBuilder) endsWithVideo() bool {
+	return b.audioInput > 0 && (b.liveInput || !b.loop)
+}
+
+// CheckAudio verifies the filters needed to generate silence and to
+// normalise loudness. A nil receiver skips the check.
+func (c *Capabilities) CheckAudio(silence, loudnorm bool) error {
+	if c == nil {
+		return nil
+	}
+	if silence && !c.HasFilter("anullsrc") {
+		return fmt.Errorf("audio filter anullsrc is %w", ErrUnsupported)
+	}
+	if loudnorm && !c.HasFilter("loudnorm") {
+		return fmt.Errorf("audio filter loudnorm is %w", ErrUnsupported)
+	}
+	return nil
+}
@@ -2,17 +2,41 @@ package ffmpeg
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 type CommandBuilder struct {
 	inputFile    string
+	playlist     bool
 	bitrate      int
 	resolution   string
+	fit          string
+	cropOffset   int
 	fps          int
 	loop         bool
-	destinations []string
-	preset       string
+	destinations []Output
+	pipeOutput   bool
+	encoder      EncoderSettings
+	passthrough  bool
+	caps         *Capabilities
+	previewDir   string
+	liveInput    bool
+	outputOffset time.Duration
+	overlays     []Overlay
+	// Audio replacing the source's own: a looping concat playlist or
+	// generated silence, read from input audioInput.
+	audioPlaylist string
+	silence       bool
+	loudnorm      bool
+	audioInput    int
+	renditions    []Rendition
+	progressPipe  int
+	// Video labels set by Build when the video compiles to a filter graph.
+	mainVideo      string
+	previewVideo   string
+	renditionVideo []string
 }
 
 func NewCommandBuilder() *CommandBuilder {
@@ -21,7 +45,7 @@ func NewCommandBuilder() *CommandBuilder {
 		resolution: "1280x720",
 		fps:        30,
 		loop:       true,
-		preset:     "veryfast",
+		encoder:    DefaultEncoderSettings(),
 	}
 }
 
@@ -30,6 +54,29 @@ func (b *CommandBuilder) WithInput(file string) *CommandBuilder {
 	return b
 }
 
+func (b *CommandBuilder) WithPlaylist(file string) *CommandBuilder {
+	b.inputFile = file
+	b.playlist = true
+	return b
+}
+
+// WithLiveInput reads an FLV stream from stdin, as pushed by the ingest
+// listener. Live input is already paced, so it is neither throttled nor looped.
+func (b *CommandBuilder) WithLiveInput() *CommandBuilder {
+	b.inputFile = "pipe:0"
+	b.liveInput = true
+	b.playlist = false
+	return b
+}
+
+// WithOutputOffset shifts the piped output's timestamps so that an encoder
+// started mid-stream carries on where the previous one stopped, keeping the
+// relays' timestamps monotonic.
+func (b *CommandBuilder) WithOutputOffset(offset time.Duration) *CommandBuilder {
+	b.outputOffset = offset
+	return b
+}
+
 func (b *CommandBuilder) WithBitrate(bitrate int) *CommandBuilder {
 	b.bitrate = bitrate
 	return b
@@ -40,6 +87,28 @@ func (b *CommandBuilder) WithResolution(res string) *CommandBuilder {
 	return b
 }
 
+// WithFit sets how the main rendition fits a source of another aspect ratio.
+// cropOffset places the crop window of FitOffset.
+func (b *CommandBuilder) WithFit(fit string, cropOffset int) *CommandBuilder {
+	b.fit = fit
+	b.cropOffset = cropOffset
+	return b
+}
+
+// WithProgress reports progress to the given file descriptor (see
+// ProgressPipe) rather than on stderr.
+func (b *CommandBuilder) WithProgress(fd int) *CommandBuilder {
+	b.progressPipe = fd
+	return b
+}
+
+// WithRenditions adds encodes beside the main one, each piped to its own
+// file descriptor (see RenditionPipe). They need piped output.
+func (b *CommandBuilder) WithRenditions(renditions []Rendition) *CommandBuilder {
+	b.renditions = renditions
+	return b
+}
+
 func (b *CommandBuilder) WithFPS(fps int) *CommandBuilder {
 	b.fps = fps
 	return b
@@ -50,13 +119,75 @@ func (b *CommandBuilder) WithLoop(loop bool) *CommandBuilder {
 	return b
 }
 
-func (b *CommandBuilder) WithDestinations(dest []string) *CommandBuilder {
+func (b *CommandBuilder) WithDestinations(dest []Output) *CommandBuilder {
 	b.destinations = dest
 	return b
 }
 
+// WithPipeOutput writes a single MPEG-TS stream to stdout instead of pushing
+// to the destinations directly, so each destination can be relayed separately.
+func (b *CommandBuilder) WithPipeOutput() *CommandBuilder {
+	b.pipeOutput = true
+	return b
+}
+
 func (b *CommandBuilder) WithPreset(p string) *CommandBuilder {
-	b.preset = p
+	b.encoder.Preset = p
+	return b
+}
+
+// WithPassthrough copies the source streams instead of re-encoding them. The
+// caller is responsible for checking the source is compatible.
+func (b *CommandBuilder) WithPassthrough(enabled bool) *CommandBuilder {
+	b.passthrough = enabled
+	return b
+}
+
+// WithPreview adds a low-bitrate HLS rendition written to dir. It is a second
+// output rather than a tee slave so it can be encoded separately from the
+// main stream.
+func (b *CommandBuilder) WithPreview(dir string) *CommandBuilder {
+	b.previewDir = dir
+	return b
+}
+
+// WithOverlays burns images and text into the encoded video. They are
+// compiled, together with the scaling, into a single -filter_complex graph.
+func (b *CommandBuilder) WithOverlays(overlays []Overlay) *CommandBuilder {
+	b.overlays = overlays
+	return b
+}
+
+// WithAudioPlaylist takes the audio from a concat playlist that loops
+// independently of the video instead of from the source.
+func (b *CommandBuilder) WithAudioPlaylist(file string) *CommandBuilder {
+	b.audioPlaylist = file
+	return b
+}
+
+// WithSilence replaces the source's audio with a silent track, for sources
+// that have none or streams that are muted.
+func (b *CommandBuilder) WithSilence(enabled bool) *CommandBuilder {
+	b.silence = enabled
+	return b
+}
+
+// WithLoudnorm normalises the outgoing audio to EBU R128.
+func (b *CommandBuilder) WithLoudnorm(enabled bool) *CommandBuilder {
+	b.loudnorm = enabled
+	return b
+}
+
+// WithCapabilities makes Build fail with a precise error when the requested
+// settings need something the installed ffmpeg lacks.
+func (b *CommandBuilder) WithCapabilities(c *Capabilities) *CommandBuilder {
+	b.caps = c
+	return b
+}
+
+// WithEncoder replaces the default codec settings with a saved profile.
+func (b *CommandBuilder) WithEncoder(e EncoderSettings) *CommandBuilder {
+	b.encoder = e
 	return b
 }
 
@@ -64,65 +195,254 @@ func (b *CommandBuilder) Build() ([]string, error) {
 	if b.inputFile == "" {
 		return nil, fmt.Errorf("input file is required")
 	}
-	if len(b.destinations) == 0 {
+	if len(b.destinations) == 0 && !b.pipeOutput {
 		return nil, fmt.Errorf("at least one destination is required")
 	}
+	if len(b.renditions) > 0 && !b.pipeOutput {
+		return nil, fmt.Errorf("extra renditions require piped output")
+	}
+	if b.passthrough && len(b.renditions) > 0 {
+		return nil, fmt.Errorf("extra renditions require re-encoding and cannot be used with passthrough")
+	}
+	if b.passthrough && len(b.overlays) > 0 {
+		return nil, fmt.Errorf("overlays require re-encoding and cannot be used with passthrough")
+	}
+	if b.passthrough && (b.audioPlaylist != "" || b.silence || b.loudnorm) {
+		return nil, fmt.Errorf("audio replacement and loudness normalization require re-encoding and cannot be used with passthrough")
+	}
+	if !b.passthrough {
+		if err := b.encoder.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid encoder settings: %w", err)
+		}
+	}
+	if err := b.checkCapabilities(); err != nil {
+		return nil, err
+	}
 
-	args := []string{"-re"}
-
-	if b.loop {
-		args = append(args, "-stream_loop", "-1")
+	var args []string
+	if b.progressPipe > 0 {
+		args = append(args, ProgressArgs(b.progressPipe)...)
+	}
+	if b.liveInput {
+		args = append(args, "-f", "flv")
+	} else {
+		args = append(args, "-re")
+		if b.loop {
+			args = append(args, "-stream_loop", "-1")
+		}
+		if b.playlist {
+			args = append(args, "-f", "concat", "-safe", "0")
+		}
 	}
 
 	args = append(args, "-thread_queue_size", "1024", "-i", b.inputFile)
+	inputs := 1
+	for _, o := range b.overlays {
+		if o.Type == OverlayImage {
+			args = append(args, "-i", o.ImagePath)
+			inputs++
+		}
+	}
+	b.audioInput = 0
+	if audioArgs := b.audioInputArgs(); audioArgs != nil {
+		args = append(args, audioArgs...)
+		b.audioInput = inputs
+	}
 
-	bitrateVal := b.bitrate
-	if bitrateVal == 0 {
-		bitrateVal = 2500
+	if b.passthrough {
+		args = append(args, "-c:v", "copy", "-c:a", "copy")
+		return b.appendPreview(b.appendOutput(args)), nil
 	}
-	bitrateStr := fmt.Sprintf("%dk", bitrateVal)
-	bufSize := fmt.Sprintf("%dk", bitrateVal*2)
 
-	fpsVal := b.fps
-	if fpsVal == 0 {
-		fpsVal = 30
+	main := b.mainRendition()
+	args = append(args, b.encoder.videoArgs(main.bitrate(), main.fps())...)
+
+	vf := main.videoChain(b.encoder.Filters, renditionPrefix(0))
+	b.mainVideo, b.previewVideo, b.renditionVideo = "", "", nil
+	if len(b.overlays) > 0 || len(b.renditions) > 0 {
+		chains := []string{vf}
+		for i, r := range b.renditions {
+			chains = append(chains, r.videoChain(b.encoder.Filters, renditionPrefix(i+1)))
+		}
+		graph, outputs, previewVideo := videoGraph(chains, b.overlays, 1, b.previewDir != "", previewHeight)
+		args = append(args, "-filter_complex", graph)
+		b.mainVideo = "[" + outputs[0] + "]"
+		for _, label := range outputs[1:] {
+			b.renditionVideo = append(b.renditionVideo, "["+label+"]")
+		}
+		if previewVideo != "" {
+			b.previewVideo = "[" + previewVideo + "]"
+		}
+	} else {
+		args = append(args, "-vf", vf)
 	}
+	args = append(args, b.audioOutputArgs()...)
 
-	args = append(args,
-		"-c:v", "libx264",
-		"-preset", b.preset,
-		"-tune", "zerolatency",
-		"-profile:v", "high",
-		"-b:v", bitrateStr,
-		"-maxrate", bitrateStr,
-		"-minrate", bitrateStr,
-		"-bufsize", bufSize,
-		"-pix_fmt", "yuv420p",
-		"-g", fmt.Sprintf("%d", fpsVal*2),
-		"-r", fmt.Sprintf("%d", fpsVal),
-	)
+	args = b.appendOutput(args)
+	for i, r := range b.renditions {
+		args = append(args, "-map", b.renditionVideo[i], "-map", b.audioMap())
+		args = append(args, b.encoder.videoArgs(r.bitrate(), r.fps())...)
+		args = append(args, b.audioOutputArgs()...)
+		args = b.appendPipe(args, RenditionPipe(i))
+	}
+	return b.appendPreview(args), nil
+}
 
-	vf := fmt.Sprintf("scale=%s", b.resolution)
-	args = append(args, "-vf", vf)
-	args = append(args,
-		"-c:a", "aac",
-		"-ac", "2",
-		"-ar", "44100",
-		"-b:a", "128k",
-	)
+func (b *CommandBuilder) mainRendition() Rendition {
+	return Rendition{Resolution: b.resolution, Bitrate: b.bitrate, FPS: b.fps, Fit: b.fit, CropOffset: b.cropOffset}
+}
+
+// audioOutputArgs are the audio encoding flags every encoded output takes.
+func (b *CommandBuilder) audioOutputArgs() []string {
+	args := b.encoder.audioArgs()
+	if b.loudnorm {
+		args = append(args, "-af", loudnormFilter)
+	}
+	if b.endsWithVideo() {
+		args = append(args, "-shortest")
+	}
+	return args
+}
+
+// videoMap is the video stream the main output takes: the overlay graph's
+// output when there is one, otherwise the input's.
+func (b *CommandBuilder) videoMap() string {
+	if b.mainVideo != "" {
+		return b.mainVideo
+	}
+	return "0:v"
+}
+
+func (b *CommandBuilder) appendOutput(args []string) []string {
+	if b.pipeOutput {
+		args = append(args, "-map", b.videoMap(), "-map", b.audioMap())
+		return b.appendPipe(args, 1)
+	}
 
 	args = append(args,
 		"-f", "tee",
-		"-map", "0:v",
-		"-map", "0:a",
+		"-map", b.videoMap(),
+		"-map", b.audioMap(),
 	)
 
 	destStrings := make([]string, len(b.destinations))
 	for i, d := range b.destinations {
-		destStrings[i] = fmt.Sprintf("[f=flv:onfail=ignore]%s", d)
+		format := d.Format
+		if format == "" {
+			format = "flv"
+		}
+		destStrings[i] = fmt.Sprintf("[f=%s:onfail=ignore]%s", format, d.teeURL())
 	}
 	teeArg := strings.Join(destStrings, "|")
-	args = append(args, teeArg)
+	return append(args, teeArg)
+}
+
+// appendPipe writes the output as MPEG-TS to the given file descriptor.
+func (b *CommandBuilder) appendPipe(args []string, fd int) []string {
+	if b.outputOffset > 0 {
+		args = append(args, "-output_ts_offset", fmt.Sprintf("%.3f", b.outputOffset.Seconds()))
+	}
+	return append(args, "-f", "mpegts", fmt.Sprintf("pipe:%d", fd))
+}
+
+// Preview rendition settings, kept small so the preview costs little CPU.
+const (
+	PreviewPlaylist   = "index.m3u8"
+	previewHeight     = 360
+	previewBitrate    = 400
+	previewSegmentSec = 2
+)
 
-	return args, nil
+func (b *CommandBuilder) appendPreview(args []string) []string {
+	if b.previewDir == "" {
+		return args
+	}
+
+	switch {
+	case b.passthrough:
+		args = append(args, "-map", "0:v", "-map", "0:a", "-c", "copy")
+	case b.previewVideo != "":
+		// The overlay graph already scaled the preview branch; -vf cannot
+		// apply to a filter graph output.
+		args = append(args, "-map", b.previewVideo, "-map", b.audioMap())
+	default:
+		args = append(args, "-map", "0:v", "-map", b.audioMap(), "-vf", fmt.Sprintf("scale=-2:%d", previewHeight))
+	}
+	if b.endsWithVideo() {
+		args = append(args, "-shortest")
+	}
+	if !b.passthrough {
+		args = append(args,
+			"-c:v", "libx264",
+			"-preset", "veryfast",
+			"-tune", "zerolatency",
+			"-b:v", fmt.Sprintf("%dk", previewBitrate),
+			"-maxrate", fmt.Sprintf("%dk", previewBitrate),
+			"-bufsize", fmt.Sprintf("%dk", previewBitrate*2),
+			"-pix_fmt", "yuv420p",
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", previewSegmentSec),
+			"-c:a", "aac",
+			"-b:a", "64k",
+			"-ac", "2",
+			"-ar", "44100",
+		)
+	}
+
+	return append(args,
+		"-f", "hls",
+		"-hls_time", fmt.Sprintf("%d", previewSegmentSec),
+		"-hls_list_size", "6",
+		"-hls_flags", "delete_segments+omit_endlist",
+		"-hls_base_url", "preview/",
+		"-hls_segment_filename", filepath.Join(b.previewDir, "seg_%05d.ts"),
+		filepath.Join(b.previewDir, PreviewPlaylist),
+	)
+}
+
+func (b *CommandBuilder) checkCapabilities() error {
+	if b.caps == nil {
+		return nil
+	}
+
+	if b.passthrough {
+		if err := b.caps.CheckPipeline(); err != nil {
+			return err
+		}
+	} else if err := b.caps.CheckEncoder(b.encoder); err != nil {
+		return err
+	}
+
+	if err := b.caps.CheckOverlays(b.overlays, b.previewDir != ""); err != nil {
+		return err
+	}
+	if !b.passthrough {
+		if err := b.caps.CheckRenditions(append([]Rendition{b.mainRendition()}, b.renditions...)); err != nil {
+			return err
+		}
+	}
+	if err := b.caps.CheckAudio(b.silence && b.audioPlaylist == "", b.loudnorm); err != nil {
+		return err
+	}
+
+	if b.previewDir != "" {
+		if !b.caps.HasMuxer("hls") {
+			return fmt.Errorf("hls muxer is %w", ErrUnsupported)
+		}
+		if !b.passthrough && !b.caps.HasEncoder("libx264") {
+			return fmt.Errorf("preview encoder libx264 is %w", ErrUnsupported)
+		}
+	}
+
+	if b.pipeOutput {
+		return nil
+	}
+	if !b.caps.HasMuxer("tee") {
+		return fmt.Errorf("tee muxer is %w", ErrUnsupported)
+	}
+	for _, d := range b.destinations {
+		if err := b.caps.CheckDestination(d.URL); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,278 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupported       = errors.New("not supported by the installed ffmpeg")
+	ErrFFmpegUnavailable = errors.New("ffmpeg is not installed or could not be run")
+)
+
+// Capabilities is what the local ffmpeg build supports, probed once at startup.
+type Capabilities struct {
+	Available       bool            `json:"available"`
+	Version         string          `json:"version"`
+	Error           string          `json:"error,omitempty"`
+	Encoders        []string        `json:"encoders"`
+	Filters         []string        `json:"filters"`
+	Muxers          []string        `json:"muxers"`
+	InputProtocols  []string        `json:"input_protocols"`
+	OutputProtocols []string        `json:"output_protocols"`
+	Features        map[string]bool `json:"features"`
+	ProbedAt        time.Time       `json:"probed_at"`
+}
+
+// requiredMuxers are used by every pipeline: the encoder writes MPEG-TS and
+// each relay writes FLV.
+var requiredMuxers = []string{"mpegts", "flv"}
+
+// DetectCapabilities runs ffmpeg's listing commands and records the result.
+// A missing or broken ffmpeg is reported in the result rather than as an error
+// so the server can still start and explain the problem.
+func DetectCapabilities() *Capabilities {
+	caps := &Capabilities{ProbedAt: time.Now().UTC()}
+
+	version, err := runListing("-version")
+	if err != nil {
+		caps.Error = err.Error()
+		caps.Features = map[string]bool{}
+		return caps
+	}
+	caps.Available = true
+	caps.Version = ParseVersion(version)
+
+	if out, err := runListing("-encoders"); err == nil {
+		caps.Encoders = ParseEncoders(out)
+	}
+	if out, err := runListing("-filters"); err == nil {
+		caps.Filters = ParseFilters(out)
+	}
+	if out, err := runListing("-muxers"); err == nil {
+		caps.Muxers = ParseMuxers(out)
+	}
+	if out, err := runListing("-protocols"); err == nil {
+		caps.InputProtocols, caps.OutputProtocols = ParseProtocols(out)
+	}
+	caps.Features = caps.features()
+
+	return caps
+}
+
+func runListing(flag string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "ffmpeg", "-hide_banner", flag).Output()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg %s: %w", flag, err)
+	}
+	return string(out), nil
+}
+
+// features summarises the capabilities the UI cares about.
+func (c *Capabilities) features() map[string]bool {
+	features := map[string]bool{}
+	for _, p := range []string{"rtmp", "rtmps", "srt"} {
+		features[p] = c.HasOutputProtocol(p)
+	}
+	for name := range videoCodecs {
+		features[name] = c.HasEncoder(name)
+	}
+	for name := range audioCodecs {
+		features[name] = c.HasEncoder(name)
+	}
+	for _, m := range []string{"mpegts", "flv", "tee", "hls"} {
+		features[m] = c.HasMuxer(m)
+	}
+	return features
+}
+
+func (c *Capabilities) HasEncoder(name string) bool {
+	return slices.Contains(c.Encoders, name)
+}
+
+func (c *Capabilities) HasFilter(name string) bool {
+	return slices.Contains(c.Filters, name)
+}
+
+func (c *Capabilities) HasMuxer(name string) bool {
+	return slices.Contains(c.Muxers, name)
+}
+
+func (c *Capabilities) HasOutputProtocol(name string) bool {
+	return slices.Contains(c.OutputProtocols, name)
+}
+
+// CheckEncoder reports the first codec or filter in the settings that the
+// installed ffmpeg cannot provide. A nil receiver skips the check.
+func (c *Capabilities) CheckEncoder(e EncoderSettings) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.CheckPipeline(); err != nil {
+		return err
+	}
+	if !c.HasEncoder(e.VideoCodec) {
+		return fmt.Errorf("video encoder %s is %w", e.VideoCodec, ErrUnsupported)
+	}
+	if !c.HasEncoder(e.AudioCodec) {
+		return fmt.Errorf("audio encoder %s is %w", e.AudioCodec, ErrUnsupported)
+	}
+	return c.CheckFilters("scale," + e.Filters)
+}
+
+// CheckFilters verifies every filter named in a simple comma-separated chain.
+func (c *Capabilities) CheckFilters(chain string) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.CheckPipeline(); err != nil {
+		return err
+	}
+	for _, name := range FilterNames(chain) {
+		if !c.HasFilter(name) {
+			return fmt.Errorf("filter %s is %w", name, ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// CheckDestination verifies the protocol of an output URL.
+func (c *Capabilities) CheckDestination(url string) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.CheckPipeline(); err != nil {
+		return err
+	}
+	scheme, _, ok := strings.Cut(url, "://")
+	if !ok {
+		return fmt.Errorf("destination has no protocol")
+	}
+	scheme = strings.ToLower(scheme)
+	if !c.HasOutputProtocol(scheme) {
+		return fmt.Errorf("protocol %s is %w", scheme, ErrUnsupported)
+	}
+	return nil
+}
+
+// CheckPipeline verifies ffmpeg runs and has the muxers every stream needs.
+func (c *Capabilities) CheckPipeline() error {
+	if c == nil {
+		return nil
+	}
+	if !c.Available {
+		return ErrFFmpegUnavailable
+	}
+	for _, m := range requiredMuxers {
+		if !c.HasMuxer(m) {
+			return fmt.Errorf("%s muxer is %w", m, ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// FilterNames extracts the filter names from a simple filter chain such as
+// "scale=1280x720,eq=contrast=1.1".
+func FilterNames(chain string) []string {
+	var names []string
+	for _, part := range strings.Split(chain, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ParseVersion extracts the version from the first line of `ffmpeg -version`.
+func ParseVersion(out string) string {
+	line, _, _ := strings.Cut(out, "\n")
+	fields := strings.Fields(line)
+	if len(fields) >= 3 && fields[0] == "ffmpeg" && fields[1] == "version" {
+		return fields[2]
+	}
+	return ""
+}
+
+// ParseEncoders reads the names from `ffmpeg -encoders`, listed below the
+// " ------" separator after the flag legend.
+func ParseEncoders(out string) []string {
+	var names []string
+	listing := false
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if !listing {
+			listing = len(fields) == 1 && strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) >= 2 {
+			names = append(names, fields[1])
+		}
+	}
+	return sortedUnique(names)
+}
+
+// ParseFilters reads the names from `ffmpeg -filters`. Filter lines are the
+// ones with an "in->out" pad description in the third column.
+func ParseFilters(out string) []string {
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			names = append(names, fields[1])
+		}
+	}
+	return sortedUnique(names)
+}
+
+// ParseMuxers reads the formats `ffmpeg -muxers` can write, listed below the
+// " --" separator.
+func ParseMuxers(out string) []string {
+	var names []string
+	listing := false
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if !listing {
+			listing = len(fields) == 1 && strings.HasPrefix(fields[0], "--")
+			continue
+		}
+		if len(fields) >= 2 && strings.Contains(fields[0], "E") {
+			names = append(names, strings.Split(fields[1], ",")...)
+		}
+	}
+	return sortedUnique(names)
+}
+
+// ParseProtocols splits `ffmpeg -protocols` into its Input and Output lists.
+func ParseProtocols(out string) ([]string, []string) {
+	var input, output []string
+	var section *[]string
+	for _, line := range strings.Split(out, "\n") {
+		name := strings.TrimSpace(line)
+		switch {
+		case name == "Input:":
+			section = &input
+		case name == "Output:":
+			section = &output
+		case name == "" || strings.HasSuffix(name, ":"):
+			continue
+		case section != nil:
+			*section = append(*section, name)
+		}
+	}
+	return sortedUnique(input), sortedUnique(output)
+}
+
+func sortedUnique(values []string) []string {
+	sort.Strings(values)
+	return slices.Compact(values)
+}
@@ -0,0 +1,215 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	RateControlCBR = "cbr"
+	RateControlVBR = "vbr"
+	RateControlCRF = "crf"
+)
+
+// EncoderSettings describes how the encoder turns the source into the
+// outgoing stream. Bitrate, resolution and frame rate stay on the stream.
+type EncoderSettings struct {
+	VideoCodec      string `json:"video_codec"`
+	Preset          string `json:"preset"`
+	Tune            string `json:"tune"`
+	Profile         string `json:"profile"`
+	RateControl     string `json:"rate_control"`
+	CRF             int    `json:"crf"`
+	KeyframeSec     int    `json:"keyframe_sec"`
+	BFrames         int    `json:"b_frames"`
+	AudioCodec      string `json:"audio_codec"`
+	AudioBitrate    int    `json:"audio_bitrate"`
+	AudioSampleRate int    `json:"audio_sample_rate"`
+	AudioChannels   int    `json:"audio_channels"`
+	Filters         string `json:"filters"`
+}
+
+// DefaultEncoderSettings matches what the builder emitted before profiles
+// existed, so streams without a profile behave as they always have.
+func DefaultEncoderSettings() EncoderSettings {
+	return EncoderSettings{
+		VideoCodec:      "libx264",
+		Preset:          "veryfast",
+		Tune:            "zerolatency",
+		Profile:         "high",
+		RateControl:     RateControlCBR,
+		KeyframeSec:     2,
+		AudioCodec:      "aac",
+		AudioBitrate:    128,
+		AudioSampleRate: 44100,
+		AudioChannels:   2,
+	}
+}
+
+type videoCodec struct {
+	presets     []string
+	tunes       []string
+	rateControl []string
+}
+
+// videoCodecs lists the H.264 encoders the FLV relays can carry, with the
+// options each one understands.
+var videoCodecs = map[string]videoCodec{
+	"libx264": {
+		presets:     []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow"},
+		tunes:       []string{"film", "animation", "grain", "stillimage", "fastdecode", "zerolatency"},
+		rateControl: []string{RateControlCBR, RateControlVBR, RateControlCRF},
+	},
+	"h264_nvenc": {
+		presets:     []string{"p1", "p2", "p3", "p4", "p5", "p6", "p7"},
+		tunes:       []string{"hq", "ll", "ull"},
+		rateControl: []string{RateControlCBR, RateControlVBR},
+	},
+	"h264_qsv": {
+		presets:     []string{"veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow"},
+		rateControl: []string{RateControlCBR, RateControlVBR},
+	},
+	"h264_videotoolbox": {
+		rateControl: []string{RateControlCBR, RateControlVBR},
+	},
+}
+
+var h264Profiles = []string{"baseline", "main", "high"}
+
+// audioCodecs maps the audio encoders FLV accepts to their allowed sample rates.
+var audioCodecs = map[string][]int{
+	"aac":        {22050, 32000, 44100, 48000},
+	"libmp3lame": {11025, 22050, 44100},
+}
+
+// Validate rejects settings that ffmpeg would refuse or that the FLV relays
+// could not carry.
+func (e EncoderSettings) Validate() error {
+	codec, ok := videoCodecs[e.VideoCodec]
+	if !ok {
+		return fmt.Errorf("unsupported video codec %q", e.VideoCodec)
+	}
+	if e.Preset != "" && !slices.Contains(codec.presets, e.Preset) {
+		return fmt.Errorf("preset %q is not valid for %s", e.Preset, e.VideoCodec)
+	}
+	if e.Tune != "" && !slices.Contains(codec.tunes, e.Tune) {
+		return fmt.Errorf("tune %q is not valid for %s", e.Tune, e.VideoCodec)
+	}
+	if e.Profile != "" && !slices.Contains(h264Profiles, e.Profile) {
+		return fmt.Errorf("unsupported H.264 profile %q", e.Profile)
+	}
+	if !slices.Contains(codec.rateControl, e.RateControl) {
+		return fmt.Errorf("rate control %q is not supported by %s", e.RateControl, e.VideoCodec)
+	}
+	if e.RateControl == RateControlCRF && (e.CRF < 1 || e.CRF > 51) {
+		return fmt.Errorf("crf must be between 1 and 51")
+	}
+	if e.KeyframeSec < 1 || e.KeyframeSec > 10 {
+		return fmt.Errorf("keyframe interval must be between 1 and 10 seconds")
+	}
+	if e.BFrames < 0 || e.BFrames > 16 {
+		return fmt.Errorf("b-frames must be between 0 and 16")
+	}
+	if e.Profile == "baseline" && e.BFrames > 0 {
+		return fmt.Errorf("baseline profile does not support b-frames")
+	}
+
+	rates, ok := audioCodecs[e.AudioCodec]
+	if !ok {
+		return fmt.Errorf("unsupported audio codec %q", e.AudioCodec)
+	}
+	if !slices.Contains(rates, e.AudioSampleRate) {
+		return fmt.Errorf("sample rate %d is not supported by %s", e.AudioSampleRate, e.AudioCodec)
+	}
+	if e.AudioBitrate < 32 || e.AudioBitrate > 512 {
+		return fmt.Errorf("audio bitrate must be between 32 and 512 kbps")
+	}
+	if e.AudioChannels != 1 && e.AudioChannels != 2 {
+		return fmt.Errorf("audio channels must be 1 or 2")
+	}
+	return validateFilters(e.Filters)
+}
+
+// allowedFilters are the video filters a profile may add. Filters that read
+// files or run commands, such as drawtext, subtitles, movie or sendcmd, are
+// left out so a profile can never put server files into the stream.
+var allowedFilters = []string{
+	"boxblur", "bwdif", "colorbalance", "colorchannelmixer", "crop", "deband",
+	"deflicker", "eq", "gblur", "hflip", "hqdn3d", "hue", "negate", "nlmeans",
+	"pad", "setdar", "setsar", "transpose", "unsharp", "vflip", "vibrance", "yadif",
+}
+
+// fileOptions are filter options that take a file path. None of the allowed
+// filters has one, but they are refused outright in case one gains it.
+var fileOptions = []string{"f", "file", "filename", "fontfile", "psfile", "stats_file", "textfile"}
+
+func validateFilters(chain string) error {
+	if strings.TrimSpace(chain) == "" {
+		return nil
+	}
+	// Quoting and escaping could hide a separator from the checks below.
+	if strings.ContainsAny(chain, ";[]'\"\\\n\r") {
+		return fmt.Errorf("filters must be a simple comma-separated filter chain")
+	}
+	for _, part := range strings.Split(chain, ",") {
+		name, args, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if !slices.Contains(allowedFilters, name) {
+			return fmt.Errorf("filter %q is not allowed", name)
+		}
+		for _, arg := range strings.Split(args, ":") {
+			key, _, hasValue := strings.Cut(arg, "=")
+			if hasValue && slices.Contains(fileOptions, strings.TrimSpace(key)) {
+				return fmt.Errorf("filter option %q is not allowed", key)
+			}
+		}
+	}
+	return nil
+}
+
+// videoArgs renders the video encoder flags for the given target bitrate
+// (kbps) and frame rate.
+func (e EncoderSettings) videoArgs(bitrate, fps int) []string {
+	rate := fmt.Sprintf("%dk", bitrate)
+	bufSize := fmt.Sprintf("%dk", bitrate*2)
+
+	args := []string{"-c:v", e.VideoCodec}
+	if e.Preset != "" {
+		args = append(args, "-preset", e.Preset)
+	}
+	if e.Tune != "" {
+		args = append(args, "-tune", e.Tune)
+	}
+	if e.Profile != "" {
+		args = append(args, "-profile:v", e.Profile)
+	}
+
+	switch e.RateControl {
+	case RateControlCRF:
+		// Capped CRF keeps quality-based encoding within what the ingest accepts.
+		args = append(args, "-crf", fmt.Sprintf("%d", e.CRF), "-maxrate", rate, "-bufsize", bufSize)
+	case RateControlVBR:
+		args = append(args, "-b:v", rate, "-maxrate", fmt.Sprintf("%dk", bitrate*3/2), "-bufsize", bufSize)
+	default:
+		args = append(args, "-b:v", rate, "-maxrate", rate, "-minrate", rate, "-bufsize", bufSize)
+	}
+
+	gop := fmt.Sprintf("%d", fps*e.KeyframeSec)
+	args = append(args,
+		"-bf", fmt.Sprintf("%d", e.BFrames),
+		"-pix_fmt", "yuv420p",
+		"-g", gop,
+		"-keyint_min", gop,
+		"-r", fmt.Sprintf("%d", fps),
+	)
+	return args
+}
+
+func (e EncoderSettings) audioArgs() []string {
+	return []string{
+		"-c:a", e.AudioCodec,
+		"-ac", fmt.Sprintf("%d", e.AudioChannels),
+		"-ar", fmt.Sprintf("%d", e.AudioSampleRate),
+		"-b:a", fmt.Sprintf("%dk", e.AudioBitrate),
+	}
+}
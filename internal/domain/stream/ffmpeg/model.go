@@ -1,11 +1,26 @@
 package ffmpeg
 
+// Progress is one report of ffmpeg's -progress output.
 type Progress struct {
-	Frame   int
-	FPS     float64
-	Time    string
-	Bitrate string
-	Speed   float64
+	Frame      int     `json:"frame"`
+	FPS        float64 `json:"fps"`
+	Time       string  `json:"time"`
+	OutTimeUS  int64   `json:"out_time_us"`
+	Bitrate    string  `json:"bitrate"`
+	TotalSize  int64   `json:"total_size"`
+	Speed      float64 `json:"speed"`
+	DropFrames int     `json:"drop_frames"`
+	DupFrames  int     `json:"dup_frames"`
+	// Quality is the quantizer of each encoded stream, keyed by output and
+	// stream index as in "0:0".
+	Quality map[string]float64 `json:"quality,omitempty"`
+	// End is set on the last report, written as ffmpeg exits.
+	End bool `json:"end"`
+}
+
+// Seconds is how far into the output the report is.
+func (p *Progress) Seconds() float64 {
+	return float64(p.OutTimeUS) / 1e6
 }
 
 type StreamSettings struct {
@@ -0,0 +1,242 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Overlay kinds.
+const (
+	OverlayImage = "image"
+	OverlayText  = "text"
+)
+
+// Overlay positions. Margins are measured from the edges the overlay is
+// anchored to.
+const (
+	PositionTopLeft     = "top-left"
+	PositionTop         = "top"
+	PositionTopRight    = "top-right"
+	PositionCenter      = "center"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottom      = "bottom"
+	PositionBottomRight = "bottom-right"
+)
+
+// Overlay is one layer burned into the encoded video, either an image such
+// as a logo or a line of text such as a title or clock.
+type Overlay struct {
+	Type      string
+	ImagePath string
+	// TextFile holds the text, already expanded with ExpandOverlayText.
+	// drawtext reads it from a file so nothing needs filter-graph escaping.
+	TextFile string
+	Position string
+	Margin   int
+	// Width scales an image overlay, keeping its aspect ratio; zero keeps
+	// its own size.
+	Width    int
+	FontSize int
+	Color    string
+	BoxColor string
+	// Opacity is between 0 and 1; zero means fully opaque.
+	Opacity float64
+}
+
+// overlayTemplates are the placeholders text overlays may use, rendered
+// from the server's local time on every frame.
+var overlayTemplates = map[string]string{
+	"{time}":     `%{localtime:%H\:%M\:%S}`,
+	"{date}":     `%{localtime:%Y-%m-%d}`,
+	"{datetime}": `%{localtime:%Y-%m-%d %H\:%M\:%S}`,
+}
+
+// ExpandOverlayText turns overlay text into drawtext's expansion syntax,
+// escaping it so only the supported templates are evaluated.
+func ExpandOverlayText(text string) string {
+	text = strings.NewReplacer(`\`, `\\`, `%`, `\%`).Replace(text)
+	for placeholder, expr := range overlayTemplates {
+		text = strings.ReplaceAll(text, placeholder, expr)
+	}
+	return text
+}
+
+// WriteOverlayText writes an expanded overlay text for drawtext to read.
+func WriteOverlayText(path, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(ExpandOverlayText(text)), 0644)
+}
+
+// overlayXY returns the x and y expressions placing an object of size
+// (objW, objH) inside a frame of size (frameW, frameH).
+func overlayXY(position string, margin int, frameW, frameH, objW, objH string) (string, string) {
+	left := fmt.Sprintf("%d", margin)
+	right := fmt.Sprintf("%s-%s-%d", frameW, objW, margin)
+	centerX := fmt.Sprintf("(%s-%s)/2", frameW, objW)
+	top := fmt.Sprintf("%d", margin)
+	bottom := fmt.Sprintf("%s-%s-%d", frameH, objH, margin)
+	centerY := fmt.Sprintf("(%s-%s)/2", frameH, objH)
+
+	switch position {
+	case PositionTopLeft:
+		return left, top
+	case PositionTop:
+		return centerX, top
+	case PositionCenter:
+		return centerX, centerY
+	case PositionBottomLeft:
+		return left, bottom
+	case PositionBottom:
+		return centerX, bottom
+	case PositionBottomRight:
+		return right, bottom
+	default:
+		return right, top
+	}
+}
+
+func (o Overlay) opacity() float64 {
+	if o.Opacity <= 0 || o.Opacity > 1 {
+		return 1
+	}
+	return o.Opacity
+}
+
+// filterColor renders a color for ffmpeg, accepting #RRGGBB as well as
+// ffmpeg's own color names.
+func filterColor(color, fallback string, opacity float64) string {
+	if color == "" {
+		color = fallback
+	}
+	if strings.HasPrefix(color, "#") {
+		color = "0x" + color[1:]
+	}
+	return fmt.Sprintf("%s@%.2f", color, opacity)
+}
+
+// escapeFilterValue quotes a value for use inside a filter graph.
+func escapeFilterValue(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+func (o Overlay) drawtext() string {
+	fontSize := o.FontSize
+	if fontSize <= 0 {
+		fontSize = 32
+	}
+	x, y := overlayXY(o.Position, o.Margin, "w", "h", "text_w", "text_h")
+	filter := fmt.Sprintf("drawtext=textfile=%s:fontsize=%d:fontcolor=%s:x=%s:y=%s",
+		escapeFilterValue(o.TextFile), fontSize, filterColor(o.Color, "white", o.opacity()), x, y)
+	if o.BoxColor != "" {
+		filter += fmt.Sprintf(":box=1:boxcolor=%s:boxborderw=%d", filterColor(o.BoxColor, "", o.opacity()), fontSize/3)
+	}
+	return filter
+}
+
+// videoGraph compiles the video chains into a -filter_complex graph. Each
+// base is the scale and profile chain of one rendition, and every rendition
+// gets the overlays, whose images are read from inputs numbered from
+// firstInput. It returns the graph, the output label of each rendition and,
+// when withPreview is set, the label of the preview taken from the first.
+func videoGraph(bases []string, overlays []Overlay, firstInput int, withPreview bool, previewHeight int) (string, []string, string) {
+	var chains []string
+	sources := []string{"0:v"}
+	if len(bases) > 1 {
+		sources = make([]string, len(bases))
+		split := fmt.Sprintf("[0:v]split=%d", len(bases))
+		for i := range bases {
+			sources[i] = fmt.Sprintf("src%d", i)
+			split += "[" + sources[i] + "]"
+		}
+		chains = append(chains, split)
+	}
+
+	outputs := make([]string, len(bases))
+	for i, base := range bases {
+		chains, outputs[i] = overlayChain(chains, sources[i], base, overlays, firstInput, renditionPrefix(i))
+	}
+
+	if !withPreview {
+		return strings.Join(chains, ";"), outputs, ""
+	}
+	chains = append(chains,
+		fmt.Sprintf("[%s]split=2[vout][vsplit]", outputs[0]),
+		fmt.Sprintf("[vsplit]scale=-2:%d[vpreview]", previewHeight),
+	)
+	outputs[0] = "vout"
+	return strings.Join(chains, ";"), outputs, "vpreview"
+}
+
+// renditionPrefix keeps the graph labels of each rendition apart. The first
+// rendition keeps unprefixed labels.
+func renditionPrefix(index int) string {
+	if index == 0 {
+		return ""
+	}
+	return fmt.Sprintf("r%d", index)
+}
+
+// overlayChain appends the chains of one rendition, from source through base
+// and each overlay in turn, and returns them with the final label.
+func overlayChain(chains []string, source, base string, overlays []Overlay, firstInput int, prefix string) ([]string, string) {
+	current := prefix + "v0"
+	chains = append(chains, fmt.Sprintf("[%s]%s[%s]", source, base, current))
+
+	input := firstInput
+	for i, o := range overlays {
+		next := fmt.Sprintf("%sv%d", prefix, i+1)
+		switch o.Type {
+		case OverlayImage:
+			img := fmt.Sprintf("%simg%d", prefix, i+1)
+			prep := "format=rgba"
+			if o.Width > 0 {
+				prep = fmt.Sprintf("scale=%d:-1,%s", o.Width, prep)
+			}
+			if op := o.opacity(); op < 1 {
+				prep += fmt.Sprintf(",colorchannelmixer=aa=%.2f", op)
+			}
+			x, y := overlayXY(o.Position, o.Margin, "W", "H", "w", "h")
+			chains = append(chains,
+				fmt.Sprintf("[%d:v]%s[%s]", input, prep, img),
+				fmt.Sprintf("[%s][%s]overlay=x=%s:y=%s[%s]", current, img, x, y, next),
+			)
+			input++
+		default:
+			chains = append(chains, fmt.Sprintf("[%s]%s[%s]", current, o.drawtext(), next))
+		}
+		current = next
+	}
+	return chains, current
+}
+
+// CheckOverlays verifies the filters the overlays compile to. A nil receiver
+// skips the check.
+func (c *Capabilities) CheckOverlays(overlays []Overlay, withPreview bool) error {
+	if c == nil || len(overlays) == 0 {
+		return nil
+	}
+	needed := []string{"scale"}
+	for _, o := range overlays {
+		if o.Type == OverlayImage {
+			needed = append(needed, "overlay", "format")
+			if o.opacity() < 1 {
+				needed = append(needed, "colorchannelmixer")
+			}
+		} else {
+			needed = append(needed, "drawtext")
+		}
+	}
+	if withPreview {
+		needed = append(needed, "split")
+	}
+	for _, name := range needed {
+		if !c.HasFilter(name) {
+			return fmt.Errorf("overlay filter %s is %w", name, ErrUnsupported)
+		}
+	}
+	return nil
+}
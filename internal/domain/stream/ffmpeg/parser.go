@@ -1,41 +1,97 @@
 package ffmpeg
 
 import (
-	"regexp"
+	"bufio"
+	"io"
 	"strconv"
 	"strings"
 )
 
-var (
-	frameReg   = regexp.MustCompile(`frame=\s*(\d+)`)
-	fpsReg     = regexp.MustCompile(`fps=\s*([\d.]+)`)
-	timeReg    = regexp.MustCompile(`time=\s*([\d:.]+)`)
-	bitrateReg = regexp.MustCompile(`bitrate=\s*([\d.kM]+bits/s)`)
-	speedReg   = regexp.MustCompile(`speed=\s*([\d.]+)x`)
-)
-
-func ParseProgress(line string) *Progress {
-	if !strings.Contains(line, "frame=") || !strings.Contains(line, "time=") {
-		return nil
-	}
+// ProgressArgs makes ffmpeg write its progress as key=value blocks to the
+// given file descriptor instead of the stats line on stderr.
+func ProgressArgs(fd int) []string {
+	return []string{"-nostats", "-progress", "pipe:" + strconv.Itoa(fd)}
+}
 
+// ReadProgress reads ffmpeg's -progress output until r ends, calling report
+// with each complete block.
+func ReadProgress(r io.Reader, report func(*Progress)) error {
+	scanner := bufio.NewScanner(r)
 	p := &Progress{}
-
-	if m := frameReg.FindStringSubmatch(line); len(m) > 1 {
-		p.Frame, _ = strconv.Atoi(m[1])
-	}
-	if m := fpsReg.FindStringSubmatch(line); len(m) > 1 {
-		p.FPS, _ = strconv.ParseFloat(m[1], 64)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		if key == "progress" {
+			p.End = value == "end"
+			report(p)
+			p = &Progress{}
+			continue
+		}
+		p.set(key, strings.TrimSpace(value))
 	}
-	if m := timeReg.FindStringSubmatch(line); len(m) > 1 {
-		p.Time = m[1]
+	return scanner.Err()
+}
+
+// set applies one key of a progress block. Values ffmpeg does not know yet
+// are reported as N/A and left at zero.
+func (p *Progress) set(key, value string) {
+	switch key {
+	case "frame":
+		p.Frame, _ = strconv.Atoi(value)
+	case "fps":
+		p.FPS, _ = strconv.ParseFloat(value, 64)
+	case "bitrate":
+		p.Bitrate = value
+	case "total_size":
+		p.TotalSize, _ = strconv.ParseInt(value, 10, 64)
+	case "out_time_us":
+		p.OutTimeUS, _ = strconv.ParseInt(value, 10, 64)
+	case "out_time_ms":
+		// Despite the name this is in microseconds too; older builds only
+		// write this one.
+		if p.OutTimeUS == 0 {
+			p.OutTimeUS, _ = strconv.ParseInt(value, 10, 64)
+		}
+	case "out_time":
+		p.Time = value
+	case "dup_frames":
+		p.DupFrames, _ = strconv.Atoi(value)
+	case "drop_frames":
+		p.DropFrames, _ = strconv.Atoi(value)
+	case "speed":
+		p.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+	default:
+		// Per-stream quality: stream_<output>_<stream>_q.
+		if rest, ok := strings.CutPrefix(key, "stream_"); ok {
+			if index, ok := strings.CutSuffix(rest, "_q"); ok {
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return
+				}
+				if p.Quality == nil {
+					p.Quality = map[string]float64{}
+				}
+				p.Quality[strings.Replace(index, "_", ":", 1)] = q
+			}
+		}
 	}
-	if m := bitrateReg.FindStringSubmatch(line); len(m) > 1 {
-		p.Bitrate = m[1]
+}
+
+// ParseBitrate converts a reported bitrate such as "2500.1kbits/s" to kbit/s.
+func ParseBitrate(bitrate string) float64 {
+	value := strings.TrimSuffix(strings.TrimSpace(bitrate), "bits/s")
+	scale := 0.001
+	switch {
+	case strings.HasSuffix(value, "k"):
+		value, scale = strings.TrimSuffix(value, "k"), 1
+	case strings.HasSuffix(value, "M"):
+		value, scale = strings.TrimSuffix(value, "M"), 1000
 	}
-	if m := speedReg.FindStringSubmatch(line); len(m) > 1 {
-		p.Speed, _ = strconv.ParseFloat(m[1], 64)
+	kbps, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
 	}
-
-	return p
+	return kbps * scale
 }
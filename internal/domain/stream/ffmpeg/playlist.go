@@ -0,0 +1,32 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func WritePlaylist(path string, files []string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("playlist requires at least one file")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create playlist directory: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("ffconcat version 1.0\n")
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return fmt.Errorf("resolve playlist entry %s: %w", f, err)
+		}
+		b.WriteString("file '")
+		b.WriteString(strings.ReplaceAll(abs, "'", `'\''`))
+		b.WriteString("'\n")
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
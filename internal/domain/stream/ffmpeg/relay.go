@@ -0,0 +1,71 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Output is a single destination as ffmpeg sees it: the muxer to write, the
+// URL and any protocol options.
+type Output struct {
+	Format  string
+	URL     string
+	Options map[string]string
+}
+
+// optionNames returns the option keys in a stable order.
+func (o Output) optionNames() []string {
+	names := make([]string, 0, len(o.Options))
+	for name := range o.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// teeURL folds the protocol options into the URL query, since tee slaves
+// cannot take per-output options. Only SRT reads its options from the URL.
+func (o Output) teeURL() string {
+	if len(o.Options) == 0 || !strings.HasPrefix(strings.ToLower(o.URL), "srt://") {
+		return o.URL
+	}
+
+	query := url.Values{}
+	for _, name := range o.optionNames() {
+		query.Set(name, o.Options[name])
+	}
+	sep := "?"
+	if strings.Contains(o.URL, "?") {
+		sep = "&"
+	}
+	return o.URL + sep + query.Encode()
+}
+
+// BuildRelayArgs returns the arguments for a copy-only process that reads the
+// shared MPEG-TS encode from stdin and pushes it to a single destination. It
+// reports progress on stdout.
+func BuildRelayArgs(out Output) ([]string, error) {
+	if out.URL == "" {
+		return nil, fmt.Errorf("destination is required")
+	}
+	format := out.Format
+	if format == "" {
+		format = "flv"
+	}
+
+	args := append([]string{"-hide_banner"}, ProgressArgs(1)...)
+	args = append(args,
+		"-fflags", "+genpts",
+		"-f", "mpegts",
+		"-i", "pipe:0",
+		"-map", "0",
+		"-c", "copy",
+		"-f", format,
+	)
+	for _, name := range out.optionNames() {
+		args = append(args, "-"+name, out.Options[name])
+	}
+	return append(args, out.URL), nil
+}
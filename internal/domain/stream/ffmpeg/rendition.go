@@ -0,0 +1,146 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How a rendition fits a source of a different aspect ratio into its size.
+// Crop, blur and offset reframe without distorting, e.g. 16:9 to 9:16.
+const (
+	FitScale  = "scale"
+	FitCrop   = "crop"
+	FitPad    = "pad"
+	FitBlur   = "blur"
+	FitOffset = "offset"
+)
+
+// Rendition is one encode of the stream's video. Destinations that need a
+// different size, bitrate or frame rate from the main encode get their own.
+type Rendition struct {
+	Resolution string
+	Bitrate    int
+	FPS        int
+	Fit        string
+	// CropOffset places the FitOffset crop window, as a percentage of the
+	// overflow from the left (or top): 50 is the center.
+	CropOffset int
+}
+
+// RenditionPipe is the file descriptor the extra rendition with the given
+// index is written to: 0 to 2 are taken by stdin, the main rendition and
+// stderr.
+func RenditionPipe(index int) int {
+	return 3 + index
+}
+
+// ProgressPipe is the file descriptor the encoder reports progress on, the
+// one after its extra renditions.
+func ProgressPipe(renditions int) int {
+	return RenditionPipe(renditions)
+}
+
+func (r Rendition) bitrate() int {
+	if r.Bitrate == 0 {
+		return 2500
+	}
+	return r.Bitrate
+}
+
+func (r Rendition) fps() int {
+	if r.FPS == 0 {
+		return 30
+	}
+	return r.FPS
+}
+
+// fitFilter sizes the video to the rendition. Scale stretches to the exact
+// size; crop fills it and trims the overflow, centered or at CropOffset; pad
+// fits inside it with black bars and blur over a blurred, filled copy of
+// itself. Blur needs a small graph, whose labels start with prefix.
+func (r Rendition) fitFilter(prefix string) string {
+	w, h, ok := strings.Cut(r.Resolution, "x")
+	if !ok {
+		return "scale=" + r.Resolution
+	}
+	fill := fmt.Sprintf("scale=%s:%s:force_original_aspect_ratio=increase", w, h)
+	fit := fmt.Sprintf("scale=%s:%s:force_original_aspect_ratio=decrease", w, h)
+	switch r.Fit {
+	case FitCrop:
+		return fmt.Sprintf("%s,crop=%s:%s,setsar=1", fill, w, h)
+	case FitOffset:
+		return fmt.Sprintf("%s,crop=%s:%s:(iw-ow)*%d/100:(ih-oh)*%d/100,setsar=1", fill, w, h, r.CropOffset, r.CropOffset)
+	case FitPad:
+		return fmt.Sprintf("%s,pad=%s:%s:(ow-iw)/2:(oh-ih)/2,setsar=1", fit, w, h)
+	case FitBlur:
+		bg, fg, blurred, fitted := prefix+"bg", prefix+"fg", prefix+"blurred", prefix+"fitted"
+		return fmt.Sprintf("split=2[%s][%s];[%s]%s,crop=%s:%s,boxblur=20:5[%s];[%s]%s[%s];[%s][%s]overlay=(W-w)/2:(H-h)/2,setsar=1",
+			bg, fg, bg, fill, w, h, blurred, fg, fit, fitted, blurred, fitted)
+	default:
+		return "scale=" + r.Resolution
+	}
+}
+
+// filterNames lists the filters fitFilter uses.
+func (r Rendition) filterNames() []string {
+	switch r.Fit {
+	case FitCrop, FitOffset:
+		return []string{"scale", "crop", "setsar"}
+	case FitPad:
+		return []string{"scale", "pad", "setsar"}
+	case FitBlur:
+		return []string{"split", "scale", "crop", "boxblur", "overlay", "setsar"}
+	default:
+		return []string{"scale"}
+	}
+}
+
+// Reframes reports whether the rendition changes the aspect ratio without
+// distorting the picture.
+func (r Rendition) Reframes() bool {
+	return r.Fit == FitCrop || r.Fit == FitOffset || r.Fit == FitBlur
+}
+
+// FramePreviewArgs renders a single frame of input, taken at the given
+// second, as the rendition would show it.
+func FramePreviewArgs(input, output string, at int, r Rendition) []string {
+	return []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-ss", fmt.Sprintf("%d", at),
+		"-i", input,
+		"-vf", r.fitFilter(""),
+		"-frames:v", "1",
+		"-q:v", "3",
+		"-y",
+		output,
+	}
+}
+
+// videoChain is the rendition's filter chain ahead of any overlays.
+func (r Rendition) videoChain(filters, prefix string) string {
+	chain := r.fitFilter(prefix)
+	if filters = strings.TrimSpace(filters); filters != "" {
+		chain += "," + filters
+	}
+	return chain
+}
+
+// CheckRenditions verifies the filters that size each rendition and split the
+// source between them. A nil receiver skips the check.
+func (c *Capabilities) CheckRenditions(renditions []Rendition) error {
+	if c == nil {
+		return nil
+	}
+	for _, r := range renditions {
+		for _, name := range r.filterNames() {
+			if !c.HasFilter(name) {
+				return fmt.Errorf("filter %s is %w", name, ErrUnsupported)
+			}
+		}
+	}
+	if len(renditions) > 1 && !c.HasFilter("split") {
+		return fmt.Errorf("rendition filter split is %w", ErrUnsupported)
+	}
+	return nil
+}
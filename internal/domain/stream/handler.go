@@ -2,10 +2,17 @@ package stream
 
 import (
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/codewithwan/gostreamix/internal/domain/asset"
 	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/encoder"
 	"github.com/codewithwan/gostreamix/internal/domain/platform"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
 	"github.com/codewithwan/gostreamix/internal/domain/video"
 	"github.com/codewithwan/gostreamix/internal/shared/middleware"
 	"github.com/gofiber/fiber/v2"
@@ -13,29 +20,63 @@ import (
 	"go.uber.org/zap"
 )
 
+var previewSegmentPattern = regexp.MustCompile(`^seg_\d+\.ts$`)
+
 type Handler struct {
 	svc      Service
 	authSvc  auth.Service
 	platSvc  platform.Service
 	videoSvc video.Service
+	ingest   *IngestHub
 	log      *zap.Logger
 }
 
-func NewHandler(svc Service, authSvc auth.Service, platSvc platform.Service, videoSvc video.Service, log *zap.Logger) *Handler {
-	return &Handler{svc: svc, authSvc: authSvc, platSvc: platSvc, videoSvc: videoSvc, log: log}
+func NewHandler(svc Service, authSvc auth.Service, platSvc platform.Service, videoSvc video.Service, ingest *IngestHub, log *zap.Logger) *Handler {
+	return &Handler{svc: svc, authSvc: authSvc, platSvc: platSvc, videoSvc: videoSvc, ingest: ingest, log: log}
 }
 
 func (h *Handler) Routes(app *fiber.App) {
 	api := app.Group("/api/streams")
 	api.Get("/", h.ApiGetStreams)
 	api.Post("/", h.ApiCreateStream)
-	api.Post("/:id/reload", h.ApiReloadStream)
+	api.Post("/:id/reload", h.ownStream, h.ApiReloadStream)
 	api.Get("/:id/workspace", h.ApiGetWorkspace)
-	api.Post("/:id/program/apply", h.ApiApplyProgram)
-	api.Post("/:id/start", h.ApiStartStream)
-	api.Post("/:id/stop", h.ApiStopStream)
+	api.Post("/:id/program/apply", h.ownStream, h.ApiApplyProgram)
+	api.Post("/:id/start", h.ownStream, h.ApiStartStream)
+	api.Post("/:id/stop", h.ownStream, h.ApiStopStream)
 	api.Get("/:id/stats", h.ApiGetStreamStats)
-	api.Delete("/:id", h.ApiDeleteStream)
+	api.Get("/:id/sessions", h.ApiGetSessions)
+	api.Get("/:id/sessions/:sid/metrics", h.ApiGetSessionMetrics)
+	api.Get("/:id/preview.m3u8", h.ApiGetPreviewPlaylist)
+	api.Get("/:id/preview/:segment", h.ApiGetPreviewSegment)
+	api.Get("/:id/reframe/preview", h.ownStream, h.ApiPreviewReframe)
+	api.Get("/:id/ingest", h.ownStream, h.ApiGetIngest)
+	api.Post("/:id/ingest/key", h.ownStream, h.ApiRotateIngestKey)
+	api.Delete("/:id", h.ownStream, h.ApiDeleteStream)
+}
+
+// ownStream lets a request on a stream through only for its owner or an
+// admin. Anyone may list streams, with their credentials masked, but only
+// they can change one, run it or read its ingest key.
+func (h *Handler) ownStream(c *fiber.Ctx) error {
+	u := middleware.GetUser(c, h.authSvc)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	streamData, err := h.svc.GetStream(c.Context(), id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "stream not found"})
+	}
+	if !u.CanManage(streamData.UserID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "only the stream's owner or an admin can do this"})
+	}
+	return c.Next()
 }
 
 func (h *Handler) ApiGetStreamStats(c *fiber.Ctx) error {
@@ -53,6 +94,150 @@ func (h *Handler) ApiGetStreamStats(c *fiber.Ctx) error {
 	return c.JSON(stats)
 }
 
+func (h *Handler) ApiGetSessions(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	sessions, err := h.svc.ListSessions(c.Context(), id)
+	if err != nil {
+		h.log.Error("Failed to list stream sessions", zap.Error(err), zap.String("streamID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list stream sessions"})
+	}
+
+	return c.JSON(sessions)
+}
+
+func (h *Handler) ApiGetSessionMetrics(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+	sessionID, err := uuid.Parse(c.Params("sid"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid session id"})
+	}
+
+	metrics, err := h.svc.GetSessionMetrics(c.Context(), id, sessionID)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to get session metrics", zap.Error(err), zap.String("streamID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to get session metrics"})
+	}
+
+	return c.JSON(metrics)
+}
+
+func (h *Handler) ApiGetPreviewPlaylist(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	// Read the file directly: SendFile caches handles, which would serve a
+	// stale playlist while ffmpeg rewrites it.
+	data, err := os.ReadFile(filepath.Join(PreviewDir(id), ffmpeg.PreviewPlaylist))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "preview not available"})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderContentType, "application/vnd.apple.mpegurl")
+	return c.Send(data)
+}
+
+func (h *Handler) ApiGetPreviewSegment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	segment := c.Params("segment")
+	if !previewSegmentPattern.MatchString(segment) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid segment"})
+	}
+
+	data, err := os.ReadFile(filepath.Join(PreviewDir(id), segment))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "segment not found"})
+	}
+
+	c.Set(fiber.HeaderContentType, "video/mp2t")
+	return c.Send(data)
+}
+
+// ApiPreviewReframe renders a frame of the stream as the resolution, fit and
+// crop_offset in the query would send it, for checking a reframe before going
+// live.
+func (h *Handler) ApiPreviewReframe(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	enc := Encoding{
+		Resolution: c.Query("resolution"),
+		Fit:        c.Query("fit"),
+		CropOffset: c.QueryInt("crop_offset", 50),
+	}
+	data, err := h.svc.PreviewReframe(c.Context(), id, enc)
+	if err != nil {
+		if errors.Is(err, ErrStreamNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, ErrInvalidFit) || errors.Is(err, ErrInvalidDestination) ||
+			errors.Is(err, ErrReframeIncompatible) || errors.Is(err, ErrStreamProgramEmpty) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to preview reframe", zap.Error(err), zap.String("streamID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to render preview"})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderContentType, "image/jpeg")
+	return c.Send(data)
+}
+
+func (h *Handler) ApiGetIngest(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	streamData, err := h.svc.GetStream(c.Context(), id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "stream not found"})
+	}
+
+	return c.JSON(fiber.Map{
+		"source_type": streamData.SourceType,
+		"url":         h.ingest.URL(),
+		"key":         streamData.IngestKey,
+		"connected":   h.ingest.Connected(id),
+	})
+}
+
+func (h *Handler) ApiRotateIngestKey(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
+	}
+
+	streamData, err := h.svc.RotateIngestKey(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrStreamNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		h.log.Error("Failed to rotate ingest key", zap.Error(err), zap.String("streamID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to rotate ingest key"})
+	}
+
+	return c.JSON(fiber.Map{"url": h.ingest.URL(), "key": streamData.IngestKey})
+}
+
 func (h *Handler) ApiDeleteStream(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -68,31 +253,53 @@ func (h *Handler) ApiDeleteStream(c *fiber.Ctx) error {
 }
 
 func (h *Handler) ApiGetStreams(c *fiber.Ctx) error {
-	streams, err := h.svc.GetStreams(c.Context())
+	owner, err := middleware.OwnerFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid owner"})
+	}
+
+	streams, err := h.svc.GetStreams(c.Context(), owner)
 	if err != nil {
 		h.log.Error("Failed to list streams", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to list streams"})
 	}
 
-	return c.JSON(streams)
+	masked := make([]*Stream, len(streams))
+	for i, s := range streams {
+		masked[i] = s.Masked()
+	}
+	return c.JSON(masked)
 }
 
 func (h *Handler) ApiCreateStream(c *fiber.Ctx) error {
+	u := middleware.GetUser(c, h.authSvc)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
 	var dto CreateStreamDTO
 	if err := c.BodyParser(&dto); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	streamData, err := h.svc.CreateStream(c.Context(), dto)
+	streamData, err := h.svc.CreateStream(c.Context(), u, dto)
 	if err != nil {
+		if isStreamInputError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		h.log.Error("Failed to create stream", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(streamData)
+	return c.Status(fiber.StatusCreated).JSON(streamData.Masked())
 }
 
 func (h *Handler) ApiReloadStream(c *fiber.Ctx) error {
+	u := middleware.GetUser(c, h.authSvc)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
@@ -103,7 +310,10 @@ func (h *Handler) ApiReloadStream(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	if _, err := h.svc.UpdateStream(c.Context(), id, dto); err != nil {
+	if _, err := h.svc.UpdateStream(c.Context(), u, id, dto); err != nil {
+		if isStreamInputError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		h.log.Error("Failed to reload stream", zap.Error(err), zap.String("streamID", id.String()))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to reload stream"})
 	}
@@ -132,30 +342,37 @@ func (h *Handler) ApiGetWorkspace(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load program"})
 	}
 
-	videos, _ := h.videoSvc.GetVideos(c.Context())
+	videos, _ := h.videoSvc.GetVideos(c.Context(), uuid.Nil)
 	plats, _ := h.platSvc.GetPlatforms(c.Context(), u.ID)
 
 	return c.JSON(fiber.Map{
-		"stream":    streamData,
-		"program":   program,
+		"stream":    streamData.Masked(),
+		"program":   program.Masked(),
 		"videos":    video.ToVideoViews(videos),
 		"platforms": toPlatformOptions(plats),
 	})
 }
 
 func (h *Handler) ApiApplyProgram(c *fiber.Ctx) error {
+	u := middleware.GetUser(c, h.authSvc)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid stream id"})
 	}
 
 	var payload struct {
-		Name        string   `json:"name"`
-		VideoIDs    []string `json:"video_ids"`
-		RTMPTargets []string `json:"rtmp_targets"`
-		Bitrate     int      `json:"bitrate"`
-		Resolution  string   `json:"resolution"`
-		ApplyLive   bool     `json:"apply_live_now"`
+		Name        string      `json:"name"`
+		VideoIDs    []string    `json:"video_ids"`
+		RTMPTargets []string    `json:"rtmp_targets"`
+		PlatformIDs []uuid.UUID `json:"platform_ids"`
+		Bitrate     int         `json:"bitrate"`
+		Resolution  string      `json:"resolution"`
+		Overlays    []Overlay   `json:"overlays"`
+		ApplyLive   bool        `json:"apply_live_now"`
 	}
 
 	if err := json.Unmarshal(c.Body(), &payload); err != nil {
@@ -175,18 +392,24 @@ func (h *Handler) ApiApplyProgram(c *fiber.Ctx) error {
 		Name:         payload.Name,
 		VideoIDs:     videoIDs,
 		RTMPTargets:  payload.RTMPTargets,
+		PlatformIDs:  payload.PlatformIDs,
 		Bitrate:      payload.Bitrate,
 		Resolution:   payload.Resolution,
+		Overlays:     payload.Overlays,
 		ApplyLiveNow: payload.ApplyLive,
 	}
 
-	program, err := h.svc.SaveProgram(c.Context(), id, dto)
+	program, err := h.svc.SaveProgram(c.Context(), u, id, dto)
 	if err != nil {
+		if errors.Is(err, ErrInvalidOverlay) || errors.Is(err, asset.ErrAssetNotFound) ||
+			errors.Is(err, platform.ErrPlatformNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		h.log.Error("Failed to apply stream program", zap.Error(err), zap.String("streamID", id.String()))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save and apply program"})
 	}
 
-	return c.JSON(program)
+	return c.JSON(program.Masked())
 }
 
 func (h *Handler) ApiStartStream(c *fiber.Ctx) error {
@@ -200,6 +423,16 @@ func (h *Handler) ApiStartStream(c *fiber.Ctx) error {
 		if strings.Contains(err.Error(), ErrStreamProgramEmpty.Error()) {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "project has no video queue"})
 		}
+		if errors.Is(err, encoder.ErrProfileNotFound) || errors.Is(err, ErrPassthroughIncompatible) ||
+			errors.Is(err, ErrInvalidDestination) || errors.Is(err, ErrFallbackVideoNotFound) ||
+			errors.Is(err, ErrInvalidOverlay) || errors.Is(err, asset.ErrAssetNotFound) ||
+			errors.Is(err, ErrInvalidAudio) || errors.Is(err, ErrReframeIncompatible) ||
+			errors.Is(err, platform.ErrPlatformNotFound) || errors.Is(err, ffmpeg.ErrUnsupported) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if errors.Is(err, ffmpeg.ErrFFmpegUnavailable) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to start stream"})
 	}
 
@@ -220,6 +453,18 @@ func (h *Handler) ApiStopStream(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusOK)
 }
 
+// isStreamInputError reports whether a create or update failed on the
+// request's own settings rather than on the server.
+func isStreamInputError(err error) bool {
+	return errors.Is(err, ErrInvalidRestartPolicy) || errors.Is(err, ErrInvalidSlowAfter) ||
+		errors.Is(err, encoder.ErrProfileNotFound) ||
+		errors.Is(err, ErrInvalidDestination) || errors.Is(err, ErrInvalidSourceType) ||
+		errors.Is(err, ErrFallbackVideoNotFound) || errors.Is(err, ErrPassthroughIncompatible) ||
+		errors.Is(err, ErrInvalidOverlay) || errors.Is(err, asset.ErrAssetNotFound) ||
+		errors.Is(err, ErrInvalidAudio) || errors.Is(err, ErrInvalidFit) ||
+		errors.Is(err, platform.ErrPlatformNotFound)
+}
+
 type platformOption struct {
 	ID        uuid.UUID `json:"id"`
 	Name      string    `json:"name"`
@@ -236,46 +481,16 @@ func toPlatformOptions(plats []*platform.Platform) []platformOption {
 			continue
 		}
 
+		masked := p.Masked()
 		options = append(options, platformOption{
 			ID:        p.ID,
 			Name:      p.Name,
 			Type:      p.PlatformType,
-			RTMPURL:   buildRTMPTarget(p.PlatformType, p.CustomURL, p.StreamKey),
+			RTMPURL:   masked.RTMPURL(),
 			Enabled:   p.Enabled,
-			StreamKey: p.StreamKey,
+			StreamKey: masked.StreamKey,
 		})
 	}
 
 	return options
 }
-
-func buildRTMPTarget(platformType, baseURL, streamKey string) string {
-	platformType = strings.ToLower(strings.TrimSpace(platformType))
-	baseURL = strings.TrimSpace(baseURL)
-	streamKey = strings.TrimSpace(streamKey)
-
-	if baseURL == "" {
-		switch platformType {
-		case "youtube":
-			baseURL = "rtmp://a.rtmp.youtube.com/live2"
-		case "twitch":
-			baseURL = "rtmp://live.twitch.tv/app"
-		case "facebook":
-			baseURL = "rtmps://live-api-s.facebook.com:443/rtmp"
-		case "tiktok":
-			baseURL = "rtmp://push-rtmp-global.tiktok.com/live"
-		}
-	}
-
-	if baseURL == "" {
-		return ""
-	}
-
-	if streamKey == "" {
-		return baseURL
-	}
-	if strings.HasSuffix(baseURL, "/") {
-		return baseURL + streamKey
-	}
-	return baseURL + "/" + streamKey
-}
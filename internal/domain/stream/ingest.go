@@ -0,0 +1,294 @@
+package stream
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/infrastructure/activity"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/rtmp"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/ws"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// IngestHub routes live publishers to their streams by ingest key and hands
+// their media to the pipeline. A stream accepts one publisher at a time.
+type IngestHub struct {
+	repo     Repository
+	hub      *ws.Hub
+	url      string
+	log      *zap.Logger
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*ingestSession
+	changed  map[uuid.UUID]chan struct{}
+}
+
+func NewIngestHub(repo Repository, hub *ws.Hub, url string, log *zap.Logger) *IngestHub {
+	return &IngestHub{
+		repo:     repo,
+		hub:      hub,
+		url:      url,
+		log:      log,
+		sessions: make(map[uuid.UUID]*ingestSession),
+		changed:  make(map[uuid.UUID]chan struct{}),
+	}
+}
+
+// URL is the server address publishers push to, with the ingest key as the
+// stream name.
+func (h *IngestHub) URL() string {
+	return h.url
+}
+
+func (h *IngestHub) Publish(app, key string) (rtmp.Publisher, error) {
+	if key == "" {
+		return nil, ErrIngestKeyUnknown
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	st, err := h.repo.FindByIngestKey(ctx, key)
+	if err != nil || !st.IsIngest() {
+		return nil, ErrIngestKeyUnknown
+	}
+
+	h.mu.Lock()
+	if _, busy := h.sessions[st.ID]; busy {
+		h.mu.Unlock()
+		return nil, ErrIngestBusy
+	}
+	sess := newIngestSession(st.ID, h.end)
+	h.sessions[st.ID] = sess
+	h.notifyLocked(st.ID)
+	h.mu.Unlock()
+
+	h.log.Info("ingest connected", zap.String("stream_id", st.ID.String()), zap.String("app", app))
+	h.record(st.ID, "info", "ingest_connected", "Live ingest connected")
+	return sess, nil
+}
+
+func (h *IngestHub) end(sess *ingestSession) {
+	h.mu.Lock()
+	if h.sessions[sess.streamID] != sess {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.sessions, sess.streamID)
+	h.notifyLocked(sess.streamID)
+	h.mu.Unlock()
+
+	h.log.Info("ingest disconnected", zap.String("stream_id", sess.streamID.String()))
+	h.record(sess.streamID, "warning", "ingest_disconnected", "Live ingest disconnected")
+}
+
+// Connected reports whether a publisher is currently live on the stream.
+func (h *IngestHub) Connected(streamID uuid.UUID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.sessions[streamID]
+	return ok
+}
+
+// Attach starts reading the stream's live session as FLV. It returns nil
+// when no publisher is connected.
+func (h *IngestHub) Attach(streamID uuid.UUID) io.ReadCloser {
+	h.mu.Lock()
+	sess := h.sessions[streamID]
+	h.mu.Unlock()
+	if sess == nil {
+		return nil
+	}
+	return sess.attach()
+}
+
+// Changed returns a channel that is closed the next time a publisher
+// connects to or disconnects from the stream.
+func (h *IngestHub) Changed(streamID uuid.UUID) <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch, ok := h.changed[streamID]
+	if !ok {
+		ch = make(chan struct{})
+		h.changed[streamID] = ch
+	}
+	return ch
+}
+
+func (h *IngestHub) notifyLocked(streamID uuid.UUID) {
+	if ch, ok := h.changed[streamID]; ok {
+		close(ch)
+		delete(h.changed, streamID)
+	}
+}
+
+func (h *IngestHub) record(streamID uuid.UUID, level, event, message string) {
+	activity.Record(activity.Entry{
+		Timestamp: time.Now().UTC(),
+		Source:    "ingest",
+		Level:     level,
+		Event:     event,
+		Message:   message,
+		StreamID:  streamID.String(),
+	})
+	h.hub.Broadcast("ingest_status", map[string]interface{}{
+		"stream_id": streamID.String(),
+		"connected": h.Connected(streamID),
+	})
+}
+
+// ingestSession buffers one publisher. It remembers the metadata and codec
+// headers so an encoder attached mid-stream can still decode it, and drops
+// media while nothing is attached.
+type ingestSession struct {
+	streamID     uuid.UUID
+	onClose      func(*ingestSession)
+	mu           sync.Mutex
+	metadata     *rtmp.Tag
+	videoHeader  *rtmp.Tag
+	audioHeader  *rtmp.Tag
+	out          chan []byte
+	waitKeyframe bool
+	closed       chan struct{}
+	closeOnce    sync.Once
+}
+
+func newIngestSession(streamID uuid.UUID, onClose func(*ingestSession)) *ingestSession {
+	return &ingestSession{
+		streamID: streamID,
+		onClose:  onClose,
+		closed:   make(chan struct{}),
+	}
+}
+
+func (s *ingestSession) WriteTag(tag rtmp.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case tag.Type == rtmp.TagScript:
+		s.metadata = &tag
+	case tag.IsSequenceHeader() && tag.Type == rtmp.TagVideo:
+		s.videoHeader = &tag
+	case tag.IsSequenceHeader():
+		s.audioHeader = &tag
+	case s.waitKeyframe:
+		// A decoder joining mid-stream can only start on a keyframe.
+		if !tag.IsKeyframe() {
+			return nil
+		}
+		s.waitKeyframe = false
+	}
+
+	if s.out == nil {
+		return nil
+	}
+	select {
+	case s.out <- tag.Bytes():
+	default:
+		// The encoder is not keeping up; dropping whole tags keeps the FLV
+		// framing intact.
+	}
+	return nil
+}
+
+func (s *ingestSession) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.onClose(s)
+	})
+}
+
+func (s *ingestSession) attach() io.ReadCloser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prelude := append([]byte(nil), rtmp.FLVHeader...)
+	for _, tag := range []*rtmp.Tag{s.metadata, s.videoHeader, s.audioHeader} {
+		if tag != nil {
+			prelude = append(prelude, tag.Bytes()...)
+		}
+	}
+
+	out := make(chan []byte, 1024)
+	s.out = out
+	s.waitKeyframe = true
+	return &ingestReader{
+		pending: prelude,
+		out:     out,
+		closed:  s.closed,
+		done:    make(chan struct{}),
+		detach: func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.out == out {
+				s.out = nil
+			}
+		},
+	}
+}
+
+type ingestReader struct {
+	pending   []byte
+	out       <-chan []byte
+	closed    <-chan struct{}
+	done      chan struct{}
+	detach    func()
+	closeOnce sync.Once
+}
+
+func (r *ingestReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		select {
+		case b := <-r.out:
+			r.pending = b
+		case <-r.closed:
+			// Deliver what the publisher sent before it left.
+			select {
+			case b := <-r.out:
+				r.pending = b
+				continue
+			default:
+			}
+			return 0, io.EOF
+		case <-r.done:
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *ingestReader) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+		r.detach()
+	})
+	return nil
+}
+
+// GenerateIngestKey returns a new random key for publishers to authenticate
+// with.
+func GenerateIngestKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate ingest key: %w", err)
+	}
+	return "live_" + hex.EncodeToString(b), nil
+}
+
+// HashIngestKey is what an ingest key is looked up by, as the key itself is
+// stored encrypted.
+func HashIngestKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,34 +3,57 @@ package stream
 import (
 	"context"
 
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
 	"github.com/google/uuid"
 )
 
 type Repository interface {
 	Create(ctx context.Context, s *Stream) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Stream, error)
-	List(ctx context.Context) ([]*Stream, error)
+	FindByIngestKey(ctx context.Context, key string) (*Stream, error)
+	List(ctx context.Context, ownerID uuid.UUID) ([]*Stream, error)
 	Update(ctx context.Context, s *Stream) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	ListByDesiredState(ctx context.Context, state string) ([]*Stream, error)
+	SetDesiredState(ctx context.Context, id uuid.UUID, state string) error
+	UpdateStatus(ctx context.Context, id uuid.UUID, status, lastError string) error
 	GetProgram(ctx context.Context, streamID uuid.UUID) (*StreamProgram, error)
 	UpsertProgram(ctx context.Context, p *StreamProgram) error
+	SetPlatforms(ctx context.Context, streamID uuid.UUID, platformIDs []uuid.UUID) error
+}
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, s *Session) error
+	UpdateSession(ctx context.Context, s *Session) error
+	GetSession(ctx context.Context, id uuid.UUID) (*Session, error)
+	ListSessions(ctx context.Context, streamID uuid.UUID, limit int) ([]*Session, error)
+	CloseOpenSessions(ctx context.Context, reason string) error
+	PruneSessions(ctx context.Context, streamID uuid.UUID, keep int) error
+	DeleteSessions(ctx context.Context, streamID uuid.UUID) error
+	AddMetric(ctx context.Context, m *SessionMetric) error
+	ListMetrics(ctx context.Context, sessionID uuid.UUID) ([]SessionMetric, error)
 }
 
 type Service interface {
-	CreateStream(ctx context.Context, dto CreateStreamDTO) (*Stream, error)
-	UpdateStream(ctx context.Context, id uuid.UUID, dto UpdateStreamDTO) (*Stream, error)
-	GetStreams(ctx context.Context) ([]*Stream, error)
+	CreateStream(ctx context.Context, caller *auth.User, dto CreateStreamDTO) (*Stream, error)
+	UpdateStream(ctx context.Context, caller *auth.User, id uuid.UUID, dto UpdateStreamDTO) (*Stream, error)
+	GetStreams(ctx context.Context, ownerID uuid.UUID) ([]*Stream, error)
 	GetStream(ctx context.Context, id uuid.UUID) (*Stream, error)
 	DeleteStream(ctx context.Context, id uuid.UUID) error
 	StartStream(ctx context.Context, id uuid.UUID) error
 	StopStream(ctx context.Context, id uuid.UUID) error
+	ResumeStreams(ctx context.Context) error
 	GetStreamStats(ctx context.Context, id uuid.UUID) (interface{}, error)
 	GetProgram(ctx context.Context, id uuid.UUID) (*StreamProgram, error)
-	SaveProgram(ctx context.Context, id uuid.UUID, dto SaveProgramDTO) (*StreamProgram, error)
+	SaveProgram(ctx context.Context, caller *auth.User, id uuid.UUID, dto SaveProgramDTO) (*StreamProgram, error)
+	RotateIngestKey(ctx context.Context, id uuid.UUID) (*Stream, error)
+	PreviewReframe(ctx context.Context, id uuid.UUID, enc Encoding) ([]byte, error)
+	ListSessions(ctx context.Context, id uuid.UUID) ([]*Session, error)
+	GetSessionMetrics(ctx context.Context, id, sessionID uuid.UUID) ([]SessionMetric, error)
 }
 
 type Pipeline interface {
-	Start(ctx context.Context, s *Stream, videoPath string) error
+	Start(ctx context.Context, s *Stream, plan Plan) error
 	Stop(ctx context.Context, s *Stream) error
-	Reload(ctx context.Context, s *Stream, videoPath string) error
+	Reload(ctx context.Context, s *Stream, plan Plan) error
 }
@@ -1,11 +1,13 @@
 package stream
 
 import (
+	"fmt"
 	"os/exec"
 	"sync"
 	"time"
 
 	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 )
@@ -13,17 +15,127 @@ import (
 type Stream struct {
 	bun.BaseModel `bun:"table:streams,alias:s"`
 
-	ID          uuid.UUID `bun:",pk,type:text" json:"id"`
-	VideoID     uuid.UUID `bun:",notnull,type:text" json:"video_id"`
-	Name        string    `bun:",notnull" json:"name"`
-	RTMPTargets []string  `bun:",type:json" json:"rtmp_targets"`
-	Bitrate     int       `json:"bitrate"`
-	Resolution  string    `json:"resolution"`
-	FPS         int       `json:"fps"`
-	Loop        bool      `json:"loop"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
-	UpdatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	ID               uuid.UUID     `bun:",pk,type:text" json:"id"`
+	UserID           uuid.UUID     `bun:",nullzero,type:text" json:"user_id"`
+	VideoID          uuid.UUID     `bun:",notnull,type:text" json:"video_id"`
+	Name             string        `bun:",notnull" json:"name"`
+	RTMPTargets      []string      `bun:",type:json" json:"rtmp_targets"`
+	Destinations     []Destination `bun:",type:json" json:"destinations"`
+	PlatformIDs      []uuid.UUID   `bun:"-" json:"platform_ids"`
+	Bitrate          int           `json:"bitrate"`
+	Resolution       string        `json:"resolution"`
+	Fit              string        `bun:",notnull,default:''" json:"fit"`
+	CropOffset       int           `bun:",notnull,default:50" json:"crop_offset"`
+	FPS              int           `json:"fps"`
+	Loop             bool          `json:"loop"`
+	Status           string        `json:"status"`
+	DesiredState     string        `bun:",notnull,default:'stopped'" json:"desired_state"`
+	LastError        string        `bun:",notnull,default:''" json:"last_error"`
+	RestartPolicy    RestartPolicy `bun:"embed:restart_" json:"restart_policy"`
+	SlowAfterSec     int           `bun:",notnull,default:30" json:"slow_after_sec"`
+	EncoderProfileID *uuid.UUID    `bun:",type:text" json:"encoder_profile_id"`
+	Passthrough      bool          `bun:",notnull,default:false" json:"passthrough"`
+	SourceType       string        `bun:",notnull,default:'file'" json:"source_type"`
+	IngestKey        string        `bun:",notnull,default:''" json:"ingest_key"`
+	IngestKeyHash    string        `bun:",notnull,default:''" json:"-"`
+	FallbackVideoID  *uuid.UUID    `bun:",type:text" json:"fallback_video_id"`
+	Overlays         []Overlay     `bun:",type:json" json:"overlays"`
+	Audio            AudioSettings `bun:"embed:audio_" json:"audio"`
+	CreatedAt        time.Time     `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt        time.Time     `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Source types: a stream either plays its video queue or relays a live
+// encoder pushing to the ingest listener.
+const (
+	SourceFile   = "file"
+	SourceIngest = "ingest"
+)
+
+// IsIngest reports whether the stream is fed by the live ingest.
+func (s *Stream) IsIngest() bool {
+	return s.SourceType == SourceIngest
+}
+
+// Masked is a copy of the stream safe to send to the browser, with the
+// stream keys in its targets, SRT passphrases and the ingest key hidden.
+func (s *Stream) Masked() *Stream {
+	masked := *s
+	masked.RTMPTargets = maskTargets(s.RTMPTargets)
+	if s.Destinations != nil {
+		masked.Destinations = make([]Destination, len(s.Destinations))
+		for i, d := range s.Destinations {
+			masked.Destinations[i] = d.Masked()
+		}
+	}
+	masked.IngestKey = secret.Mask(s.IngestKey)
+	return &masked
+}
+
+// DefaultSlowAfterSec is how long the encoder may run slower than real time
+// before it is reported, unless the stream sets its own threshold:
+// destinations buffer through short dips.
+const DefaultSlowAfterSec = 30
+
+// SlowAfter is how long the stream's encoder may run slower than real time
+// before a pipeline_slow event is raised.
+func (s *Stream) SlowAfter() time.Duration {
+	if s.SlowAfterSec <= 0 {
+		return DefaultSlowAfterSec * time.Second
+	}
+	return time.Duration(s.SlowAfterSec) * time.Second
+}
+
+func validateSlowAfter(sec int) error {
+	if sec < 5 || sec > 3600 {
+		return fmt.Errorf("%w: slow_after_sec must be between 5 and 3600", ErrInvalidSlowAfter)
+	}
+	return nil
+}
+
+// RestartPolicy controls how the pipeline recovers when ffmpeg exits
+// unexpectedly. A MaxAttempts of zero disables automatic restarts.
+type RestartPolicy struct {
+	MaxAttempts    int `json:"max_attempts"`
+	BackoffSec     int `json:"backoff_sec"`
+	MaxBackoffSec  int `json:"max_backoff_sec"`
+	ResetWindowSec int `json:"reset_window_sec"`
+}
+
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		MaxAttempts:    5,
+		BackoffSec:     2,
+		MaxBackoffSec:  60,
+		ResetWindowSec: 300,
+	}
+}
+
+func (r RestartPolicy) Validate() error {
+	if r.MaxAttempts < 0 || r.BackoffSec < 0 || r.MaxBackoffSec < 0 || r.ResetWindowSec < 0 {
+		return ErrInvalidRestartPolicy
+	}
+	if r.MaxAttempts > 0 && r.BackoffSec == 0 {
+		return ErrInvalidRestartPolicy
+	}
+	if r.MaxBackoffSec > 0 && r.MaxBackoffSec < r.BackoffSec {
+		return ErrInvalidRestartPolicy
+	}
+	return nil
+}
+
+// Backoff returns the delay before the given restart attempt (1-based),
+// doubling each time and capped at MaxBackoffSec.
+func (r RestartPolicy) Backoff(attempt int) time.Duration {
+	delay := time.Duration(r.BackoffSec) * time.Second
+	limit := time.Duration(r.MaxBackoffSec) * time.Second
+	for i := 1; i < attempt && (limit == 0 || delay < limit); i++ {
+		delay *= 2
+	}
+	if limit > 0 && delay > limit {
+		delay = limit
+	}
+	return delay
 }
 
 type StreamProgram struct {
@@ -35,18 +147,96 @@ type StreamProgram struct {
 	RTMPTargets []string    `bun:",type:json" json:"rtmp_targets"`
 	Bitrate     int         `json:"bitrate"`
 	Resolution  string      `json:"resolution"`
+	Overlays    []Overlay   `bun:",type:json" json:"overlays"`
 	CreatedAt   time.Time   `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt   time.Time   `bun:",nullzero,notnull,default:current_timestamp" json:"updated_at"`
 }
 
+// Masked is a copy of the program with the stream keys in its targets
+// hidden.
+func (p *StreamProgram) Masked() *StreamProgram {
+	masked := *p
+	masked.RTMPTargets = maskTargets(p.RTMPTargets)
+	return &masked
+}
+
+// StreamPlatform links a stream to a saved platform, whose URL and stream key
+// are resolved when the stream starts. Position keeps the order the platforms
+// were chosen in.
+type StreamPlatform struct {
+	bun.BaseModel `bun:"table:stream_platforms,alias:stp"`
+
+	StreamID   uuid.UUID `bun:",pk,type:text"`
+	PlatformID uuid.UUID `bun:",pk,type:text"`
+	Position   int       `bun:",notnull"`
+}
+
+type QueueItem struct {
+	VideoID  uuid.UUID `json:"video_id"`
+	Name     string    `json:"name"`
+	Path     string    `json:"-"`
+	Duration int       `json:"duration"`
+	HasAudio bool      `json:"-"`
+}
+
+// Plan is what the pipeline needs beyond the stream record to launch it.
+type Plan struct {
+	Queue        []QueueItem
+	Destinations []Destination
+	Encoder      ffmpeg.EncoderSettings
+	Passthrough  bool
+	// Ingest streams read the live publisher instead of the queue and play
+	// Fallback, when set, while it is disconnected.
+	Ingest   bool
+	Fallback *QueueItem
+	Overlays []Overlay
+	Audio    AudioPlan
+	// Renditions are the distinct encodes the destinations need; the first
+	// is the main one, which also feeds the preview.
+	Renditions []Rendition
+}
+
+const (
+	DesiredRunning = "running"
+	DesiredStopped = "stopped"
+)
+
+type DestinationStatus string
+
+const (
+	DestinationConnecting DestinationStatus = "connecting"
+	DestinationConnected  DestinationStatus = "connected"
+	DestinationFailed     DestinationStatus = "failed"
+	DestinationRetrying   DestinationStatus = "retrying"
+	DestinationStopped    DestinationStatus = "stopped"
+)
+
+type DestinationState struct {
+	Index     int               `json:"index"`
+	Target    string            `json:"target"`
+	Status    DestinationStatus `json:"status"`
+	Retries   int               `json:"retries"`
+	LastError string            `json:"last_error,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
 type ProcessStatus string
 
 const (
-	StatusStarting ProcessStatus = "starting"
-	StatusRunning  ProcessStatus = "running"
-	StatusStopping ProcessStatus = "stopping"
-	StatusStopped  ProcessStatus = "stopped"
-	StatusError    ProcessStatus = "error"
+	StatusStarting   ProcessStatus = "starting"
+	StatusRunning    ProcessStatus = "running"
+	StatusStopping   ProcessStatus = "stopping"
+	StatusStopped    ProcessStatus = "stopped"
+	StatusError      ProcessStatus = "error"
+	StatusRestarting ProcessStatus = "restarting"
+	StatusWaiting    ProcessStatus = "waiting"
+)
+
+// What the encoder is currently reading.
+const (
+	InputQueue    = "queue"
+	InputIngest   = "ingest"
+	InputFallback = "fallback"
 )
 
 type Process struct {
@@ -55,9 +245,159 @@ type Process struct {
 	Status       ProcessStatus
 	StartedAt    time.Time
 	LastProgress *ffmpeg.Progress
+	Queue        []QueueItem
+	CurrentIndex int
+	Restarts     int
+	Input        string
+	relays       []*relay
+	session      *sessionRecorder
+	switchTo     string
+	progressAt   time.Time
+	slowSince    time.Time
+	slowReported bool
+	runStartedAt time.Time
+	stop         chan struct{}
+	stopOnce     sync.Once
+	done         chan struct{}
+	doneOnce     sync.Once
 	mu           sync.RWMutex
 }
 
+func (p *Process) DestinationStates() []DestinationState {
+	p.mu.RLock()
+	relays := p.relays
+	p.mu.RUnlock()
+
+	states := make([]DestinationState, len(relays))
+	for i, r := range relays {
+		states[i] = r.State()
+	}
+	return states
+}
+
+func (p *Process) setRelays(relays []*relay) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.relays = relays
+}
+
+// attach makes cmd the current encoder. It refuses once a stop was
+// requested, as Stop only signalled the encoder before it; the caller then
+// has to discard cmd.
+func (p *Process) attach(cmd *exec.Cmd) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.StopRequested() {
+		return false
+	}
+	p.Cmd = cmd
+	p.runStartedAt = time.Now()
+	return true
+}
+
+func (p *Process) GetCmd() *exec.Cmd {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Cmd
+}
+
+func (p *Process) RequestStop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+func (p *Process) StopChan() <-chan struct{} {
+	return p.stop
+}
+
+// Done is closed once the stream has fully stopped: its encoder, any pending
+// restart and its relays.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *Process) markExited() {
+	p.doneOnce.Do(func() { close(p.done) })
+}
+
+func (p *Process) StopRequested() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// NextRestart records a restart attempt and returns how long to wait before
+// it. The attempt counter resets when the last run outlived the policy's
+// reset window; false means the policy is used up.
+func (p *Process) NextRestart(policy RestartPolicy) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if policy.MaxAttempts <= 0 {
+		return 0, false
+	}
+
+	window := time.Duration(policy.ResetWindowSec) * time.Second
+	if window > 0 && time.Since(p.runStartedAt) >= window {
+		p.Restarts = 0
+	}
+
+	if p.Restarts >= policy.MaxAttempts {
+		return 0, false
+	}
+
+	p.Restarts++
+	return policy.Backoff(p.Restarts), true
+}
+
+func (p *Process) SetInput(input string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Input = input
+}
+
+func (p *Process) GetInput() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Input
+}
+
+// requestSwitch marks the next encoder exit as a deliberate change to the
+// given input rather than a failure.
+func (p *Process) requestSwitch(input string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.switchTo = input
+}
+
+func (p *Process) takeSwitch() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	input := p.switchTo
+	p.switchTo = ""
+	return input, input != ""
+}
+
+// Stalled reports whether the current encoder has stopped reporting
+// progress: for longer than timeout once it has started, or for longer than
+// grace since launch if it never did.
+func (p *Process) Stalled(timeout, grace time.Duration) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.progressAt.Before(p.runStartedAt) {
+		return time.Since(p.runStartedAt) > grace
+	}
+	return time.Since(p.progressAt) > timeout
+}
+
+func (p *Process) RestartCount() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Restarts
+}
+
 func (p *Process) SetStatus(status ProcessStatus) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -74,4 +414,43 @@ func (p *Process) UpdateProgress(progress *ffmpeg.Progress) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.LastProgress = progress
+	p.progressAt = time.Now()
+}
+
+// TrackSpeed follows how long the encoder has run slower than real time and
+// reports true once when that has lasted for after. A speed of zero is not
+// known yet and does not count.
+func (p *Process) TrackSpeed(speed float64, after time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if speed <= 0 || speed >= 1 {
+		p.slowSince, p.slowReported = time.Time{}, false
+		return false
+	}
+	if p.slowSince.IsZero() {
+		p.slowSince = time.Now()
+	}
+	if p.slowReported || time.Since(p.slowSince) < after {
+		return false
+	}
+	p.slowReported = true
+	return true
+}
+
+func (p *Process) SetCurrentIndex(index int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changed := p.CurrentIndex != index
+	p.CurrentIndex = index
+	return changed
+}
+
+func (p *Process) CurrentItem() *QueueItem {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.CurrentIndex < 0 || p.CurrentIndex >= len(p.Queue) {
+		return nil
+	}
+	item := p.Queue[p.CurrentIndex]
+	return &item
 }
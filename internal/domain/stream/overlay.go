@@ -0,0 +1,158 @@
+package stream
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/google/uuid"
+)
+
+// maxOverlays keeps the filter graph, and the CPU it costs, bounded.
+const maxOverlays = 8
+
+var overlayColorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{6}|[a-zA-Z]+)$`)
+
+// Overlay is an image or text layer burned into the stream. Image overlays
+// reference an uploaded asset; text may contain the {time}, {date} and
+// {datetime} templates.
+type Overlay struct {
+	Type     string     `json:"type"`
+	AssetID  *uuid.UUID `json:"asset_id,omitempty"`
+	Text     string     `json:"text,omitempty"`
+	Position string     `json:"position"`
+	Margin   int        `json:"margin"`
+	Width    int        `json:"width,omitempty"`
+	FontSize int        `json:"font_size,omitempty"`
+	Color    string     `json:"color,omitempty"`
+	BoxColor string     `json:"box_color,omitempty"`
+	Opacity  float64    `json:"opacity"`
+	// ImagePath is resolved from the asset when the stream is planned.
+	ImagePath string `json:"-"`
+}
+
+func (o Overlay) Validate() error {
+	switch o.Type {
+	case ffmpeg.OverlayImage:
+		if o.AssetID == nil || *o.AssetID == uuid.Nil {
+			return overlayError("image overlay needs an asset_id")
+		}
+		if o.Text != "" || o.FontSize != 0 || o.Color != "" || o.BoxColor != "" {
+			return overlayError("text options do not apply to image overlays")
+		}
+		if o.Width < 0 || o.Width > 3840 {
+			return overlayError("width must be between 0 and 3840")
+		}
+	case ffmpeg.OverlayText:
+		if strings.TrimSpace(o.Text) == "" {
+			return overlayError("text overlay needs text")
+		}
+		if len(o.Text) > 256 {
+			return overlayError("text must be at most 256 characters")
+		}
+		if o.AssetID != nil || o.Width != 0 {
+			return overlayError("image options do not apply to text overlays")
+		}
+		if o.FontSize < 0 || o.FontSize > 200 {
+			return overlayError("font_size must be between 0 and 200")
+		}
+		for _, color := range []string{o.Color, o.BoxColor} {
+			if color != "" && !overlayColorPattern.MatchString(color) {
+				return overlayError("color %q must be #RRGGBB or a color name", color)
+			}
+		}
+	default:
+		return overlayError("unsupported overlay type %q", o.Type)
+	}
+
+	switch o.Position {
+	case "", ffmpeg.PositionTopLeft, ffmpeg.PositionTop, ffmpeg.PositionTopRight, ffmpeg.PositionCenter,
+		ffmpeg.PositionBottomLeft, ffmpeg.PositionBottom, ffmpeg.PositionBottomRight:
+	default:
+		return overlayError("unsupported position %q", o.Position)
+	}
+	if o.Margin < 0 || o.Margin > 500 {
+		return overlayError("margin must be between 0 and 500")
+	}
+	if o.Opacity < 0 || o.Opacity > 1 {
+		return overlayError("opacity must be between 0 and 1")
+	}
+	return nil
+}
+
+// FFmpeg renders the overlay for the command builder; textFile is where its
+// expanded text is written.
+func (o Overlay) FFmpeg(textFile string) ffmpeg.Overlay {
+	out := ffmpeg.Overlay{
+		Type:      o.Type,
+		ImagePath: o.ImagePath,
+		Position:  o.Position,
+		Margin:    o.Margin,
+		Width:     o.Width,
+		FontSize:  o.FontSize,
+		Color:     o.Color,
+		BoxColor:  o.BoxColor,
+		Opacity:   o.Opacity,
+	}
+	if o.Type == ffmpeg.OverlayText {
+		out.TextFile = textFile
+	}
+	return out
+}
+
+func validateOverlays(overlays []Overlay) error {
+	if len(overlays) > maxOverlays {
+		return overlayError("at most %d overlays are allowed", maxOverlays)
+	}
+	for i, o := range overlays {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("overlay %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func overlayError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidOverlay, fmt.Sprintf(format, args...))
+}
+
+var overlayRoot = filepath.Join("data", "overlays")
+
+// overlayDir holds the text files of the stream's running overlays.
+func overlayDir(streamID uuid.UUID) string {
+	return filepath.Join(overlayRoot, streamID.String())
+}
+
+// ffmpegOverlays renders the plan's overlays for the command builder, with
+// text overlays read from files in dir.
+func (p Plan) ffmpegOverlays(dir string) []ffmpeg.Overlay {
+	overlays := make([]ffmpeg.Overlay, len(p.Overlays))
+	for i, o := range p.Overlays {
+		overlays[i] = o.FFmpeg(overlayTextPath(dir, i))
+	}
+	return overlays
+}
+
+func overlayTextPath(dir string, index int) string {
+	return filepath.Join(dir, fmt.Sprintf("text_%d.txt", index))
+}
+
+// writeOverlayFiles checks the overlay images are in place and writes the
+// text overlays to dir.
+func writeOverlayFiles(dir string, overlays []Overlay) error {
+	for i, o := range overlays {
+		if o.Type == ffmpeg.OverlayImage {
+			if _, err := os.Stat(o.ImagePath); err != nil {
+				return fmt.Errorf("overlay %d image not found at %s: %w", i+1, o.ImagePath, err)
+			}
+			continue
+		}
+		if err := ffmpeg.WriteOverlayText(overlayTextPath(dir, i), o.Text); err != nil {
+			return fmt.Errorf("write overlay %d text: %w", i+1, err)
+		}
+	}
+	return nil
+}
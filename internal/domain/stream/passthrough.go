@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"fmt"
+
+	"github.com/codewithwan/gostreamix/internal/domain/video"
+)
+
+// maxPassthroughKeyframeSec is the longest keyframe interval the common RTMP
+// ingests accept without complaint.
+const maxPassthroughKeyframeSec = 4.0
+
+// CheckPassthrough reports why a source cannot be streamed with -c copy, or
+// nil if it can. ref is the first item in the queue: the concat demuxer can
+// only copy files whose stream parameters match, so later items are compared
+// against it.
+func CheckPassthrough(s *Stream, meta, ref *video.Metadata) error {
+	if meta.VideoCodec != "h264" {
+		return passthroughError("video codec is %s, need h264", orUnknown(meta.VideoCodec))
+	}
+	if meta.PixelFormat != "yuv420p" {
+		return passthroughError("pixel format is %s, need yuv420p", orUnknown(meta.PixelFormat))
+	}
+	if s.Resolution != "" && meta.Resolution != s.Resolution {
+		return passthroughError("resolution is %s, stream is set to %s", orUnknown(meta.Resolution), s.Resolution)
+	}
+	if meta.KeyframeSec == 0 {
+		return passthroughError("keyframe interval could not be measured")
+	}
+	if meta.KeyframeSec > maxPassthroughKeyframeSec {
+		return passthroughError("keyframe interval is %.1fs, need at most %.0fs", meta.KeyframeSec, maxPassthroughKeyframeSec)
+	}
+	if meta.AudioCodec != "aac" {
+		return passthroughError("audio codec is %s, need aac", orUnknown(meta.AudioCodec))
+	}
+
+	if ref != nil && ref != meta {
+		if meta.Resolution != ref.Resolution || meta.FPS != ref.FPS {
+			return passthroughError("video is %s@%d, first item is %s@%d", meta.Resolution, meta.FPS, ref.Resolution, ref.FPS)
+		}
+		if meta.AudioSampleRate != ref.AudioSampleRate || meta.AudioChannels != ref.AudioChannels {
+			return passthroughError("audio is %dHz/%dch, first item is %dHz/%dch",
+				meta.AudioSampleRate, meta.AudioChannels, ref.AudioSampleRate, ref.AudioChannels)
+		}
+	}
+
+	return nil
+}
+
+func passthroughError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrPassthroughIncompatible, fmt.Sprintf(format, args...))
+}
+
+func orUnknown(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
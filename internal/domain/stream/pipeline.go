@@ -2,130 +2,849 @@ package stream
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/activity"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/events"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/ws"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 type pipeline struct {
-	pm  *ProcessManager
-	hub *ws.Hub
-	log *zap.Logger
+	pm       *ProcessManager
+	hub      *ws.Hub
+	repo     Repository
+	sessions SessionRepository
+	caps     *ffmpeg.Capabilities
+	ingest   *IngestHub
+	bus      *events.Bus
+	log      *zap.Logger
 }
 
-func NewPipeline(pm *ProcessManager, hub *ws.Hub, log *zap.Logger) Pipeline {
+func NewPipeline(pm *ProcessManager, hub *ws.Hub, repo Repository, sessions SessionRepository, caps *ffmpeg.Capabilities, ingest *IngestHub, bus *events.Bus, log *zap.Logger) Pipeline {
 	return &pipeline{
-		pm:  pm,
-		hub: hub,
-		log: log,
+		pm:       pm,
+		hub:      hub,
+		repo:     repo,
+		sessions: sessions,
+		caps:     caps,
+		ingest:   ingest,
+		bus:      bus,
+		log:      log,
 	}
 }
 
-func (p *pipeline) Start(ctx context.Context, s *Stream, videoPath string) error {
-	p.log.Info("Starting pipeline", zap.String("stream_id", s.ID.String()))
+// launchSpec is everything needed to (re)launch the encoder on any input.
+type launchSpec struct {
+	stream   *Stream
+	plan     Plan
+	preview  string
+	overlays []ffmpeg.Overlay
+}
+
+// encoderRun is one running encoder process. outputs holds one stream per
+// rendition, the main one being stdout.
+type encoderRun struct {
+	args     []string
+	cmd      *exec.Cmd
+	outputs  []io.ReadCloser
+	progress io.ReadCloser
+	stderr   io.ReadCloser
+	stdin    io.Closer
+}
+
+func (p *pipeline) Start(ctx context.Context, s *Stream, plan Plan) error {
+	queue := plan.Queue
+	p.log.Info("Starting pipeline", zap.String("stream_id", s.ID.String()), zap.Int("queue_length", len(queue)))
 	p.emitLog("info", "pipeline_starting", s.ID, "Preparing ffmpeg pipeline")
 
-	if _, running := p.pm.Get(s.ID); running {
-		return fmt.Errorf("stream %s is already running", s.ID.String())
+	if !plan.Ingest && len(queue) == 0 {
+		return ErrStreamProgramEmpty
+	}
+
+	proc, err := p.pm.Register(s.ID, queue)
+	if err != nil {
+		return fmt.Errorf("stream %s: %w", s.ID.String(), err)
+	}
+	// Until the supervisor takes over, every failure releases the slot and
+	// removes whatever was written for the stream.
+	launched := false
+	defer func() {
+		if !launched {
+			removeStreamFiles(s.ID)
+			p.pm.Unregister(s.ID)
+			proc.markExited()
+		}
+	}()
+
+	for _, item := range queue {
+		if _, err := os.Stat(item.Path); err != nil {
+			p.log.Error("Video file not found", zap.String("path", item.Path), zap.Error(err))
+			p.emitLog("error", "video_missing", s.ID, fmt.Sprintf("Video source not found: %s", item.Name))
+			return fmt.Errorf("video file not found at %s: %w", item.Path, err)
+		}
+	}
+	if plan.Fallback != nil {
+		if _, err := os.Stat(plan.Fallback.Path); err != nil {
+			p.emitLog("error", "video_missing", s.ID, fmt.Sprintf("Fallback video not found: %s", plan.Fallback.Name))
+			return fmt.Errorf("fallback video not found at %s: %w", plan.Fallback.Path, err)
+		}
+	}
+
+	if len(plan.Destinations) == 0 {
+		return fmt.Errorf("at least one destination is required")
+	}
+	if len(plan.Renditions) == 0 {
+		plan.Renditions = PlanRenditions(s, plan.Destinations)
+	}
+
+	spec := launchSpec{stream: s, plan: plan}
+	if p.previewSupported(plan) {
+		dir := PreviewDir(s.ID)
+		_ = os.RemoveAll(dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			p.log.Warn("Failed to create preview directory", zap.String("stream_id", s.ID.String()), zap.Error(err))
+		} else {
+			spec.preview = dir
+		}
+	}
+
+	if len(plan.Audio.Playlist) > 0 {
+		files := make([]string, len(plan.Audio.Playlist))
+		for i, item := range plan.Audio.Playlist {
+			if _, err := os.Stat(item.Path); err != nil {
+				p.emitLog("error", "audio_missing", s.ID, fmt.Sprintf("Audio track not found: %s", item.Name))
+				return fmt.Errorf("audio file not found at %s: %w", item.Path, err)
+			}
+			files[i] = item.Path
+		}
+		if err := ffmpeg.WritePlaylist(audioPlaylistPath(s.ID), files); err != nil {
+			return fmt.Errorf("failed to write audio playlist: %w", err)
+		}
+	}
+
+	if len(plan.Overlays) > 0 {
+		dir := overlayDir(s.ID)
+		_ = os.RemoveAll(dir)
+		if err := writeOverlayFiles(dir, plan.Overlays); err != nil {
+			p.emitLog("error", "overlay_missing", s.ID, err.Error())
+			return err
+		}
+		spec.overlays = plan.ffmpegOverlays(dir)
 	}
 
-	if _, err := os.Stat(videoPath); err != nil {
-		p.log.Error("Video file not found", zap.String("path", videoPath), zap.Error(err))
-		p.emitLog("error", "video_missing", s.ID, "Video source not found")
-		return fmt.Errorf("video file not found at %s: %w", videoPath, err)
+	if !plan.Ingest && len(queue) > 1 {
+		files := make([]string, len(queue))
+		for i, item := range queue {
+			files[i] = item.Path
+		}
+		if err := ffmpeg.WritePlaylist(playlistPath(s.ID), files); err != nil {
+			return fmt.Errorf("failed to write playlist: %w", err)
+		}
+	}
+
+	input := p.selectInput(spec, retryGate{})
+
+	var run *encoderRun
+	if input != "" {
+		run, err = p.launchInput(spec, input, 0)
+		if err != nil {
+			p.log.Error("Failed to start ffmpeg", zap.Error(err))
+			p.emitLog("error", "pipeline_start_failed", s.ID, err.Error())
+			return err
+		}
+		if !proc.attach(run.cmd) {
+			p.discard(run)
+			return fmt.Errorf("stream %s was stopped while starting", s.ID.String())
+		}
 	}
 
+	proc.session = p.startSession(s.ID)
+	relays := make([]*relay, len(plan.Destinations))
+	for i, target := range plan.Destinations {
+		relays[i] = newRelay(i, target, s.RestartPolicy, p.destinationChanged(s), p.log)
+		go relays[i].Run()
+	}
+	proc.setRelays(relays)
+
+	if run != nil {
+		proc.session.launched(run.args)
+		proc.SetInput(input)
+		p.setStatus(proc, s.ID, StatusRunning)
+		p.emitLog("info", "pipeline_running", s.ID, "Pipeline is live")
+	}
+	p.publish(s, events.StreamStarted, "Stream started", nil)
+
+	launched = true
+	go p.supervise(proc, spec, input, run)
+
+	return nil
+}
+
+// removeStreamFiles deletes the temporary files written for a stream run.
+func removeStreamFiles(streamID uuid.UUID) {
+	_ = os.Remove(playlistPath(streamID))
+	_ = os.Remove(audioPlaylistPath(streamID))
+	_ = os.RemoveAll(PreviewDir(streamID))
+	_ = os.RemoveAll(overlayDir(streamID))
+}
+
+// discard kills an encoder that was launched but will not be used.
+func (p *pipeline) discard(run *encoderRun) {
+	_ = run.cmd.Process.Kill()
+	for _, output := range run.outputs {
+		_ = output.Close()
+	}
+	_ = run.progress.Close()
+	_ = run.stderr.Close()
+	if run.stdin != nil {
+		_ = run.stdin.Close()
+	}
+	_ = run.cmd.Wait()
+}
+
+// retryGate holds the primary input back after it failed while the fallback
+// video plays.
+type retryGate struct {
+	at    time.Time
+	never bool
+	// A new ingest session lifts the gate: the publisher has reconnected.
+	changed <-chan struct{}
+}
+
+func primaryInput(spec launchSpec) string {
+	if spec.plan.Ingest {
+		return InputIngest
+	}
+	return InputQueue
+}
+
+// selectInput picks what the encoder should read right now: the primary
+// input when it is usable, otherwise the fallback video. Without a fallback
+// an ingest stream waits ("") for its publisher, while a file stream keeps
+// retrying its queue.
+func (p *pipeline) selectInput(spec launchSpec, gate retryGate) string {
+	if p.primaryReady(spec, gate) {
+		return primaryInput(spec)
+	}
+	if spec.plan.Fallback != nil {
+		return InputFallback
+	}
+	if spec.plan.Ingest {
+		return ""
+	}
+	return InputQueue
+}
+
+func (p *pipeline) primaryReady(spec launchSpec, gate retryGate) bool {
+	if spec.plan.Ingest {
+		if !p.ingest.Connected(spec.stream.ID) {
+			return false
+		}
+		if gate.changed != nil && isClosed(gate.changed) {
+			return true
+		}
+	}
+	if gate.never || time.Now().Before(gate.at) {
+		return false
+	}
+	return spec.plan.Ingest || queueReadable(spec.plan.Queue)
+}
+
+// queueReadable checks that every queued file can still be opened and read.
+func queueReadable(queue []QueueItem) bool {
+	buf := make([]byte, 1)
+	for _, item := range queue {
+		f, err := os.Open(item.Path)
+		if err != nil {
+			return false
+		}
+		_, err = f.Read(buf)
+		_ = f.Close()
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// buildArgs renders the encoder command for the given input. offset is how
+// long the pipeline has already been on air.
+func (p *pipeline) buildArgs(spec launchSpec, input string, offset time.Duration) ([]string, error) {
+	s, plan := spec.stream, spec.plan
+	main := plan.Renditions[0].Settings
+	extra := make([]ffmpeg.Rendition, 0, len(plan.Renditions)-1)
+	for _, r := range plan.Renditions[1:] {
+		extra = append(extra, r.Settings)
+	}
 	builder := ffmpeg.NewCommandBuilder().
-		WithInput(videoPath).
-		WithBitrate(s.Bitrate).
-		WithResolution(s.Resolution).
-		WithFPS(s.FPS).
+		WithBitrate(main.Bitrate).
+		WithResolution(main.Resolution).
+		WithFPS(main.FPS).
+		WithFit(main.Fit, main.CropOffset).
+		WithRenditions(extra).
+		WithProgress(ffmpeg.ProgressPipe(len(extra))).
 		WithLoop(s.Loop).
-		WithDestinations(s.RTMPTargets)
+		WithEncoder(plan.Encoder).
+		WithPassthrough(plan.Passthrough).
+		WithCapabilities(p.caps).
+		WithOverlays(spec.overlays).
+		WithPipeOutput().
+		WithOutputOffset(offset)
+
+	if spec.preview != "" {
+		builder.WithPreview(spec.preview)
+	}
+
+	audio := plan.Audio
+	builder.WithLoudnorm(audio.Normalize)
+	switch {
+	case len(audio.Playlist) > 0:
+		builder.WithAudioPlaylist(audioPlaylistPath(s.ID))
+	case input == InputIngest:
+		// A live publisher's tracks are unknown until it connects.
+		builder.WithSilence(audio.Mute)
+	case input == InputFallback:
+		builder.WithSilence(audio.silenceFor([]QueueItem{*plan.Fallback}))
+	default:
+		builder.WithSilence(audio.silenceFor(plan.Queue))
+	}
+
+	switch {
+	case input == InputIngest:
+		builder.WithLiveInput()
+	case input == InputFallback:
+		builder.WithInput(plan.Fallback.Path).WithLoop(true)
+	case len(plan.Queue) == 1:
+		builder.WithInput(plan.Queue[0].Path)
+	default:
+		builder.WithPlaylist(playlistPath(s.ID))
+	}
 
 	args, err := builder.Build()
 	if err != nil {
-		return fmt.Errorf("failed to build ffmpeg command: %w", err)
+		return nil, fmt.Errorf("failed to build ffmpeg command: %w", err)
 	}
+	return args, nil
+}
 
-	p.log.Info("Executing ffmpeg", zap.Strings("args", args))
+func (p *pipeline) launchInput(spec launchSpec, input string, offset time.Duration) (*encoderRun, error) {
+	args, err := p.buildArgs(spec, input, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdin io.ReadCloser
+	if input == InputIngest {
+		if stdin = p.ingest.Attach(spec.stream.ID); stdin == nil {
+			return nil, fmt.Errorf("live ingest disconnected")
+		}
+	}
+
+	p.log.Info("Executing ffmpeg", zap.String("input", input), zap.Strings("args", args))
+	run, err := p.launch(args, stdin, len(spec.plan.Renditions)-1)
+	if err != nil && stdin != nil {
+		_ = stdin.Close()
+	}
+	return run, err
+}
+
+// launch starts the encoder. Each extra rendition is written to a pipe
+// handed to ffmpeg as an extra file, at the descriptor RenditionPipe gives,
+// followed by the progress pipe.
+func (p *pipeline) launch(args []string, stdin io.ReadCloser, extraOutputs int) (*encoderRun, error) {
 	cmd := exec.Command("ffmpeg", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	outputs := []io.ReadCloser{stdout}
+	writers := make([]*os.File, 0, extraOutputs)
+	closePipes := func() {
+		for _, r := range outputs[1:] {
+			_ = r.Close()
+		}
+		for _, w := range writers {
+			_ = w.Close()
+		}
+	}
+	for i := 0; i <= extraOutputs; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closePipes()
+			return nil, fmt.Errorf("failed to create rendition pipe: %w", err)
+		}
+		outputs = append(outputs, r)
+		writers = append(writers, w)
 	}
+	cmd.ExtraFiles = writers
+	progress := outputs[len(outputs)-1]
+	outputs = outputs[:len(outputs)-1]
 
-	proc := p.pm.Register(s.ID, cmd)
+	// Feed stdin ourselves rather than through cmd.Stdin, whose copy would
+	// hold up Wait for as long as the live source stays quiet.
+	var stdinPipe io.WriteCloser
+	if stdin != nil {
+		if stdinPipe, err = cmd.StdinPipe(); err != nil {
+			closePipes()
+			return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+		}
+	}
 
 	if err := cmd.Start(); err != nil {
-		p.pm.Unregister(s.ID)
-		p.log.Error("Failed to start ffmpeg", zap.Error(err))
-		p.emitLog("error", "pipeline_start_failed", s.ID, "Failed to start ffmpeg")
-		return fmt.Errorf("failed to start ffmpeg: %w", err)
+		closePipes()
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	// ffmpeg holds its own copies; closing ours lets the readers see the end
+	// of each rendition when it exits.
+	for _, w := range writers {
+		_ = w.Close()
+	}
+
+	if stdin != nil {
+		go func() {
+			_, _ = io.Copy(stdinPipe, stdin)
+			_ = stdinPipe.Close()
+		}()
+	}
+
+	return &encoderRun{args: args, cmd: cmd, outputs: outputs, progress: progress, stderr: stderr, stdin: stdin}, nil
+}
+
+// fanout copies one rendition to the relays of its destinations until the
+// encoder closes it.
+func (p *pipeline) fanout(stdout io.Reader, relays []*relay) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := stdout.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			for _, r := range relays {
+				r.Feed(chunk)
+			}
+		}
+		if err != nil {
+			return
+		}
 	}
+}
+
+func (p *pipeline) destinationChanged(s *Stream) func(DestinationState) {
+	streamID := s.ID
+	return func(state DestinationState) {
+		p.hub.Broadcast("destination_status", map[string]interface{}{
+			"stream_id":   streamID.String(),
+			"destination": state,
+		})
+
+		switch state.Status {
+		case DestinationFailed:
+			message := fmt.Sprintf("Destination %s failed: %s", state.Target, state.LastError)
+			p.emitLog("warning", "destination_failed", streamID, message)
+			p.publish(s, events.DestinationFailed, message, map[string]interface{}{"destination": state.Target})
+		case DestinationConnected:
+			p.emitLog("info", "destination_connected", streamID, fmt.Sprintf("Destination %s connected", state.Target))
+		}
+	}
+}
+
+// supervise keeps the encoder alive according to the stream's restart
+// policy and unregisters it once it stops for good. The relays outlive any
+// single encoder, so destinations stay connected while it is replaced.
+//
+// When the primary input fails and the stream has a fallback video, the
+// encoder is relaunched on the fallback straight away instead of waiting out
+// the restart backoff; once the backoff has passed and the primary is usable
+// again it switches back. Ingest streams also move to the fallback while their
+// publisher is away, which does not count as a failure.
+func (p *pipeline) supervise(proc *Process, spec launchSpec, input string, run *encoderRun) {
+	s := spec.stream
+	defer proc.markExited()
+	defer p.pm.Unregister(s.ID)
+	defer removeStreamFiles(s.ID)
+	defer func() {
+		var wg sync.WaitGroup
+		for _, r := range proc.relays {
+			wg.Add(1)
+			go func(r *relay) {
+				defer wg.Done()
+				r.Stop(stopTimeout)
+			}(r)
+		}
+		wg.Wait()
+	}()
+
+	status := StatusStopped
+	lastError := ""
+	exitReason := ExitStopped
+	defer func() { proc.session.finish(exitReason, lastError) }()
+	restarted := false
+	reason := ""
+	var gate retryGate
+	for {
+		if run == nil && input == "" {
+			proc.SetInput("")
+			p.setStatus(proc, s.ID, StatusWaiting)
+			p.emitLog("info", "ingest_waiting", s.ID, "Waiting for the live ingest to connect")
+			select {
+			case <-p.ingest.Changed(s.ID):
+			case <-proc.StopChan():
+			}
+			if proc.StopRequested() {
+				p.emitLog("info", "pipeline_stopped", s.ID, "Pipeline stopped")
+				break
+			}
+			input = p.selectInput(spec, gate)
+			continue
+		}
+
+		var exitErr error
+		if run == nil {
+			next, err := p.launchInput(spec, input, time.Since(proc.StartedAt))
+			if err != nil {
+				p.log.Error("Failed to restart ffmpeg", zap.String("stream_id", s.ID.String()), zap.Error(err))
+				p.emitLog("error", "pipeline_restart_failed", s.ID, "Failed to restart ffmpeg")
+				exitErr = err
+			} else if !proc.attach(next.cmd) {
+				// Stop came in while ffmpeg was launching and could only
+				// signal the previous encoder.
+				p.discard(next)
+				p.emitLog("info", "pipeline_stopped", s.ID, "Pipeline stopped")
+				break
+			} else {
+				run = next
+				proc.session.launched(run.args)
+				if previous := proc.GetInput(); previous != "" && previous != input {
+					p.sourceSwitched(s.ID, previous, input, reason)
+				}
+				proc.SetInput(input)
+				p.setStatus(proc, s.ID, StatusRunning)
+				if restarted {
+					message := fmt.Sprintf("Pipeline restarted (attempt %d)", proc.RestartCount())
+					p.emitLog("info", "pipeline_restarted", s.ID, message)
+					p.publish(s, events.StreamRestarted, message, map[string]interface{}{"attempt": proc.RestartCount()})
+				}
+			}
+		}
+		restarted = false
+		reason = ""
+
+		if run != nil {
+			exitErr = p.runEncoder(proc, spec, input, gate, run)
+			run = nil
+		}
+
+		if proc.StopRequested() {
+			p.log.Info("ffmpeg stopped on request", zap.String("stream_id", s.ID.String()))
+			p.emitLog("info", "pipeline_stopped", s.ID, "Pipeline stopped")
+			break
+		}
 
-	proc.SetStatus(StatusRunning)
+		if next, ok := proc.takeSwitch(); ok {
+			input, reason = next, "primary source is available again"
+			gate = retryGate{}
+			continue
+		}
+
+		if input == InputIngest && !p.ingest.Connected(s.ID) {
+			gate = retryGate{}
+			input, reason = p.selectInput(spec, gate), "live ingest disconnected"
+			continue
+		}
+
+		if input == InputQueue && exitErr == nil {
+			p.log.Info("ffmpeg exited successfully", zap.String("stream_id", s.ID.String()))
+			p.emitLog("info", "pipeline_stopped", s.ID, "Pipeline stopped")
+			exitReason = ExitCompleted
+			if err := p.repo.SetDesiredState(context.Background(), s.ID, DesiredStopped); err != nil {
+				p.log.Warn("failed to persist desired state", zap.String("stream_id", s.ID.String()), zap.Error(err))
+			}
+			break
+		}
+
+		if input != InputFallback && spec.plan.Fallback != nil {
+			delay, ok := proc.NextRestart(s.RestartPolicy)
+			gate = retryGate{at: time.Now().Add(delay), never: !ok}
+			if spec.plan.Ingest {
+				gate.changed = p.ingest.Changed(s.ID)
+			}
+			if ok {
+				proc.session.restarted()
+				p.emitLog("warning", "pipeline_restarting", s.ID, fmt.Sprintf(
+					"Primary source failed; retrying it in %s (attempt %d/%d)", delay, proc.RestartCount(), s.RestartPolicy.MaxAttempts,
+				))
+			} else {
+				p.emitLog("error", "pipeline_error", s.ID, "Primary source failed; staying on the fallback video")
+			}
+			input, reason = InputFallback, "primary source failed"
+			continue
+		}
+
+		delay, ok := proc.NextRestart(s.RestartPolicy)
+		if !ok {
+			p.emitLog("error", "pipeline_error", s.ID, "ffmpeg exited with error")
+			status = StatusError
+			exitReason = ExitFailed
+			if exitErr != nil {
+				lastError = fmt.Sprintf("ffmpeg exited with error: %v", exitErr)
+			} else {
+				lastError = "ffmpeg could not be restarted"
+			}
+			break
+		}
+
+		attempt := proc.RestartCount()
+		proc.session.restarted()
+		p.emitLog("warning", "pipeline_restarting", s.ID, fmt.Sprintf(
+			"Restarting ffmpeg in %s (attempt %d/%d)", delay, attempt, s.RestartPolicy.MaxAttempts,
+		))
+		proc.SetStatus(StatusRestarting)
+		p.persistStatus(s.ID, StatusRestarting, "")
+		p.hub.Broadcast("stream_status", map[string]interface{}{
+			"stream_id": s.ID.String(),
+			"status":    StatusRestarting,
+			"attempt":   attempt,
+		})
+
+		select {
+		case <-time.After(delay):
+		case <-proc.StopChan():
+			p.emitLog("info", "pipeline_stopped", s.ID, "Pipeline stopped")
+		}
+		if proc.StopRequested() {
+			break
+		}
+
+		next := p.selectInput(spec, gate)
+		if next != input {
+			reason = "fallback video failed"
+		}
+		input = next
+		restarted = true
+	}
+
+	if status == StatusError {
+		p.publish(s, events.StreamError, lastError, nil)
+	} else {
+		p.publish(s, events.StreamStopped, "Stream stopped", map[string]interface{}{"reason": exitReason})
+	}
+
+	proc.SetStatus(status)
+	p.persistStatus(s.ID, status, lastError)
 	p.hub.Broadcast("stream_status", map[string]interface{}{
 		"stream_id": s.ID.String(),
-		"status":    "running",
+		"status":    status,
 	})
-	p.emitLog("info", "pipeline_running", s.ID, "Pipeline is live")
+}
 
-	go p.monitorProcess(proc, s.ID, stderr)
+// Encoder stall detection: ffmpeg can hang on an unreadable input instead of
+// exiting, which would leave the destinations without data.
+const (
+	stallTimeout = 8 * time.Second
+	startupGrace = 20 * time.Second
+)
 
-	return nil
+// runEncoder relays one encoder run to the destinations and waits for it to
+// exit. A stalled encoder is killed so it can be replaced. While the fallback
+// video plays, the primary input is watched so the supervisor can switch back
+// to it.
+func (p *pipeline) runEncoder(proc *Process, spec launchSpec, input string, gate retryGate, run *encoderRun) error {
+	var fanouts sync.WaitGroup
+	for i, output := range run.outputs {
+		relays := make([]*relay, 0, len(spec.plan.Renditions[i].Destinations))
+		for _, d := range spec.plan.Renditions[i].Destinations {
+			relays = append(relays, proc.relays[d])
+		}
+		fanouts.Add(1)
+		go func(output io.Reader) {
+			defer fanouts.Done()
+			p.fanout(output, relays)
+		}(output)
+	}
+	outputDone := make(chan struct{})
+	go func() {
+		fanouts.Wait()
+		close(outputDone)
+	}()
+
+	watchDone := make(chan struct{})
+	go p.watchStop(proc, run.cmd, watchDone)
+	go p.watchStall(proc, spec.stream.ID, run.cmd, watchDone)
+	if input == InputFallback && (spec.plan.Ingest || !gate.never) {
+		go p.watchPrimary(proc, spec, gate, run.cmd, watchDone)
+	}
+
+	err := p.monitorProcess(proc, spec.stream, run, outputDone)
+	close(watchDone)
+	for _, output := range run.outputs[1:] {
+		_ = output.Close()
+	}
+	if run.stdin != nil {
+		_ = run.stdin.Close()
+	}
+	return err
+}
+
+// watchStop ends the encoder when the stream is stopped. Stop signals the
+// encoder it sees, which may already have been replaced by a restart.
+func (p *pipeline) watchStop(proc *Process, cmd *exec.Cmd, done <-chan struct{}) {
+	select {
+	case <-done:
+		return
+	case <-proc.StopChan():
+	}
+	_ = cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		_ = cmd.Process.Kill()
+	}
+}
+
+func (p *pipeline) watchStall(proc *Process, streamID uuid.UUID, cmd *exec.Cmd, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if proc.Stalled(stallTimeout, startupGrace) {
+			p.emitLog("warning", "pipeline_stalled", streamID, "ffmpeg stopped making progress")
+			_ = cmd.Process.Kill()
+			return
+		}
+	}
+}
+
+func (p *pipeline) watchPrimary(proc *Process, spec launchSpec, gate retryGate, cmd *exec.Cmd, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		var changed <-chan struct{}
+		if spec.plan.Ingest {
+			changed = p.ingest.Changed(spec.stream.ID)
+		}
+		if p.primaryReady(spec, gate) {
+			proc.requestSwitch(primaryInput(spec))
+			_ = cmd.Process.Signal(os.Interrupt)
+			return
+		}
+		select {
+		case <-changed:
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// sourceSwitched records a change of encoder input.
+func (p *pipeline) sourceSwitched(streamID uuid.UUID, from, to, reason string) {
+	level := "info"
+	if to == InputFallback {
+		level = "warning"
+	}
+	message := fmt.Sprintf("Switched from %s to %s", from, to)
+	if reason != "" {
+		message += ": " + reason
+	}
+	p.emitLog(level, "source_switched", streamID, message)
+	p.hub.Broadcast("stream_source", map[string]interface{}{
+		"stream_id": streamID.String(),
+		"from":      from,
+		"to":        to,
+		"reason":    reason,
+	})
+}
+
+func (p *pipeline) setStatus(proc *Process, streamID uuid.UUID, status ProcessStatus) {
+	proc.SetStatus(status)
+	p.persistStatus(streamID, status, "")
+	p.hub.Broadcast("stream_status", map[string]interface{}{
+		"stream_id": streamID.String(),
+		"status":    status,
+	})
 }
 
-func (p *pipeline) monitorProcess(proc *Process, streamID uuid.UUID, stderr io.ReadCloser) {
-	defer stderr.Close()
-	defer p.pm.Unregister(streamID)
+func (p *pipeline) monitorProcess(proc *Process, s *Stream, run *encoderRun, outputDone <-chan struct{}) error {
+	streamID := s.ID
+	defer run.stderr.Close()
+	defer run.progress.Close()
 
-	scanner := bufio.NewScanner(stderr)
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		_ = ffmpeg.ReadProgress(run.progress, func(progress *ffmpeg.Progress) {
+			p.reportProgress(proc, s, progress, s.SlowAfter())
+		})
+	}()
+
+	scanner := bufio.NewScanner(run.stderr)
+	scanner.Split(scanLines)
 	var processLog []string
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		if progress := ffmpeg.ParseProgress(line); progress != nil {
-			proc.UpdateProgress(progress)
-			p.hub.Broadcast("stream_progress", map[string]interface{}{
-				"stream_id": streamID.String(),
-				"progress":  progress,
-			})
-		} else {
-			// Log other ffmpeg output for debugging
-			p.log.Info("ffmpeg output", zap.String("stream_id", streamID.String()), zap.String("line", line))
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
 
-			// Keep last 10 lines for error context
-			processLog = append(processLog, line)
-			if len(processLog) > 10 {
-				processLog = processLog[1:]
-			}
+		// Log other ffmpeg output for debugging
+		p.log.Info("ffmpeg output", zap.String("stream_id", streamID.String()), zap.String("line", line))
 
-			if looksLikeFFmpegError(line) {
-				activity.Record(activity.Entry{
-					Timestamp: time.Now().UTC(),
-					Source:    "ffmpeg",
-					Level:     "error",
-					Event:     "stderr",
-					Message:   line,
-					StreamID:  streamID.String(),
-				})
-			}
+		proc.session.outputLine(line)
+
+		// Keep last 10 lines for error context
+		processLog = append(processLog, line)
+		if len(processLog) > 10 {
+			processLog = processLog[1:]
+		}
+
+		if looksLikeFFmpegError(line) {
+			activity.Record(activity.Entry{
+				Timestamp: time.Now().UTC(),
+				Source:    "ffmpeg",
+				Level:     "error",
+				Event:     "stderr",
+				Message:   line,
+				StreamID:  streamID.String(),
+			})
 		}
 	}
 
-	status := StatusStopped
-	if err := proc.Cmd.Wait(); err != nil {
+	<-progressDone
+	<-outputDone
+	err := run.cmd.Wait()
+	if err != nil && !proc.StopRequested() {
 		errorContext := ""
 		if len(processLog) > 0 {
 			errorContext = fmt.Sprintf("\nLast output lines:\n%s", strings.Join(processLog, "\n"))
@@ -135,20 +854,44 @@ func (p *pipeline) monitorProcess(proc *Process, streamID uuid.UUID, stderr io.R
 			zap.Error(err),
 			zap.String("context", errorContext),
 		)
-		p.emitLog("error", "pipeline_error", streamID, "ffmpeg exited with error")
-		status = StatusError
-	} else {
-		p.log.Info("ffmpeg exited successfully", zap.String("stream_id", streamID.String()))
-		p.emitLog("info", "pipeline_stopped", streamID, "Pipeline stopped")
 	}
 
-	proc.SetStatus(status)
-	p.hub.Broadcast("stream_status", map[string]interface{}{
-		"stream_id": streamID.String(),
-		"status":    status,
+	return err
+}
+
+// reportProgress records an encoder progress report and follows the queue
+// position it implies. An encoder slower than real time for slowAfter is
+// reported once.
+func (p *pipeline) reportProgress(proc *Process, s *Stream, progress *ffmpeg.Progress, slowAfter time.Duration) {
+	streamID := s.ID
+	proc.UpdateProgress(progress)
+	proc.session.sample(progress)
+	if proc.TrackSpeed(progress.Speed, slowAfter) {
+		message := fmt.Sprintf("Encoding at %.2fx, slower than real time for %s", progress.Speed, slowAfter)
+		p.emitLog("warning", "pipeline_slow", streamID, message)
+		p.publish(s, events.StreamSlow, message, map[string]interface{}{"speed": progress.Speed})
+	}
+	index := queueIndexAt(proc.Queue, progress.Seconds(), s.Loop)
+	if proc.SetCurrentIndex(index) {
+		if item := proc.CurrentItem(); item != nil {
+			p.emitLog("info", "queue_advanced", streamID, fmt.Sprintf("Now playing %s", item.Name))
+		}
+	}
+	p.hub.Broadcast("stream_progress", map[string]interface{}{
+		"stream_id":   streamID.String(),
+		"progress":    progress,
+		"queue_index": index,
+		"queue_total": len(proc.Queue),
+		"current":     proc.CurrentItem(),
 	})
 }
 
+// stopTimeout is how long ffmpeg gets to exit after an interrupt before it
+// is killed.
+const stopTimeout = 5 * time.Second
+
+// Stop asks the stream to stop and waits until it has: the encoder, a
+// restart that was pending and the relays all wound down.
 func (p *pipeline) Stop(ctx context.Context, s *Stream) error {
 	proc, ok := p.pm.Get(s.ID)
 	if !ok {
@@ -156,32 +899,40 @@ func (p *pipeline) Stop(ctx context.Context, s *Stream) error {
 	}
 	p.emitLog("info", "pipeline_stopping", s.ID, "Stopping pipeline")
 
+	proc.RequestStop()
 	proc.SetStatus(StatusStopping)
 	p.hub.Broadcast("stream_status", map[string]interface{}{
 		"stream_id": s.ID.String(),
 		"status":    "stopping",
 	})
 
-	if err := proc.Cmd.Process.Signal(os.Interrupt); err != nil {
-		return proc.Cmd.Process.Kill()
+	if cmd := proc.GetCmd(); cmd != nil && cmd.Process != nil {
+		_ = cmd.Process.Signal(os.Interrupt)
 	}
 
-	done := make(chan error, 1)
-	go func() {
-		done <- nil
-	}()
-
 	select {
-	case <-time.After(5 * time.Second):
-		return proc.Cmd.Process.Kill()
+	case <-proc.Done():
+		return nil
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-done:
+	case <-time.After(stopTimeout):
+	}
+
+	if cmd := proc.GetCmd(); cmd != nil && cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+	// The relays get their own timeout once the encoder is gone.
+	select {
+	case <-proc.Done():
 		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(2 * stopTimeout):
+		return fmt.Errorf("stream %s did not stop in time", s.ID.String())
 	}
 }
 
-func (p *pipeline) Reload(ctx context.Context, s *Stream, videoPath string) error {
+func (p *pipeline) Reload(ctx context.Context, s *Stream, plan Plan) error {
 	p.emitLog("info", "pipeline_reload", s.ID, "Applying live changes")
 
 	if err := p.Stop(ctx, s); err != nil {
@@ -200,7 +951,7 @@ func (p *pipeline) Reload(ctx context.Context, s *Stream, videoPath string) erro
 		time.Sleep(100 * time.Millisecond)
 	}
 
-	if err := p.Start(ctx, s, videoPath); err != nil {
+	if err := p.Start(ctx, s, plan); err != nil {
 		p.emitLog("error", "pipeline_reload_failed", s.ID, "Failed to start reloaded process")
 		return fmt.Errorf("start reloaded process: %w", err)
 	}
@@ -209,6 +960,23 @@ func (p *pipeline) Reload(ctx context.Context, s *Stream, videoPath string) erro
 	return nil
 }
 
+func (p *pipeline) persistStatus(streamID uuid.UUID, status ProcessStatus, lastError string) {
+	if err := p.repo.UpdateStatus(context.Background(), streamID, string(status), lastError); err != nil {
+		p.log.Warn("failed to persist stream status", zap.String("stream_id", streamID.String()), zap.Error(err))
+	}
+}
+
+// publish announces a lifecycle event of the stream to the event bus.
+func (p *pipeline) publish(s *Stream, eventType, message string, data map[string]interface{}) {
+	p.bus.Publish(events.Event{
+		Type:       eventType,
+		StreamID:   s.ID.String(),
+		StreamName: s.Name,
+		Message:    message,
+		Data:       data,
+	})
+}
+
 func (p *pipeline) emitLog(level, event string, streamID uuid.UUID, message string) {
 	activity.Record(activity.Entry{
 		Timestamp: time.Now().UTC(),
@@ -228,6 +996,68 @@ func (p *pipeline) emitLog(level, event string, streamID uuid.UUID, message stri
 	})
 }
 
+func playlistPath(streamID uuid.UUID) string {
+	return filepath.Join("data", "playlists", streamID.String()+".txt")
+}
+
+var previewRoot = filepath.Join("data", "preview")
+
+// PreviewDir is where the stream's HLS preview is written while it runs.
+func PreviewDir(streamID uuid.UUID) string {
+	return filepath.Join(previewRoot, streamID.String())
+}
+
+// previewSupported reports whether the local ffmpeg can write the preview;
+// without it the stream still runs, just with no preview.
+func (p *pipeline) previewSupported(plan Plan) bool {
+	if p.caps == nil {
+		return true
+	}
+	return p.caps.HasMuxer("hls") && (plan.Passthrough || p.caps.HasEncoder("libx264"))
+}
+
+// queueIndexAt maps an output timestamp onto the queue item being played,
+// wrapping around the total queue duration when the stream loops.
+func queueIndexAt(queue []QueueItem, seconds float64, loop bool) int {
+	if len(queue) <= 1 {
+		return 0
+	}
+
+	total := 0
+	for _, item := range queue {
+		total += item.Duration
+	}
+	if total <= 0 {
+		return 0
+	}
+
+	position := seconds
+	if loop {
+		position = math.Mod(seconds, float64(total))
+	}
+
+	elapsed := 0.0
+	for i, item := range queue {
+		elapsed += float64(item.Duration)
+		if position < elapsed {
+			return i
+		}
+	}
+	return len(queue) - 1
+}
+
+// scanLines splits ffmpeg's stderr on either line ending: progress reports
+// are terminated by a bare carriage return.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
 func normalizeLogLevel(level string) string {
 	normalized := strings.ToLower(strings.TrimSpace(level))
 
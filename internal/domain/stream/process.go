@@ -1,7 +1,6 @@
 package stream
 
 import (
-	"os/exec"
 	"sync"
 	"time"
 
@@ -19,18 +18,27 @@ func NewProcessManager() *ProcessManager {
 	}
 }
 
-func (m *ProcessManager) Register(id uuid.UUID, cmd *exec.Cmd) *Process {
+// Register reserves the stream's slot before its encoder is launched, so two
+// concurrent starts cannot both launch one. It fails while the stream is
+// registered.
+func (m *ProcessManager) Register(id uuid.UUID, queue []QueueItem) (*Process, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if _, ok := m.processes[id]; ok {
+		return nil, ErrStreamAlreadyRunning
+	}
 	p := &Process{
-		ID:        id,
-		Cmd:       cmd,
-		Status:    StatusStarting,
-		StartedAt: time.Now(),
+		ID:           id,
+		Status:       StatusStarting,
+		StartedAt:    time.Now(),
+		Queue:        queue,
+		runStartedAt: time.Now(),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
 	}
 	m.processes[id] = p
-	return p
+	return p, nil
 }
 
 func (m *ProcessManager) Unregister(id uuid.UUID) {
@@ -0,0 +1,40 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// queueIndexAt is not exported, so it is tested from inside the package.
+func TestQueueIndexAt(t *testing.T) {
+	queue := []QueueItem{{Duration: 10}, {Duration: 20}, {Duration: 30}}
+
+	tests := []struct {
+		name    string
+		queue   []QueueItem
+		seconds float64
+		loop    bool
+		want    int
+	}{
+		{"Empty queue", nil, 42, true, 0},
+		{"Single item", queue[:1], 500, false, 0},
+		{"Start of the queue", queue, 0, false, 0},
+		{"Just before the first boundary", queue, 9.999, false, 0},
+		{"On the first boundary", queue, 10, false, 1},
+		{"On the last boundary", queue, 30, false, 2},
+		{"Last second of the queue", queue, 59.9, false, 2},
+		{"Past the end without looping", queue, 600, false, 2},
+		{"Loop wraps to the start", queue, 60, true, 0},
+		{"Loop wraps into the second item", queue, 60 + 15, true, 1},
+		{"Several loops later", queue, 3*60 + 45, true, 2},
+		{"Negative timestamps before the first frame", queue, -0.5, false, 0},
+		{"Negative timestamps while looping", queue, -0.5, true, 0},
+		{"Unknown durations", []QueueItem{{}, {}}, 12, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, queueIndexAt(tt.queue, tt.seconds, tt.loop))
+		})
+	}
+}
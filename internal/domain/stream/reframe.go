@@ -0,0 +1,102 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/codewithwan/gostreamix/internal/domain/video"
+	"github.com/google/uuid"
+)
+
+// maxReframeUpscale is how far a crop may enlarge the source before the
+// result looks too soft to send.
+const maxReframeUpscale = 2.0
+
+// CheckReframe reports why a source cannot be reframed into the rendition,
+// or nil if it can.
+func CheckReframe(r ffmpeg.Rendition, meta *video.Metadata) error {
+	if !r.Reframes() {
+		return nil
+	}
+	tw, th, ok := parseResolution(r.Resolution)
+	if !ok {
+		return reframeError("target resolution %q is not WIDTHxHEIGHT", r.Resolution)
+	}
+	sw, sh, ok := parseResolution(meta.Resolution)
+	if !ok {
+		return reframeError("source resolution is %s", orUnknown(meta.Resolution))
+	}
+
+	sourceAspect, targetAspect := float64(sw)/float64(sh), float64(tw)/float64(th)
+	if r.Fit == ffmpeg.FitOffset && sourceAspect <= targetAspect {
+		return reframeError("source is %s, too narrow for an offset crop to %s", meta.Resolution, r.Resolution)
+	}
+	if r.Fit == ffmpeg.FitCrop || r.Fit == ffmpeg.FitOffset {
+		// The crop fills the target, so the source's shorter side relative
+		// to the target decides the upscale.
+		scale := max(float64(tw)/float64(sw), float64(th)/float64(sh))
+		if scale > maxReframeUpscale {
+			return reframeError("cropping %s to %s would upscale it %.1fx", meta.Resolution, r.Resolution, scale)
+		}
+	}
+	return nil
+}
+
+func parseResolution(res string) (int, int, bool) {
+	w, h, ok := strings.Cut(res, "x")
+	if !ok {
+		return 0, 0, false
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil || width <= 0 {
+		return 0, 0, false
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+func reframeError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrReframeIncompatible, fmt.Sprintf(format, args...))
+}
+
+// renderTimeout bounds how long ffmpeg may take to render a preview frame.
+const renderTimeout = 20 * time.Second
+
+var reframeRoot = filepath.Join("data", "reframe")
+
+// renderFrame renders a single frame of input as the rendition shows it and
+// returns the JPEG. Each call writes its own temporary file, so previews of
+// the same stream can run side by side.
+func renderFrame(ctx context.Context, streamID uuid.UUID, input string, at int, r ffmpeg.Rendition) ([]byte, error) {
+	if err := os.MkdirAll(reframeRoot, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(reframeRoot, streamID.String()+"-*.jpg")
+	if err != nil {
+		return nil, err
+	}
+	output := f.Name()
+	_ = f.Close()
+	defer func() { _ = os.Remove(output) }()
+
+	ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ffmpeg", ffmpeg.FramePreviewArgs(input, output, at, r)...).CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("render frame: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("render frame: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return os.ReadFile(output)
+}
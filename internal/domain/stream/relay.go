@@ -0,0 +1,274 @@
+package stream
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"go.uber.org/zap"
+)
+
+const relayBufferChunks = 256
+
+// relay pushes the shared encode to a single destination through its own
+// copy-only ffmpeg process, reconnecting with backoff whenever that process
+// dies so the other destinations are never interrupted.
+type relay struct {
+	target   Destination
+	policy   RestartPolicy
+	feed     chan []byte
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	onChange func(DestinationState)
+	log      *zap.Logger
+
+	mu    sync.RWMutex
+	state DestinationState
+	cmd   *exec.Cmd
+}
+
+func newRelay(index int, target Destination, policy RestartPolicy, onChange func(DestinationState), log *zap.Logger) *relay {
+	if policy.BackoffSec <= 0 {
+		policy = DefaultRestartPolicy()
+	}
+
+	return &relay{
+		target:   target,
+		policy:   policy,
+		feed:     make(chan []byte, relayBufferChunks),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		onChange: onChange,
+		log:      log,
+		state: DestinationState{
+			Index:     index,
+			Target:    target.Display(),
+			Status:    DestinationConnecting,
+			UpdatedAt: time.Now().UTC(),
+		},
+	}
+}
+
+func (r *relay) State() DestinationState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state
+}
+
+// Feed hands a chunk of the encode to the relay without blocking. Chunks are
+// dropped while the relay is reconnecting or falling behind.
+func (r *relay) Feed(chunk []byte) {
+	select {
+	case r.feed <- chunk:
+	default:
+	}
+}
+
+func (r *relay) Run() {
+	defer close(r.done)
+
+	attempt := 0
+	for {
+		connected, err := r.runOnce(attempt > 0)
+		if r.stopped() {
+			r.setState(DestinationStopped, "", attempt)
+			return
+		}
+
+		if connected {
+			attempt = 0
+		}
+		attempt++
+
+		reason := "destination disconnected"
+		if err != nil {
+			reason = err.Error()
+		}
+		r.log.Warn("relay destination failed", zap.String("target", r.target.Display()), zap.String("reason", reason), zap.Int("attempt", attempt))
+		r.setState(DestinationFailed, reason, attempt)
+
+		if !r.wait(r.policy.Backoff(attempt)) {
+			r.setState(DestinationStopped, "", attempt)
+			return
+		}
+	}
+}
+
+func (r *relay) Stop(timeout time.Duration) {
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	r.mu.RLock()
+	cmd := r.cmd
+	r.mu.RUnlock()
+	if cmd != nil && cmd.Process != nil {
+		_ = cmd.Process.Signal(os.Interrupt)
+	}
+
+	select {
+	case <-r.done:
+	case <-time.After(timeout):
+		if cmd != nil && cmd.Process != nil {
+			_ = cmd.Process.Kill()
+		}
+		<-r.done
+	}
+}
+
+func (r *relay) runOnce(retry bool) (bool, error) {
+	args, err := ffmpeg.BuildRelayArgs(r.target.Output())
+	if err != nil {
+		return false, err
+	}
+
+	cmd := exec.Command("ffmpeg", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return false, fmt.Errorf("create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return false, fmt.Errorf("create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return false, fmt.Errorf("create stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return false, fmt.Errorf("start relay: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cmd = cmd
+	r.mu.Unlock()
+
+	status := DestinationConnecting
+	if retry {
+		status = DestinationRetrying
+	}
+	r.setState(status, "", r.State().Retries)
+
+	var connected atomic.Bool
+	var lastError string
+	exited := make(chan struct{})
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		// The first report means the output is open, i.e. connected.
+		_ = ffmpeg.ReadProgress(stdout, func(*ffmpeg.Progress) {
+			if connected.CompareAndSwap(false, true) {
+				r.setState(DestinationConnected, "", 0)
+			}
+		})
+	}()
+	go func() {
+		defer close(exited)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if line := scanner.Text(); looksLikeFFmpegError(line) {
+				lastError = r.target.Redact(strings.TrimSpace(line))
+			}
+		}
+		<-progressDone
+	}()
+
+	writeErr := r.pump(stdin, exited)
+	_ = stdin.Close()
+	<-exited
+	waitErr := cmd.Wait()
+
+	r.mu.Lock()
+	r.cmd = nil
+	r.mu.Unlock()
+
+	switch {
+	case lastError != "":
+		return connected.Load(), fmt.Errorf("%s", lastError)
+	case waitErr != nil:
+		return connected.Load(), waitErr
+	default:
+		return connected.Load(), writeErr
+	}
+}
+
+func (r *relay) pump(stdin io.Writer, exited <-chan struct{}) error {
+	for {
+		select {
+		case <-r.stop:
+			return nil
+		case <-exited:
+			return nil
+		case chunk := <-r.feed:
+			if _, err := stdin.Write(chunk); err != nil {
+				return fmt.Errorf("write to relay: %w", err)
+			}
+		}
+	}
+}
+
+// wait sleeps for the backoff delay while discarding stale chunks, so a
+// reconnecting relay resumes from live data. It returns false when stopped.
+func (r *relay) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case <-r.stop:
+			return false
+		case <-r.feed:
+		}
+	}
+}
+
+func (r *relay) stopped() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *relay) setState(status DestinationStatus, lastError string, retries int) {
+	r.mu.Lock()
+	changed := r.state.Status != status
+	r.state.Status = status
+	r.state.Retries = retries
+	if lastError != "" || status == DestinationConnected {
+		r.state.LastError = lastError
+	}
+	r.state.UpdatedAt = time.Now().UTC()
+	state := r.state
+	r.mu.Unlock()
+
+	if changed && r.onChange != nil {
+		r.onChange(state)
+	}
+}
+
+// maskTarget hides the stream key, which is conventionally the last path
+// segment of an ingest URL, before a target is shown to clients.
+func maskTarget(target string) string {
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil || u.Host == "" {
+		return "****"
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	if i := strings.LastIndex(path, "/"); i >= 0 && i < len(path)-1 {
+		path = path[:i+1] + "****"
+	}
+
+	return u.Scheme + "://" + u.Host + path
+}
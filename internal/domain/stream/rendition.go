@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+)
+
+var resolutionPattern = regexp.MustCompile(`^(\d{2,4})x(\d{2,4})$`)
+
+// Encoding overrides the stream's video settings for one destination, such
+// as a vertical encode for short-form platforms. Zero fields keep the
+// stream's value.
+type Encoding struct {
+	Resolution string `json:"resolution,omitempty"`
+	Bitrate    int    `json:"bitrate,omitempty"`
+	FPS        int    `json:"fps,omitempty"`
+	Fit        string `json:"fit,omitempty"`
+	CropOffset int    `json:"crop_offset,omitempty"`
+}
+
+func (e Encoding) Validate() error {
+	if e.Resolution != "" {
+		m := resolutionPattern.FindStringSubmatch(e.Resolution)
+		if m == nil {
+			return destinationError("resolution must look like 1280x720")
+		}
+		w, _ := strconv.Atoi(m[1])
+		h, _ := strconv.Atoi(m[2])
+		if w%2 != 0 || h%2 != 0 {
+			return destinationError("resolution %s must have even dimensions", e.Resolution)
+		}
+	}
+	if e.Bitrate != 0 && (e.Bitrate < 500 || e.Bitrate > 50000) {
+		return destinationError("bitrate must be between 500 and 50000 kbps")
+	}
+	if e.FPS < 0 || e.FPS > 60 {
+		return destinationError("fps must be between 1 and 60")
+	}
+	if err := validateFit(e.Fit, e.CropOffset); err != nil {
+		return destinationError("%v", err)
+	}
+	return nil
+}
+
+func validateFit(fit string, cropOffset int) error {
+	switch fit {
+	case "", ffmpeg.FitScale, ffmpeg.FitCrop, ffmpeg.FitPad, ffmpeg.FitBlur, ffmpeg.FitOffset:
+	default:
+		return fmt.Errorf("unsupported fit %q", fit)
+	}
+	if cropOffset < 0 || cropOffset > 100 {
+		return fmt.Errorf("crop_offset must be between 0 and 100")
+	}
+	return nil
+}
+
+// Rendition is one encode of the stream and the destinations it feeds, as
+// indexes into Plan.Destinations.
+type Rendition struct {
+	Settings     ffmpeg.Rendition
+	Destinations []int
+}
+
+// baseRendition is the encode destinations get without an override.
+func (s *Stream) baseRendition() ffmpeg.Rendition {
+	r := ffmpeg.Rendition{Resolution: s.Resolution, Bitrate: s.Bitrate, FPS: s.FPS}
+	return withFit(r, s.Fit, s.CropOffset)
+}
+
+// withFit sets the rendition's fit. The offset only counts for the offset
+// fit, so renditions that differ in nothing else are still shared.
+func withFit(r ffmpeg.Rendition, fit string, cropOffset int) ffmpeg.Rendition {
+	r.Fit = fit
+	r.CropOffset = 0
+	if fit == ffmpeg.FitOffset {
+		r.CropOffset = cropOffset
+	}
+	return r
+}
+
+// apply overrides the rendition with the encoding's non-zero fields.
+func (e Encoding) apply(r ffmpeg.Rendition) ffmpeg.Rendition {
+	if e.Resolution != "" {
+		r.Resolution = e.Resolution
+	}
+	if e.Bitrate != 0 {
+		r.Bitrate = e.Bitrate
+	}
+	if e.FPS != 0 {
+		r.FPS = e.FPS
+	}
+	if e.Fit != "" {
+		r = withFit(r, e.Fit, e.CropOffset)
+	}
+	return r
+}
+
+// PlanRenditions groups the destinations by the encode they need, so each
+// distinct rendition is encoded once however many destinations share it.
+// Renditions are ordered by their first destination.
+func PlanRenditions(s *Stream, destinations []Destination) []Rendition {
+	var renditions []Rendition
+	index := map[ffmpeg.Rendition]int{}
+	for i, d := range destinations {
+		settings := s.baseRendition()
+		if d.Encoding != nil {
+			settings = d.Encoding.apply(settings)
+		}
+
+		n, ok := index[settings]
+		if !ok {
+			n = len(renditions)
+			index[settings] = n
+			renditions = append(renditions, Rendition{Settings: settings})
+		}
+		renditions[n].Destinations = append(renditions[n].Destinations, i)
+	}
+	return renditions
+}
@@ -3,43 +3,135 @@ package stream
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"time"
 
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 )
 
+// repository keeps the credentials of a stream encrypted at rest: the
+// rtmp_targets, which carry stream keys, SRT passphrases and the ingest key.
+// Callers only see plaintext.
 type repository struct {
-	db *bun.DB
+	db     *bun.DB
+	cipher *secret.Cipher
 }
 
-func NewRepository(db *bun.DB) Repository {
-	return &repository{db: db}
+func NewRepository(db *bun.DB, cipher *secret.Cipher) Repository {
+	return &repository{db: db, cipher: cipher}
 }
 
 func (r *repository) Create(ctx context.Context, s *Stream) error {
-	_, err := r.db.NewInsert().Model(s).Exec(ctx)
+	restore, err := r.seal(s)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	_, err = r.db.NewInsert().Model(s).Exec(ctx)
 	return err
 }
 
 func (r *repository) GetByID(ctx context.Context, id uuid.UUID) (*Stream, error) {
 	s := new(Stream)
-	err := r.db.NewSelect().Model(s).Where("id = ?", id).Scan(ctx)
-	return s, err
+	if err := r.db.NewSelect().Model(s).Where("id = ?", id).Scan(ctx); err != nil {
+		return s, err
+	}
+	if err := r.open(s); err != nil {
+		return s, err
+	}
+	return s, r.attachPlatforms(ctx, s)
 }
 
-func (r *repository) List(ctx context.Context) ([]*Stream, error) {
+// FindByIngestKey looks the stream up by the key's hash, as the stored key
+// is encrypted.
+func (r *repository) FindByIngestKey(ctx context.Context, key string) (*Stream, error) {
+	s := new(Stream)
+	if key == "" {
+		return s, sql.ErrNoRows
+	}
+	if err := r.db.NewSelect().Model(s).Where("ingest_key_hash = ?", HashIngestKey(key)).Scan(ctx); err != nil {
+		return s, err
+	}
+	if err := r.open(s); err != nil {
+		return s, err
+	}
+	return s, r.attachPlatforms(ctx, s)
+}
+
+func (r *repository) List(ctx context.Context, ownerID uuid.UUID) ([]*Stream, error) {
 	var streams []*Stream
-	err := r.db.NewSelect().Model(&streams).Scan(ctx)
-	return streams, err
+	q := r.db.NewSelect().Model(&streams)
+	if ownerID != uuid.Nil {
+		q = q.Where("user_id = ?", ownerID)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return streams, err
+	}
+	if err := r.open(streams...); err != nil {
+		return nil, err
+	}
+	return streams, r.attachPlatforms(ctx, streams...)
 }
 
+// runtimeColumns are written by the pipeline and the owner's account, never
+// by an edit: the row being saved may have been read before the pipeline
+// last changed them.
+var runtimeColumns = []string{"user_id", "status", "desired_state", "last_error", "created_at"}
+
+// Update saves the stream's settings. Its status and desired state are left
+// alone; UpdateStatus and SetDesiredState own them.
 func (r *repository) Update(ctx context.Context, s *Stream) error {
-	_, err := r.db.NewUpdate().Model(s).WherePK().Exec(ctx)
+	restore, err := r.seal(s)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	s.UpdatedAt = time.Now().UTC()
+	_, err = r.db.NewUpdate().Model(s).ExcludeColumn(runtimeColumns...).WherePK().Exec(ctx)
 	return err
 }
 
 func (r *repository) Delete(ctx context.Context, id uuid.UUID) error {
-	_, err := r.db.NewDelete().Model((*Stream)(nil)).Where("id = ?", id).Exec(ctx)
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*StreamPlatform)(nil)).Where("stream_id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewDelete().Model((*Stream)(nil)).Where("id = ?", id).Exec(ctx)
+		return err
+	})
+}
+
+func (r *repository) ListByDesiredState(ctx context.Context, state string) ([]*Stream, error) {
+	var streams []*Stream
+	if err := r.db.NewSelect().Model(&streams).Where("desired_state = ?", state).Scan(ctx); err != nil {
+		return streams, err
+	}
+	if err := r.open(streams...); err != nil {
+		return nil, err
+	}
+	return streams, r.attachPlatforms(ctx, streams...)
+}
+
+func (r *repository) SetDesiredState(ctx context.Context, id uuid.UUID, state string) error {
+	_, err := r.db.NewUpdate().Model((*Stream)(nil)).
+		Set("desired_state = ?", state).
+		Set("updated_at = current_timestamp").
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+func (r *repository) UpdateStatus(ctx context.Context, id uuid.UUID, status, lastError string) error {
+	_, err := r.db.NewUpdate().Model((*Stream)(nil)).
+		Set("status = ?", status).
+		Set("last_error = ?", lastError).
+		Set("updated_at = current_timestamp").
+		Where("id = ?", id).
+		Exec(ctx)
 	return err
 }
 
@@ -52,6 +144,9 @@ func (r *repository) GetProgram(ctx context.Context, streamID uuid.UUID) (*Strea
 		}
 		return nil, err
 	}
+	if err := r.openValues(p.RTMPTargets); err != nil {
+		return nil, fmt.Errorf("decrypt rtmp targets: %w", err)
+	}
 
 	return p, nil
 }
@@ -62,6 +157,14 @@ func (r *repository) UpsertProgram(ctx context.Context, p *StreamProgram) error
 		return err
 	}
 
+	targets, err := r.sealValues(p.RTMPTargets)
+	if err != nil {
+		return fmt.Errorf("encrypt rtmp targets: %w", err)
+	}
+	plainTargets := p.RTMPTargets
+	p.RTMPTargets = targets
+	defer func() { p.RTMPTargets = plainTargets }()
+
 	if existing == nil {
 		_, err := r.db.NewInsert().Model(p).Exec(ctx)
 		return err
@@ -71,3 +174,126 @@ func (r *repository) UpsertProgram(ctx context.Context, p *StreamProgram) error
 	_, err = r.db.NewUpdate().Model(p).Where("stream_id = ?", p.StreamID).Exec(ctx)
 	return err
 }
+
+// SetPlatforms replaces the stream's platform links, keeping the given order.
+func (r *repository) SetPlatforms(ctx context.Context, streamID uuid.UUID, platformIDs []uuid.UUID) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*StreamPlatform)(nil)).Where("stream_id = ?", streamID).Exec(ctx); err != nil {
+			return err
+		}
+		if len(platformIDs) == 0 {
+			return nil
+		}
+
+		links := make([]StreamPlatform, len(platformIDs))
+		for i, id := range platformIDs {
+			links[i] = StreamPlatform{StreamID: streamID, PlatformID: id, Position: i}
+		}
+		_, err := tx.NewInsert().Model(&links).Exec(ctx)
+		return err
+	})
+}
+
+// attachPlatforms loads the platform links of the streams in one query.
+func (r *repository) attachPlatforms(ctx context.Context, streams ...*Stream) error {
+	if len(streams) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*Stream, len(streams))
+	ids := make([]uuid.UUID, 0, len(streams))
+	for _, s := range streams {
+		s.PlatformIDs = []uuid.UUID{}
+		byID[s.ID] = s
+		ids = append(ids, s.ID)
+	}
+
+	var links []StreamPlatform
+	err := r.db.NewSelect().Model(&links).
+		Where("stream_id IN (?)", bun.In(ids)).
+		Order("stream_id ASC", "position ASC").
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if s, ok := byID[link.StreamID]; ok {
+			s.PlatformIDs = append(s.PlatformIDs, link.PlatformID)
+		}
+	}
+	return nil
+}
+
+// seal swaps the stream's credentials for sealed copies while it is written
+// and returns a func that puts the plaintext back. The ingest key's hash is
+// kept alongside it for lookups.
+func (r *repository) seal(s *Stream) (func(), error) {
+	targets, err := r.sealValues(s.RTMPTargets)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt rtmp targets: %w", err)
+	}
+	var destinations []Destination
+	if s.Destinations != nil {
+		destinations = make([]Destination, len(s.Destinations))
+		copy(destinations, s.Destinations)
+	}
+	for i := range destinations {
+		opts := &destinations[i].Options
+		if opts.Passphrase, err = r.cipher.Seal(opts.Passphrase); err != nil {
+			return nil, fmt.Errorf("encrypt passphrase: %w", err)
+		}
+	}
+	ingestKey, err := r.cipher.Seal(s.IngestKey)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt ingest key: %w", err)
+	}
+
+	plainTargets, plainDestinations, plainKey := s.RTMPTargets, s.Destinations, s.IngestKey
+	s.RTMPTargets, s.Destinations, s.IngestKey = targets, destinations, ingestKey
+	s.IngestKeyHash = HashIngestKey(plainKey)
+	return func() {
+		s.RTMPTargets, s.Destinations, s.IngestKey = plainTargets, plainDestinations, plainKey
+	}, nil
+}
+
+// open decrypts the credentials of streams that were read, in place.
+func (r *repository) open(streams ...*Stream) error {
+	for _, s := range streams {
+		if err := r.openValues(s.RTMPTargets); err != nil {
+			return fmt.Errorf("decrypt rtmp targets: %w", err)
+		}
+		for i := range s.Destinations {
+			if err := r.cipher.OpenFields(&s.Destinations[i].Options.Passphrase); err != nil {
+				return fmt.Errorf("decrypt passphrase: %w", err)
+			}
+		}
+		if err := r.cipher.OpenFields(&s.IngestKey); err != nil {
+			return fmt.Errorf("decrypt ingest key: %w", err)
+		}
+	}
+	return nil
+}
+
+// sealValues returns a sealed copy of values.
+func (r *repository) sealValues(values []string) ([]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	sealed := make([]string, len(values))
+	for i, value := range values {
+		var err error
+		if sealed[i], err = r.cipher.Seal(value); err != nil {
+			return nil, err
+		}
+	}
+	return sealed, nil
+}
+
+func (r *repository) openValues(values []string) error {
+	for i := range values {
+		if err := r.cipher.OpenFields(&values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
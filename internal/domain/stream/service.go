@@ -2,45 +2,140 @@ package stream
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/codewithwan/gostreamix/internal/domain/asset"
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/encoder"
+	"github.com/codewithwan/gostreamix/internal/domain/platform"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
 	"github.com/codewithwan/gostreamix/internal/domain/video"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/activity"
 	"github.com/google/uuid"
 )
 
 type service struct {
-	repo      Repository
-	videoRepo video.Repository
-	pipeline  Pipeline
-	pm        *ProcessManager
+	repo         Repository
+	sessions     SessionRepository
+	videoRepo    video.Repository
+	encoderRepo  encoder.Repository
+	assetRepo    asset.Repository
+	platformRepo platform.Repository
+	pipeline     Pipeline
+	pm           *ProcessManager
+	caps         *ffmpeg.Capabilities
+	probe        func(path string) (*video.Metadata, error)
+	renderFrame  func(ctx context.Context, streamID uuid.UUID, input string, at int, r ffmpeg.Rendition) ([]byte, error)
 }
 
-func NewService(repo Repository, videoRepo video.Repository, pipeline Pipeline, pm *ProcessManager) Service {
+func NewService(repo Repository, sessions SessionRepository, videoRepo video.Repository, encoderRepo encoder.Repository, assetRepo asset.Repository, platformRepo platform.Repository, pipeline Pipeline, pm *ProcessManager, caps *ffmpeg.Capabilities) Service {
 	return &service{
-		repo:      repo,
-		videoRepo: videoRepo,
-		pipeline:  pipeline,
-		pm:        pm,
+		repo:         repo,
+		sessions:     sessions,
+		videoRepo:    videoRepo,
+		encoderRepo:  encoderRepo,
+		assetRepo:    assetRepo,
+		platformRepo: platformRepo,
+		pipeline:     pipeline,
+		pm:           pm,
+		caps:         caps,
+		probe:        video.ProbeVideo,
+		renderFrame:  renderFrame,
 	}
 }
 
-func (s *service) CreateStream(ctx context.Context, dto CreateStreamDTO) (*Stream, error) {
+func (s *service) CreateStream(ctx context.Context, caller *auth.User, dto CreateStreamDTO) (*Stream, error) {
+	policy := DefaultRestartPolicy()
+	if dto.RestartPolicy != nil {
+		if err := dto.RestartPolicy.Validate(); err != nil {
+			return nil, err
+		}
+		policy = *dto.RestartPolicy
+	}
+	slowAfter := DefaultSlowAfterSec
+	if dto.SlowAfterSec != nil {
+		if err := validateSlowAfter(*dto.SlowAfterSec); err != nil {
+			return nil, err
+		}
+		slowAfter = *dto.SlowAfterSec
+	}
+
+	profileID, err := s.resolveEncoderProfile(ctx, dto.EncoderProfileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDestinations(dto.Destinations); err != nil {
+		return nil, err
+	}
+	platformIDs, err := s.resolvePlatforms(ctx, caller, dto.PlatformIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateOverlays(dto.Overlays); err != nil {
+		return nil, err
+	}
+	cropOffset := 50
+	if dto.CropOffset != nil {
+		cropOffset = *dto.CropOffset
+	}
+	if err := validateFit(dto.Fit, cropOffset); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFit, err)
+	}
+	audio := AudioSettings{Mode: AudioSource}
+	if dto.Audio != nil {
+		if err := dto.Audio.Validate(); err != nil {
+			return nil, err
+		}
+		audio = *dto.Audio
+	}
+	if audio.Mode == "" {
+		audio.Mode = AudioSource
+	}
+	fallbackID, err := s.resolveFallbackVideo(ctx, dto.FallbackVideoID)
+	if err != nil {
+		return nil, err
+	}
+
 	stream := &Stream{
-		ID:          uuid.New(),
-		VideoID:     dto.VideoID,
-		Name:        dto.Name,
-		RTMPTargets: dto.RTMPTargets,
-		Bitrate:     dto.Bitrate,
-		Resolution:  dto.Resolution,
-		FPS:         dto.FPS,
-		Loop:        dto.Loop,
-		Status:      "idle",
+		ID:               uuid.New(),
+		UserID:           caller.ID,
+		VideoID:          dto.VideoID,
+		Name:             dto.Name,
+		RTMPTargets:      dto.RTMPTargets,
+		Destinations:     dto.Destinations,
+		PlatformIDs:      platformIDs,
+		Bitrate:          dto.Bitrate,
+		Resolution:       dto.Resolution,
+		Fit:              dto.Fit,
+		CropOffset:       cropOffset,
+		FPS:              dto.FPS,
+		Loop:             dto.Loop,
+		Status:           "idle",
+		DesiredState:     DesiredStopped,
+		RestartPolicy:    policy,
+		SlowAfterSec:     slowAfter,
+		EncoderProfileID: profileID,
+		Passthrough:      dto.Passthrough,
+		SourceType:       SourceFile,
+		FallbackVideoID:  fallbackID,
+		Overlays:         dto.Overlays,
+		Audio:            audio,
+	}
+	if err := s.applySourceType(stream, dto.SourceType); err != nil {
+		return nil, err
 	}
 	if err := s.repo.Create(ctx, stream); err != nil {
 		return nil, fmt.Errorf("create stream record: %w", err)
 	}
+	if err := s.repo.SetPlatforms(ctx, stream.ID, platformIDs); err != nil {
+		return nil, fmt.Errorf("link stream platforms: %w", err)
+	}
 
 	videoIDs := make([]uuid.UUID, 0, 1)
 	if dto.VideoID != uuid.Nil {
@@ -54,6 +149,7 @@ func (s *service) CreateStream(ctx context.Context, dto CreateStreamDTO) (*Strea
 		RTMPTargets: stream.RTMPTargets,
 		Bitrate:     stream.Bitrate,
 		Resolution:  stream.Resolution,
+		Overlays:    stream.Overlays,
 	}
 	if err := s.repo.UpsertProgram(ctx, program); err != nil {
 		return nil, fmt.Errorf("create stream program: %w", err)
@@ -62,7 +158,7 @@ func (s *service) CreateStream(ctx context.Context, dto CreateStreamDTO) (*Strea
 	return stream, nil
 }
 
-func (s *service) UpdateStream(ctx context.Context, id uuid.UUID, dto UpdateStreamDTO) (*Stream, error) {
+func (s *service) UpdateStream(ctx context.Context, caller *auth.User, id uuid.UUID, dto UpdateStreamDTO) (*Stream, error) {
 	stream, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("get stream by id for update: %w", err)
@@ -73,24 +169,108 @@ func (s *service) UpdateStream(ctx context.Context, id uuid.UUID, dto UpdateStre
 
 	stream.VideoID = dto.VideoID
 	stream.Name = dto.Name
-	stream.RTMPTargets = dto.RTMPTargets
+	stream.RTMPTargets = unmaskTargets(dto.RTMPTargets, stream.RTMPTargets)
 	stream.Bitrate = dto.Bitrate
 	stream.Resolution = dto.Resolution
 	stream.FPS = dto.FPS
 	stream.Loop = dto.Loop
+	cropOffset := stream.CropOffset
+	if dto.CropOffset != nil {
+		cropOffset = *dto.CropOffset
+	}
+	if err := validateFit(dto.Fit, cropOffset); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFit, err)
+	}
+	stream.Fit = dto.Fit
+	stream.CropOffset = cropOffset
+	if dto.RestartPolicy != nil {
+		if err := dto.RestartPolicy.Validate(); err != nil {
+			return nil, err
+		}
+		stream.RestartPolicy = *dto.RestartPolicy
+	}
+	if dto.SlowAfterSec != nil {
+		if err := validateSlowAfter(*dto.SlowAfterSec); err != nil {
+			return nil, err
+		}
+		stream.SlowAfterSec = *dto.SlowAfterSec
+	}
+	if dto.EncoderProfileID != nil {
+		profileID, err := s.resolveEncoderProfile(ctx, dto.EncoderProfileID)
+		if err != nil {
+			return nil, err
+		}
+		stream.EncoderProfileID = profileID
+	}
+	if dto.Passthrough != nil {
+		stream.Passthrough = *dto.Passthrough
+	}
+	if dto.Destinations != nil {
+		destinations := unmaskDestinations(dto.Destinations, stream.Destinations)
+		if err := validateDestinations(destinations); err != nil {
+			return nil, err
+		}
+		stream.Destinations = destinations
+	}
+	if dto.PlatformIDs != nil {
+		platformIDs, err := s.resolvePlatforms(ctx, caller, dto.PlatformIDs)
+		if err != nil {
+			return nil, err
+		}
+		stream.PlatformIDs = platformIDs
+	}
+	if dto.FallbackVideoID != nil {
+		fallbackID, err := s.resolveFallbackVideo(ctx, dto.FallbackVideoID)
+		if err != nil {
+			return nil, err
+		}
+		stream.FallbackVideoID = fallbackID
+	}
+	if dto.Overlays != nil {
+		if err := validateOverlays(dto.Overlays); err != nil {
+			return nil, err
+		}
+		stream.Overlays = dto.Overlays
+	}
+	if dto.Audio != nil {
+		if err := dto.Audio.Validate(); err != nil {
+			return nil, err
+		}
+		stream.Audio = *dto.Audio
+		if stream.Audio.Mode == "" {
+			stream.Audio.Mode = AudioSource
+		}
+	}
+	if err := s.applySourceType(stream, dto.SourceType); err != nil {
+		return nil, err
+	}
 
 	if err := s.repo.Update(ctx, stream); err != nil {
 		return nil, fmt.Errorf("update stream record: %w", err)
 	}
+	if dto.PlatformIDs != nil {
+		if err := s.repo.SetPlatforms(ctx, stream.ID, stream.PlatformIDs); err != nil {
+			return nil, fmt.Errorf("link stream platforms: %w", err)
+		}
+	}
+	if dto.Overlays != nil {
+		if err := s.syncProgramOverlays(ctx, stream); err != nil {
+			return nil, err
+		}
+	}
 
 	if _, running := s.pm.Get(id); running {
-		video, err := s.videoRepo.GetByID(ctx, stream.VideoID)
+		program, err := s.repo.GetProgram(ctx, id)
 		if err != nil {
-			return nil, fmt.Errorf("get video for live update: %w", err)
+			return nil, fmt.Errorf("get stream program for live update: %w", err)
 		}
 
-		videoPath := filepath.Join("data", "uploads", video.Filename)
-		if err := s.pipeline.Reload(ctx, stream, videoPath); err != nil {
+		plan, err := s.loadPlan(ctx, stream, program)
+		if err != nil {
+			return nil, fmt.Errorf("load plan for live update: %w", err)
+		}
+
+		if err := s.pipeline.Reload(ctx, stream, plan); err != nil {
 			return nil, fmt.Errorf("reload live pipeline: %w", err)
 		}
 	}
@@ -112,11 +292,7 @@ func (s *service) StartStream(ctx context.Context, id uuid.UUID) error {
 		return fmt.Errorf("get stream program: %w", err)
 	}
 
-	videoID := stream.VideoID
 	if program != nil {
-		if len(program.VideoIDs) > 0 {
-			videoID = program.VideoIDs[0]
-		}
 		if len(program.RTMPTargets) > 0 {
 			stream.RTMPTargets = program.RTMPTargets
 		}
@@ -126,25 +302,553 @@ func (s *service) StartStream(ctx context.Context, id uuid.UUID) error {
 		if program.Resolution != "" {
 			stream.Resolution = program.Resolution
 		}
+		if program.Overlays != nil {
+			stream.Overlays = program.Overlays
+		}
+	}
+
+	plan, err := s.loadPlan(ctx, stream, program)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCapabilities(stream, plan); err != nil {
+		return err
+	}
+
+	// The desired state is written first so a stream that is running is
+	// always resumed on the next boot, and so a supervisor writing
+	// DesiredStopped once a short queue ends is never overwritten here.
+	if err := s.repo.SetDesiredState(ctx, id, DesiredRunning); err != nil {
+		return fmt.Errorf("persist desired state: %w", err)
+	}
+
+	if err := s.pipeline.Start(ctx, stream, plan); err != nil {
+		startErr := fmt.Errorf("start stream pipeline: %w", err)
+		if resetErr := s.repo.SetDesiredState(ctx, id, DesiredStopped); resetErr != nil {
+			return errors.Join(startErr, fmt.Errorf("reset desired state: %w", resetErr))
+		}
+		return startErr
+	}
+	return nil
+}
+
+// ResumeStreams restarts every stream that was meant to be live when the
+// server last went down. Streams that cannot start are marked as errored.
+func (s *service) ResumeStreams(ctx context.Context) error {
+	// Previews left behind by an unclean shutdown would be served as if live.
+	_ = os.RemoveAll(previewRoot)
+	_ = os.RemoveAll(overlayRoot)
+	if err := s.sessions.CloseOpenSessions(ctx, ExitInterrupted); err != nil {
+		return fmt.Errorf("close interrupted sessions: %w", err)
+	}
+
+	streams, err := s.repo.ListByDesiredState(ctx, DesiredRunning)
+	if err != nil {
+		return fmt.Errorf("list streams to resume: %w", err)
 	}
 
-	if videoID == uuid.Nil {
-		return ErrStreamProgramEmpty
+	var errs []error
+	for _, st := range streams {
+		if _, running := s.pm.Get(st.ID); running {
+			continue
+		}
+
+		if err := s.StartStream(ctx, st.ID); err != nil {
+			reason := err.Error()
+			if updateErr := s.repo.UpdateStatus(ctx, st.ID, string(StatusError), reason); updateErr != nil {
+				errs = append(errs, fmt.Errorf("mark stream %s as errored: %w", st.ID.String(), updateErr))
+			}
+			activity.Record(activity.Entry{
+				Timestamp: time.Now().UTC(),
+				Source:    "stream",
+				Level:     "error",
+				Event:     "stream_resume_failed",
+				Message:   fmt.Sprintf("Failed to resume %s: %s", st.Name, reason),
+				StreamID:  st.ID.String(),
+			})
+			errs = append(errs, fmt.Errorf("resume stream %s: %w", st.ID.String(), err))
+			continue
+		}
+
+		activity.Record(activity.Entry{
+			Timestamp: time.Now().UTC(),
+			Source:    "stream",
+			Level:     "info",
+			Event:     "stream_resumed",
+			Message:   fmt.Sprintf("Resumed %s after server restart", st.Name),
+			StreamID:  st.ID.String(),
+		})
 	}
 
-	video, err := s.videoRepo.GetByID(ctx, videoID)
+	return errors.Join(errs...)
+}
+
+// loadPlan gathers the queue and encoder settings the pipeline needs to
+// launch the stream.
+func (s *service) loadPlan(ctx context.Context, stream *Stream, program *StreamProgram) (Plan, error) {
+	destinations, err := stream.ResolveDestinations()
 	if err != nil {
-		return fmt.Errorf("video not found: %w", err)
+		return Plan{}, err
+	}
+	linked, err := s.platformDestinations(ctx, stream)
+	if err != nil {
+		return Plan{}, err
+	}
+	destinations = append(linked, destinations...)
+
+	plan := Plan{
+		Destinations: destinations,
+		Ingest:       stream.IsIngest(),
+		Renditions:   PlanRenditions(stream, destinations),
+	}
+	if stream.FallbackVideoID != nil {
+		item, err := s.loadItem(ctx, *stream.FallbackVideoID)
+		if err != nil {
+			return Plan{}, fmt.Errorf("%w: %v", ErrFallbackVideoNotFound, err)
+		}
+		plan.Fallback = &item
+	}
+
+	if !plan.Ingest {
+		if plan.Queue, err = s.loadQueue(ctx, stream, program); err != nil {
+			return Plan{}, err
+		}
+	}
+
+	if plan.Overlays, err = s.loadOverlays(ctx, stream.Overlays); err != nil {
+		return Plan{}, err
+	}
+	if plan.Audio, err = s.loadAudio(ctx, stream.Audio); err != nil {
+		return Plan{}, err
+	}
+
+	if stream.Passthrough {
+		if len(plan.Overlays) > 0 {
+			return Plan{}, fmt.Errorf("%w: overlays need re-encoding", ErrPassthroughIncompatible)
+		}
+		if stream.Audio.Replaced() || stream.Audio.Normalize {
+			return Plan{}, fmt.Errorf("%w: replacing or normalizing audio needs re-encoding", ErrPassthroughIncompatible)
+		}
+		for _, d := range destinations {
+			if d.Encoding != nil {
+				return Plan{}, fmt.Errorf("%w: %s has its own encoding", ErrPassthroughIncompatible, d.Display())
+			}
+		}
+		// The fallback is copied too, so it must match the queue.
+		items := plan.Queue
+		if plan.Fallback != nil {
+			items = append(items[:len(items):len(items)], *plan.Fallback)
+		}
+		if err := s.checkPassthrough(stream, items); err != nil {
+			return Plan{}, err
+		}
+		plan.Passthrough = true
+		return plan, nil
 	}
 
-	videoPath := filepath.Join("data", "uploads", video.Filename)
+	items := plan.Queue
+	if plan.Fallback != nil {
+		items = append(items[:len(items):len(items)], *plan.Fallback)
+	}
+	if err := s.checkReframe(plan.Renditions, items); err != nil {
+		return Plan{}, err
+	}
 
-	if err := s.pipeline.Start(ctx, stream, videoPath); err != nil {
-		return fmt.Errorf("start stream pipeline: %w", err)
+	if plan.Encoder, err = s.loadEncoder(ctx, stream); err != nil {
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
+// resolvePlatforms checks every platform exists and drops repeats, keeping
+// the first position of each.
+func (s *service) resolvePlatforms(ctx context.Context, caller *auth.User, ids []uuid.UUID) ([]uuid.UUID, error) {
+	resolved := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		p, err := s.platformRepo.FindByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("platform %s: %w", id, err)
+		}
+		// Linking a platform streams with its owner's key, so only the
+		// owner and admins may do it. Others are told it does not exist.
+		if !caller.CanManage(p.UserID) {
+			return nil, fmt.Errorf("platform %s: %w", id, platform.ErrPlatformNotFound)
+		}
+		resolved = append(resolved, id)
+	}
+	return resolved, nil
+}
+
+// platformDestinations resolves the stream's linked platforms into
+// destinations with their current URL and stream key, so a rotated key is
+// picked up on the next start. Disabled platforms are skipped.
+func (s *service) platformDestinations(ctx context.Context, stream *Stream) ([]Destination, error) {
+	destinations := make([]Destination, 0, len(stream.PlatformIDs))
+	for _, id := range stream.PlatformIDs {
+		p, err := s.platformRepo.FindByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("platform %s: %w", id, err)
+		}
+		if !p.Enabled {
+			activity.Record(activity.Entry{
+				Timestamp: time.Now().UTC(),
+				Source:    "stream",
+				Level:     "warning",
+				Event:     "platform_skipped",
+				Message:   fmt.Sprintf("Skipped %s on %s: the platform is disabled", p.Name, stream.Name),
+				StreamID:  stream.ID.String(),
+			})
+			continue
+		}
+
+		rtmpURL := p.RTMPURL()
+		if rtmpURL == "" {
+			return nil, fmt.Errorf("platform %s: %w: no RTMP URL for type %q", p.Name, ErrInvalidDestination, p.PlatformType)
+		}
+		d, err := ParseDestination(rtmpURL)
+		if err != nil {
+			return nil, fmt.Errorf("platform %s: %w", p.Name, err)
+		}
+		destinations = append(destinations, d)
+	}
+	return destinations, nil
+}
+
+func (s *service) loadEncoder(ctx context.Context, stream *Stream) (ffmpeg.EncoderSettings, error) {
+	if stream.EncoderProfileID == nil {
+		return ffmpeg.DefaultEncoderSettings(), nil
+	}
+	profile, err := s.encoderRepo.FindByID(ctx, *stream.EncoderProfileID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ffmpeg.EncoderSettings{}, encoder.ErrProfileNotFound
+	}
+	if err != nil {
+		return ffmpeg.EncoderSettings{}, fmt.Errorf("load encoder profile: %w", err)
+	}
+	return profile.EncoderSettings, nil
+}
+
+// checkPassthrough probes every queued file and refuses to start rather than
+// silently re-encoding when one of them cannot be copied as-is.
+func (s *service) checkPassthrough(stream *Stream, queue []QueueItem) error {
+	var ref *video.Metadata
+	for _, item := range queue {
+		meta, err := s.probe(item.Path)
+		if err != nil {
+			return fmt.Errorf("%w: %s: probe failed: %v", ErrPassthroughIncompatible, item.Name, err)
+		}
+		if ref == nil {
+			ref = meta
+		}
+		if err := CheckPassthrough(stream, meta, ref); err != nil {
+			return fmt.Errorf("%s: %w", item.Name, err)
+		}
 	}
 	return nil
 }
 
+// checkReframe probes the queued files when a rendition reframes them, so a
+// source that would be cropped beyond use is refused before going live. A
+// live ingest source is not known ahead of time and is not checked.
+func (s *service) checkReframe(renditions []Rendition, items []QueueItem) error {
+	var reframes []ffmpeg.Rendition
+	for _, r := range renditions {
+		if r.Settings.Reframes() {
+			reframes = append(reframes, r.Settings)
+		}
+	}
+	if len(reframes) == 0 {
+		return nil
+	}
+
+	for _, item := range items {
+		meta, err := s.probe(item.Path)
+		if err != nil {
+			return fmt.Errorf("%w: %s: probe failed: %v", ErrReframeIncompatible, item.Name, err)
+		}
+		for _, r := range reframes {
+			if err := CheckReframe(r, meta); err != nil {
+				return fmt.Errorf("%s: %w", item.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// PreviewReframe renders a frame of the stream's first queued video as the
+// encoding would send it, and returns the JPEG. Zero fields of the encoding
+// keep the stream's value.
+func (s *service) PreviewReframe(ctx context.Context, id uuid.UUID, enc Encoding) ([]byte, error) {
+	stream, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get stream for reframe preview: %w", err)
+	}
+	if stream == nil {
+		return nil, ErrStreamNotFound
+	}
+	if err := validateFit(enc.Fit, enc.CropOffset); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFit, err)
+	}
+	if err := enc.Validate(); err != nil {
+		return nil, err
+	}
+
+	program, err := s.repo.GetProgram(ctx, stream.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get stream program for reframe preview: %w", err)
+	}
+	queue, err := s.loadQueue(ctx, stream, program)
+	if err != nil {
+		return nil, err
+	}
+	item := queue[0]
+
+	r := enc.apply(stream.baseRendition())
+	meta, err := s.probe(item.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: probe failed: %v", ErrReframeIncompatible, item.Name, err)
+	}
+	if err := CheckReframe(r, meta); err != nil {
+		return nil, fmt.Errorf("%s: %w", item.Name, err)
+	}
+
+	// A frame a little way in avoids the black or title frames many files
+	// open with.
+	at := 0
+	if item.Duration > 10 {
+		at = item.Duration / 10
+	}
+	return s.renderFrame(ctx, stream.ID, item.Path, at, r)
+}
+
+// checkCapabilities fails early, with the exact missing piece, when the local
+// ffmpeg build cannot run the plan.
+func (s *service) checkCapabilities(stream *Stream, plan Plan) error {
+	if plan.Passthrough {
+		if err := s.caps.CheckPipeline(); err != nil {
+			return err
+		}
+	} else if err := s.caps.CheckEncoder(plan.Encoder); err != nil {
+		return err
+	}
+	if err := s.caps.CheckOverlays(plan.ffmpegOverlays(""), false); err != nil {
+		return err
+	}
+	if !plan.Passthrough {
+		settings := make([]ffmpeg.Rendition, len(plan.Renditions))
+		for i, r := range plan.Renditions {
+			settings[i] = r.Settings
+		}
+		if err := s.caps.CheckRenditions(settings); err != nil {
+			return err
+		}
+	}
+	inputs := plan.Queue
+	if plan.Fallback != nil {
+		inputs = append(inputs[:len(inputs):len(inputs)], *plan.Fallback)
+	}
+	silence := len(plan.Audio.Playlist) == 0 && plan.Audio.silenceFor(inputs)
+	if err := s.caps.CheckAudio(silence, plan.Audio.Normalize); err != nil {
+		return err
+	}
+
+	for _, d := range plan.Destinations {
+		if err := s.caps.CheckDestination(d.URL); err != nil {
+			return fmt.Errorf("destination %s: %w", d.Display(), err)
+		}
+	}
+	return nil
+}
+
+// applySourceType switches the stream between file and live input, giving
+// it an ingest key the first time it goes live. An empty type keeps the
+// current one.
+func (s *service) applySourceType(stream *Stream, sourceType string) error {
+	switch sourceType {
+	case "":
+	case SourceFile, SourceIngest:
+		stream.SourceType = sourceType
+	default:
+		return ErrInvalidSourceType
+	}
+
+	if !stream.IsIngest() {
+		return nil
+	}
+	if stream.Passthrough {
+		return fmt.Errorf("%w: live ingest is always re-encoded", ErrPassthroughIncompatible)
+	}
+	if stream.IngestKey == "" {
+		key, err := GenerateIngestKey()
+		if err != nil {
+			return err
+		}
+		stream.IngestKey = key
+	}
+	return nil
+}
+
+// RotateIngestKey replaces the stream's ingest key. A publisher already
+// connected with the old key stays connected until it disconnects.
+func (s *service) RotateIngestKey(ctx context.Context, id uuid.UUID) (*Stream, error) {
+	stream, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrStreamNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get stream by id: %w", err)
+	}
+	key, err := GenerateIngestKey()
+	if err != nil {
+		return nil, err
+	}
+	stream.IngestKey = key
+	if err := s.repo.Update(ctx, stream); err != nil {
+		return nil, fmt.Errorf("update ingest key: %w", err)
+	}
+	return stream, nil
+}
+
+// resolveFallbackVideo checks that the fallback video is in the library. The
+// nil UUID clears it.
+func (s *service) resolveFallbackVideo(ctx context.Context, id *uuid.UUID) (*uuid.UUID, error) {
+	if id == nil || *id == uuid.Nil {
+		return nil, nil
+	}
+	if _, err := s.videoRepo.GetByID(ctx, *id); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFallbackVideoNotFound, err)
+	}
+	return id, nil
+}
+
+// loadOverlays resolves each image overlay to its asset file.
+func (s *service) loadOverlays(ctx context.Context, overlays []Overlay) ([]Overlay, error) {
+	resolved := make([]Overlay, 0, len(overlays))
+	for i, o := range overlays {
+		if o.Type == ffmpeg.OverlayImage {
+			a, err := s.assetRepo.FindByID(ctx, *o.AssetID)
+			if err != nil {
+				return nil, fmt.Errorf("overlay %d: %w: %v", i+1, asset.ErrAssetNotFound, err)
+			}
+			o.ImagePath = a.Path()
+		}
+		resolved = append(resolved, o)
+	}
+	return resolved, nil
+}
+
+// loadAudio resolves the stream's audio playlist into playable items.
+func (s *service) loadAudio(ctx context.Context, settings AudioSettings) (AudioPlan, error) {
+	plan := AudioPlan{Mute: settings.Mode == AudioMute, Normalize: settings.Normalize}
+	if settings.Mode != AudioPlaylist {
+		return plan, nil
+	}
+	for _, id := range settings.Playlist {
+		item, err := s.loadItem(ctx, id)
+		if err != nil {
+			return AudioPlan{}, fmt.Errorf("%w: %v", ErrInvalidAudio, err)
+		}
+		if !item.HasAudio {
+			return AudioPlan{}, audioError("%s has no audio", item.Name)
+		}
+		plan.Playlist = append(plan.Playlist, item)
+	}
+	return plan, nil
+}
+
+// syncProgramOverlays carries the stream's overlays over to its saved
+// program, which otherwise takes precedence when the stream starts.
+func (s *service) syncProgramOverlays(ctx context.Context, stream *Stream) error {
+	program, err := s.repo.GetProgram(ctx, stream.ID)
+	if err != nil {
+		return fmt.Errorf("get stream program for overlays: %w", err)
+	}
+	if program == nil {
+		return nil
+	}
+	program.Overlays = stream.Overlays
+	if err := s.repo.UpsertProgram(ctx, program); err != nil {
+		return fmt.Errorf("update program overlays: %w", err)
+	}
+	return nil
+}
+
+func validateDestinations(destinations []Destination) error {
+	for i, d := range destinations {
+		if err := d.Validate(); err != nil {
+			return fmt.Errorf("destination %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// resolveEncoderProfile checks that a requested profile exists. The nil UUID
+// clears the reference so the stream falls back to the default settings.
+func (s *service) resolveEncoderProfile(ctx context.Context, id *uuid.UUID) (*uuid.UUID, error) {
+	if id == nil || *id == uuid.Nil {
+		return nil, nil
+	}
+	_, err := s.encoderRepo.FindByID(ctx, *id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, encoder.ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find encoder profile: %w", err)
+	}
+	return id, nil
+}
+
+// loadQueue resolves the program's video queue into playable items, falling
+// back to the stream's single video when no program has been saved yet.
+func (s *service) loadQueue(ctx context.Context, stream *Stream, program *StreamProgram) ([]QueueItem, error) {
+	videoIDs := make([]uuid.UUID, 0, 1)
+	if program != nil && len(program.VideoIDs) > 0 {
+		videoIDs = program.VideoIDs
+	} else if stream.VideoID != uuid.Nil {
+		videoIDs = append(videoIDs, stream.VideoID)
+	}
+
+	if len(videoIDs) == 0 {
+		return nil, ErrStreamProgramEmpty
+	}
+
+	queue := make([]QueueItem, 0, len(videoIDs))
+	for _, videoID := range videoIDs {
+		item, err := s.loadItem(ctx, videoID)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, item)
+	}
+
+	return queue, nil
+}
+
+func (s *service) loadItem(ctx context.Context, videoID uuid.UUID) (QueueItem, error) {
+	v, err := s.videoRepo.GetByID(ctx, videoID)
+	if err != nil {
+		return QueueItem{}, fmt.Errorf("video %s not found: %w", videoID.String(), err)
+	}
+
+	name := v.OriginalName
+	if name == "" {
+		name = v.Filename
+	}
+
+	return QueueItem{
+		VideoID:  v.ID,
+		Name:     name,
+		Path:     filepath.Join("data", "uploads", v.Filename),
+		Duration: v.Duration,
+		HasAudio: v.HasAudio,
+	}, nil
+}
+
 func (s *service) GetProgram(ctx context.Context, id uuid.UUID) (*StreamProgram, error) {
 	streamData, err := s.repo.GetByID(ctx, id)
 	if err != nil {
@@ -174,10 +878,11 @@ func (s *service) GetProgram(ctx context.Context, id uuid.UUID) (*StreamProgram,
 		RTMPTargets: streamData.RTMPTargets,
 		Bitrate:     streamData.Bitrate,
 		Resolution:  streamData.Resolution,
+		Overlays:    streamData.Overlays,
 	}, nil
 }
 
-func (s *service) SaveProgram(ctx context.Context, id uuid.UUID, dto SaveProgramDTO) (*StreamProgram, error) {
+func (s *service) SaveProgram(ctx context.Context, caller *auth.User, id uuid.UUID, dto SaveProgramDTO) (*StreamProgram, error) {
 	streamData, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("get stream by id for save program: %w", err)
@@ -188,7 +893,22 @@ func (s *service) SaveProgram(ctx context.Context, id uuid.UUID, dto SaveProgram
 	if len(dto.VideoIDs) == 0 {
 		return nil, fmt.Errorf("program must contain at least one video")
 	}
-	if len(dto.RTMPTargets) == 0 {
+	platformIDs := streamData.PlatformIDs
+	if dto.PlatformIDs != nil {
+		if platformIDs, err = s.resolvePlatforms(ctx, caller, dto.PlatformIDs); err != nil {
+			return nil, err
+		}
+	}
+	current, err := s.repo.GetProgram(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get stream program: %w", err)
+	}
+	previous := streamData.RTMPTargets
+	if current != nil {
+		previous = append(current.RTMPTargets, previous...)
+	}
+	targets := unmaskTargets(dto.RTMPTargets, previous)
+	if len(targets) == 0 && len(platformIDs) == 0 && len(streamData.Destinations) == 0 {
 		return nil, fmt.Errorf("program must contain at least one target")
 	}
 	if dto.Bitrate <= 0 {
@@ -197,14 +917,22 @@ func (s *service) SaveProgram(ctx context.Context, id uuid.UUID, dto SaveProgram
 	if strings.TrimSpace(dto.Resolution) == "" {
 		dto.Resolution = streamData.Resolution
 	}
+	overlays := streamData.Overlays
+	if dto.Overlays != nil {
+		if err := validateOverlays(dto.Overlays); err != nil {
+			return nil, err
+		}
+		overlays = dto.Overlays
+	}
 
 	program := &StreamProgram{
 		ID:          uuid.New(),
 		StreamID:    id,
 		VideoIDs:    dto.VideoIDs,
-		RTMPTargets: dto.RTMPTargets,
+		RTMPTargets: targets,
 		Bitrate:     dto.Bitrate,
 		Resolution:  dto.Resolution,
+		Overlays:    overlays,
 	}
 	if err := s.repo.UpsertProgram(ctx, program); err != nil {
 		return nil, fmt.Errorf("upsert stream program: %w", err)
@@ -214,21 +942,27 @@ func (s *service) SaveProgram(ctx context.Context, id uuid.UUID, dto SaveProgram
 	if name := strings.TrimSpace(dto.Name); name != "" {
 		streamData.Name = name
 	}
-	streamData.RTMPTargets = dto.RTMPTargets
+	streamData.RTMPTargets = targets
+	streamData.PlatformIDs = platformIDs
 	streamData.Bitrate = dto.Bitrate
 	streamData.Resolution = dto.Resolution
+	streamData.Overlays = overlays
 	if err := s.repo.Update(ctx, streamData); err != nil {
 		return nil, fmt.Errorf("update stream from program: %w", err)
 	}
+	if dto.PlatformIDs != nil {
+		if err := s.repo.SetPlatforms(ctx, id, platformIDs); err != nil {
+			return nil, fmt.Errorf("link stream platforms: %w", err)
+		}
+	}
 
 	if dto.ApplyLiveNow {
 		if _, running := s.pm.Get(id); running {
-			videoData, err := s.videoRepo.GetByID(ctx, dto.VideoIDs[0])
+			plan, err := s.loadPlan(ctx, streamData, program)
 			if err != nil {
-				return nil, fmt.Errorf("get first video for apply live: %w", err)
+				return nil, fmt.Errorf("load plan for apply live: %w", err)
 			}
-			videoPath := filepath.Join("data", "uploads", videoData.Filename)
-			if err := s.pipeline.Reload(ctx, streamData, videoPath); err != nil {
+			if err := s.pipeline.Reload(ctx, streamData, plan); err != nil {
 				return nil, fmt.Errorf("reload pipeline from saved program: %w", err)
 			}
 		}
@@ -245,14 +979,19 @@ func (s *service) StopStream(ctx context.Context, id uuid.UUID) error {
 	if stream == nil {
 		return ErrStreamNotFound
 	}
+	if err := s.repo.SetDesiredState(ctx, id, DesiredStopped); err != nil {
+		return fmt.Errorf("persist desired state: %w", err)
+	}
 	if err := s.pipeline.Stop(ctx, stream); err != nil {
 		return fmt.Errorf("stop stream pipeline: %w", err)
 	}
 	return nil
 }
 
-func (s *service) GetStreams(ctx context.Context) ([]*Stream, error) {
-	streams, err := s.repo.List(ctx)
+// GetStreams lists the streams owned by ownerID, or every stream when it is
+// uuid.Nil.
+func (s *service) GetStreams(ctx context.Context, ownerID uuid.UUID) ([]*Stream, error) {
+	streams, err := s.repo.List(ctx, ownerID)
 	if err != nil {
 		return nil, fmt.Errorf("list streams: %w", err)
 	}
@@ -266,9 +1005,14 @@ func (s *service) GetStreamStats(ctx context.Context, id uuid.UUID) (interface{}
 	}
 
 	return map[string]interface{}{
-		"status":     proc.GetStatus(),
-		"started_at": proc.StartedAt,
-		"progress":   proc.LastProgress,
+		"status":       proc.GetStatus(),
+		"started_at":   proc.StartedAt,
+		"progress":     proc.LastProgress,
+		"queue_total":  len(proc.Queue),
+		"current":      proc.CurrentItem(),
+		"restarts":     proc.RestartCount(),
+		"input":        proc.GetInput(),
+		"destinations": proc.DestinationStates(),
 	}, nil
 }
 
@@ -285,5 +1029,38 @@ func (s *service) DeleteStream(ctx context.Context, id uuid.UUID) error {
 	if err := s.repo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("delete stream record: %w", err)
 	}
+	if err := s.sessions.DeleteSessions(ctx, id); err != nil {
+		return fmt.Errorf("delete stream sessions: %w", err)
+	}
 	return nil
 }
+
+// ListSessions returns the stream's latest sessions, newest first.
+func (s *service) ListSessions(ctx context.Context, id uuid.UUID) ([]*Session, error) {
+	sessions, err := s.sessions.ListSessions(ctx, id, sessionsKept)
+	if err != nil {
+		return nil, fmt.Errorf("list stream sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// GetSessionMetrics returns the progress samples of one of the stream's
+// sessions, oldest first.
+func (s *service) GetSessionMetrics(ctx context.Context, id, sessionID uuid.UUID) ([]SessionMetric, error) {
+	session, err := s.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("get stream session: %w", err)
+	}
+	if session.StreamID != id {
+		return nil, ErrSessionNotFound
+	}
+
+	metrics, err := s.sessions.ListMetrics(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("list session metrics: %w", err)
+	}
+	return metrics, nil
+}
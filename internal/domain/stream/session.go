@@ -0,0 +1,165 @@
+package stream
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// Why a session ended.
+const (
+	ExitStopped     = "stopped"
+	ExitCompleted   = "completed"
+	ExitFailed      = "failed"
+	ExitInterrupted = "interrupted"
+)
+
+const (
+	// sessionSampleInterval spaces the progress samples kept for a session.
+	sessionSampleInterval = 10 * time.Second
+	// sessionsKept bounds the history of each stream.
+	sessionsKept = 50
+	// sessionOutputLines is how much of ffmpeg's output a session keeps.
+	sessionOutputLines = 20
+)
+
+// Session is one time the stream was on air, from start to stop, across any
+// encoder restarts in between.
+type Session struct {
+	bun.BaseModel `bun:"table:stream_sessions,alias:ss"`
+
+	ID         uuid.UUID  `bun:",pk,type:text" json:"id"`
+	StreamID   uuid.UUID  `bun:",notnull,type:text" json:"stream_id"`
+	StartedAt  time.Time  `bun:",notnull" json:"started_at"`
+	StoppedAt  *time.Time `bun:",nullzero" json:"stopped_at"`
+	ExitReason string     `bun:",notnull,default:''" json:"exit_reason"`
+	Error      string     `bun:",notnull,default:''" json:"error,omitempty"`
+	Restarts   int        `bun:",notnull,default:0" json:"restarts"`
+	// Args are those of the last encoder launched. Destination URLs, and
+	// with them stream keys, only reach the relays and are not included.
+	Args       []string `bun:",type:json" json:"args"`
+	LastOutput []string `bun:",type:json" json:"last_output"`
+}
+
+// SessionMetric is a sample of the encoder's progress during a session.
+type SessionMetric struct {
+	bun.BaseModel `bun:"table:stream_session_metrics,alias:ssm"`
+
+	ID          int64     `bun:",pk,autoincrement" json:"id"`
+	SessionID   uuid.UUID `bun:",notnull,type:text" json:"session_id"`
+	Frame       int       `json:"frame"`
+	FPS         float64   `json:"fps"`
+	BitrateKbps float64   `json:"bitrate_kbps"`
+	Speed       float64   `json:"speed"`
+	DropFrames  int       `json:"drop_frames"`
+	RecordedAt  time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"recorded_at"`
+}
+
+// sessionRecorder keeps the record of a running session up to date. Failing
+// to persist it is logged but never stops the stream.
+type sessionRecorder struct {
+	repo      SessionRepository
+	log       *zap.Logger
+	session   *Session
+	sampledAt time.Time
+	output    []string
+	mu        sync.Mutex
+}
+
+func (p *pipeline) startSession(streamID uuid.UUID) *sessionRecorder {
+	rec := &sessionRecorder{
+		repo: p.sessions,
+		log:  p.log,
+		session: &Session{
+			ID:        uuid.New(),
+			StreamID:  streamID,
+			StartedAt: time.Now().UTC(),
+		},
+	}
+	ctx := context.Background()
+	if err := rec.repo.CreateSession(ctx, rec.session); err != nil {
+		rec.warn("failed to record stream session", err)
+	}
+	if err := rec.repo.PruneSessions(ctx, streamID, sessionsKept); err != nil {
+		rec.warn("failed to prune stream sessions", err)
+	}
+	return rec
+}
+
+// launched records the args of a new encoder run.
+func (r *sessionRecorder) launched(args []string) {
+	r.mu.Lock()
+	r.session.Args = args
+	r.mu.Unlock()
+	r.save()
+}
+
+func (r *sessionRecorder) restarted() {
+	r.mu.Lock()
+	r.session.Restarts++
+	r.mu.Unlock()
+	r.save()
+}
+
+// sample stores the progress if the last sample is old enough.
+func (r *sessionRecorder) sample(progress *ffmpeg.Progress) {
+	r.mu.Lock()
+	if time.Since(r.sampledAt) < sessionSampleInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.sampledAt = time.Now()
+	r.mu.Unlock()
+
+	metric := &SessionMetric{
+		SessionID:   r.session.ID,
+		Frame:       progress.Frame,
+		FPS:         progress.FPS,
+		BitrateKbps: ffmpeg.ParseBitrate(progress.Bitrate),
+		Speed:       progress.Speed,
+		DropFrames:  progress.DropFrames,
+	}
+	if err := r.repo.AddMetric(context.Background(), metric); err != nil {
+		r.warn("failed to record session metric", err)
+	}
+}
+
+// outputLine keeps the last lines ffmpeg wrote besides its progress.
+func (r *sessionRecorder) outputLine(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.output = append(r.output, strings.TrimSpace(line))
+	if len(r.output) > sessionOutputLines {
+		r.output = r.output[1:]
+	}
+}
+
+func (r *sessionRecorder) finish(reason, lastError string) {
+	r.mu.Lock()
+	now := time.Now().UTC()
+	r.session.StoppedAt = &now
+	r.session.ExitReason = reason
+	r.session.Error = lastError
+	r.session.LastOutput = append([]string(nil), r.output...)
+	r.mu.Unlock()
+	r.save()
+}
+
+func (r *sessionRecorder) save() {
+	r.mu.Lock()
+	session := *r.session
+	r.mu.Unlock()
+	if err := r.repo.UpdateSession(context.Background(), &session); err != nil {
+		r.warn("failed to update stream session", err)
+	}
+}
+
+func (r *sessionRecorder) warn(msg string, err error) {
+	r.log.Warn(msg, zap.String("stream_id", r.session.StreamID.String()), zap.String("session_id", r.session.ID.String()), zap.Error(err))
+}
@@ -0,0 +1,97 @@
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type sessionRepository struct {
+	db *bun.DB
+}
+
+func NewSessionRepository(db *bun.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, s *Session) error {
+	_, err := r.db.NewInsert().Model(s).Exec(ctx)
+	return err
+}
+
+func (r *sessionRepository) UpdateSession(ctx context.Context, s *Session) error {
+	_, err := r.db.NewUpdate().Model(s).WherePK().Exec(ctx)
+	return err
+}
+
+func (r *sessionRepository) GetSession(ctx context.Context, id uuid.UUID) (*Session, error) {
+	s := new(Session)
+	err := r.db.NewSelect().Model(s).Where("id = ?", id).Scan(ctx)
+	return s, err
+}
+
+func (r *sessionRepository) ListSessions(ctx context.Context, streamID uuid.UUID, limit int) ([]*Session, error) {
+	sessions := make([]*Session, 0)
+	err := r.db.NewSelect().Model(&sessions).
+		Where("stream_id = ?", streamID).
+		Order("started_at DESC").
+		Limit(limit).
+		Scan(ctx)
+	return sessions, err
+}
+
+// CloseOpenSessions ends the sessions the server went down in the middle of.
+func (r *sessionRepository) CloseOpenSessions(ctx context.Context, reason string) error {
+	_, err := r.db.NewUpdate().Model((*Session)(nil)).
+		Set("stopped_at = ?", time.Now().UTC()).
+		Set("exit_reason = ?", reason).
+		Where("stopped_at IS NULL").
+		Exec(ctx)
+	return err
+}
+
+// PruneSessions keeps the stream's latest sessions and drops the rest with
+// their metrics.
+func (r *sessionRepository) PruneSessions(ctx context.Context, streamID uuid.UUID, keep int) error {
+	kept := r.db.NewSelect().Model((*Session)(nil)).Column("id").
+		Where("stream_id = ?", streamID).
+		Order("started_at DESC").
+		Limit(keep)
+	stale := r.db.NewSelect().Model((*Session)(nil)).Column("id").
+		Where("stream_id = ?", streamID).
+		Where("id NOT IN (?)", kept)
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*SessionMetric)(nil)).Where("session_id IN (?)", stale).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewDelete().Model((*Session)(nil)).Where("id IN (?)", stale).Exec(ctx)
+		return err
+	})
+}
+
+func (r *sessionRepository) DeleteSessions(ctx context.Context, streamID uuid.UUID) error {
+	sessions := r.db.NewSelect().Model((*Session)(nil)).Column("id").Where("stream_id = ?", streamID)
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*SessionMetric)(nil)).Where("session_id IN (?)", sessions).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewDelete().Model((*Session)(nil)).Where("stream_id = ?", streamID).Exec(ctx)
+		return err
+	})
+}
+
+func (r *sessionRepository) AddMetric(ctx context.Context, m *SessionMetric) error {
+	_, err := r.db.NewInsert().Model(m).Exec(ctx)
+	return err
+}
+
+func (r *sessionRepository) ListMetrics(ctx context.Context, sessionID uuid.UUID) ([]SessionMetric, error) {
+	metrics := make([]SessionMetric, 0)
+	err := r.db.NewSelect().Model(&metrics).
+		Where("session_id = ?", sessionID).
+		Order("recorded_at ASC").
+		Scan(ctx)
+	return metrics, err
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudioSettings_Validate(t *testing.T) {
+	assert.NoError(t, stream.AudioSettings{}.Validate())
+	assert.NoError(t, stream.AudioSettings{Mode: stream.AudioMute, Normalize: true}.Validate())
+	assert.NoError(t, stream.AudioSettings{Mode: stream.AudioPlaylist, Playlist: []uuid.UUID{uuid.New()}}.Validate())
+
+	assert.ErrorIs(t, stream.AudioSettings{Mode: stream.AudioPlaylist}.Validate(), stream.ErrInvalidAudio)
+	assert.ErrorIs(t, stream.AudioSettings{Mode: stream.AudioSource, Playlist: []uuid.UUID{uuid.New()}}.Validate(), stream.ErrInvalidAudio)
+	assert.ErrorIs(t, stream.AudioSettings{Mode: "dub"}.Validate(), stream.ErrInvalidAudio)
+}
+
+func TestCommandBuilder_Audio(t *testing.T) {
+	t.Run("Source audio is mapped from the input", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().WithInput("in.mp4").WithPipeOutput().Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "-map 0:v -map 0:a")
+		assert.NotContains(t, cmd, "-shortest")
+		assert.NotContains(t, cmd, "-af")
+	})
+
+	t.Run("Audio playlist loops as its own input", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithOverlays([]ffmpeg.Overlay{{Type: ffmpeg.OverlayImage, ImagePath: "logo.png"}}).
+			WithAudioPlaylist("data/playlists/abc-audio.txt").
+			WithLoudnorm(true).
+			WithPipeOutput().
+			WithPreview("data/preview/abc").
+			Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "-i logo.png -re -stream_loop -1 -f concat -safe 0 -i data/playlists/abc-audio.txt")
+		assert.Contains(t, cmd, "-af loudnorm=I=-16:TP=-1.5:LRA=11")
+		main, preview, found := strings.Cut(cmd, "pipe:1 ")
+		assert.True(t, found)
+		assert.Contains(t, main, "-map 2:a")
+		assert.Contains(t, preview, "-map 2:a")
+		assert.NotContains(t, cmd, "-shortest")
+	})
+
+	t.Run("Silence ends with a video that does not loop", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithLoop(false).
+			WithSilence(true).
+			WithPipeOutput().
+			Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "-i in.mp4 -f lavfi -i anullsrc=channel_layout=stereo:sample_rate=44100")
+		assert.Contains(t, cmd, "-shortest")
+		assert.Contains(t, cmd, "-map 0:v -map 1:a")
+	})
+
+	t.Run("Passthrough copies the source audio", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithPassthrough(true).
+			WithPipeOutput().
+			Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "-c:a copy")
+		assert.Contains(t, cmd, "-map 0:a")
+		assert.NotContains(t, cmd, "-c:a aac")
+		assert.NotContains(t, cmd, "-af")
+		assert.NotContains(t, cmd, "anullsrc")
+	})
+
+	t.Run("Passthrough rejects silence", func(t *testing.T) {
+		_, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithPassthrough(true).
+			WithSilence(true).
+			WithPipeOutput().
+			Build()
+		assert.Error(t, err)
+	})
+
+	t.Run("Capabilities cover the audio filters", func(t *testing.T) {
+		caps := fixtureCapabilities(t)
+		assert.NoError(t, caps.CheckAudio(true, true))
+
+		caps.Filters = []string{"scale"}
+		assert.EqualError(t, caps.CheckAudio(false, true), "audio filter loudnorm is not supported by the installed ffmpeg")
+	})
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandBuilder_EncoderSettings(t *testing.T) {
+	t.Run("Defaults keep CBR x264", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().WithInput("in.mp4").WithPipeOutput().Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "-c:v libx264 -preset veryfast -tune zerolatency -profile:v high")
+		assert.Contains(t, cmd, "-b:v 2500k -maxrate 2500k -minrate 2500k -bufsize 5000k")
+		assert.Contains(t, cmd, "-g 60")
+		assert.Contains(t, cmd, "-c:a aac -ac 2 -ar 44100 -b:a 128k")
+	})
+
+	t.Run("Profile renders CRF and filters", func(t *testing.T) {
+		settings := ffmpeg.DefaultEncoderSettings()
+		settings.RateControl = ffmpeg.RateControlCRF
+		settings.CRF = 21
+		settings.KeyframeSec = 4
+		settings.BFrames = 2
+		settings.AudioSampleRate = 48000
+		settings.Filters = "eq=contrast=1.1"
+
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithFPS(25).
+			WithBitrate(3000).
+			WithEncoder(settings).
+			WithPipeOutput().
+			Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "-crf 21 -maxrate 3000k -bufsize 6000k")
+		assert.NotContains(t, cmd, "-minrate")
+		assert.Contains(t, cmd, "-bf 2")
+		assert.Contains(t, cmd, "-g 100")
+		assert.Contains(t, cmd, "-vf scale=1280x720,eq=contrast=1.1")
+		assert.Contains(t, cmd, "-ar 48000")
+	})
+
+	t.Run("Invalid settings fail the build", func(t *testing.T) {
+		settings := ffmpeg.DefaultEncoderSettings()
+		settings.VideoCodec = "libx265"
+
+		_, err := ffmpeg.NewCommandBuilder().WithInput("in.mp4").WithEncoder(settings).WithPipeOutput().Build()
+		assert.Error(t, err)
+	})
+}
+
+func TestCommandBuilder_Preview(t *testing.T) {
+	t.Run("Encoded preview is a separate low-bitrate output", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithPipeOutput().
+			WithPreview("data/preview/abc").
+			Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		main, preview, found := strings.Cut(cmd, "pipe:1 ")
+		assert.True(t, found)
+		assert.Contains(t, main, "-b:v 2500k")
+		assert.Contains(t, preview, "-vf scale=-2:360 -c:v libx264")
+		assert.Contains(t, preview, "-b:v 400k")
+		assert.Contains(t, preview, "-hls_segment_filename data/preview/abc/seg_%05d.ts data/preview/abc/index.m3u8")
+	})
+
+	t.Run("Passthrough preview copies", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithPassthrough(true).
+			WithPipeOutput().
+			WithPreview("data/preview/abc").
+			Build()
+		assert.NoError(t, err)
+
+		_, preview, _ := strings.Cut(strings.Join(args, " "), "pipe:1 ")
+		assert.True(t, strings.HasPrefix(preview, "-map 0:v -map 0:a -c copy -f hls"))
+	})
+}
+
+func TestCommandBuilder_LiveInput(t *testing.T) {
+	args, err := ffmpeg.NewCommandBuilder().
+		WithLiveInput().
+		WithLoop(true).
+		WithPipeOutput().
+		WithOutputOffset(90500 * time.Millisecond).
+		Build()
+	assert.NoError(t, err)
+
+	cmd := strings.Join(args, " ")
+	assert.True(t, strings.HasPrefix(cmd, "-f flv -thread_queue_size 1024 -i pipe:0 "))
+	assert.NotContains(t, cmd, "-re")
+	assert.NotContains(t, cmd, "-stream_loop")
+	assert.Contains(t, cmd, "-output_ts_offset 90.500 -f mpegts pipe:1")
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read fixture %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func fixtureCapabilities(t *testing.T) *ffmpeg.Capabilities {
+	in, out := ffmpeg.ParseProtocols(readFixture(t, "protocols.txt"))
+	return &ffmpeg.Capabilities{
+		Available:       true,
+		Encoders:        ffmpeg.ParseEncoders(readFixture(t, "encoders.txt")),
+		Filters:         ffmpeg.ParseFilters(readFixture(t, "filters.txt")),
+		Muxers:          ffmpeg.ParseMuxers(readFixture(t, "muxers.txt")),
+		InputProtocols:  in,
+		OutputProtocols: out,
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	caps := fixtureCapabilities(t)
+
+	assert.Equal(t, []string{"aac", "h264_vaapi", "libopus", "libvpx-vp9", "libx264", "libx264rgb", "srt"}, caps.Encoders)
+	assert.Equal(t, []string{"abench", "anullsrc", "eq", "loudnorm", "overlay", "scale"}, caps.Filters)
+	assert.Equal(t, []string{"3g2", "flv", "hls", "mpegts", "tee"}, caps.Muxers)
+	assert.Contains(t, caps.InputProtocols, "srt")
+	assert.NotContains(t, caps.OutputProtocols, "srt")
+	assert.Contains(t, caps.OutputProtocols, "rtmps")
+
+	assert.Equal(t, "6.1.1", ffmpeg.ParseVersion("ffmpeg version 6.1.1 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc"))
+}
+
+func TestCapabilities_Checks(t *testing.T) {
+	caps := fixtureCapabilities(t)
+
+	settings := ffmpeg.DefaultEncoderSettings()
+	assert.NoError(t, caps.CheckEncoder(settings))
+
+	settings.VideoCodec = "h264_nvenc"
+	assert.ErrorIs(t, caps.CheckEncoder(settings), ffmpeg.ErrUnsupported)
+	assert.EqualError(t, caps.CheckEncoder(settings), "video encoder h264_nvenc is not supported by the installed ffmpeg")
+
+	settings = ffmpeg.DefaultEncoderSettings()
+	settings.Filters = "eq=contrast=1.1,unsharp"
+	assert.EqualError(t, caps.CheckEncoder(settings), "filter unsharp is not supported by the installed ffmpeg")
+
+	assert.NoError(t, caps.CheckDestination("rtmps://live.example.com/app/key"))
+	assert.ErrorIs(t, caps.CheckDestination("srt://ingest.example.com:9000"), ffmpeg.ErrUnsupported)
+
+	var none *ffmpeg.Capabilities
+	assert.NoError(t, none.CheckEncoder(settings))
+
+	missing := &ffmpeg.Capabilities{}
+	assert.ErrorIs(t, missing.CheckPipeline(), ffmpeg.ErrFFmpegUnavailable)
+}
+
+func TestCommandBuilder_Capabilities(t *testing.T) {
+	settings := ffmpeg.DefaultEncoderSettings()
+	settings.VideoCodec = "h264_qsv"
+	settings.Tune = ""
+
+	_, err := ffmpeg.NewCommandBuilder().
+		WithInput("in.mp4").
+		WithEncoder(settings).
+		WithCapabilities(fixtureCapabilities(t)).
+		WithPipeOutput().
+		Build()
+	assert.ErrorIs(t, err, ffmpeg.ErrUnsupported)
+}
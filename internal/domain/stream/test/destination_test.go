@@ -0,0 +1,194 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDestination(t *testing.T) {
+	d, err := stream.ParseDestination("rtmps://live-api-s.facebook.com:443/rtmp/FB-key")
+	assert.NoError(t, err)
+	assert.Equal(t, stream.ProtocolRTMPS, d.Protocol)
+	assert.Equal(t, "rtmps://live-api-s.facebook.com:443/rtmp/****", d.Display())
+
+	_, err = stream.ParseDestination("rtmp://live.twitch.tv")
+	assert.ErrorIs(t, err, stream.ErrInvalidDestination)
+
+	_, err = stream.ParseDestination("http://example.com/live/key")
+	assert.ErrorIs(t, err, stream.ErrInvalidDestination)
+}
+
+func TestDestination_Validate(t *testing.T) {
+	srt := func(opts stream.DestinationOptions) stream.Destination {
+		return stream.Destination{Protocol: stream.ProtocolSRT, URL: "srt://ingest.example.com:9000", Options: opts}
+	}
+
+	tests := []struct {
+		name  string
+		dest  stream.Destination
+		valid bool
+	}{
+		{"srt defaults", srt(stream.DestinationOptions{}), true},
+		{"srt full", srt(stream.DestinationOptions{LatencyMs: 200, Passphrase: "0123456789ab", PBKeyLen: 32, StreamID: "#!::r=live/key,m=publish"}), true},
+		{"srt without port", stream.Destination{Protocol: stream.ProtocolSRT, URL: "srt://ingest.example.com"}, false},
+		{"srt latency too low", srt(stream.DestinationOptions{LatencyMs: 5}), false},
+		{"srt short passphrase", srt(stream.DestinationOptions{Passphrase: "short"}), false},
+		{"srt keylen without passphrase", srt(stream.DestinationOptions{PBKeyLen: 16}), false},
+		{"srt with tls", srt(stream.DestinationOptions{TLSVerify: true}), false},
+		{"scheme mismatch", stream.Destination{Protocol: stream.ProtocolRTMPS, URL: "rtmp://a.rtmp.youtube.com/live2/key"}, false},
+		{"rtmp with srt option", stream.Destination{Protocol: stream.ProtocolRTMP, URL: "rtmp://a.rtmp.youtube.com/live2/key", Options: stream.DestinationOptions{LatencyMs: 200}}, false},
+		{"rtmp with tls", stream.Destination{Protocol: stream.ProtocolRTMP, URL: "rtmp://a.rtmp.youtube.com/live2/key", Options: stream.DestinationOptions{TLSVerify: true}}, false},
+		{"rtmps verify", stream.Destination{Protocol: stream.ProtocolRTMPS, URL: "rtmps://live.example.com/app/key", Options: stream.DestinationOptions{TLSVerify: true}}, true},
+		{"rtmps relative ca file", stream.Destination{Protocol: stream.ProtocolRTMPS, URL: "rtmps://live.example.com/app/key", Options: stream.DestinationOptions{CAFile: "ca.pem"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.dest.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, stream.ErrInvalidDestination)
+			}
+		})
+	}
+}
+
+func TestDestination_CAFile(t *testing.T) {
+	root := t.TempDir()
+	certs := filepath.Join(root, "certs")
+	assert.NoError(t, os.MkdirAll(filepath.Join(certs, "sub"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(certs, "ca.pem"), []byte("pem"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "outside.pem"), []byte("pem"), 0644))
+	assert.NoError(t, os.Symlink(filepath.Join(root, "outside.pem"), filepath.Join(certs, "link.pem")))
+	stream.SetCertsDir(certs)
+	t.Cleanup(func() { stream.SetCertsDir(filepath.Join("data", "certs")) })
+
+	rtmps := func(caFile string) stream.Destination {
+		return stream.Destination{
+			Protocol: stream.ProtocolRTMPS,
+			URL:      "rtmps://live.example.com/app/key",
+			Options:  stream.DestinationOptions{TLSVerify: true, CAFile: caFile},
+		}
+	}
+
+	t.Run("Files in the certificates directory are accepted", func(t *testing.T) {
+		for _, name := range []string{"ca.pem", filepath.Join(certs, "ca.pem")} {
+			d := rtmps(name)
+			assert.NoError(t, d.Validate(), name)
+
+			real, _ := filepath.EvalSymlinks(filepath.Join(certs, "ca.pem"))
+			assert.Equal(t, real, d.Output().Options["ca_file"])
+		}
+	})
+
+	t.Run("Everything else gets the same error", func(t *testing.T) {
+		var messages []string
+		for _, name := range []string{
+			"missing.pem",
+			"sub",
+			"../outside.pem",
+			filepath.Join(root, "outside.pem"),
+			"link.pem",
+			"/etc/passwd",
+		} {
+			err := rtmps(name).Validate()
+			assert.ErrorIs(t, err, stream.ErrInvalidDestination, name)
+			if err != nil {
+				assert.NotContains(t, err.Error(), name)
+				messages = append(messages, err.Error())
+			}
+		}
+		for _, msg := range messages {
+			assert.Equal(t, messages[0], msg)
+		}
+	})
+}
+
+func TestDestination_RelayArgs(t *testing.T) {
+	t.Run("SRT uses mpegts with protocol options", func(t *testing.T) {
+		d := stream.Destination{
+			Protocol: stream.ProtocolSRT,
+			URL:      "srt://ingest.example.com:9000",
+			Options:  stream.DestinationOptions{LatencyMs: 120, Passphrase: "0123456789ab", StreamID: "live/key"},
+		}
+
+		args, err := ffmpeg.BuildRelayArgs(d.Output())
+		assert.NoError(t, err)
+		assert.Equal(t,
+			"-c copy -f mpegts -latency 120000 -passphrase 0123456789ab -streamid live/key srt://ingest.example.com:9000",
+			strings.Join(args[len(args)-11:], " "),
+		)
+	})
+
+	t.Run("RTMPS passes TLS options", func(t *testing.T) {
+		d := stream.Destination{
+			Protocol: stream.ProtocolRTMPS,
+			URL:      "rtmps://live.example.com/app/key",
+			Options:  stream.DestinationOptions{TLSVerify: true},
+		}
+
+		args, err := ffmpeg.BuildRelayArgs(d.Output())
+		assert.NoError(t, err)
+		assert.Equal(t, "-f flv -tls_verify 1 rtmps://live.example.com/app/key", strings.Join(args[len(args)-5:], " "))
+	})
+}
+
+func TestDestination_RedactsErrors(t *testing.T) {
+	t.Run("The stream key is hidden in output errors", func(t *testing.T) {
+		d, err := stream.ParseDestination("rtmp://live.twitch.tv/app/tw-secret-key")
+		assert.NoError(t, err)
+
+		line := d.Redact("[out#0/flv] Error opening output rtmp://live.twitch.tv/app/tw-secret-key: I/O error")
+		assert.Equal(t, "[out#0/flv] Error opening output rtmp://live.twitch.tv/app/****: I/O error", line)
+	})
+
+	t.Run("The SRT passphrase is hidden", func(t *testing.T) {
+		d := stream.Destination{
+			Protocol: stream.ProtocolSRT,
+			URL:      "srt://ingest.example.com:9000",
+			Options:  stream.DestinationOptions{Passphrase: "srt-passphrase"},
+		}
+
+		line := d.Redact("Invalid passphrase srt-passphrase for srt://ingest.example.com:9000")
+		assert.NotContains(t, line, "srt-passphrase")
+	})
+}
+
+func TestCommandBuilder_TeeDestinations(t *testing.T) {
+	rtmp, _ := stream.ParseDestination("rtmp://a.rtmp.youtube.com/live2/key")
+	srt := stream.Destination{
+		Protocol: stream.ProtocolSRT,
+		URL:      "srt://ingest.example.com:9000",
+		Options:  stream.DestinationOptions{LatencyMs: 200},
+	}
+
+	args, err := ffmpeg.NewCommandBuilder().
+		WithInput("in.mp4").
+		WithDestinations([]ffmpeg.Output{rtmp.Output(), srt.Output()}).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"[f=flv:onfail=ignore]rtmp://a.rtmp.youtube.com/live2/key|[f=mpegts:onfail=ignore]srt://ingest.example.com:9000?latency=200000",
+		args[len(args)-1],
+	)
+}
+
+func TestStream_ResolveDestinations(t *testing.T) {
+	s := &stream.Stream{
+		RTMPTargets:  []string{"rtmp://a.rtmp.youtube.com/live2/key", " "},
+		Destinations: []stream.Destination{{Protocol: stream.ProtocolSRT, URL: "srt://ingest.example.com:9000"}},
+	}
+
+	destinations, err := s.ResolveDestinations()
+	assert.NoError(t, err)
+	assert.Len(t, destinations, 2)
+	assert.Equal(t, stream.ProtocolRTMP, destinations[0].Protocol)
+	assert.Equal(t, stream.ProtocolSRT, destinations[1].Protocol)
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/ws"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// streamStore is a stream service serving one stream from memory.
+type streamStore struct {
+	stream.Service
+	stream  *stream.Stream
+	stopped bool
+}
+
+func (s *streamStore) GetStream(ctx context.Context, id uuid.UUID) (*stream.Stream, error) {
+	if id != s.stream.ID {
+		return nil, stream.ErrStreamNotFound
+	}
+	return s.stream, nil
+}
+
+func (s *streamStore) GetStreams(ctx context.Context, ownerID uuid.UUID) ([]*stream.Stream, error) {
+	return []*stream.Stream{s.stream}, nil
+}
+
+func (s *streamStore) StopStream(ctx context.Context, id uuid.UUID) error {
+	s.stopped = true
+	return nil
+}
+
+func TestHandler_StreamOwnership(t *testing.T) {
+	owner := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	other := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	viewer := &auth.User{ID: uuid.New(), Role: auth.RoleViewer}
+	admin := &auth.User{ID: uuid.New(), Role: auth.RoleAdmin}
+
+	st := &stream.Stream{
+		ID:          uuid.New(),
+		UserID:      owner.ID,
+		Name:        "Live",
+		RTMPTargets: []string{"rtmp://live.twitch.tv/app/tw-secret-key"},
+		SourceType:  stream.SourceIngest,
+		IngestKey:   "live_0123456789abcdef",
+	}
+	svc := &streamStore{stream: st}
+	ingest := stream.NewIngestHub(nil, ws.NewHub(), "rtmp://localhost:1935/live", zap.NewNop())
+	handler := stream.NewHandler(svc, nil, nil, nil, ingest, zap.NewNop())
+
+	var current *auth.User
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", current)
+		return c.Next()
+	})
+	handler.Routes(app)
+
+	call := func(user *auth.User, method, path string) (int, map[string]interface{}) {
+		current = user
+		resp, err := app.Test(httptest.NewRequest(method, path, nil), -1)
+		assert.NoError(t, err)
+		var body map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+	base := "/api/streams/" + st.ID.String()
+
+	t.Run("Lists hide credentials", func(t *testing.T) {
+		current = viewer
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/streams/", nil), -1)
+		assert.NoError(t, err)
+		var streams []stream.Stream
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&streams))
+		assert.Len(t, streams, 1)
+		assert.Equal(t, []string{"rtmp://live.twitch.tv/app/****"}, streams[0].RTMPTargets)
+		assert.Equal(t, "********cdef", streams[0].IngestKey)
+	})
+
+	t.Run("Only the owner and admins read the ingest key", func(t *testing.T) {
+		status, _ := call(other, "GET", base+"/ingest")
+		assert.Equal(t, fiber.StatusForbidden, status)
+
+		for _, user := range []*auth.User{owner, admin} {
+			status, body := call(user, "GET", base+"/ingest")
+			assert.Equal(t, fiber.StatusOK, status)
+			assert.Equal(t, st.IngestKey, body["key"])
+		}
+	})
+
+	t.Run("Only the owner and admins control the stream", func(t *testing.T) {
+		status, _ := call(other, "POST", base+"/stop")
+		assert.Equal(t, fiber.StatusForbidden, status)
+		assert.False(t, svc.stopped)
+
+		status, _ = call(owner, "POST", base+"/stop")
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.True(t, svc.stopped)
+	})
+
+	t.Run("Unknown streams are not found", func(t *testing.T) {
+		status, _ := call(admin, "DELETE", "/api/streams/"+uuid.New().String())
+		assert.Equal(t, fiber.StatusNotFound, status)
+	})
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/rtmp"
+	"github.com/codewithwan/gostreamix/internal/infrastructure/ws"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func newIngestHub(t *testing.T) (*stream.IngestHub, *stream.Stream) {
+	st := &stream.Stream{ID: uuid.New(), SourceType: stream.SourceIngest, IngestKey: "live_abc"}
+	repo := new(MockStreamRepository)
+	repo.On("FindByIngestKey", mock.Anything, "live_abc").Return(st, nil)
+	repo.On("FindByIngestKey", mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows)
+	return stream.NewIngestHub(repo, ws.NewHub(), "rtmp://localhost:1935/live", zap.NewNop()), st
+}
+
+func TestIngestHub_Publish(t *testing.T) {
+	t.Run("Unknown key is rejected", func(t *testing.T) {
+		hub, _ := newIngestHub(t)
+		_, err := hub.Publish("live", "nope")
+		assert.ErrorIs(t, err, stream.ErrIngestKeyUnknown)
+	})
+
+	t.Run("One publisher per stream", func(t *testing.T) {
+		hub, st := newIngestHub(t)
+		changed := hub.Changed(st.ID)
+
+		pub, err := hub.Publish("live", "live_abc")
+		assert.NoError(t, err)
+		assert.True(t, hub.Connected(st.ID))
+		assert.True(t, isClosed(changed))
+
+		_, err = hub.Publish("live", "live_abc")
+		assert.ErrorIs(t, err, stream.ErrIngestBusy)
+
+		changed = hub.Changed(st.ID)
+		pub.Close()
+		assert.False(t, hub.Connected(st.ID))
+		assert.True(t, isClosed(changed))
+	})
+
+	t.Run("File streams do not accept ingest", func(t *testing.T) {
+		st := &stream.Stream{ID: uuid.New(), SourceType: stream.SourceFile, IngestKey: "live_old"}
+		repo := new(MockStreamRepository)
+		repo.On("FindByIngestKey", mock.Anything, "live_old").Return(st, nil)
+		hub := stream.NewIngestHub(repo, ws.NewHub(), "", zap.NewNop())
+
+		_, err := hub.Publish("live", "live_old")
+		assert.ErrorIs(t, err, stream.ErrIngestKeyUnknown)
+	})
+}
+
+func TestIngestHub_Attach(t *testing.T) {
+	hub, st := newIngestHub(t)
+	assert.Nil(t, hub.Attach(st.ID))
+
+	pub, err := hub.Publish("live", "live_abc")
+	assert.NoError(t, err)
+
+	metadata := rtmp.Tag{Type: rtmp.TagScript, Data: []byte{0x02, 0, 1, 'x'}}
+	avcHeader := rtmp.Tag{Type: rtmp.TagVideo, Data: []byte{0x17, 0x00, 0, 0, 0, 0x01}}
+	interframe := rtmp.Tag{Type: rtmp.TagVideo, Timestamp: 40, Data: []byte{0x27, 0x01}}
+	keyframe := rtmp.Tag{Type: rtmp.TagVideo, Timestamp: 80, Data: []byte{0x17, 0x01}}
+	assert.NoError(t, pub.WriteTag(metadata))
+	assert.NoError(t, pub.WriteTag(avcHeader))
+	// Media before an encoder attaches is dropped.
+	assert.NoError(t, pub.WriteTag(keyframe))
+
+	reader := hub.Attach(st.ID)
+	assert.NoError(t, pub.WriteTag(interframe))
+	assert.NoError(t, pub.WriteTag(keyframe))
+	pub.Close()
+
+	got, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+
+	var want bytes.Buffer
+	want.Write(rtmp.FLVHeader)
+	want.Write(metadata.Bytes())
+	want.Write(avcHeader.Bytes())
+	want.Write(keyframe.Bytes())
+	assert.Equal(t, want.Bytes(), got, "joins on the next keyframe after the cached headers")
+	assert.NoError(t, reader.Close())
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestService_RotateIngestKey(t *testing.T) {
+	id := uuid.New()
+	rotate := func(err error) error {
+		repo := new(MockStreamRepository)
+		repo.On("GetByID", mock.Anything, id).Return(nil, err)
+		svc := stream.NewService(repo, nil, nil, nil, nil, nil, nil, stream.NewProcessManager(), nil)
+		_, rotateErr := svc.RotateIngestKey(context.Background(), id)
+		return rotateErr
+	}
+
+	assert.ErrorIs(t, rotate(sql.ErrNoRows), stream.ErrStreamNotFound)
+
+	err := rotate(sql.ErrConnDone)
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+	assert.NotErrorIs(t, err, stream.ErrStreamNotFound)
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlay_Validate(t *testing.T) {
+	assetID := uuid.New()
+
+	tests := []struct {
+		name    string
+		overlay stream.Overlay
+		valid   bool
+	}{
+		{"logo", stream.Overlay{Type: ffmpeg.OverlayImage, AssetID: &assetID, Position: ffmpeg.PositionTopRight, Width: 160, Opacity: 0.8}, true},
+		{"image without asset", stream.Overlay{Type: ffmpeg.OverlayImage}, false},
+		{"image with text options", stream.Overlay{Type: ffmpeg.OverlayImage, AssetID: &assetID, FontSize: 24}, false},
+		{"clock", stream.Overlay{Type: ffmpeg.OverlayText, Text: "{time}", Position: ffmpeg.PositionBottomRight, Color: "#FFCC00"}, true},
+		{"empty text", stream.Overlay{Type: ffmpeg.OverlayText, Text: "  "}, false},
+		{"bad color", stream.Overlay{Type: ffmpeg.OverlayText, Text: "Live", Color: "white:box=1"}, false},
+		{"bad position", stream.Overlay{Type: ffmpeg.OverlayText, Text: "Live", Position: "middle"}, false},
+		{"opacity above one", stream.Overlay{Type: ffmpeg.OverlayText, Text: "Live", Opacity: 1.5}, false},
+		{"unknown type", stream.Overlay{Type: "video"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.overlay.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, stream.ErrInvalidOverlay)
+			}
+		})
+	}
+}
+
+func TestExpandOverlayText(t *testing.T) {
+	assert.Equal(t, `Live 100\% \\ %{localtime:%H\:%M\:%S}`, ffmpeg.ExpandOverlayText(`Live 100% \ {time}`))
+}
+
+func TestCommandBuilder_Overlays(t *testing.T) {
+	overlays := []ffmpeg.Overlay{
+		{Type: ffmpeg.OverlayImage, ImagePath: "data/assets/logo.png", Position: ffmpeg.PositionTopRight, Margin: 20, Width: 160, Opacity: 0.5},
+		{Type: ffmpeg.OverlayText, TextFile: "data/overlays/abc/text_1.txt", Position: ffmpeg.PositionBottomLeft, Margin: 10, FontSize: 28, BoxColor: "black"},
+	}
+
+	t.Run("Overlays compile into a filter graph", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithOverlays(overlays).
+			WithPipeOutput().
+			Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "-i in.mp4 -i data/assets/logo.png ")
+		assert.NotContains(t, cmd, "-vf")
+		assert.Contains(t, cmd, "-filter_complex [0:v]scale=1280x720[v0];"+
+			"[1:v]scale=160:-1,format=rgba,colorchannelmixer=aa=0.50[img1];"+
+			"[v0][img1]overlay=x=W-w-20:y=20[v1];"+
+			"[v1]drawtext=textfile='data/overlays/abc/text_1.txt':fontsize=28:fontcolor=white@1.00:x=10:y=h-text_h-10"+
+			":box=1:boxcolor=black@1.00:boxborderw=9[v2]")
+		assert.Contains(t, cmd, "-map [v2] -map 0:a")
+	})
+
+	t.Run("Preview takes a scaled branch of the graph", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithOverlays(overlays).
+			WithPipeOutput().
+			WithPreview("data/preview/abc").
+			Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "[v2]split=2[vout][vsplit];[vsplit]scale=-2:360[vpreview]")
+		main, preview, found := strings.Cut(cmd, "pipe:1 ")
+		assert.True(t, found)
+		assert.Contains(t, main, "-map [vout] -map 0:a")
+		assert.Contains(t, preview, "-map [vpreview] -map 0:a -c:v libx264")
+		assert.NotContains(t, preview, "-vf")
+	})
+
+	t.Run("Passthrough cannot overlay", func(t *testing.T) {
+		_, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithOverlays(overlays).
+			WithPassthrough(true).
+			WithPipeOutput().
+			Build()
+		assert.Error(t, err)
+	})
+
+	t.Run("Missing drawtext is reported", func(t *testing.T) {
+		caps := fixtureCapabilities(t)
+		err := caps.CheckOverlays(overlays[1:], false)
+		assert.ErrorIs(t, err, ffmpeg.ErrUnsupported)
+		assert.EqualError(t, err, "overlay filter drawtext is not supported by the installed ffmpeg")
+	})
+}
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository_ListByOwner(t *testing.T) {
+	db := setupSessionDB(t)
+	ctx := context.Background()
+	for _, model := range []interface{}{(*stream.Stream)(nil), (*stream.StreamPlatform)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := stream.NewRepository(db, testCipher(t))
+
+	alice, bob := uuid.New(), uuid.New()
+	for _, s := range []*stream.Stream{
+		{ID: uuid.New(), UserID: alice, Name: "Alice 1"},
+		{ID: uuid.New(), UserID: alice, Name: "Alice 2"},
+		{ID: uuid.New(), UserID: bob, Name: "Bob"},
+	} {
+		assert.NoError(t, repo.Create(ctx, s))
+	}
+
+	all, err := repo.List(ctx, uuid.Nil)
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	owned, err := repo.List(ctx, bob)
+	assert.NoError(t, err)
+	assert.Len(t, owned, 1)
+	assert.Equal(t, "Bob", owned[0].Name)
+	assert.Equal(t, bob, owned[0].UserID)
+}
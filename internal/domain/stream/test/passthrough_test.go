@@ -0,0 +1,81 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/codewithwan/gostreamix/internal/domain/video"
+	"github.com/stretchr/testify/assert"
+)
+
+func compatibleMeta() *video.Metadata {
+	return &video.Metadata{
+		Resolution:      "1280x720",
+		FPS:             30,
+		VideoCodec:      "h264",
+		PixelFormat:     "yuv420p",
+		KeyframeSec:     2,
+		AudioCodec:      "aac",
+		AudioSampleRate: 44100,
+		AudioChannels:   2,
+	}
+}
+
+func TestCheckPassthrough(t *testing.T) {
+	s := &stream.Stream{Resolution: "1280x720"}
+
+	t.Run("Compatible source", func(t *testing.T) {
+		meta := compatibleMeta()
+		assert.NoError(t, stream.CheckPassthrough(s, meta, meta))
+	})
+
+	tests := []struct {
+		name   string
+		mutate func(*video.Metadata)
+		reason string
+	}{
+		{"HEVC video", func(m *video.Metadata) { m.VideoCodec = "hevc" }, "video codec is hevc"},
+		{"10-bit pixels", func(m *video.Metadata) { m.PixelFormat = "yuv420p10le" }, "pixel format"},
+		{"Wrong resolution", func(m *video.Metadata) { m.Resolution = "1920x1080" }, "resolution is 1920x1080"},
+		{"Long GOP", func(m *video.Metadata) { m.KeyframeSec = 10 }, "keyframe interval is 10.0s"},
+		{"Unmeasured GOP", func(m *video.Metadata) { m.KeyframeSec = 0 }, "could not be measured"},
+		{"Opus audio", func(m *video.Metadata) { m.AudioCodec = "opus" }, "audio codec is opus"},
+		{"No audio", func(m *video.Metadata) { m.AudioCodec = "" }, "audio codec is unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := compatibleMeta()
+			tt.mutate(meta)
+
+			err := stream.CheckPassthrough(s, meta, meta)
+			assert.ErrorIs(t, err, stream.ErrPassthroughIncompatible)
+			assert.Contains(t, err.Error(), tt.reason)
+		})
+	}
+
+	t.Run("Queue items must match the first", func(t *testing.T) {
+		ref := compatibleMeta()
+		next := compatibleMeta()
+		next.AudioSampleRate = 48000
+
+		err := stream.CheckPassthrough(s, next, ref)
+		assert.ErrorIs(t, err, stream.ErrPassthroughIncompatible)
+	})
+}
+
+func TestCommandBuilder_Passthrough(t *testing.T) {
+	args, err := ffmpeg.NewCommandBuilder().
+		WithInput("in.mp4").
+		WithPassthrough(true).
+		WithPipeOutput().
+		Build()
+	assert.NoError(t, err)
+
+	cmd := strings.Join(args, " ")
+	assert.Contains(t, cmd, "-i in.mp4 -c:v copy -c:a copy -map 0:v -map 0:a -f mpegts pipe:1")
+	assert.NotContains(t, cmd, "libx264")
+	assert.NotContains(t, cmd, "-vf")
+}
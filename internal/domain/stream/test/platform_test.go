@@ -0,0 +1,121 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/platform"
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// platformStore is a platform repository holding platforms in memory.
+type platformStore struct {
+	platform.Repository
+	platforms map[uuid.UUID]*platform.Platform
+}
+
+func (s *platformStore) FindByID(ctx context.Context, id uuid.UUID) (*platform.Platform, error) {
+	if p, ok := s.platforms[id]; ok {
+		return p, nil
+	}
+	return nil, platform.ErrPlatformNotFound
+}
+
+func TestRepository_Platforms(t *testing.T) {
+	db := setupSessionDB(t)
+	ctx := context.Background()
+	for _, model := range []interface{}{(*stream.Stream)(nil), (*stream.StreamPlatform)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := stream.NewRepository(db, testCipher(t))
+
+	s := &stream.Stream{ID: uuid.New(), Name: "Lofi", DesiredState: stream.DesiredStopped}
+	other := &stream.Stream{ID: uuid.New(), Name: "Other", DesiredState: stream.DesiredStopped}
+	assert.NoError(t, repo.Create(ctx, s))
+	assert.NoError(t, repo.Create(ctx, other))
+
+	youtube, twitch, kick := uuid.New(), uuid.New(), uuid.New()
+
+	t.Run("Links keep their order", func(t *testing.T) {
+		assert.NoError(t, repo.SetPlatforms(ctx, s.ID, []uuid.UUID{twitch, youtube}))
+		assert.NoError(t, repo.SetPlatforms(ctx, other.ID, []uuid.UUID{kick}))
+
+		found, err := repo.GetByID(ctx, s.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{twitch, youtube}, found.PlatformIDs)
+
+		list, err := repo.List(ctx, uuid.Nil)
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+		for _, st := range list {
+			if st.ID == other.ID {
+				assert.Equal(t, []uuid.UUID{kick}, st.PlatformIDs)
+			}
+		}
+	})
+
+	t.Run("Setting replaces the links", func(t *testing.T) {
+		assert.NoError(t, repo.SetPlatforms(ctx, s.ID, []uuid.UUID{youtube}))
+
+		found, err := repo.GetByID(ctx, s.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{youtube}, found.PlatformIDs)
+	})
+
+	t.Run("Deleting the stream removes its links", func(t *testing.T) {
+		assert.NoError(t, repo.Delete(ctx, s.ID))
+
+		count, err := db.NewSelect().Model((*stream.StreamPlatform)(nil)).Where("stream_id = ?", s.ID).Count(ctx)
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+
+		found, err := repo.GetByID(ctx, other.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{kick}, found.PlatformIDs)
+	})
+}
+
+func TestService_LinksOnlyOwnedPlatforms(t *testing.T) {
+	ctx := context.Background()
+	owner := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	other := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	admin := &auth.User{ID: uuid.New(), Role: auth.RoleAdmin}
+	mine := &platform.Platform{ID: uuid.New(), UserID: owner.ID}
+	theirs := &platform.Platform{ID: uuid.New(), UserID: other.ID}
+	platforms := &platformStore{platforms: map[uuid.UUID]*platform.Platform{mine.ID: mine, theirs.ID: theirs}}
+
+	update := func(caller *auth.User, ids ...uuid.UUID) (*MockStreamRepository, error) {
+		repo := new(MockStreamRepository)
+		st := &stream.Stream{ID: uuid.New(), UserID: owner.ID, CropOffset: 50, SourceType: stream.SourceFile}
+		repo.On("GetByID", ctx, st.ID).Return(st, nil)
+		repo.On("Update", ctx, st).Return(nil)
+		repo.On("SetPlatforms", ctx, st.ID, ids).Return(nil)
+		svc := stream.NewService(repo, nil, nil, nil, nil, platforms, nil, stream.NewProcessManager(), nil)
+		_, err := svc.UpdateStream(ctx, caller, st.ID, stream.UpdateStreamDTO{PlatformIDs: ids})
+		return repo, err
+	}
+
+	t.Run("Owners link their own platforms", func(t *testing.T) {
+		repo, err := update(owner, mine.ID)
+		assert.NoError(t, err)
+		repo.AssertCalled(t, "SetPlatforms", ctx, mock.Anything, []uuid.UUID{mine.ID})
+	})
+
+	t.Run("Someone else's platform is not found", func(t *testing.T) {
+		repo, err := update(owner, mine.ID, theirs.ID)
+		assert.ErrorIs(t, err, platform.ErrPlatformNotFound)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "SetPlatforms", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Admins may link any platform", func(t *testing.T) {
+		_, err := update(admin, mine.ID, theirs.ID)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func readProgressFixture(t *testing.T, name string) []*ffmpeg.Progress {
+	var reports []*ffmpeg.Progress
+	err := ffmpeg.ReadProgress(strings.NewReader(readFixture(t, name)), func(p *ffmpeg.Progress) {
+		reports = append(reports, p)
+	})
+	assert.NoError(t, err)
+	return reports
+}
+
+func TestReadProgress(t *testing.T) {
+	reports := readProgressFixture(t, "progress.txt")
+	assert.Len(t, reports, 3)
+
+	t.Run("Unknown values stay zero", func(t *testing.T) {
+		first := reports[0]
+		assert.Zero(t, first.OutTimeUS)
+		assert.Zero(t, first.Speed)
+		assert.Equal(t, "N/A", first.Bitrate)
+		assert.Equal(t, int64(48), first.TotalSize)
+		assert.False(t, first.End)
+	})
+
+	t.Run("A block carries every field", func(t *testing.T) {
+		p := reports[1]
+		assert.Equal(t, 1500, p.Frame)
+		assert.Equal(t, 29.97, p.FPS)
+		assert.Equal(t, "2612.4kbits/s", p.Bitrate)
+		assert.Equal(t, int64(16327680), p.TotalSize)
+		assert.Equal(t, int64(50000000), p.OutTimeUS)
+		assert.Equal(t, 50.0, p.Seconds())
+		assert.Equal(t, "00:00:50.000000", p.Time)
+		assert.Equal(t, 2, p.DupFrames)
+		assert.Equal(t, 7, p.DropFrames)
+		assert.Equal(t, 1.01, p.Speed)
+		assert.Equal(t, map[string]float64{"0:0": 23, "1:0": 28}, p.Quality)
+	})
+
+	t.Run("The last block is marked", func(t *testing.T) {
+		last := reports[2]
+		assert.True(t, last.End)
+		assert.Equal(t, 1.0, last.Speed)
+	})
+
+	t.Run("Older builds only report out_time_ms", func(t *testing.T) {
+		reports := readProgressFixture(t, "progress_legacy.txt")
+		assert.Len(t, reports, 1)
+		assert.Equal(t, 25.0, reports[0].Seconds())
+		assert.Equal(t, 1, reports[0].DropFrames)
+	})
+}
+
+func TestParseBitrate(t *testing.T) {
+	assert.InDelta(t, 1677.7, ffmpeg.ParseBitrate("1677.7kbits/s"), 0.001)
+	assert.InDelta(t, 2500.0, ffmpeg.ParseBitrate("2.5Mbits/s"), 0.001)
+	assert.Zero(t, ffmpeg.ParseBitrate("N/A"))
+}
+
+func TestCommandBuilder_Progress(t *testing.T) {
+	args, err := ffmpeg.NewCommandBuilder().
+		WithInput("in.mp4").
+		WithRenditions([]ffmpeg.Rendition{{Resolution: "720x1280"}}).
+		WithProgress(ffmpeg.ProgressPipe(1)).
+		WithPipeOutput().
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-nostats", "-progress", "pipe:4"}, args[:3])
+
+	relay, err := ffmpeg.BuildRelayArgs(ffmpeg.Output{URL: "rtmp://live.example.com/app/key"})
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join(relay, " "), "-nostats -progress pipe:1")
+}
@@ -0,0 +1,67 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePlaylist(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("An empty queue is rejected", func(t *testing.T) {
+		path := filepath.Join(dir, "empty.txt")
+		assert.Error(t, ffmpeg.WritePlaylist(path, nil))
+		assert.NoFileExists(t, path)
+	})
+
+	t.Run("Entries are absolute and quoted", func(t *testing.T) {
+		path := filepath.Join(dir, "nested", "playlist.txt")
+		assert.NoError(t, ffmpeg.WritePlaylist(path, []string{"/videos/a.mp4", "/videos/it's.mp4"}))
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "ffconcat version 1.0\nfile '/videos/a.mp4'\nfile '/videos/it'\\''s.mp4'\n", string(data))
+	})
+
+	t.Run("Relative paths are resolved", func(t *testing.T) {
+		path := filepath.Join(dir, "relative.txt")
+		assert.NoError(t, ffmpeg.WritePlaylist(path, []string{"uploads/b.mp4"}))
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		abs, _ := filepath.Abs("uploads/b.mp4")
+		assert.Contains(t, string(data), "file '"+abs+"'\n")
+	})
+}
+
+func TestReadProgress_MalformedTimestamps(t *testing.T) {
+	tests := []struct {
+		name    string
+		block   string
+		seconds float64
+	}{
+		{"Not available yet", "out_time_us=N/A\nout_time_ms=N/A\n", 0},
+		{"Garbage", "out_time_us=12ab\n", 0},
+		{"Empty value", "out_time_us=\n", 0},
+		{"Missing separator", "out_time_us 5000000\n", 0},
+		{"Surrounding whitespace", "  out_time_us= 5000000 \n", 5},
+		{"Negative before the first frame", "out_time_us=-23220\n", -0.02322},
+		{"Legacy key after an unknown value", "out_time_us=N/A\nout_time_ms=7500000\n", 7.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reports []*ffmpeg.Progress
+			err := ffmpeg.ReadProgress(strings.NewReader(tt.block+"progress=continue\n"), func(p *ffmpeg.Progress) {
+				reports = append(reports, p)
+			})
+			assert.NoError(t, err)
+			assert.Len(t, reports, 1)
+			assert.InDelta(t, tt.seconds, reports[0].Seconds(), 1e-9)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/codewithwan/gostreamix/internal/domain/video"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandBuilder_Reframe(t *testing.T) {
+	t.Run("Offset crop places the window", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithResolution("1080x1920").
+			WithFit(ffmpeg.FitOffset, 25).
+			WithPipeOutput().
+			Build()
+		assert.NoError(t, err)
+		assert.Contains(t, strings.Join(args, " "),
+			"-vf scale=1080:1920:force_original_aspect_ratio=increase,crop=1080:1920:(iw-ow)*25/100:(ih-oh)*25/100,setsar=1")
+	})
+
+	t.Run("Blur fills the frame with a blurred copy", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithResolution("1920x1080").
+			WithRenditions([]ffmpeg.Rendition{{Resolution: "1080x1920", Fit: ffmpeg.FitBlur}}).
+			WithPipeOutput().
+			Build()
+		assert.NoError(t, err)
+		assert.Contains(t, strings.Join(args, " "),
+			"[src1]split=2[r1bg][r1fg];"+
+				"[r1bg]scale=1080:1920:force_original_aspect_ratio=increase,crop=1080:1920,boxblur=20:5[r1blurred];"+
+				"[r1fg]scale=1080:1920:force_original_aspect_ratio=decrease[r1fitted];"+
+				"[r1blurred][r1fitted]overlay=(W-w)/2:(H-h)/2,setsar=1[r1v0]")
+	})
+
+	t.Run("Frame preview renders one image", func(t *testing.T) {
+		args := ffmpeg.FramePreviewArgs("in.mp4", "out.jpg", 12, ffmpeg.Rendition{Resolution: "1080x1920", Fit: ffmpeg.FitCrop})
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "-ss 12 -i in.mp4 -vf scale=1080:1920:force_original_aspect_ratio=increase,crop=1080:1920,setsar=1")
+		assert.Contains(t, cmd, "-frames:v 1")
+		assert.True(t, strings.HasSuffix(cmd, "out.jpg"))
+	})
+
+	t.Run("Capabilities cover the blur filters", func(t *testing.T) {
+		caps := fixtureCapabilities(t)
+		assert.EqualError(t, caps.CheckRenditions([]ffmpeg.Rendition{{Resolution: "1080x1920", Fit: ffmpeg.FitBlur}}),
+			"filter split is not supported by the installed ffmpeg")
+	})
+}
+
+func TestCheckReframe(t *testing.T) {
+	vertical := ffmpeg.Rendition{Resolution: "1080x1920", Fit: ffmpeg.FitCrop}
+	offset := ffmpeg.Rendition{Resolution: "1080x1920", Fit: ffmpeg.FitOffset, CropOffset: 30}
+	blur := ffmpeg.Rendition{Resolution: "1080x1920", Fit: ffmpeg.FitBlur}
+
+	tests := []struct {
+		name      string
+		rendition ffmpeg.Rendition
+		source    string
+		valid     bool
+	}{
+		{"center crop of 1080p", vertical, "1920x1080", true},
+		{"offset crop of 1080p", offset, "1920x1080", true},
+		{"blur of 720p", blur, "1280x720", true},
+		{"scale is never checked", ffmpeg.Rendition{Resolution: "1080x1920"}, "", true},
+		{"unknown source", vertical, "", false},
+		{"offset of a vertical source", offset, "1080x1920", false},
+		{"crop of 480p upscales too far", vertical, "854x480", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := stream.CheckReframe(tt.rendition, &video.Metadata{Resolution: tt.source})
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, stream.ErrReframeIncompatible)
+			}
+		})
+	}
+}
+
+func TestPlanRenditions_StreamFit(t *testing.T) {
+	s := &stream.Stream{Resolution: "1080x1920", Bitrate: 4000, Fit: ffmpeg.FitOffset, CropOffset: 70}
+	destinations := []stream.Destination{
+		{Protocol: stream.ProtocolRTMP, URL: "rtmp://push-rtmp-global.tiktok.com/live/tt"},
+		{Protocol: stream.ProtocolRTMP, URL: "rtmp://shorts.example.com/live/sh", Encoding: &stream.Encoding{Fit: ffmpeg.FitBlur, CropOffset: 10}},
+	}
+
+	renditions := stream.PlanRenditions(s, destinations)
+	assert.Len(t, renditions, 2)
+	assert.Equal(t, ffmpeg.Rendition{Resolution: "1080x1920", Bitrate: 4000, Fit: ffmpeg.FitOffset, CropOffset: 70}, renditions[0].Settings)
+	assert.Equal(t, ffmpeg.Rendition{Resolution: "1080x1920", Bitrate: 4000, Fit: ffmpeg.FitBlur}, renditions[1].Settings)
+}
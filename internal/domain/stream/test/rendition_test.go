@@ -0,0 +1,101 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoding_Validate(t *testing.T) {
+	assert.NoError(t, stream.Encoding{Resolution: "720x1280", Bitrate: 3000, FPS: 30, Fit: ffmpeg.FitCrop}.Validate())
+	assert.NoError(t, stream.Encoding{}.Validate())
+
+	assert.ErrorIs(t, stream.Encoding{Resolution: "720p"}.Validate(), stream.ErrInvalidDestination)
+	assert.ErrorIs(t, stream.Encoding{Resolution: "721x1280"}.Validate(), stream.ErrInvalidDestination)
+	assert.ErrorIs(t, stream.Encoding{Bitrate: 100}.Validate(), stream.ErrInvalidDestination)
+	assert.ErrorIs(t, stream.Encoding{FPS: 120}.Validate(), stream.ErrInvalidDestination)
+	assert.ErrorIs(t, stream.Encoding{Fit: "stretch"}.Validate(), stream.ErrInvalidDestination)
+}
+
+func TestPlanRenditions(t *testing.T) {
+	s := &stream.Stream{Resolution: "1920x1080", Bitrate: 6000, FPS: 30}
+	vertical := &stream.Encoding{Resolution: "720x1280", Bitrate: 3000, Fit: ffmpeg.FitCrop}
+	destinations := []stream.Destination{
+		{Protocol: stream.ProtocolRTMP, URL: "rtmp://a.rtmp.youtube.com/live2/yt"},
+		{Protocol: stream.ProtocolRTMP, URL: "rtmp://push-rtmp-global.tiktok.com/live/tt", Encoding: vertical},
+		{Protocol: stream.ProtocolRTMP, URL: "rtmp://live.twitch.tv/app/tw", Encoding: &stream.Encoding{Bitrate: 6000}},
+		{Protocol: stream.ProtocolRTMP, URL: "rtmp://shorts.example.com/live/sh", Encoding: vertical},
+	}
+
+	renditions := stream.PlanRenditions(s, destinations)
+	assert.Len(t, renditions, 2)
+	assert.Equal(t, ffmpeg.Rendition{Resolution: "1920x1080", Bitrate: 6000, FPS: 30}, renditions[0].Settings)
+	assert.Equal(t, []int{0, 2}, renditions[0].Destinations)
+	assert.Equal(t, ffmpeg.Rendition{Resolution: "720x1280", Bitrate: 3000, FPS: 30, Fit: ffmpeg.FitCrop}, renditions[1].Settings)
+	assert.Equal(t, []int{1, 3}, renditions[1].Destinations)
+}
+
+func TestCommandBuilder_Renditions(t *testing.T) {
+	t.Run("Each rendition is encoded once and piped separately", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithResolution("1920x1080").
+			WithBitrate(6000).
+			WithRenditions([]ffmpeg.Rendition{{Resolution: "720x1280", Bitrate: 3000, FPS: 30, Fit: ffmpeg.FitCrop}}).
+			WithPipeOutput().
+			WithPreview("data/preview/abc").
+			Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "-filter_complex [0:v]split=2[src0][src1];[src0]scale=1920x1080[v0];"+
+			"[src1]scale=720:1280:force_original_aspect_ratio=increase,crop=720:1280,setsar=1[r1v0];"+
+			"[v0]split=2[vout][vsplit];[vsplit]scale=-2:360[vpreview]")
+
+		main, rest, found := strings.Cut(cmd, "pipe:1 ")
+		assert.True(t, found)
+		assert.Contains(t, main, "-b:v 6000k")
+		assert.Contains(t, main, "-map [vout] -map 0:a")
+
+		vertical, preview, found := strings.Cut(rest, "pipe:3 ")
+		assert.True(t, found)
+		assert.Contains(t, vertical, "-map [r1v0] -map 0:a -c:v libx264")
+		assert.Contains(t, vertical, "-b:v 3000k")
+		assert.Contains(t, vertical, "-c:a aac")
+		assert.Contains(t, preview, "-map [vpreview]")
+	})
+
+	t.Run("Overlays are drawn on every rendition", func(t *testing.T) {
+		args, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithOverlays([]ffmpeg.Overlay{{Type: ffmpeg.OverlayImage, ImagePath: "logo.png"}}).
+			WithRenditions([]ffmpeg.Rendition{{Resolution: "720x1280", Fit: ffmpeg.FitPad}}).
+			WithPipeOutput().
+			Build()
+		assert.NoError(t, err)
+
+		cmd := strings.Join(args, " ")
+		assert.Contains(t, cmd, "[1:v]format=rgba[img1];[v0][img1]overlay=x=W-w-0:y=0[v1]")
+		assert.Contains(t, cmd, "pad=720:1280:(ow-iw)/2:(oh-ih)/2,setsar=1[r1v0];[1:v]format=rgba[r1img1];[r1v0][r1img1]overlay")
+		assert.Contains(t, cmd, "-map [r1v1]")
+	})
+
+	t.Run("Renditions need piped output", func(t *testing.T) {
+		_, err := ffmpeg.NewCommandBuilder().
+			WithInput("in.mp4").
+			WithDestinations([]ffmpeg.Output{{URL: "rtmp://live.example.com/app/key"}}).
+			WithRenditions([]ffmpeg.Rendition{{Resolution: "720x1280"}}).
+			Build()
+		assert.Error(t, err)
+	})
+
+	t.Run("Capabilities cover the fit filters", func(t *testing.T) {
+		caps := fixtureCapabilities(t)
+		assert.NoError(t, caps.CheckRenditions([]ffmpeg.Rendition{{Resolution: "1280x720"}}))
+		assert.EqualError(t, caps.CheckRenditions([]ffmpeg.Rendition{{Resolution: "1280x720", Fit: ffmpeg.FitCrop}}),
+			"filter crop is not supported by the installed ffmpeg")
+	})
+}
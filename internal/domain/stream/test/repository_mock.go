@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStreamRepository struct {
+	mock.Mock
+}
+
+func (m *MockStreamRepository) Create(ctx context.Context, s *stream.Stream) error {
+	args := m.Called(ctx, s)
+	return args.Error(0)
+}
+
+func (m *MockStreamRepository) GetByID(ctx context.Context, id uuid.UUID) (*stream.Stream, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*stream.Stream), args.Error(1)
+}
+
+func (m *MockStreamRepository) FindByIngestKey(ctx context.Context, key string) (*stream.Stream, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*stream.Stream), args.Error(1)
+}
+
+func (m *MockStreamRepository) List(ctx context.Context, ownerID uuid.UUID) ([]*stream.Stream, error) {
+	args := m.Called(ctx, ownerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*stream.Stream), args.Error(1)
+}
+
+func (m *MockStreamRepository) Update(ctx context.Context, s *stream.Stream) error {
+	args := m.Called(ctx, s)
+	return args.Error(0)
+}
+
+func (m *MockStreamRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStreamRepository) ListByDesiredState(ctx context.Context, state string) ([]*stream.Stream, error) {
+	args := m.Called(ctx, state)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*stream.Stream), args.Error(1)
+}
+
+func (m *MockStreamRepository) SetDesiredState(ctx context.Context, id uuid.UUID, state string) error {
+	args := m.Called(ctx, id, state)
+	return args.Error(0)
+}
+
+func (m *MockStreamRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status, lastError string) error {
+	args := m.Called(ctx, id, status, lastError)
+	return args.Error(0)
+}
+
+func (m *MockStreamRepository) GetProgram(ctx context.Context, streamID uuid.UUID) (*stream.StreamProgram, error) {
+	args := m.Called(ctx, streamID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*stream.StreamProgram), args.Error(1)
+}
+
+func (m *MockStreamRepository) UpsertProgram(ctx context.Context, p *stream.StreamProgram) error {
+	args := m.Called(ctx, p)
+	return args.Error(0)
+}
+
+func (m *MockStreamRepository) SetPlatforms(ctx context.Context, streamID uuid.UUID, platformIDs []uuid.UUID) error {
+	args := m.Called(ctx, streamID, platformIDs)
+	return args.Error(0)
+}
@@ -0,0 +1,177 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCipher(t *testing.T) *secret.Cipher {
+	cipher, err := secret.New("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher
+}
+
+func TestRepository_UpdateKeepsRuntimeState(t *testing.T) {
+	db := setupSessionDB(t)
+	ctx := context.Background()
+	for _, model := range []interface{}{(*stream.Stream)(nil), (*stream.StreamPlatform)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := stream.NewRepository(db, testCipher(t))
+
+	owner := uuid.New()
+	s := &stream.Stream{ID: uuid.New(), UserID: owner, Name: "Before", Status: string(stream.StatusStopped), DesiredState: stream.DesiredStopped}
+	assert.NoError(t, repo.Create(ctx, s))
+
+	// The edit was loaded before the pipeline started the stream.
+	edited, err := repo.GetByID(ctx, s.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.UpdateStatus(ctx, s.ID, string(stream.StatusRunning), ""))
+	assert.NoError(t, repo.SetDesiredState(ctx, s.ID, stream.DesiredRunning))
+
+	edited.Name = "After"
+	edited.UserID = uuid.New()
+	assert.NoError(t, repo.Update(ctx, edited))
+
+	found, err := repo.GetByID(ctx, s.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "After", found.Name)
+	assert.Equal(t, string(stream.StatusRunning), found.Status)
+	assert.Equal(t, stream.DesiredRunning, found.DesiredState)
+	assert.Equal(t, owner, found.UserID)
+}
+
+func TestRepository_SealsCredentials(t *testing.T) {
+	db := setupSessionDB(t)
+	ctx := context.Background()
+	for _, model := range []interface{}{(*stream.Stream)(nil), (*stream.StreamProgram)(nil), (*stream.StreamPlatform)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := stream.NewRepository(db, testCipher(t))
+
+	target := "rtmp://a.rtmp.youtube.com/live2/yt-secret-key"
+	srt := stream.Destination{
+		Protocol: stream.ProtocolSRT,
+		URL:      "srt://ingest.example.com:9000",
+		Options:  stream.DestinationOptions{Passphrase: "srt-passphrase"},
+	}
+	s := &stream.Stream{
+		ID:           uuid.New(),
+		Name:         "Live",
+		RTMPTargets:  []string{target},
+		Destinations: []stream.Destination{srt},
+		SourceType:   stream.SourceIngest,
+		IngestKey:    "live_0123456789abcdef",
+		DesiredState: stream.DesiredStopped,
+	}
+	assert.NoError(t, repo.Create(ctx, s))
+	assert.Equal(t, target, s.RTMPTargets[0], "the caller keeps the plaintext")
+	assert.NoError(t, repo.UpsertProgram(ctx, &stream.StreamProgram{ID: uuid.New(), StreamID: s.ID, RTMPTargets: []string{target}}))
+
+	t.Run("Stored values are sealed", func(t *testing.T) {
+		var row struct {
+			Targets      string `bun:"rtmp_targets"`
+			Destinations string `bun:"destinations"`
+			IngestKey    string `bun:"ingest_key"`
+		}
+		err := db.NewSelect().Table("streams").Column("rtmp_targets", "destinations", "ingest_key").Where("id = ?", s.ID).Scan(ctx, &row)
+		assert.NoError(t, err)
+		assert.NotContains(t, row.Targets, "yt-secret-key")
+		assert.NotContains(t, row.Destinations, "srt-passphrase")
+		assert.Contains(t, row.Destinations, "srt://ingest.example.com:9000")
+		assert.True(t, secret.IsSealed(row.IngestKey))
+
+		var programTargets string
+		err = db.NewSelect().Table("stream_programs").Column("rtmp_targets").Where("stream_id = ?", s.ID).Scan(ctx, &programTargets)
+		assert.NoError(t, err)
+		assert.NotContains(t, programTargets, "yt-secret-key")
+	})
+
+	t.Run("Reads return plaintext", func(t *testing.T) {
+		found, err := repo.GetByID(ctx, s.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{target}, found.RTMPTargets)
+		assert.Equal(t, []stream.Destination{srt}, found.Destinations)
+		assert.Equal(t, s.IngestKey, found.IngestKey)
+
+		program, err := repo.GetProgram(ctx, s.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{target}, program.RTMPTargets)
+	})
+
+	t.Run("Ingest keys are found by their hash", func(t *testing.T) {
+		found, err := repo.FindByIngestKey(ctx, s.IngestKey)
+		assert.NoError(t, err)
+		assert.Equal(t, s.ID, found.ID)
+
+		_, err = repo.FindByIngestKey(ctx, "live_unknown")
+		assert.Error(t, err)
+		_, err = repo.FindByIngestKey(ctx, "")
+		assert.Error(t, err)
+	})
+}
+
+func TestStream_Masked(t *testing.T) {
+	ctx := context.Background()
+	db := setupSessionDB(t)
+	for _, model := range []interface{}{(*stream.Stream)(nil), (*stream.StreamProgram)(nil), (*stream.StreamPlatform)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := stream.NewRepository(db, testCipher(t))
+	svc := stream.NewService(repo, nil, nil, nil, nil, nil, nil, stream.NewProcessManager(), nil)
+	owner := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+
+	s := &stream.Stream{
+		ID:          uuid.New(),
+		UserID:      owner.ID,
+		Name:        "Live",
+		RTMPTargets: []string{"rtmp://live.twitch.tv/app/tw-secret-key"},
+		Destinations: []stream.Destination{{
+			Protocol: stream.ProtocolSRT,
+			URL:      "srt://ingest.example.com:9000",
+			Options:  stream.DestinationOptions{Passphrase: "srt-passphrase"},
+		}},
+		CropOffset:   50,
+		SourceType:   stream.SourceIngest,
+		IngestKey:    "live_0123456789abcdef",
+		DesiredState: stream.DesiredStopped,
+	}
+	assert.NoError(t, repo.Create(ctx, s))
+
+	masked := s.Masked()
+
+	t.Run("Credentials are hidden", func(t *testing.T) {
+		assert.Equal(t, []string{"rtmp://live.twitch.tv/app/****"}, masked.RTMPTargets)
+		assert.Equal(t, "********rase", masked.Destinations[0].Options.Passphrase)
+		assert.Equal(t, "********cdef", masked.IngestKey)
+		assert.Equal(t, "srt-passphrase", s.Destinations[0].Options.Passphrase, "the stream itself is unchanged")
+	})
+
+	t.Run("Masked values are saved back unchanged", func(t *testing.T) {
+		_, err := svc.UpdateStream(ctx, owner, s.ID, stream.UpdateStreamDTO{
+			Name:         s.Name,
+			RTMPTargets:  append(masked.RTMPTargets, "rtmp://example.com/live/new-key"),
+			Destinations: masked.Destinations,
+		})
+		assert.NoError(t, err)
+
+		found, err := repo.GetByID(ctx, s.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"rtmp://live.twitch.tv/app/tw-secret-key", "rtmp://example.com/live/new-key"}, found.RTMPTargets)
+		assert.Equal(t, s.Destinations, found.Destinations)
+	})
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/codewithwan/gostreamix/internal/domain/stream/ffmpeg"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestartPolicy_Backoff(t *testing.T) {
+	policy := stream.RestartPolicy{MaxAttempts: 6, BackoffSec: 2, MaxBackoffSec: 20, ResetWindowSec: 300}
+
+	assert.Equal(t, 2*time.Second, policy.Backoff(1))
+	assert.Equal(t, 4*time.Second, policy.Backoff(2))
+	assert.Equal(t, 8*time.Second, policy.Backoff(3))
+	assert.Equal(t, 16*time.Second, policy.Backoff(4))
+	assert.Equal(t, 20*time.Second, policy.Backoff(5))
+	assert.Equal(t, 20*time.Second, policy.Backoff(50))
+}
+
+func TestRestartPolicy_Validate(t *testing.T) {
+	t.Run("Default policy is valid", func(t *testing.T) {
+		assert.NoError(t, stream.DefaultRestartPolicy().Validate())
+	})
+
+	t.Run("Disabled policy is valid", func(t *testing.T) {
+		assert.NoError(t, stream.RestartPolicy{}.Validate())
+	})
+
+	t.Run("Negative values are rejected", func(t *testing.T) {
+		err := stream.RestartPolicy{MaxAttempts: -1}.Validate()
+		assert.ErrorIs(t, err, stream.ErrInvalidRestartPolicy)
+	})
+
+	t.Run("Max backoff below initial backoff is rejected", func(t *testing.T) {
+		err := stream.RestartPolicy{MaxAttempts: 3, BackoffSec: 10, MaxBackoffSec: 5}.Validate()
+		assert.ErrorIs(t, err, stream.ErrInvalidRestartPolicy)
+	})
+}
+
+func TestProcess_NextRestart(t *testing.T) {
+	pm := stream.NewProcessManager()
+	proc, err := pm.Register(uuid.New(), nil)
+	assert.NoError(t, err)
+	policy := stream.RestartPolicy{MaxAttempts: 2, BackoffSec: 1, MaxBackoffSec: 10, ResetWindowSec: 300}
+
+	delay, ok := proc.NextRestart(policy)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, delay)
+
+	delay, ok = proc.NextRestart(policy)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, delay)
+
+	_, ok = proc.NextRestart(policy)
+	assert.False(t, ok)
+	assert.Equal(t, 2, proc.RestartCount())
+}
+
+func TestProcess_Stalled(t *testing.T) {
+	pm := stream.NewProcessManager()
+	proc, err := pm.Register(uuid.New(), nil)
+	assert.NoError(t, err)
+
+	assert.False(t, proc.Stalled(time.Hour, time.Hour), "within the startup grace")
+	time.Sleep(5 * time.Millisecond)
+	assert.True(t, proc.Stalled(time.Hour, time.Millisecond), "no progress since launch")
+
+	proc.UpdateProgress(&ffmpeg.Progress{Frame: 1})
+	assert.False(t, proc.Stalled(time.Hour, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	assert.True(t, proc.Stalled(time.Millisecond, time.Hour), "progress stopped")
+}
+
+func TestProcessManager_Register(t *testing.T) {
+	pm := stream.NewProcessManager()
+	id := uuid.New()
+
+	proc, err := pm.Register(id, nil)
+	assert.NoError(t, err)
+
+	_, err = pm.Register(id, nil)
+	assert.ErrorIs(t, err, stream.ErrStreamAlreadyRunning)
+	found, ok := pm.Get(id)
+	assert.True(t, ok)
+	assert.Same(t, proc, found, "a second start must not replace the running stream")
+
+	pm.Unregister(id)
+	_, err = pm.Register(id, nil)
+	assert.NoError(t, err)
+}
+
+func TestStream_SlowAfter(t *testing.T) {
+	assert.Equal(t, stream.DefaultSlowAfterSec*time.Second, (&stream.Stream{}).SlowAfter())
+	assert.Equal(t, 90*time.Second, (&stream.Stream{SlowAfterSec: 90}).SlowAfter())
+}
+
+func TestProcess_TrackSpeed(t *testing.T) {
+	pm := stream.NewProcessManager()
+	proc, err := pm.Register(uuid.New(), nil)
+	assert.NoError(t, err)
+	slowAfter := 50 * time.Millisecond
+
+	assert.False(t, proc.TrackSpeed(0.8, slowAfter), "slow but not for long")
+	time.Sleep(slowAfter + 10*time.Millisecond)
+	assert.False(t, proc.TrackSpeed(0.8, stream.DefaultSlowAfterSec*time.Second), "the default threshold has not passed")
+	assert.True(t, proc.TrackSpeed(0.8, slowAfter))
+	assert.False(t, proc.TrackSpeed(0.7, slowAfter), "reported once")
+
+	assert.False(t, proc.TrackSpeed(1.0, slowAfter), "back to real time")
+	assert.False(t, proc.TrackSpeed(0.9, slowAfter), "a new dip starts over")
+}
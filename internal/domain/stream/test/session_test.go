@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	_ "github.com/glebarez/go-sqlite"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+)
+
+func setupSessionDB(t *testing.T) *bun.DB {
+	sqldb, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqldb.SetMaxOpenConns(1)
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+
+	ctx := context.Background()
+	for _, model := range []interface{}{(*stream.Session)(nil), (*stream.SessionMetric)(nil)} {
+		if _, err := db.NewCreateTable().Model(model).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestSessionRepository(t *testing.T) {
+	db := setupSessionDB(t)
+	repo := stream.NewSessionRepository(db)
+	ctx := context.Background()
+	streamID := uuid.New()
+	start := time.Now().UTC().Add(-time.Hour)
+
+	sessions := make([]*stream.Session, 3)
+	for i := range sessions {
+		sessions[i] = &stream.Session{ID: uuid.New(), StreamID: streamID, StartedAt: start.Add(time.Duration(i) * time.Minute)}
+		assert.NoError(t, repo.CreateSession(ctx, sessions[i]))
+		assert.NoError(t, repo.AddMetric(ctx, &stream.SessionMetric{SessionID: sessions[i].ID, FPS: 30, BitrateKbps: 2500}))
+	}
+
+	t.Run("Update and list newest first", func(t *testing.T) {
+		stopped := time.Now().UTC()
+		sessions[2].StoppedAt = &stopped
+		sessions[2].ExitReason = stream.ExitFailed
+		sessions[2].Args = []string{"-i", "in.mp4"}
+		sessions[2].LastOutput = []string{"Connection refused"}
+		assert.NoError(t, repo.UpdateSession(ctx, sessions[2]))
+
+		list, err := repo.ListSessions(ctx, streamID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, list, 3)
+		assert.Equal(t, sessions[2].ID, list[0].ID)
+		assert.Equal(t, stream.ExitFailed, list[0].ExitReason)
+		assert.Equal(t, []string{"Connection refused"}, list[0].LastOutput)
+		assert.NotNil(t, list[0].StoppedAt)
+	})
+
+	t.Run("Open sessions are closed as interrupted", func(t *testing.T) {
+		assert.NoError(t, repo.CloseOpenSessions(ctx, stream.ExitInterrupted))
+
+		found, err := repo.GetSession(ctx, sessions[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, stream.ExitInterrupted, found.ExitReason)
+		assert.NotNil(t, found.StoppedAt)
+
+		found, err = repo.GetSession(ctx, sessions[2].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, stream.ExitFailed, found.ExitReason)
+	})
+
+	t.Run("Pruning drops the oldest sessions and their metrics", func(t *testing.T) {
+		assert.NoError(t, repo.PruneSessions(ctx, streamID, 2))
+
+		list, err := repo.ListSessions(ctx, streamID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+
+		metrics, err := repo.ListMetrics(ctx, sessions[0].ID)
+		assert.NoError(t, err)
+		assert.Empty(t, metrics)
+		metrics, err = repo.ListMetrics(ctx, sessions[1].ID)
+		assert.NoError(t, err)
+		assert.Len(t, metrics, 1)
+		assert.Equal(t, 2500.0, metrics[0].BitrateKbps)
+	})
+
+	t.Run("Deleting removes every session", func(t *testing.T) {
+		assert.NoError(t, repo.DeleteSessions(ctx, streamID))
+
+		list, err := repo.ListSessions(ctx, streamID, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, list)
+	})
+}
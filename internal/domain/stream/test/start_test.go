@@ -0,0 +1,66 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/stream"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// stubPipeline records the desired state the repository held when the
+// pipeline was started.
+type stubPipeline struct {
+	stream.Pipeline
+	repo      *MockStreamRepository
+	err       error
+	startedIn []string
+}
+
+func (p *stubPipeline) Start(ctx context.Context, s *stream.Stream, plan stream.Plan) error {
+	for _, call := range p.repo.Calls {
+		if call.Method == "SetDesiredState" {
+			p.startedIn = append(p.startedIn, call.Arguments.String(2))
+		}
+	}
+	return p.err
+}
+
+func TestService_StartStreamPersistsDesiredState(t *testing.T) {
+	ctx := context.Background()
+
+	start := func(pipelineErr error) (*MockStreamRepository, *stubPipeline, error) {
+		repo := new(MockStreamRepository)
+		st := &stream.Stream{
+			ID:          uuid.New(),
+			Name:        "Live",
+			SourceType:  stream.SourceIngest,
+			RTMPTargets: []string{"rtmp://live.twitch.tv/app/key"},
+		}
+		repo.On("GetByID", ctx, st.ID).Return(st, nil)
+		repo.On("GetProgram", ctx, st.ID).Return(nil, nil)
+		repo.On("SetDesiredState", ctx, st.ID, stream.DesiredRunning).Return(nil)
+		repo.On("SetDesiredState", ctx, st.ID, stream.DesiredStopped).Return(nil)
+		pipeline := &stubPipeline{repo: repo, err: pipelineErr}
+		svc := stream.NewService(repo, nil, nil, nil, nil, nil, pipeline, stream.NewProcessManager(), nil)
+		return repo, pipeline, svc.StartStream(ctx, st.ID)
+	}
+
+	t.Run("The stream is marked running before it starts", func(t *testing.T) {
+		repo, pipeline, err := start(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{stream.DesiredRunning}, pipeline.startedIn)
+		repo.AssertNotCalled(t, "SetDesiredState", ctx, mock.Anything, stream.DesiredStopped)
+	})
+
+	t.Run("A failed start puts it back to stopped", func(t *testing.T) {
+		repo, _, err := start(errors.New("ffmpeg missing"))
+		assert.Error(t, err)
+		last := repo.Calls[len(repo.Calls)-1]
+		assert.Equal(t, "SetDesiredState", last.Method)
+		assert.Equal(t, stream.DesiredStopped, last.Arguments.String(2))
+	})
+}
@@ -1,6 +1,9 @@
 package video
 
+import "github.com/google/uuid"
+
 type ProcessVideoDTO struct {
+	UserID       uuid.UUID
 	Filename     string
 	OriginalName string
 	Path         string
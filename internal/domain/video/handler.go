@@ -1,11 +1,14 @@
 package video
 
 import (
+	"database/sql"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/shared/middleware"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -35,6 +38,7 @@ type VideoView struct {
 	Size      int64     `json:"size"`
 	Thumbnail string    `json:"thumbnail"`
 	Duration  int       `json:"duration"`
+	HasAudio  bool      `json:"has_audio"`
 }
 
 func ToVideoView(v *Video) VideoView {
@@ -45,6 +49,7 @@ func ToVideoView(v *Video) VideoView {
 		Size:      v.Size,
 		Thumbnail: v.Thumbnail,
 		Duration:  v.Duration,
+		HasAudio:  v.HasAudio,
 	}
 }
 
@@ -57,7 +62,12 @@ func ToVideoViews(videos []*Video) []VideoView {
 }
 
 func (h *Handler) ApiGetVideos(c *fiber.Ctx) error {
-	videos, err := h.svc.GetVideos(c.Context())
+	owner, err := middleware.OwnerFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid owner"})
+	}
+
+	videos, err := h.svc.GetVideos(c.Context(), owner)
 	if err != nil {
 		h.log.Error("Failed to get videos", zap.Error(err))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to retrieve videos"})
@@ -66,6 +76,11 @@ func (h *Handler) ApiGetVideos(c *fiber.Ctx) error {
 }
 
 func (h *Handler) ApiUploadVideo(c *fiber.Ctx) error {
+	u := middleware.GetUser(c, h.authSvc)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
 	file, err := c.FormFile("video")
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "no video file found"})
@@ -85,6 +100,7 @@ func (h *Handler) ApiUploadVideo(c *fiber.Ctx) error {
 	}
 
 	v, err := h.svc.ProcessVideo(c.Context(), ProcessVideoDTO{
+		UserID:       u.ID,
 		Filename:     filename,
 		OriginalName: file.Filename,
 		Path:         path,
@@ -100,11 +116,28 @@ func (h *Handler) ApiUploadVideo(c *fiber.Ctx) error {
 }
 
 func (h *Handler) ApiDeleteVideo(c *fiber.Ctx) error {
+	u := middleware.GetUser(c, h.authSvc)
+	if u == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid video id"})
 	}
 
+	v, err := h.svc.GetVideo(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrVideoNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": ErrVideoNotFound.Error()})
+		}
+		h.log.Error("Failed to get video", zap.Error(err), zap.String("videoID", id.String()))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete video"})
+	}
+	if !u.CanManage(v.UserID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "only the video's owner or an admin can do this"})
+	}
+
 	if err := h.svc.DeleteVideo(c.Context(), id); err != nil {
 		h.log.Error("Failed to delete video", zap.Error(err), zap.String("videoID", id.String()))
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete video"})
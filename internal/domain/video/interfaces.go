@@ -9,12 +9,12 @@ import (
 type Repository interface {
 	Create(ctx context.Context, v *Video) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Video, error)
-	List(ctx context.Context) ([]*Video, error)
+	List(ctx context.Context, ownerID uuid.UUID) ([]*Video, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 type Service interface {
-	GetVideos(ctx context.Context) ([]*Video, error)
+	GetVideos(ctx context.Context, ownerID uuid.UUID) ([]*Video, error)
 	ProcessVideo(ctx context.Context, dto ProcessVideoDTO) (*Video, error)
 	GetVideo(ctx context.Context, id uuid.UUID) (*Video, error)
 	DeleteVideo(ctx context.Context, id uuid.UUID) error
@@ -11,18 +11,27 @@ type Video struct {
 	bun.BaseModel `bun:"table:videos,alias:v"`
 
 	ID           uuid.UUID `bun:",pk,type:text" json:"id"`
+	UserID       uuid.UUID `bun:",nullzero,type:text" json:"user_id"`
 	Filename     string    `bun:",notnull" json:"filename"`
 	OriginalName string    `json:"original_name"`
 	Folder       string    `bun:",notnull,default:''" json:"folder"`
 	Size         int64     `json:"size"`
 	Thumbnail    string    `json:"thumbnail"`
 	Duration     int       `json:"duration"`
+	HasAudio     bool      `bun:",notnull,default:true" json:"has_audio"`
 	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"created_at"`
 }
 
 type Metadata struct {
-	Duration   int
-	Resolution string
-	Bitrate    int
-	FPS        int
+	Duration        int
+	Resolution      string
+	Bitrate         int
+	FPS             int
+	VideoCodec      string
+	PixelFormat     string
+	KeyframeSec     float64
+	HasAudio        bool
+	AudioCodec      string
+	AudioSampleRate int
+	AudioChannels   int
 }
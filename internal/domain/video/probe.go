@@ -31,10 +31,14 @@ func ProbeVideo(path string) (*Metadata, error) {
 			Bitrate  string `json:"bit_rate"`
 		} `json:"format"`
 		Streams []struct {
-			CodecType string `json:"codec_type"`
-			Width     int    `json:"width"`
-			Height    int    `json:"height"`
-			AvgFPS    string `json:"avg_frame_rate"`
+			CodecType  string `json:"codec_type"`
+			CodecName  string `json:"codec_name"`
+			PixFmt     string `json:"pix_fmt"`
+			Width      int    `json:"width"`
+			Height     int    `json:"height"`
+			AvgFPS     string `json:"avg_frame_rate"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
 		} `json:"streams"`
 	}
 
@@ -48,8 +52,13 @@ func ProbeVideo(path string) (*Metadata, error) {
 	b, _ := strconv.Atoi(data.Format.Bitrate)
 	meta.Bitrate = b / 1000
 
+	videoFound, audioFound := false, false
 	for _, s := range data.Streams {
-		if s.CodecType == "video" {
+		switch {
+		case s.CodecType == "video" && !videoFound:
+			videoFound = true
+			meta.VideoCodec = s.CodecName
+			meta.PixelFormat = s.PixFmt
 			meta.Resolution = fmt.Sprintf("%dx%d", s.Width, s.Height)
 			if s.AvgFPS != "" && s.AvgFPS != "0/0" {
 				parts := strings.Split(s.AvgFPS, "/")
@@ -61,13 +70,65 @@ func ProbeVideo(path string) (*Metadata, error) {
 					}
 				}
 			}
-			break
+		case s.CodecType == "audio" && !audioFound:
+			audioFound = true
+			meta.HasAudio = true
+			meta.AudioCodec = s.CodecName
+			meta.AudioSampleRate, _ = strconv.Atoi(s.SampleRate)
+			meta.AudioChannels = s.Channels
 		}
 	}
 
+	if videoFound {
+		meta.KeyframeSec = probeKeyframeInterval(path)
+	}
+
 	return meta, nil
 }
 
+// keyframeScanSec bounds how much of the file is read to measure the GOP.
+const keyframeScanSec = 60
+
+// probeKeyframeInterval returns the longest gap in seconds between video
+// keyframes in the opening part of the file, or zero if it cannot be measured.
+func probeKeyframeInterval(path string) float64 {
+	args := []string{
+		"-v", "quiet",
+		"-select_streams", "v:0",
+		"-read_intervals", fmt.Sprintf("%%+%d", keyframeScanSec),
+		"-show_entries", "packet=pts_time,flags",
+		"-of", "csv=p=0",
+		path,
+	}
+
+	out, err := exec.Command("ffprobe", args...).Output()
+	if err != nil {
+		return 0
+	}
+	return maxKeyframeGap(string(out))
+}
+
+// maxKeyframeGap parses ffprobe packet lines of the form "pts_time,flags".
+func maxKeyframeGap(packets string) float64 {
+	var last, maxGap float64
+	seen := false
+	for _, line := range strings.Split(packets, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) < 2 || !strings.Contains(fields[1], "K") {
+			continue
+		}
+		pts, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		if seen && pts-last > maxGap {
+			maxGap = pts - last
+		}
+		last, seen = pts, true
+	}
+	return maxGap
+}
+
 func GenerateThumbnail(videoPath, thumbPath string) error {
 	dir := filepath.Dir(thumbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -26,9 +26,13 @@ func (r *repository) GetByID(ctx context.Context, id uuid.UUID) (*Video, error)
 	return v, err
 }
 
-func (r *repository) List(ctx context.Context) ([]*Video, error) {
+func (r *repository) List(ctx context.Context, ownerID uuid.UUID) ([]*Video, error) {
 	var videos []*Video
-	err := r.db.NewSelect().Model(&videos).Scan(ctx)
+	q := r.db.NewSelect().Model(&videos)
+	if ownerID != uuid.Nil {
+		q = q.Where("user_id = ?", ownerID)
+	}
+	err := q.Scan(ctx)
 	return videos, err
 }
 
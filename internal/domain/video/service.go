@@ -17,8 +17,10 @@ func NewService(repo Repository) Service {
 	return &service{repo: repo}
 }
 
-func (s *service) GetVideos(ctx context.Context) ([]*Video, error) {
-	videos, err := s.repo.List(ctx)
+// GetVideos lists the videos owned by ownerID, or every video when it is
+// uuid.Nil.
+func (s *service) GetVideos(ctx context.Context, ownerID uuid.UUID) ([]*Video, error) {
+	videos, err := s.repo.List(ctx, ownerID)
 	if err != nil {
 		return nil, fmt.Errorf("list videos: %w", err)
 	}
@@ -41,6 +43,9 @@ func (s *service) ProcessVideo(ctx context.Context, dto ProcessVideoDTO) (*Video
 			Resolution: "unknown",
 			Bitrate:    0,
 			FPS:        0,
+			// Assume audio rather than have the stream replace it with
+			// silence on a guess.
+			HasAudio: true,
 		}
 		fmt.Printf("Warning: failed to probe video: %v\n", err)
 	}
@@ -59,12 +64,14 @@ func (s *service) ProcessVideo(ctx context.Context, dto ProcessVideoDTO) (*Video
 
 	v := &Video{
 		ID:           uuid.New(),
+		UserID:       dto.UserID,
 		Filename:     dto.Filename,
 		OriginalName: dto.OriginalName,
 		Folder:       dto.Folder,
 		Size:         info.Size(),
 		Thumbnail:    thumbName,
 		Duration:     meta.Duration,
+		HasAudio:     meta.HasAudio,
 	}
 
 	if err := s.repo.Create(ctx, v); err != nil {
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/video"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func TestHandler_DeleteVideoOwnership(t *testing.T) {
+	owner := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	other := &auth.User{ID: uuid.New(), Role: auth.RoleOperator}
+	admin := &auth.User{ID: uuid.New(), Role: auth.RoleAdmin}
+	v := &video.Video{ID: uuid.New(), UserID: owner.ID, Filename: "owned.mp4", Thumbnail: "owned.jpg"}
+
+	repo := new(MockVideoRepository)
+	repo.On("GetByID", mock.Anything, v.ID).Return(v, nil)
+	repo.On("GetByID", mock.Anything, mock.Anything).Return(nil, sql.ErrNoRows)
+	repo.On("Delete", mock.Anything, v.ID).Return(nil)
+	handler := video.NewHandler(video.NewService(repo), nil, zap.NewNop())
+
+	var current *auth.User
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", current)
+		return c.Next()
+	})
+	handler.Routes(app)
+
+	remove := func(user *auth.User, id uuid.UUID) int {
+		current = user
+		resp, err := app.Test(httptest.NewRequest("DELETE", "/api/videos/"+id.String(), nil), -1)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusForbidden, remove(other, v.ID))
+	repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+
+	assert.Equal(t, fiber.StatusNotFound, remove(admin, uuid.New()))
+	assert.Equal(t, fiber.StatusNoContent, remove(owner, v.ID))
+}
@@ -25,8 +25,8 @@ func (m *MockVideoRepository) GetByID(ctx context.Context, id uuid.UUID) (*video
 	return args.Get(0).(*video.Video), args.Error(1)
 }
 
-func (m *MockVideoRepository) List(ctx context.Context) ([]*video.Video, error) {
-	args := m.Called(ctx)
+func (m *MockVideoRepository) List(ctx context.Context, ownerID uuid.UUID) ([]*video.Video, error) {
+	args := m.Called(ctx, ownerID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
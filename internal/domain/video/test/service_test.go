@@ -21,9 +21,9 @@ func TestVideoService_GetVideos(t *testing.T) {
 		service := video.NewService(mockRepo)
 		ctx := context.Background()
 
-		mockRepo.On("List", ctx).Return(mockVideos, nil)
+		mockRepo.On("List", ctx, uuid.Nil).Return(mockVideos, nil)
 
-		res, err := service.GetVideos(ctx)
+		res, err := service.GetVideos(ctx, uuid.Nil)
 		assert.NoError(t, err)
 		assert.Equal(t, len(mockVideos), len(res))
 		mockRepo.AssertExpectations(t)
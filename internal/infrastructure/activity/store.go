@@ -23,6 +23,7 @@ type Entry struct {
 	IsAPI      bool      `json:"is_api"`
 	RequestID  string    `json:"request_id"`
 	StatusText string    `json:"status_text"`
+	Error      string    `json:"error,omitempty"`
 }
 
 type PageResult[T any] struct {
@@ -3,12 +3,21 @@ package config
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 )
 
 type Config struct {
 	Port, Host, DBPath, LogLevel, Secret, ProxyHeader, AppURL string
+	IngestAddr, IngestURL                                     string
+	// CertsDir holds the CA files rtmps destinations may reference.
+	CertsDir string
+	// EncryptionKey seals the credentials stored in the database. It
+	// defaults to Secret; changing it needs gostreamix-cli --rekey.
+	EncryptionKey string
 }
 
 func NewConfig() *Config {
@@ -27,15 +36,35 @@ func NewConfig() *Config {
 		}
 	}
 
+	appURL := getEnv("APP_URL", "http://localhost:8080")
+	ingestAddr := getEnv("INGEST_ADDR", ":1935")
+
 	return &Config{
-		Port:        getEnv("PORT", "8080"),
-		Host:        getEnv("HOST", "0.0.0.0"),
-		DBPath:      dbPath,
-		LogLevel:    getEnv("LOG_LEVEL", "info"),
-		Secret:      secret,
-		ProxyHeader: os.Getenv("PROXY_HEADER"),
-		AppURL:      getEnv("APP_URL", "http://localhost:8080"),
+		Port:          getEnv("PORT", "8080"),
+		Host:          getEnv("HOST", "0.0.0.0"),
+		DBPath:        dbPath,
+		LogLevel:      getEnv("LOG_LEVEL", "info"),
+		Secret:        secret,
+		ProxyHeader:   os.Getenv("PROXY_HEADER"),
+		AppURL:        appURL,
+		IngestAddr:    ingestAddr,
+		IngestURL:     getEnv("INGEST_URL", defaultIngestURL(appURL, ingestAddr)),
+		CertsDir:      getEnv("CERTS_DIR", filepath.Join("data", "certs")),
+		EncryptionKey: getEnv("ENCRYPTION_KEY", secret),
+	}
+}
+
+// defaultIngestURL points publishers at the app's host on the ingest port.
+func defaultIngestURL(appURL, ingestAddr string) string {
+	host := "localhost"
+	if u, err := url.Parse(appURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	port := "1935"
+	if _, p, err := net.SplitHostPort(ingestAddr); err == nil && p != "" {
+		port = p
 	}
+	return fmt.Sprintf("rtmp://%s/live", net.JoinHostPort(host, port))
 }
 
 func getEnv(k, f string) string {
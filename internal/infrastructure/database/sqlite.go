@@ -3,25 +3,32 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 
+	"github.com/codewithwan/gostreamix/internal/domain/asset"
 	"github.com/codewithwan/gostreamix/internal/domain/auth"
+	"github.com/codewithwan/gostreamix/internal/domain/encoder"
 	"github.com/codewithwan/gostreamix/internal/domain/notification"
 	"github.com/codewithwan/gostreamix/internal/domain/platform"
+	"github.com/codewithwan/gostreamix/internal/domain/schedule"
 	"github.com/codewithwan/gostreamix/internal/domain/stream"
 	"github.com/codewithwan/gostreamix/internal/domain/video"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/config"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/monitor"
+	"github.com/codewithwan/gostreamix/internal/shared/secret"
 	_ "github.com/glebarez/go-sqlite"
+	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
 	"go.uber.org/zap"
 )
 
-func NewSQLiteDB(cfg *config.Config, log *zap.Logger) (*bun.DB, error) {
+func NewSQLiteDB(cfg *config.Config, cipher *secret.Cipher, log *zap.Logger) (*bun.DB, error) {
 	if err := os.MkdirAll(filepath.Dir(cfg.DBPath), 0755); err != nil {
 		return nil, err
 	}
@@ -32,21 +39,33 @@ func NewSQLiteDB(cfg *config.Config, log *zap.Logger) (*bun.DB, error) {
 	db := bun.NewDB(sqldb, sqlitedialect.New())
 	log.Info("database connected", zap.String("path", cfg.DBPath))
 
-	if err := migrate(ctx(), db, log); err != nil {
+	if err := migrate(ctx(), db, cipher, log); err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
-func migrate(ctx context.Context, db *bun.DB, log *zap.Logger) error {
+func migrate(ctx context.Context, db *bun.DB, cipher *secret.Cipher, log *zap.Logger) error {
 	models := []interface{}{
 		(*auth.User)(nil),
 		(*auth.RefreshToken)(nil),
+		(*auth.APIToken)(nil),
+		(*auth.TwoFactor)(nil),
 		(*stream.Stream)(nil),
 		(*stream.StreamProgram)(nil),
+		(*stream.StreamPlatform)(nil),
+		(*stream.Session)(nil),
+		(*stream.SessionMetric)(nil),
 		(*video.Video)(nil),
 		(*platform.Platform)(nil),
+		(*schedule.Schedule)(nil),
+		(*encoder.EncoderProfile)(nil),
+		(*asset.Asset)(nil),
 		(*notification.Settings)(nil),
+		(*notification.AlertRule)(nil),
+		(*notification.AlertDelivery)(nil),
+		(*notification.WebhookEndpoint)(nil),
+		(*notification.WebhookDelivery)(nil),
 		(*monitor.MetricSample)(nil),
 	}
 
@@ -65,7 +84,239 @@ func migrate(ctx context.Context, db *bun.DB, log *zap.Logger) error {
 	if err := ensureColumnExists(ctx, db, "videos", "folder", "TEXT NOT NULL DEFAULT ''"); err != nil {
 		return err
 	}
+	if err := ensureColumnExists(ctx, db, "videos", "has_audio", "BOOLEAN NOT NULL DEFAULT TRUE"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "desired_state", "TEXT NOT NULL DEFAULT 'stopped'"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "last_error", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "restart_max_attempts", "INTEGER NOT NULL DEFAULT 5"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "restart_backoff_sec", "INTEGER NOT NULL DEFAULT 2"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "restart_max_backoff_sec", "INTEGER NOT NULL DEFAULT 60"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "restart_reset_window_sec", "INTEGER NOT NULL DEFAULT 300"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "slow_after_sec", "INTEGER NOT NULL DEFAULT 30"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "encoder_profile_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "passthrough", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "destinations", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "source_type", "TEXT NOT NULL DEFAULT 'file'"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "ingest_key", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "ingest_key_hash", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "fallback_video_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "overlays", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "stream_programs", "overlays", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "fit", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "crop_offset", "INTEGER NOT NULL DEFAULT 50"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "audio_mode", "TEXT NOT NULL DEFAULT 'source'"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "audio_playlist", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "audio_normalize", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "users", "role", "TEXT NOT NULL DEFAULT 'viewer'"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "users", "disabled", "BOOLEAN NOT NULL DEFAULT FALSE"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "users", "invite_token_hash", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "users", "invite_expires_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "streams", "user_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumnExists(ctx, db, "videos", "user_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := hashIngestKeys(ctx, db, cipher); err != nil {
+		return fmt.Errorf("hash ingest keys: %w", err)
+	}
+	// Ingest keys are stored sealed, so they are looked up and kept unique
+	// by their hash.
+	if _, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS idx_streams_ingest_key"); err != nil {
+		return fmt.Errorf("drop ingest key index: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS idx_streams_ingest_key_hash ON streams (ingest_key_hash) WHERE ingest_key_hash != ''"); err != nil {
+		return fmt.Errorf("create ingest key index: %w", err)
+	}
+	if err := linkStreamPlatforms(ctx, db, cipher); err != nil {
+		return fmt.Errorf("link stream platforms: %w", err)
+	}
+	if err := sealSecrets(ctx, db, cipher, log); err != nil {
+		return fmt.Errorf("encrypt stored secrets: %w", err)
+	}
+	if err := assignOwners(ctx, db); err != nil {
+		return fmt.Errorf("assign owners: %w", err)
+	}
+
+	return nil
+}
+
+// linkStreamPlatforms turns rtmp_targets that were copied out of a saved
+// platform into links to it, so those streams follow later key rotations.
+// Targets that match no platform are left as they are.
+func linkStreamPlatforms(ctx context.Context, db *bun.DB, cipher *secret.Cipher) error {
+	var platforms []*platform.Platform
+	if err := db.NewSelect().Model(&platforms).Scan(ctx); err != nil {
+		return err
+	}
+	byURL := make(map[string]uuid.UUID, len(platforms))
+	for _, p := range platforms {
+		// A key sealed with another encryption key cannot be matched.
+		if err := cipher.OpenFields(&p.StreamKey); err != nil {
+			continue
+		}
+		if rtmpURL := p.RTMPURL(); rtmpURL != "" {
+			byURL[rtmpURL] = p.ID
+		}
+	}
+	if len(byURL) == 0 {
+		return nil
+	}
+
+	var streams []*stream.Stream
+	if err := db.NewSelect().Model(&streams).Column("id", "rtmp_targets").Scan(ctx); err != nil {
+		return err
+	}
+
+	for _, s := range streams {
+		var program stream.StreamProgram
+		err := db.NewSelect().Model(&program).Column("id", "rtmp_targets").Where("stream_id = ?", s.ID).Scan(ctx)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		// Targets are written back in plaintext; sealSecrets seals them
+		// again.
+		if openValues(cipher, s.RTMPTargets) != nil || openValues(cipher, program.RTMPTargets) != nil {
+			continue
+		}
+
+		targets, linked := splitPlatformTargets(s.RTMPTargets, byURL)
+		programTargets, programLinked := splitPlatformTargets(program.RTMPTargets, byURL)
+		linked = append(linked, programLinked...)
+		if len(linked) == 0 {
+			continue
+		}
+
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			position, err := tx.NewSelect().Model((*stream.StreamPlatform)(nil)).Where("stream_id = ?", s.ID).Count(ctx)
+			if err != nil {
+				return err
+			}
+			for _, id := range linked {
+				link := &stream.StreamPlatform{StreamID: s.ID, PlatformID: id, Position: position}
+				res, err := tx.NewInsert().Model(link).On("CONFLICT DO NOTHING").Exec(ctx)
+				if err != nil {
+					return err
+				}
+				if n, _ := res.RowsAffected(); n > 0 {
+					position++
+				}
+			}
+
+			s.RTMPTargets = targets
+			if _, err := tx.NewUpdate().Model(s).Column("rtmp_targets").WherePK().Exec(ctx); err != nil {
+				return err
+			}
+			if program.ID != uuid.Nil {
+				program.RTMPTargets = programTargets
+				_, err := tx.NewUpdate().Model(&program).Column("rtmp_targets").WherePK().Exec(ctx)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitPlatformTargets separates the targets that are a saved platform's URL
+// from the rest.
+func splitPlatformTargets(targets []string, byURL map[string]uuid.UUID) ([]string, []uuid.UUID) {
+	kept := make([]string, 0, len(targets))
+	var linked []uuid.UUID
+	for _, target := range targets {
+		if id, ok := byURL[strings.TrimSpace(target)]; ok {
+			linked = append(linked, id)
+			continue
+		}
+		kept = append(kept, target)
+	}
+	return kept, linked
+}
+
+// openValues decrypts values in place.
+func openValues(cipher *secret.Cipher, values []string) error {
+	for i := range values {
+		if err := cipher.OpenFields(&values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// hashIngestKeys fills in the lookup hash of ingest keys stored before it
+// was kept. Keys sealed with another encryption key are left for a re-key.
+func hashIngestKeys(ctx context.Context, db *bun.DB, cipher *secret.Cipher) error {
+	var streams []*stream.Stream
+	err := db.NewSelect().Model(&streams).Column("id", "ingest_key").
+		Where("ingest_key != ''").Where("ingest_key_hash = ''").
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range streams {
+		key, err := cipher.Open(s.IngestKey)
+		if err != nil {
+			continue
+		}
+		s.IngestKeyHash = stream.HashIngestKey(key)
+		if _, err := db.NewUpdate().Model(s).Column("ingest_key_hash").WherePK().Exec(ctx); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -92,3 +343,211 @@ func ensureColumnExists(ctx context.Context, db *bun.DB, table, column, definiti
 func ctx() context.Context {
 	return context.Background()
 }
+
+// assignOwners upgrades a single-user install: the account created at setup
+// becomes an admin, and streams and videos without an owner are given to the
+// oldest admin.
+func assignOwners(ctx context.Context, db *bun.DB) error {
+	admins, err := db.NewSelect().Model((*auth.User)(nil)).Where("role = ?", auth.RoleAdmin).Count(ctx)
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		oldest := db.NewSelect().Model((*auth.User)(nil)).Column("id").Order("created_at ASC").Limit(1)
+		if _, err := db.NewUpdate().Model((*auth.User)(nil)).Set("role = ?", auth.RoleAdmin).Where("id IN (?)", oldest).Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	var primary auth.User
+	err = db.NewSelect().Model(&primary).Where("role = ?", auth.RoleAdmin).Order("created_at ASC").Limit(1).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"streams", "videos"} {
+		if _, err := db.NewUpdate().Table(table).Set("user_id = ?", primary.ID).Where("user_id IS NULL OR user_id = ''").Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealedColumns are the credentials the repositories keep encrypted.
+var sealedColumns = []struct{ table, column string }{
+	{"platforms", "stream_key"},
+	{"notification_settings", "discord_webhook"},
+	{"notification_settings", "telegram_bot_token"},
+	{"webhook_endpoints", "secret"},
+	{"user_two_factor", "secret"},
+	{"streams", "ingest_key"},
+}
+
+// sealSecrets encrypts credentials still stored in plaintext. Values sealed
+// with a different key are left alone and reported, as they need a re-key.
+func sealSecrets(ctx context.Context, db *bun.DB, cipher *secret.Cipher, log *zap.Logger) error {
+	unreadable := 0
+	sealed, err := rewriteSecrets(ctx, db, func(value string) (string, error) {
+		if !secret.IsSealed(value) {
+			return cipher.Seal(value)
+		}
+		if _, err := cipher.Open(value); err != nil {
+			unreadable++
+		}
+		return value, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if sealed > 0 {
+		log.Info("encrypted stored secrets", zap.Int("count", sealed))
+	}
+	if unreadable > 0 {
+		log.Warn("stored secrets cannot be decrypted with the current encryption key; run gostreamix-cli --rekey",
+			zap.Int("count", unreadable))
+	}
+	return nil
+}
+
+// RekeySecrets re-encrypts the stored credentials sealed with from so they
+// open with to, and seals any still in plaintext. Values that already open
+// with to are kept, so an interrupted re-key can simply be run again.
+func RekeySecrets(ctx context.Context, db *bun.DB, from, to *secret.Cipher) (int, error) {
+	return rewriteSecrets(ctx, db, func(value string) (string, error) {
+		if secret.IsSealed(value) {
+			if _, err := to.Open(value); err == nil {
+				return value, nil
+			}
+		}
+		plain, err := from.Open(value)
+		if err != nil {
+			return "", err
+		}
+		return to.Seal(plain)
+	})
+}
+
+// rewriteSecrets passes every stored credential through rewrite in one
+// transaction and reports how many changed.
+func rewriteSecrets(ctx context.Context, db *bun.DB, rewrite func(value string) (string, error)) (int, error) {
+	changed := 0
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, col := range sealedColumns {
+			var rows []struct {
+				ID    interface{} `bun:"id"`
+				Value string      `bun:"value"`
+			}
+			err := tx.NewSelect().
+				Table(col.table).
+				Column("id").
+				ColumnExpr("? AS value", bun.Ident(col.column)).
+				Where("? != ''", bun.Ident(col.column)).
+				Scan(ctx, &rows)
+			if err != nil {
+				return fmt.Errorf("read %s.%s: %w", col.table, col.column, err)
+			}
+
+			for _, row := range rows {
+				value, err := rewrite(row.Value)
+				if err != nil {
+					return fmt.Errorf("%s.%s of %v: %w", col.table, col.column, row.ID, err)
+				}
+				if value == row.Value {
+					continue
+				}
+				_, err = tx.NewUpdate().
+					Table(col.table).
+					Set("? = ?", bun.Ident(col.column), value).
+					Where("id = ?", row.ID).
+					Exec(ctx)
+				if err != nil {
+					return fmt.Errorf("write %s.%s: %w", col.table, col.column, err)
+				}
+				changed++
+			}
+		}
+
+		n, err := rewriteStreamSecrets(ctx, tx, rewrite)
+		changed += n
+		return err
+	})
+	return changed, err
+}
+
+// rewriteStreamSecrets passes the credentials kept inside JSON columns
+// through rewrite: the stream keys in the rtmp_targets of streams and their
+// programs, and the passphrases of destinations.
+func rewriteStreamSecrets(ctx context.Context, tx bun.Tx, rewrite func(value string) (string, error)) (int, error) {
+	changed := 0
+
+	var streams []*stream.Stream
+	if err := tx.NewSelect().Model(&streams).Column("id", "rtmp_targets", "destinations").Scan(ctx); err != nil {
+		return changed, fmt.Errorf("read streams: %w", err)
+	}
+	for _, s := range streams {
+		values := make([]*string, 0, len(s.RTMPTargets)+len(s.Destinations))
+		for i := range s.RTMPTargets {
+			values = append(values, &s.RTMPTargets[i])
+		}
+		for i := range s.Destinations {
+			values = append(values, &s.Destinations[i].Options.Passphrase)
+		}
+		n, err := rewriteValues(values, rewrite)
+		if err != nil {
+			return changed, fmt.Errorf("streams of %v: %w", s.ID, err)
+		}
+		if n == 0 {
+			continue
+		}
+		if _, err := tx.NewUpdate().Model(s).Column("rtmp_targets", "destinations").WherePK().Exec(ctx); err != nil {
+			return changed, fmt.Errorf("write streams: %w", err)
+		}
+		changed += n
+	}
+
+	var programs []*stream.StreamProgram
+	if err := tx.NewSelect().Model(&programs).Column("id", "rtmp_targets").Scan(ctx); err != nil {
+		return changed, fmt.Errorf("read stream_programs: %w", err)
+	}
+	for _, p := range programs {
+		values := make([]*string, len(p.RTMPTargets))
+		for i := range p.RTMPTargets {
+			values[i] = &p.RTMPTargets[i]
+		}
+		n, err := rewriteValues(values, rewrite)
+		if err != nil {
+			return changed, fmt.Errorf("stream_programs of %v: %w", p.ID, err)
+		}
+		if n == 0 {
+			continue
+		}
+		if _, err := tx.NewUpdate().Model(p).Column("rtmp_targets").WherePK().Exec(ctx); err != nil {
+			return changed, fmt.Errorf("write stream_programs: %w", err)
+		}
+		changed += n
+	}
+	return changed, nil
+}
+
+// rewriteValues rewrites the non-empty values in place and reports how many
+// changed.
+func rewriteValues(values []*string, rewrite func(value string) (string, error)) (int, error) {
+	changed := 0
+	for _, v := range values {
+		if *v == "" {
+			continue
+		}
+		value, err := rewrite(*v)
+		if err != nil {
+			return changed, err
+		}
+		if value != *v {
+			*v = value
+			changed++
+		}
+	}
+	return changed, nil
+}
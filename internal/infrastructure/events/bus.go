@@ -0,0 +1,70 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Stream lifecycle events.
+const (
+	StreamStarted     = "stream.started"
+	StreamStopped     = "stream.stopped"
+	StreamError       = "stream.error"
+	StreamRestarted   = "stream.restarted"
+	StreamSlow        = "stream.slow"
+	DestinationFailed = "destination.failed"
+)
+
+// Types lists every event that is published.
+func Types() []string {
+	return []string{StreamStarted, StreamStopped, StreamError, StreamRestarted, StreamSlow, DestinationFailed}
+}
+
+// Known reports whether t is a published event type.
+func Known(t string) bool {
+	for _, known := range Types() {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	Type       string                 `json:"type"`
+	StreamID   string                 `json:"stream_id"`
+	StreamName string                 `json:"stream_name"`
+	Message    string                 `json:"message"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// Bus hands published events to every subscriber. Subscribers run on their
+// own goroutine, so a slow one never holds up the publisher.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []func(Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(handler func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *Bus) Publish(e Event) {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+	for _, handler := range handlers {
+		go handler(e)
+	}
+}
@@ -0,0 +1,215 @@
+package rtmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfECMAArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0a
+	amfDate        = 0x0b
+	amfLongString  = 0x0c
+)
+
+// EncodeAMF0 serialises command values. Supported types are float64, int,
+// bool, string, map[string]interface{} (as an object) and nil (as null).
+func EncodeAMF0(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		encodeAMF0Value(&buf, v)
+	}
+	return buf.Bytes()
+}
+
+func encodeAMF0Value(buf *bytes.Buffer, v interface{}) {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteByte(amfNull)
+	case bool:
+		buf.WriteByte(amfBoolean)
+		if val {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case int:
+		encodeAMF0Value(buf, float64(val))
+	case float64:
+		buf.WriteByte(amfNumber)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(val))
+	case string:
+		buf.WriteByte(amfString)
+		writeAMF0String(buf, val)
+	case map[string]interface{}:
+		buf.WriteByte(amfObject)
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeAMF0String(buf, k)
+			encodeAMF0Value(buf, val[k])
+		}
+		buf.Write([]byte{0, 0, amfObjectEnd})
+	default:
+		buf.WriteByte(amfUndefined)
+	}
+}
+
+func writeAMF0String(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+// DecodeAMF0 parses every value in data. Objects and ECMA arrays decode to
+// map[string]interface{}, numbers and dates to float64, null and undefined to nil.
+func DecodeAMF0(data []byte) ([]interface{}, error) {
+	d := &amfDecoder{data: data}
+	var values []interface{}
+	for d.pos < len(d.data) {
+		v, err := d.value()
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// amfMaxDepth bounds how deeply objects and arrays may nest, so a crafted
+// command cannot exhaust the stack.
+const amfMaxDepth = 32
+
+type amfDecoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (d *amfDecoder) take(n int) ([]byte, error) {
+	if d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("amf0: unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *amfDecoder) value() (interface{}, error) {
+	marker, err := d.take(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch marker[0] {
+	case amfObject, amfECMAArray, amfStrictArray:
+		if d.depth >= amfMaxDepth {
+			return nil, fmt.Errorf("amf0: nesting deeper than %d", amfMaxDepth)
+		}
+		d.depth++
+		defer func() { d.depth-- }()
+	}
+
+	switch marker[0] {
+	case amfNumber, amfDate:
+		b, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		if marker[0] == amfDate {
+			if _, err := d.take(2); err != nil {
+				return nil, err
+			}
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case amfBoolean:
+		b, err := d.take(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case amfString:
+		return d.shortString()
+	case amfLongString:
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.take(int(binary.BigEndian.Uint32(b)))
+		return string(s), err
+	case amfObject:
+		return d.properties()
+	case amfECMAArray:
+		if _, err := d.take(4); err != nil {
+			return nil, err
+		}
+		return d.properties()
+	case amfStrictArray:
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		// The count comes off the wire, so it only sizes the slice as far
+		// as the remaining bytes could hold one value each.
+		n := int(binary.BigEndian.Uint32(b))
+		items := make([]interface{}, 0, min(n, len(d.data)-d.pos))
+		for i := 0; i < n; i++ {
+			v, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case amfNull, amfUndefined:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("amf0: unsupported marker 0x%02x", marker[0])
+	}
+}
+
+func (d *amfDecoder) shortString() (string, error) {
+	b, err := d.take(2)
+	if err != nil {
+		return "", err
+	}
+	s, err := d.take(int(binary.BigEndian.Uint16(b)))
+	return string(s), err
+}
+
+func (d *amfDecoder) properties() (map[string]interface{}, error) {
+	props := map[string]interface{}{}
+	for {
+		key, err := d.shortString()
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			end, err := d.take(1)
+			if err != nil {
+				return nil, err
+			}
+			if end[0] == amfObjectEnd {
+				return props, nil
+			}
+			return nil, fmt.Errorf("amf0: malformed object end")
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		props[key] = v
+	}
+}
@@ -0,0 +1,282 @@
+package rtmp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	defaultChunkSize = 128
+
+	// Until a publish is accepted the peer only sends commands, so chunks
+	// and messages are held to these until then.
+	commandChunkSize   = 64 << 10
+	commandMessageSize = 64 << 10
+
+	// maxChunkSize and maxMessageSize apply to publishers sending media.
+	maxChunkSize   = 1 << 20
+	maxMessageSize = 16 << 20
+
+	// maxChunkStreams caps how many chunk streams a peer may open, and
+	// maxBuffered the bytes held in partial messages across all of them.
+	maxChunkStreams = 16
+	maxBuffered     = 32 << 20
+)
+
+const (
+	msgSetChunkSize     uint8 = 1
+	msgAbort            uint8 = 2
+	msgAck              uint8 = 3
+	msgUserControl      uint8 = 4
+	msgWindowAckSize    uint8 = 5
+	msgSetPeerBandwidth uint8 = 6
+	msgAudio            uint8 = 8
+	msgVideo            uint8 = 9
+	msgDataAMF3         uint8 = 15
+	msgCommandAMF3      uint8 = 17
+	msgDataAMF0         uint8 = 18
+	msgCommandAMF0      uint8 = 20
+)
+
+type message struct {
+	typeID    uint8
+	streamID  uint32
+	timestamp uint32
+	payload   []byte
+}
+
+type chunkState struct {
+	timestamp uint32
+	delta     uint32
+	length    uint32
+	typeID    uint8
+	streamID  uint32
+	extended  bool
+	buf       []byte
+}
+
+type chunkReader struct {
+	r              *bufio.Reader
+	chunkSize      uint32
+	maxChunkSize   uint32
+	maxMessageSize uint32
+	streams        map[uint32]*chunkState
+	buffered       int
+}
+
+func newChunkReader(r io.Reader) *chunkReader {
+	return &chunkReader{
+		r:              bufio.NewReader(r),
+		chunkSize:      defaultChunkSize,
+		maxChunkSize:   commandChunkSize,
+		maxMessageSize: commandMessageSize,
+		streams:        map[uint32]*chunkState{},
+	}
+}
+
+// allowMedia raises the limits to what a publisher sending media needs.
+func (cr *chunkReader) allowMedia() {
+	cr.maxChunkSize = maxChunkSize
+	cr.maxMessageSize = maxMessageSize
+}
+
+func (cr *chunkReader) setChunkSize(size uint32) error {
+	if size == 0 || size > cr.maxChunkSize {
+		return fmt.Errorf("rtmp: chunk size %d is outside 1..%d", size, cr.maxChunkSize)
+	}
+	cr.chunkSize = size
+	return nil
+}
+
+// reset releases the message buffered on st. The buffer is not reused, so
+// a large message does not keep its memory once it has been handed on.
+func (cr *chunkReader) reset(st *chunkState) {
+	cr.buffered -= len(st.buf)
+	st.buf = nil
+}
+
+func (cr *chunkReader) readN(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(cr.r, b)
+	return b, err
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+// readMessage reads chunks until one message is complete.
+func (cr *chunkReader) readMessage() (*message, error) {
+	for {
+		b0, err := cr.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		format := b0 >> 6
+		csid := uint32(b0 & 0x3f)
+		switch csid {
+		case 0:
+			b, err := cr.readN(1)
+			if err != nil {
+				return nil, err
+			}
+			csid = 64 + uint32(b[0])
+		case 1:
+			b, err := cr.readN(2)
+			if err != nil {
+				return nil, err
+			}
+			csid = 64 + uint32(b[0]) + uint32(b[1])*256
+		}
+
+		st := cr.streams[csid]
+		if st == nil {
+			if format != 0 {
+				return nil, fmt.Errorf("rtmp: chunk stream %d starts without a full header", csid)
+			}
+			if len(cr.streams) >= maxChunkStreams {
+				return nil, fmt.Errorf("rtmp: more than %d chunk streams", maxChunkStreams)
+			}
+			st = &chunkState{}
+			cr.streams[csid] = st
+		}
+
+		if format < 3 {
+			cr.reset(st)
+		}
+		starting := len(st.buf) == 0
+
+		switch format {
+		case 0:
+			h, err := cr.readN(11)
+			if err != nil {
+				return nil, err
+			}
+			st.timestamp = uint24(h[0:3])
+			st.length = uint24(h[3:6])
+			st.typeID = h[6]
+			st.streamID = binary.LittleEndian.Uint32(h[7:11])
+			st.delta = 0
+			st.extended = st.timestamp == 0xFFFFFF
+			if st.extended {
+				ext, err := cr.readN(4)
+				if err != nil {
+					return nil, err
+				}
+				st.timestamp = binary.BigEndian.Uint32(ext)
+			}
+		case 1, 2:
+			size := 3
+			if format == 1 {
+				size = 7
+			}
+			h, err := cr.readN(size)
+			if err != nil {
+				return nil, err
+			}
+			st.delta = uint24(h[0:3])
+			if format == 1 {
+				st.length = uint24(h[3:6])
+				st.typeID = h[6]
+			}
+			st.extended = st.delta == 0xFFFFFF
+			if st.extended {
+				ext, err := cr.readN(4)
+				if err != nil {
+					return nil, err
+				}
+				st.delta = binary.BigEndian.Uint32(ext)
+			}
+			st.timestamp += st.delta
+		case 3:
+			if st.extended {
+				if _, err := cr.readN(4); err != nil {
+					return nil, err
+				}
+			}
+			if starting {
+				st.timestamp += st.delta
+			}
+		}
+
+		if st.length > cr.maxMessageSize {
+			return nil, fmt.Errorf("rtmp: message of %d bytes exceeds limit", st.length)
+		}
+
+		n := st.length - uint32(len(st.buf))
+		if n > cr.chunkSize {
+			n = cr.chunkSize
+		}
+		if cr.buffered+int(n) > maxBuffered {
+			return nil, fmt.Errorf("rtmp: more than %d bytes of partial messages", maxBuffered)
+		}
+		payload, err := cr.readN(int(n))
+		if err != nil {
+			return nil, err
+		}
+		st.buf = append(st.buf, payload...)
+		cr.buffered += int(n)
+
+		if uint32(len(st.buf)) == st.length {
+			msg := &message{
+				typeID:    st.typeID,
+				streamID:  st.streamID,
+				timestamp: st.timestamp,
+				payload:   st.buf,
+			}
+			cr.reset(st)
+			return msg, nil
+		}
+	}
+}
+
+// abort discards the partial message on a chunk stream.
+func (cr *chunkReader) abort(csid uint32) {
+	if st := cr.streams[csid]; st != nil {
+		cr.reset(st)
+	}
+}
+
+// writeChunks frames one message on chunk stream csid (2..63) using chunkSize.
+func writeChunks(w io.Writer, chunkSize int, csid uint8, msg message) error {
+	header := make([]byte, 12, 16)
+	header[0] = csid & 0x3f
+	ts := msg.timestamp
+	extended := ts >= 0xFFFFFF
+	if extended {
+		ts = 0xFFFFFF
+	}
+	header[1], header[2], header[3] = byte(ts>>16), byte(ts>>8), byte(ts)
+	size := len(msg.payload)
+	header[4], header[5], header[6] = byte(size>>16), byte(size>>8), byte(size)
+	header[7] = msg.typeID
+	binary.LittleEndian.PutUint32(header[8:12], msg.streamID)
+	if extended {
+		header = binary.BigEndian.AppendUint32(header, msg.timestamp)
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	for off := 0; off < size; off += chunkSize {
+		if off > 0 {
+			cont := []byte{0xC0 | (csid & 0x3f)}
+			if extended {
+				cont = binary.BigEndian.AppendUint32(cont, msg.timestamp)
+			}
+			if _, err := w.Write(cont); err != nil {
+				return err
+			}
+		}
+		end := off + chunkSize
+		if end > size {
+			end = size
+		}
+		if _, err := w.Write(msg.payload[off:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package rtmp
+
+import "encoding/binary"
+
+const (
+	TagAudio  uint8 = 8
+	TagVideo  uint8 = 9
+	TagScript uint8 = 18
+)
+
+// FLVHeader starts an FLV byte stream with audio and video, followed by the
+// zero PreviousTagSize that precedes the first tag.
+var FLVHeader = []byte{'F', 'L', 'V', 0x01, 0x05, 0, 0, 0, 9, 0, 0, 0, 0}
+
+// Tag is one audio, video or script message received from a publisher.
+type Tag struct {
+	Type      uint8
+	Timestamp uint32
+	Data      []byte
+}
+
+// Bytes encodes the tag in FLV framing, including its trailing
+// PreviousTagSize field.
+func (t Tag) Bytes() []byte {
+	size := len(t.Data)
+	out := make([]byte, 11+size+4)
+	out[0] = t.Type
+	out[1], out[2], out[3] = byte(size>>16), byte(size>>8), byte(size)
+	out[4], out[5], out[6] = byte(t.Timestamp>>16), byte(t.Timestamp>>8), byte(t.Timestamp)
+	out[7] = byte(t.Timestamp >> 24)
+	copy(out[11:], t.Data)
+	binary.BigEndian.PutUint32(out[11+size:], uint32(11+size))
+	return out
+}
+
+// IsKeyframe reports whether the tag is a video keyframe.
+func (t Tag) IsKeyframe() bool {
+	return t.Type == TagVideo && len(t.Data) > 0 && t.Data[0]>>4 == 1
+}
+
+// IsSequenceHeader reports whether the tag carries the AVC or AAC decoder
+// configuration a new consumer needs before any media.
+func (t Tag) IsSequenceHeader() bool {
+	if len(t.Data) < 2 {
+		return false
+	}
+	switch t.Type {
+	case TagVideo:
+		return t.Data[0]&0x0f == 7 && t.Data[1] == 0
+	case TagAudio:
+		return t.Data[0]>>4 == 10 && t.Data[1] == 0
+	}
+	return false
+}
@@ -0,0 +1,366 @@
+package rtmp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	handshakeSize  = 1536
+	outChunkSize   = 4096
+	ackWindowSize  = 2500000
+	readTimeout    = 30 * time.Second
+	setDataFrame   = "@setDataFrame"
+	csidControl    = 2
+	csidCommand    = 3
+	csidStreamCtrl = 5
+
+	// maxConns and maxConnsPerIP bound the connections the server holds
+	// open; connections past either limit are closed straight away.
+	maxConns      = 256
+	maxConnsPerIP = 8
+)
+
+// Publisher receives the media of one publish session. Close is called once
+// when the publisher disconnects.
+type Publisher interface {
+	WriteTag(tag Tag) error
+	Close()
+}
+
+// Handler authorises publish requests. Returning an error rejects the
+// publisher with NetStream.Publish.BadName.
+type Handler interface {
+	Publish(app, key string) (Publisher, error)
+}
+
+// Server accepts RTMP publishers and hands their audio, video and metadata
+// messages to a Handler as FLV tags. Playback is not supported.
+type Server struct {
+	addr    string
+	handler Handler
+	log     *zap.Logger
+
+	mu    sync.Mutex
+	conns int
+	perIP map[string]int
+}
+
+func NewServer(addr string, handler Handler, log *zap.Logger) *Server {
+	return &Server{addr: addr, handler: handler, log: log, perIP: map[string]int{}}
+}
+
+// ListenAndServe listens on the configured address until ctx is cancelled.
+// An empty address disables the server.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if s.addr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listen rtmp: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+	s.log.Info("rtmp ingest listening", zap.String("addr", ln.Addr().String()))
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on ln until it is closed.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		ip := remoteIP(conn)
+		if !s.acquire(ip) {
+			s.log.Debug("rtmp connection refused: too many connections", zap.String("remote", conn.RemoteAddr().String()))
+			_ = conn.Close()
+			continue
+		}
+		go func() {
+			defer s.release(ip)
+			defer func() { _ = conn.Close() }()
+			if err := s.serveConn(conn); err != nil && !errors.Is(err, io.EOF) {
+				s.log.Debug("rtmp connection closed", zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+			}
+		}()
+	}
+}
+
+func (s *Server) acquire(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns >= maxConns || s.perIP[ip] >= maxConnsPerIP {
+		return false
+	}
+	s.conns++
+	s.perIP[ip]++
+	return true
+}
+
+func (s *Server) release(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns--
+	if s.perIP[ip]--; s.perIP[ip] <= 0 {
+		delete(s.perIP, ip)
+	}
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+type session struct {
+	conn      net.Conn
+	cr        *chunkReader
+	w         *bufio.Writer
+	handler   Handler
+	app       string
+	publisher Publisher
+	ackWindow uint32
+	received  uint32
+	lastAck   uint32
+}
+
+func (s *Server) serveConn(conn net.Conn) error {
+	_ = conn.SetDeadline(time.Now().Add(readTimeout))
+	if err := handshake(conn); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	counter := &countingReader{r: conn}
+	sess := &session{
+		conn:    conn,
+		cr:      newChunkReader(counter),
+		w:       bufio.NewWriter(conn),
+		handler: s.handler,
+	}
+	defer func() {
+		if sess.publisher != nil {
+			sess.publisher.Close()
+		}
+	}()
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+		msg, err := sess.cr.readMessage()
+		if err != nil {
+			return err
+		}
+		sess.received = counter.n
+		if err := sess.acknowledge(); err != nil {
+			return err
+		}
+		done, err := sess.handle(msg)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n uint32
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += uint32(n)
+	return n, err
+}
+
+func handshake(rw io.ReadWriter) error {
+	c0c1 := make([]byte, 1+handshakeSize)
+	if _, err := io.ReadFull(rw, c0c1); err != nil {
+		return err
+	}
+	if c0c1[0] != 3 {
+		return fmt.Errorf("unsupported rtmp version %d", c0c1[0])
+	}
+
+	out := make([]byte, 1+2*handshakeSize)
+	out[0] = 3
+	_, _ = rand.Read(out[9 : 1+handshakeSize])
+	copy(out[1+handshakeSize:], c0c1[1:])
+	if _, err := rw.Write(out); err != nil {
+		return err
+	}
+
+	c2 := make([]byte, handshakeSize)
+	_, err := io.ReadFull(rw, c2)
+	return err
+}
+
+func (s *session) acknowledge() error {
+	if s.ackWindow == 0 || s.received-s.lastAck < s.ackWindow {
+		return nil
+	}
+	s.lastAck = s.received
+	return s.send(csidControl, message{typeID: msgAck, payload: binary.BigEndian.AppendUint32(nil, s.received)})
+}
+
+func (s *session) send(csid uint8, msg message) error {
+	if err := writeChunks(s.w, outChunkSize, csid, msg); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+func (s *session) sendControl(typeID uint8, payload []byte) error {
+	return writeChunks(s.w, defaultChunkSize, csidControl, message{typeID: typeID, payload: payload})
+}
+
+func (s *session) sendCommand(csid uint8, streamID uint32, values ...interface{}) error {
+	return s.send(csid, message{typeID: msgCommandAMF0, streamID: streamID, payload: EncodeAMF0(values...)})
+}
+
+func (s *session) handle(msg *message) (bool, error) {
+	switch msg.typeID {
+	case msgSetChunkSize:
+		if len(msg.payload) < 4 {
+			return false, fmt.Errorf("short set chunk size")
+		}
+		if err := s.cr.setChunkSize(binary.BigEndian.Uint32(msg.payload) & 0x7fffffff); err != nil {
+			return false, err
+		}
+	case msgAbort:
+		if len(msg.payload) >= 4 {
+			s.cr.abort(binary.BigEndian.Uint32(msg.payload))
+		}
+	case msgWindowAckSize:
+		if len(msg.payload) >= 4 {
+			s.ackWindow = binary.BigEndian.Uint32(msg.payload)
+		}
+	case msgUserControl:
+		// Answer ping requests so clients that probe liveness stay connected.
+		if len(msg.payload) >= 6 && binary.BigEndian.Uint16(msg.payload) == 6 {
+			pong := append([]byte{0, 7}, msg.payload[2:6]...)
+			if err := s.sendControl(msgUserControl, pong); err != nil {
+				return false, err
+			}
+			return false, s.w.Flush()
+		}
+	case msgAudio, msgVideo:
+		if s.publisher != nil {
+			return false, s.publisher.WriteTag(Tag{Type: msg.typeID, Timestamp: msg.timestamp, Data: msg.payload})
+		}
+	case msgDataAMF0, msgDataAMF3:
+		payload := msg.payload
+		if msg.typeID == msgDataAMF3 && len(payload) > 0 {
+			payload = payload[1:]
+		}
+		if s.publisher != nil {
+			prefix := EncodeAMF0(setDataFrame)
+			if strings.HasPrefix(string(payload), string(prefix)) {
+				payload = payload[len(prefix):]
+			}
+			return false, s.publisher.WriteTag(Tag{Type: TagScript, Timestamp: msg.timestamp, Data: payload})
+		}
+	case msgCommandAMF0, msgCommandAMF3:
+		payload := msg.payload
+		if msg.typeID == msgCommandAMF3 && len(payload) > 0 {
+			payload = payload[1:]
+		}
+		values, err := DecodeAMF0(payload)
+		if err != nil {
+			return false, err
+		}
+		return s.command(msg, values)
+	}
+	return false, nil
+}
+
+func (s *session) command(msg *message, values []interface{}) (bool, error) {
+	if len(values) < 2 {
+		return false, nil
+	}
+	name, _ := values[0].(string)
+	txn, _ := values[1].(float64)
+
+	switch name {
+	case "connect":
+		if len(values) > 2 {
+			if obj, ok := values[2].(map[string]interface{}); ok {
+				s.app, _ = obj["app"].(string)
+			}
+		}
+		if err := s.sendControl(msgWindowAckSize, binary.BigEndian.AppendUint32(nil, ackWindowSize)); err != nil {
+			return false, err
+		}
+		if err := s.sendControl(msgSetPeerBandwidth, append(binary.BigEndian.AppendUint32(nil, ackWindowSize), 2)); err != nil {
+			return false, err
+		}
+		if err := s.sendControl(msgSetChunkSize, binary.BigEndian.AppendUint32(nil, outChunkSize)); err != nil {
+			return false, err
+		}
+		return false, s.sendCommand(csidCommand, 0, "_result", txn,
+			map[string]interface{}{"fmsVer": "FMS/3,0,1,123", "capabilities": 31.0},
+			map[string]interface{}{
+				"level":          "status",
+				"code":           "NetConnection.Connect.Success",
+				"description":    "Connection succeeded.",
+				"objectEncoding": 0.0,
+			})
+	case "releaseStream", "FCPublish":
+		if txn == 0 {
+			return false, nil
+		}
+		return false, s.sendCommand(csidCommand, 0, "_result", txn, nil, nil)
+	case "createStream":
+		return false, s.sendCommand(csidCommand, 0, "_result", txn, nil, 1.0)
+	case "publish":
+		if s.publisher != nil {
+			return false, nil
+		}
+		key := ""
+		if len(values) > 3 {
+			key, _ = values[3].(string)
+		}
+		if i := strings.IndexByte(key, '?'); i >= 0 {
+			key = key[:i]
+		}
+		pub, err := s.handler.Publish(s.app, key)
+		if err != nil {
+			_ = s.sendCommand(csidStreamCtrl, msg.streamID, "onStatus", 0, nil, map[string]interface{}{
+				"level":       "error",
+				"code":        "NetStream.Publish.BadName",
+				"description": err.Error(),
+			})
+			return true, nil
+		}
+		s.publisher = pub
+		s.cr.allowMedia()
+		return false, s.sendCommand(csidStreamCtrl, msg.streamID, "onStatus", 0, nil, map[string]interface{}{
+			"level":       "status",
+			"code":        "NetStream.Publish.Start",
+			"description": "Publishing " + key + ".",
+		})
+	case "FCUnpublish", "deleteStream", "closeStream":
+		return s.publisher != nil, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,338 @@
+package test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codewithwan/gostreamix/internal/infrastructure/rtmp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakePublisher struct {
+	mu     sync.Mutex
+	tags   []rtmp.Tag
+	closed chan struct{}
+}
+
+func (p *fakePublisher) WriteTag(tag rtmp.Tag) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tags = append(p.tags, tag)
+	return nil
+}
+
+func (p *fakePublisher) Close() { close(p.closed) }
+
+type fakeHandler struct {
+	app, key string
+	pub      *fakePublisher
+}
+
+func (h *fakeHandler) Publish(app, key string) (rtmp.Publisher, error) {
+	h.app, h.key = app, key
+	if key != "secret" {
+		return nil, errors.New("unknown key")
+	}
+	return h.pub, nil
+}
+
+func startServer(t *testing.T, h rtmp.Handler) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	srv := rtmp.NewServer("", h, zap.NewNop())
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = ln.Close() })
+	return ln.Addr().String()
+}
+
+// writeMessage sends one message in 128-byte chunks. A non-zero delta sends
+// a type 1 header relative to the previous message on the chunk stream.
+func writeMessage(w io.Writer, csid byte, delta bool, ts uint32, typeID byte, streamID uint32, payload []byte) error {
+	var buf bytes.Buffer
+	size := len(payload)
+	if delta {
+		buf.WriteByte(0x40 | csid)
+		buf.Write([]byte{byte(ts >> 16), byte(ts >> 8), byte(ts)})
+		buf.Write([]byte{byte(size >> 16), byte(size >> 8), byte(size), typeID})
+	} else {
+		buf.WriteByte(csid)
+		buf.Write([]byte{byte(ts >> 16), byte(ts >> 8), byte(ts)})
+		buf.Write([]byte{byte(size >> 16), byte(size >> 8), byte(size), typeID})
+		_ = binary.Write(&buf, binary.LittleEndian, streamID)
+	}
+	for off := 0; off < size; off += 128 {
+		if off > 0 {
+			buf.WriteByte(0xC0 | csid)
+		}
+		end := off + 128
+		if end > size {
+			end = size
+		}
+		buf.Write(payload[off:end])
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	conn := dialRaw(t, addr)
+	go func() { _, _ = io.Copy(io.Discard, conn) }()
+	return conn
+}
+
+// dialRaw completes the handshake and leaves the server's replies unread.
+func dialRaw(t *testing.T, addr string) net.Conn {
+	conn, err := net.Dial("tcp", addr)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	c1 := make([]byte, 1536)
+	c1[8] = 0xAB
+	_, err = conn.Write(append([]byte{3}, c1...))
+	assert.NoError(t, err)
+
+	s := make([]byte, 1+2*1536)
+	_, err = io.ReadFull(conn, s)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(3), s[0])
+	assert.Equal(t, c1, s[1+1536:], "S2 echoes C1")
+
+	_, err = conn.Write(make([]byte, 1536))
+	assert.NoError(t, err)
+	return conn
+}
+
+// assertHungUp checks that the server closes conn rather than waiting for
+// more input.
+func assertHungUp(t *testing.T, conn net.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err := io.Copy(io.Discard, conn)
+	assert.NoError(t, err, "server should close the connection")
+}
+
+func publish(t *testing.T, conn net.Conn, key string) {
+	connect := rtmp.EncodeAMF0("connect", 1, map[string]interface{}{"app": "live", "type": "nonprivate"})
+	assert.NoError(t, writeMessage(conn, 3, false, 0, 20, 0, connect))
+	assert.NoError(t, writeMessage(conn, 3, false, 0, 20, 0, rtmp.EncodeAMF0("createStream", 2, nil)))
+	assert.NoError(t, writeMessage(conn, 8, false, 0, 20, 1, rtmp.EncodeAMF0("publish", 0, nil, key, "live")))
+}
+
+func TestServerRelaysPublishedMedia(t *testing.T) {
+	h := &fakeHandler{pub: &fakePublisher{closed: make(chan struct{})}}
+	conn := dial(t, startServer(t, h))
+
+	publish(t, conn, "secret?token=1")
+
+	meta := append(rtmp.EncodeAMF0("@setDataFrame"), rtmp.EncodeAMF0("onMetaData", map[string]interface{}{"width": 1280.0})...)
+	assert.NoError(t, writeMessage(conn, 4, false, 0, 18, 1, meta))
+
+	keyframe := append([]byte{0x17, 0x01, 0, 0, 0}, bytes.Repeat([]byte{0xEE}, 300)...)
+	assert.NoError(t, writeMessage(conn, 6, false, 1000, 9, 1, keyframe))
+	assert.NoError(t, writeMessage(conn, 6, true, 40, 9, 1, []byte{0x27, 0x01, 0, 0, 0}))
+	assert.NoError(t, writeMessage(conn, 3, false, 0, 20, 0, rtmp.EncodeAMF0("FCUnpublish", 5, nil, "secret")))
+	defer conn.Close()
+
+	select {
+	case <-h.pub.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher was not closed after unpublish")
+	}
+
+	assert.Equal(t, "live", h.app)
+	assert.Equal(t, "secret", h.key)
+
+	tags := h.pub.tags
+	if !assert.Len(t, tags, 3) {
+		return
+	}
+	values, err := rtmp.DecodeAMF0(tags[0].Data)
+	assert.NoError(t, err)
+	assert.Equal(t, "onMetaData", values[0], "@setDataFrame is stripped")
+
+	assert.Equal(t, rtmp.TagVideo, tags[1].Type)
+	assert.Equal(t, uint32(1000), tags[1].Timestamp)
+	assert.Equal(t, keyframe, tags[1].Data, "chunks are reassembled")
+	assert.True(t, tags[1].IsKeyframe())
+
+	assert.Equal(t, uint32(1040), tags[2].Timestamp)
+	assert.False(t, tags[2].IsKeyframe())
+}
+
+func TestServerRejectsUnknownKey(t *testing.T) {
+	h := &fakeHandler{pub: &fakePublisher{closed: make(chan struct{})}}
+	addr := startServer(t, h)
+
+	conn, err := net.Dial("tcp", addr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, _ = conn.Write(append([]byte{3}, make([]byte, 1536)...))
+	_, _ = io.ReadFull(conn, make([]byte, 1+2*1536))
+	_, _ = conn.Write(make([]byte, 1536))
+	publish(t, conn, "wrong")
+
+	// The server answers with BadName and hangs up.
+	_, err = io.Copy(io.Discard, conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "wrong", h.key)
+}
+
+func TestServerLimitsPeersBeforePublish(t *testing.T) {
+	addr := startServer(t, &fakeHandler{pub: &fakePublisher{closed: make(chan struct{})}})
+
+	t.Run("Huge chunk size", func(t *testing.T) {
+		conn := dialRaw(t, addr)
+		defer conn.Close()
+		assert.NoError(t, writeMessage(conn, 2, false, 0, 1, 0, []byte{0x7f, 0xff, 0xff, 0xff}))
+		assert.NoError(t, writeMessage(conn, 3, false, 0, 20, 0, rtmp.EncodeAMF0("createStream", 2, nil)))
+		assertHungUp(t, conn)
+	})
+
+	t.Run("Media-sized message", func(t *testing.T) {
+		conn := dialRaw(t, addr)
+		defer conn.Close()
+		// Only the header is sent: the declared length alone is refused.
+		header := []byte{6, 0, 0, 0, 0x02, 0x00, 0x00, 9, 1, 0, 0, 0}
+		_, err := conn.Write(header)
+		assert.NoError(t, err)
+		assertHungUp(t, conn)
+	})
+
+	t.Run("Too many chunk streams", func(t *testing.T) {
+		conn := dialRaw(t, addr)
+		defer conn.Close()
+		// Each message stays one byte short, so every chunk stream holds
+		// a partial message.
+		for csid := byte(10); csid < 40; csid++ {
+			header := []byte{csid, 0, 0, 0, 0, 0, 2, 9, 1, 0, 0, 0, 0xEE}
+			if _, err := conn.Write(header); err != nil {
+				break
+			}
+		}
+		assertHungUp(t, conn)
+	})
+}
+
+func TestServerRejectsMalformedCommands(t *testing.T) {
+	addr := startServer(t, &fakeHandler{pub: &fakePublisher{closed: make(chan struct{})}})
+	command := rtmp.EncodeAMF0("connect", 1)
+
+	t.Run("Huge strict array", func(t *testing.T) {
+		conn := dialRaw(t, addr)
+		defer conn.Close()
+		// The array claims four billion items in five bytes.
+		payload := append(command, 0x0A, 0xFF, 0xFF, 0xFF, 0xFF)
+		assert.NoError(t, writeMessage(conn, 3, false, 0, 20, 0, payload))
+		assertHungUp(t, conn)
+	})
+
+	t.Run("Deep nesting", func(t *testing.T) {
+		conn := dialRaw(t, addr)
+		defer conn.Close()
+		payload := append([]byte{}, command...)
+		for i := 0; i < 1000; i++ {
+			payload = append(payload, 0x0A, 0, 0, 0, 1)
+		}
+		payload = append(payload, 0x05)
+		_, err := rtmp.DecodeAMF0(payload)
+		assert.Error(t, err)
+		assert.NoError(t, writeMessage(conn, 3, false, 0, 20, 0, payload))
+		assertHungUp(t, conn)
+	})
+
+	// The server is still up for well-formed peers.
+	conn := dial(t, addr)
+	_ = conn.Close()
+}
+
+func TestServerAllowsMediaAfterPublish(t *testing.T) {
+	h := &fakeHandler{pub: &fakePublisher{closed: make(chan struct{})}}
+	conn := dial(t, startServer(t, h))
+	defer conn.Close()
+
+	publish(t, conn, "secret")
+	frame := append([]byte{0x17, 0x01, 0, 0, 0}, bytes.Repeat([]byte{0xEE}, 200<<10)...)
+	assert.NoError(t, writeMessage(conn, 6, false, 0, 9, 1, frame))
+	assert.NoError(t, writeMessage(conn, 3, false, 0, 20, 0, rtmp.EncodeAMF0("FCUnpublish", 5, nil, "secret")))
+
+	select {
+	case <-h.pub.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher was not closed after unpublish")
+	}
+	h.pub.mu.Lock()
+	defer h.pub.mu.Unlock()
+	if assert.Len(t, h.pub.tags, 1) {
+		assert.Equal(t, frame, h.pub.tags[0].Data)
+	}
+}
+
+func TestServerLimitsConnectionsPerIP(t *testing.T) {
+	addr := startServer(t, &fakeHandler{pub: &fakePublisher{closed: make(chan struct{})}})
+
+	var conns []net.Conn
+	for i := 0; i < 8; i++ {
+		conns = append(conns, dial(t, addr))
+	}
+
+	extra, err := net.Dial("tcp", addr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assertHungUp(t, extra)
+	_ = extra.Close()
+
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+	assert.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return false
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Write(append([]byte{3}, make([]byte, 1536)...)); err != nil {
+			return false
+		}
+		_, err = io.ReadFull(conn, make([]byte, 1+2*1536))
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestTagBytes(t *testing.T) {
+	tag := rtmp.Tag{Type: rtmp.TagAudio, Timestamp: 0x01020304, Data: []byte{0xAF, 0x00, 0x12}}
+	b := tag.Bytes()
+
+	assert.Equal(t, []byte{8, 0, 0, 3, 0x02, 0x03, 0x04, 0x01, 0, 0, 0}, b[:11])
+	assert.Equal(t, tag.Data, b[11:14])
+	assert.Equal(t, uint32(14), binary.BigEndian.Uint32(b[14:]))
+	assert.True(t, tag.IsSequenceHeader())
+}
+
+func TestAMF0RoundTrip(t *testing.T) {
+	encoded := rtmp.EncodeAMF0("_result", 1, map[string]interface{}{"code": "ok", "n": 2.5, "flag": true}, nil)
+	values, err := rtmp.DecodeAMF0(encoded)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		"_result",
+		1.0,
+		map[string]interface{}{"code": "ok", "n": 2.5, "flag": true},
+		nil,
+	}, values)
+}
@@ -7,10 +7,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/codewithwan/gostreamix/internal/domain/asset"
 	"github.com/codewithwan/gostreamix/internal/domain/auth"
 	"github.com/codewithwan/gostreamix/internal/domain/dashboard"
+	"github.com/codewithwan/gostreamix/internal/domain/encoder"
 	"github.com/codewithwan/gostreamix/internal/domain/notification"
 	"github.com/codewithwan/gostreamix/internal/domain/platform"
+	"github.com/codewithwan/gostreamix/internal/domain/schedule"
 	"github.com/codewithwan/gostreamix/internal/domain/stream"
 	"github.com/codewithwan/gostreamix/internal/domain/video"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/activity"
@@ -18,6 +21,7 @@ import (
 	"github.com/codewithwan/gostreamix/internal/infrastructure/frontend"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/monitor"
 	"github.com/codewithwan/gostreamix/internal/infrastructure/ws"
+	"github.com/codewithwan/gostreamix/internal/shared/middleware"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/csrf"
@@ -45,6 +49,9 @@ func NewServer(
 	streamH *stream.Handler,
 	videoH *video.Handler,
 	platformH *platform.Handler,
+	scheduleH *schedule.Handler,
+	encoderH *encoder.Handler,
+	assetH *asset.Handler,
 	collector *monitor.Collector,
 ) *Server {
 	fiberConfig := fiber.Config{
@@ -120,6 +127,7 @@ func NewServer(
 	}))
 
 	app.Use(csrf.New(csrf.Config{
+		Next: middleware.HasBearerToken,
 		Extractor: func(c *fiber.Ctx) (string, error) {
 			token := c.Get("X-CSRF-Token")
 			if token == "" {
@@ -171,6 +179,7 @@ func NewServer(
 	app.Use("/api/auth/login", loginLimiter)
 	app.Use("/setup", setupLimiter)
 	app.Use("/api/auth/setup", setupLimiter)
+	app.Use("/api/auth/invite/accept", setupLimiter)
 
 	app.Use(logger.New(logger.Config{
 		Format:     "${time}	INFO	http request	{\"status\": ${status}, \"method\": \"${method}\", \"path\": \"${path}\", \"latency\": \"${latency}\", \"ip\": \"${ip}\"}\n",
@@ -203,6 +212,9 @@ func NewServer(
 	streamH.Routes(app)
 	videoH.Routes(app)
 	platformH.Routes(app)
+	scheduleH.Routes(app)
+	encoderH.Routes(app)
+	assetH.Routes(app)
 
 	serveSPA := func(c *fiber.Ctx) error {
 		indexHTML, readErr := frontend.ReadIndex()
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 	"time"
 
@@ -21,7 +22,7 @@ func NewAuthGuard(svc auth.Service, jwt *jwt.JWTService) auth.Guard {
 
 func (g *AuthGuard) RequireSetup(c *fiber.Ctx) error {
 	p := c.Path()
-	if isPublicPath(p) || p == "/setup" || p == "/login" || p == "/api/auth/setup" || p == "/api/auth/login" || p == "/api/auth/session" || p == "/api/auth/refresh" {
+	if isPublicPath(p) || isSignInPath(p) {
 		return c.Next()
 	}
 	s, _ := g.svc.IsSetup(c.Context())
@@ -36,19 +37,29 @@ func (g *AuthGuard) RequireSetup(c *fiber.Ctx) error {
 
 func (g *AuthGuard) RequireAuth(c *fiber.Ctx) error {
 	p := c.Path()
-	if isPublicPath(p) || p == "/login" || p == "/setup" || p == "/api/auth/login" || p == "/api/auth/setup" || p == "/api/auth/session" || p == "/api/auth/refresh" {
+	if isPublicPath(p) || isSignInPath(p) {
+		return c.Next()
+	}
+
+	// A bearer token replaces the session entirely; the cookies are not
+	// looked at, so the request cannot ride on a browser login.
+	if token, ok := bearerToken(c); ok {
+		u, t, err := g.svc.AuthenticateToken(c.Context(), token)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": auth.ErrInvalidToken.Error()})
+		}
+		c.Locals("user_id", u.ID)
+		c.Locals("user", u)
+		c.Locals("api_token", t)
 		return c.Next()
 	}
 
 	ck := c.Cookies("jwt")
-	valid := false
+	var id uuid.UUID
 	if ck != "" {
-		id := g.jwt.GetUserID(ck)
-		if id != uuid.Nil {
-			c.Locals("user_id", id)
-			valid = true
-		}
+		id = g.jwt.GetUserID(ck)
 	}
+	valid := id != uuid.Nil
 
 	if !valid {
 		// Try Refresh
@@ -83,13 +94,119 @@ func (g *AuthGuard) RequireAuth(c *fiber.Ctx) error {
 			SameSite: "Strict",
 		})
 
-		id := g.jwt.GetUserID(at)
-		c.Locals("user_id", id)
+		id = g.jwt.GetUserID(at)
 	}
 
+	// The account is loaded on every request so disabling a user or
+	// changing their role takes effect at once.
+	u, err := g.svc.GetUserByID(c.Context(), id)
+	if err != nil || u.Disabled {
+		clearSessionCookies(c)
+		return unauthorizedResponse(c)
+	}
+	c.Locals("user_id", id)
+	c.Locals("user", u)
+
+	return c.Next()
+}
+
+// RequireRole rejects requests the signed in user's role does not allow.
+// It runs after RequireAuth; public routes carry no user and pass through.
+func (g *AuthGuard) RequireRole(c *fiber.Ctx) error {
+	u := auth.CurrentUser(c)
+	if u == nil {
+		return c.Next()
+	}
+	if !u.HasRole(requiredRole(c.Method(), c.Path())) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "your role does not allow this action"})
+	}
+	if t := auth.CurrentToken(c); t != nil && !t.HasScope(requiredScope(c.Method(), c.Path())) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "the API token's scopes do not allow this action"})
+	}
 	return c.Next()
 }
 
+// streamControlActions are the stream endpoints a stream-control token may
+// call.
+var streamControlActions = []string{"/start", "/stop", "/reload", "/program/apply"}
+
+// operatorReads are the stream endpoints that only read but still need an
+// operator: rendering a reframe preview runs ffmpeg on the server, and the
+// ingest endpoint reveals the key publishers authenticate with.
+var operatorReads = []string{"/reframe/preview", "/ingest"}
+
+func isOperatorRead(path string) bool {
+	if !strings.HasPrefix(path, "/api/streams/") {
+		return false
+	}
+	for _, suffix := range operatorReads {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredScope is the API token scope a request needs.
+func requiredScope(method, path string) string {
+	if isOperatorRead(path) {
+		return auth.ScopeStreamControl
+	}
+	if method == fiber.MethodGet || method == fiber.MethodHead {
+		return auth.ScopeReadOnly
+	}
+	if method == fiber.MethodPost && strings.HasPrefix(path, "/api/streams/") {
+		for _, action := range streamControlActions {
+			if strings.HasSuffix(path, action) {
+				return auth.ScopeStreamControl
+			}
+		}
+	}
+	return auth.ScopeAdmin
+}
+
+// HasBearerToken reports whether the request authenticates with an API
+// token. Such requests need no CSRF token: browsers never attach one on
+// their own.
+func HasBearerToken(c *fiber.Ctx) bool {
+	_, ok := bearerToken(c)
+	return ok
+}
+
+func bearerToken(c *fiber.Ctx) (string, bool) {
+	scheme, token, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// requiredRole is the least privileged role allowed to make a request.
+// Managing users and notification settings is left to admins; otherwise
+// viewers may read anything short of the operatorReads and operators may
+// change anything.
+func requiredRole(method, path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/users"), strings.HasPrefix(path, "/api/settings"):
+		return auth.RoleAdmin
+	case strings.HasPrefix(path, "/api/auth/"):
+		return auth.RoleViewer
+	case isOperatorRead(path):
+		return auth.RoleOperator
+	case method == fiber.MethodGet || method == fiber.MethodHead:
+		return auth.RoleViewer
+	default:
+		return auth.RoleOperator
+	}
+}
+
+func clearSessionCookies(c *fiber.Ctx) {
+	for _, name := range []string{"jwt", "refresh_token"} {
+		c.Cookie(&fiber.Cookie{Name: name, Value: "", Path: "/", Expires: time.Unix(0, 0), HTTPOnly: true})
+	}
+}
+
 func unauthorizedResponse(c *fiber.Ctx) error {
 	if strings.HasPrefix(c.Path(), "/api/") {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
@@ -97,6 +214,16 @@ func unauthorizedResponse(c *fiber.Ctx) error {
 	return c.Redirect("/login")
 }
 
+// isSignInPath reports whether the path is part of signing in, which must
+// work without a session.
+func isSignInPath(path string) bool {
+	switch path {
+	case "/login", "/setup", "/api/auth/login", "/api/auth/login/2fa", "/api/auth/setup", "/api/auth/session", "/api/auth/refresh", "/api/auth/invite/accept":
+		return true
+	}
+	return false
+}
+
 func isPublicPath(path string) bool {
 	return strings.HasPrefix(path, "/assets") ||
 		strings.HasPrefix(path, "/uploads") ||
@@ -127,6 +254,9 @@ func (g *AuthGuard) GuestOnly(c *fiber.Ctx) error {
 }
 
 func GetUser(c *fiber.Ctx, svc auth.Service) *auth.User {
+	if u := auth.CurrentUser(c); u != nil {
+		return u
+	}
 	id, ok := c.Locals("user_id").(uuid.UUID)
 	if !ok {
 		return nil
@@ -134,3 +264,20 @@ func GetUser(c *fiber.Ctx, svc auth.Service) *auth.User {
 	u, _ := svc.GetUserByID(c.Context(), id)
 	return u
 }
+
+// OwnerFilter reads the owner query parameter lists can be filtered by: an
+// empty value lists everyone's, "me" the signed in user's, anything else is
+// a user ID.
+func OwnerFilter(c *fiber.Ctx) (uuid.UUID, error) {
+	switch owner := c.Query("owner"); owner {
+	case "":
+		return uuid.Nil, nil
+	case "me":
+		if u := auth.CurrentUser(c); u != nil {
+			return u.ID, nil
+		}
+		return uuid.Nil, errors.New("owner=me needs a signed in user")
+	default:
+		return uuid.Parse(owner)
+	}
+}
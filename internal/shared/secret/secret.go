@@ -0,0 +1,189 @@
+// Package secret encrypts the credentials kept in the database, such as
+// stream keys and notification tokens.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks sealed values; anything without it is plaintext written
+// before encryption was introduced.
+const prefix = "enc:v1:"
+
+var ErrDecrypt = errors.New("cannot decrypt secret: wrong encryption key or corrupted value")
+
+// Cipher seals values with envelope encryption: each value is encrypted with
+// its own random data key, and that key is wrapped with the master key
+// derived from the configured encryption key. Both layers use AES-256-GCM.
+type Cipher struct {
+	master cipher.AEAD
+}
+
+func New(key string) (*Cipher, error) {
+	if key == "" {
+		return nil, errors.New("encryption key is empty")
+	}
+	derived, err := hkdf.Key(sha256.New, []byte(key), nil, "gostreamix secrets v1", 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive encryption key: %w", err)
+	}
+	master, err := newAEAD(derived)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{master: master}, nil
+}
+
+// Seal encrypts value. The empty string stays empty so unset credentials
+// still read as unset.
+func (c *Cipher) Seal(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("generate data key: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(c.master, dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(data, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return prefix + encode(wrapped) + "." + encode(sealed), nil
+}
+
+// Open decrypts a sealed value. Values that were never sealed are returned
+// as they are.
+func (c *Cipher) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+
+	wrapped, sealed, found := strings.Cut(strings.TrimPrefix(value, prefix), ".")
+	if !found {
+		return "", ErrDecrypt
+	}
+	dataKey, err := open(c.master, wrapped)
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(data, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// SealFields encrypts the fields in place before a row is written and
+// returns a func that puts their plaintext back.
+func (c *Cipher) SealFields(fields ...*string) (func(), error) {
+	plain := make([]string, len(fields))
+	for i, f := range fields {
+		plain[i] = *f
+	}
+	restore := func() {
+		for i, f := range fields {
+			*f = plain[i]
+		}
+	}
+	for i, f := range fields {
+		sealed, err := c.Seal(plain[i])
+		if err != nil {
+			restore()
+			return nil, err
+		}
+		*f = sealed
+	}
+	return restore, nil
+}
+
+// OpenFields decrypts the fields of a row that was read, in place.
+func (c *Cipher) OpenFields(fields ...*string) error {
+	for _, f := range fields {
+		plain, err := c.Open(*f)
+		if err != nil {
+			return err
+		}
+		*f = plain
+	}
+	return nil
+}
+
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Mask hides all but the last four characters of a credential so it can be
+// shown in the UI.
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 8 {
+		return "********"
+	}
+	return "********" + value[len(value)-4:]
+}
+
+// Unmask returns current when submitted is its mask, as a form that was
+// only shown the masked value sends it back unchanged.
+func Unmask(submitted, current string) string {
+	if current != "" && submitted == Mask(current) {
+		return current
+	}
+	return submitted
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plain under a fresh nonce, which is prepended to the result.
+func seal(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(aead cipher.AEAD, encoded string) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}